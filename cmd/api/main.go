@@ -1,71 +1,737 @@
 package main
 
 import (
+	"cmp"
+	"context"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"os"
+	"os/signal"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
-	"github.com/gustavosett/WhereGo/internal/geoip"
+	"github.com/gustavosett/WhereGo/internal/auth"
+	"github.com/gustavosett/WhereGo/internal/compress"
+	"github.com/gustavosett/WhereGo/internal/format"
+	"github.com/gustavosett/WhereGo/internal/geodns"
 	"github.com/gustavosett/WhereGo/internal/handlers"
-	jsoniter "github.com/json-iterator/go"
+	"github.com/gustavosett/WhereGo/internal/resp"
+	"github.com/gustavosett/WhereGo/internal/rules"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/miekg/dns"
+	"golang.org/x/time/rate"
 )
 
-var json = jsoniter.ConfigCompatibleWithStandardLibrary
+// legacyDeprecatedAt is when the unversioned lookup route was deprecated in
+// favor of /v1.
+var legacyDeprecatedAt = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
 
-func NewServer(dbPath string) (*echo.Echo, *geoip.Service, error) {
-	geoService, err := geoip.NewService(dbPath)
+// shutdownTimeout is how long in-flight requests may take to finish when
+// the server is stopped.
+const shutdownTimeout = 10 * time.Second
+
+// ServerOption configures the server built by NewServer.
+type ServerOption func(*serverOptions)
+
+type serverOptions struct {
+	geoip         []wherego.Option
+	cacheSize     int
+	overridesPath string
+	embeddedIPv4  bool
+	apiDocs       bool
+	apiKeys       *auth.Keys
+	cors          *middleware.CORSConfig
+	proxies       []netip.Prefix
+	maxAge        time.Duration
+	compression   *compress.Config
+	socketRate    rate.Limit
+	socketBurst   int
+	socketPing    time.Duration
+	asnPath       string
+	compatAPIs    []string
+	anonymousPath string
+	policy        *rules.Policy
+	enforcePolicy bool
+	forwardAuth   bool
+}
+
+// WithGeoIPOptions passes options through to wherego.Open.
+func WithGeoIPOptions(options ...wherego.Option) ServerOption {
+	return func(o *serverOptions) {
+		o.geoip = append(o.geoip, options...)
+	}
+}
+
+// WithCache enables the lookup cache with room for about size networks.
+func WithCache(size int) ServerOption {
+	return func(o *serverOptions) {
+		o.cacheSize = size
+	}
+}
+
+// WithOverrides loads lookup overrides from the YAML, JSON or MaxMind DB
+// file at path. See wherego.Overrides for the file format.
+func WithOverrides(path string) ServerOption {
+	return func(o *serverOptions) {
+		o.overridesPath = path
+	}
+}
+
+// WithEmbeddedIPv4Lookup answers lookups for 6to4 and Teredo addresses with
+// the record of the IPv4 address embedded in them.
+func WithEmbeddedIPv4Lookup() ServerOption {
+	return func(o *serverOptions) {
+		o.embeddedIPv4 = true
+	}
+}
+
+// WithAPIDocs serves an API reference page for the OpenAPI document at
+// /docs. The page is rendered on the server and loads no scripts.
+func WithAPIDocs() ServerOption {
+	return func(o *serverOptions) {
+		o.apiDocs = true
+	}
+}
+
+// WithAPIKeys requires an API key from keys on the lookup routes and serves
+// the per-key usage counters to admin keys at /admin/usage. The health,
+// metrics and documentation routes stay open.
+func WithAPIKeys(keys *auth.Keys) ServerOption {
+	return func(o *serverOptions) {
+		o.apiKeys = keys
+	}
+}
+
+// WithCORS lets browsers call the API from the origins allowed by config
+// and answers CORS preflight requests. Origins may contain "*" wildcards,
+// such as "https://*.example.com". When config.ExposeHeaders is nil, the
+// response headers clients need to follow deprecations and quotas are
+// exposed.
+func WithCORS(config middleware.CORSConfig) ServerOption {
+	return func(o *serverOptions) {
+		o.cors = &config
+	}
+}
+
+// WithTrustedProxies takes the client address from the X-Forwarded-For
+// header when the request comes through proxies in the given networks.
+// Without it, the client address is the remote address of the connection.
+func WithTrustedProxies(proxies ...netip.Prefix) ServerOption {
+	return func(o *serverOptions) {
+		o.proxies = append(o.proxies, proxies...)
+	}
+}
+
+// WithUpdateInterval tells the server how often its database is updated.
+// Lookup responses may be cached for that long; without it, clients
+// revalidate every response with its ETag.
+func WithUpdateInterval(interval time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.maxAge = interval
+	}
+}
+
+// WithCompression compresses responses for clients that accept it. See
+// compress.Config.
+func WithCompression(config compress.Config) ServerOption {
+	return func(o *serverOptions) {
+		o.compression = &config
+	}
+}
+
+// WithSocketRateLimit limits each WebSocket connection to perSecond
+// lookups per second, with bursts of up to burst lookups. Without it,
+// connections are not limited.
+func WithSocketRateLimit(perSecond float64, burst int) ServerOption {
+	return func(o *serverOptions) {
+		o.socketRate, o.socketBurst = rate.Limit(perSecond), burst
+	}
+}
+
+// WithSocketPingInterval sets how often WebSocket clients are pinged to
+// keep their connections alive. It defaults to
+// handlers.DefaultPingInterval.
+func WithSocketPingInterval(interval time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.socketPing = interval
+	}
+}
+
+// WithASNDatabase opens the GeoLite2-ASN or GeoIP2-ISP database at path
+// next to the main database, for the autonomous system fields of the
+// compatibility APIs and the DNS asn lookups.
+func WithASNDatabase(path string) ServerOption {
+	return func(o *serverOptions) {
+		o.asnPath = path
+	}
+}
+
+// WithCompatAPIs serves the compatibility APIs with the given names,
+// handlers.CompatIPAPI and handlers.CompatIPInfo, under route prefixes of
+// the same names.
+func WithCompatAPIs(names ...string) ServerOption {
+	return func(o *serverOptions) {
+		o.compatAPIs = append(o.compatAPIs, names...)
+	}
+}
+
+// WithAnonymousIPDatabase opens the GeoIP2 Anonymous IP database at path
+// next to the main database, for the anonymous.* attributes of policies.
+func WithAnonymousIPDatabase(path string) ServerOption {
+	return func(o *serverOptions) {
+		o.anonymousPath = path
+	}
+}
+
+// WithPolicy serves POST /v1/evaluate, which decides whether policy allows
+// an address.
+func WithPolicy(policy *rules.Policy) ServerOption {
+	return func(o *serverOptions) {
+		o.policy = policy
+	}
+}
+
+// WithPolicyEnforcement rejects lookups from client addresses the policy
+// of WithPolicy denies.
+func WithPolicyEnforcement() ServerOption {
+	return func(o *serverOptions) {
+		o.enforcePolicy = true
+	}
+}
+
+// WithForwardAuth serves /auth to reverse proxies, which decides with the
+// policy of WithPolicy whether they forward a request. The client address
+// is taken from X-Forwarded-For, so it needs WithTrustedProxies with the
+// networks of the proxies. Envoy's HTTP ext_authz appends the path of the
+// request to /auth, which is served too.
+func WithForwardAuth() ServerOption {
+	return func(o *serverOptions) {
+		o.forwardAuth = true
+	}
+}
+
+// corsExposeHeaders are the response headers exposed to browsers by
+// default.
+var corsExposeHeaders = []string{"Deprecation", "Link", echo.HeaderRetryAfter}
+
+func applyServerOptions(options []ServerOption) serverOptions {
+	var opts serverOptions
+	for _, option := range options {
+		if option != nil {
+			option(&opts)
+		}
+	}
+	return opts
+}
+
+func NewServer(dbPath string, options ...ServerOption) (*echo.Echo, *wherego.Service, error) {
+	s, err := newServer(dbPath, options...)
 	if err != nil {
 		return nil, nil, err
 	}
+	return s.echo, s.service, nil
+}
+
+// server is the API server built by newServer.
+type server struct {
+	echo    *echo.Echo
+	service *wherego.Service
+	sockets *handlers.SocketHandler
+}
+
+// shutdown stops the server gracefully. http.Server.Shutdown does not
+// track WebSocket connections, so they are closed alongside and waited
+// for too.
+func (s *server) shutdown(ctx context.Context) error {
+	sockets := make(chan error, 1)
+	go func() { sockets <- s.sockets.Shutdown(ctx) }()
+	return errors.Join(s.echo.Shutdown(ctx), <-sockets)
+}
+
+func newServer(dbPath string, options ...ServerOption) (*server, error) {
+	opts := applyServerOptions(options)
+	geoService, err := wherego.NewService(dbPath, opts.geoip...)
+	if err != nil {
+		return nil, err
+	}
+	geoService.Cache = wherego.NewCache(opts.cacheSize)
+	geoService.LookupEmbeddedIPv4 = opts.embeddedIPv4
+	if opts.overridesPath != "" {
+		overrides, err := wherego.LoadOverrides(opts.overridesPath)
+		if err != nil {
+			closeService(geoService)
+			return nil, err
+		}
+		geoService.Overrides = overrides
+	}
+	if opts.asnPath != "" {
+		asnDB, err := wherego.Open(opts.asnPath)
+		if err != nil {
+			closeService(geoService)
+			return nil, err
+		}
+		geoService.ASNDB = asnDB
+	}
+	if opts.anonymousPath != "" {
+		anonymousDB, err := wherego.Open(opts.anonymousPath)
+		if err != nil {
+			closeService(geoService)
+			return nil, err
+		}
+		geoService.AnonymousIPDB = anonymousDB
+	}
+	if opts.enforcePolicy && opts.policy == nil {
+		closeService(geoService)
+		return nil, errors.New("policy enforcement needs a policy")
+	}
+	if opts.forwardAuth && opts.policy == nil {
+		closeService(geoService)
+		return nil, errors.New("forward auth needs a policy")
+	}
+	if opts.forwardAuth && len(opts.proxies) == 0 {
+		// Without trusted proxies every request would be decided for the
+		// address of the proxy.
+		closeService(geoService)
+		return nil, errors.New("forward auth needs trusted proxies")
+	}
+	if opts.policy != nil {
+		// Reject rules that need data of a database not loaded, now and on
+		// reload, rather than failing every decision.
+		if err := opts.policy.Bind(geoService); err != nil {
+			closeService(geoService)
+			return nil, err
+		}
+	}
+	for _, name := range opts.compatAPIs {
+		if name != handlers.CompatIPAPI && name != handlers.CompatIPInfo {
+			closeService(geoService)
+			return nil, fmt.Errorf("unknown compatibility API %q", name)
+		}
+	}
 
+	// geohttp.NewHandler serves the same v1 routes, but the server's
+	// handler also takes the policy, private caching and the routes beyond
+	// /v1, which the library does not expose.
 	handler := &handlers.GeoIPHandler{
-		GeoService: geoService,
+		GeoService:  geoService,
+		CacheMaxAge: opts.maxAge,
+		// Shared caches must not answer for the key or policy checks.
+		Private: opts.apiKeys != nil || opts.enforcePolicy,
+		Formats: format.Default(),
+		Policy:  opts.policy,
+	}
+
+	sockets := &handlers.SocketHandler{
+		Lookups:      handler,
+		Rate:         opts.socketRate,
+		Burst:        opts.socketBurst,
+		PingInterval: opts.socketPing,
 	}
 
 	e := echo.New()
-	e.JSONSerializer = &JSONSerializer{}
+	e.JSONSerializer = &handlers.JSONSerializer{}
+	e.HTTPErrorHandler = handlers.HTTPErrorHandler
+	e.IPExtractor = handlers.IPExtractor(opts.proxies)
+	if opts.cors != nil {
+		config := *opts.cors
+		if config.ExposeHeaders == nil {
+			config.ExposeHeaders = corsExposeHeaders
+		}
+		e.Use(middleware.CORSWithConfig(config))
+		sockets.CheckOrigin = checkOrigin(config.AllowOrigins)
+	}
+	if opts.compression != nil {
+		e.Use(compress.Middleware(*opts.compression))
+	}
 
 	e.GET("/health", handlers.HealthCheck)
-	e.GET("/lookup/:ip", handler.Lookup)
+	e.GET("/metrics", handler.Metrics)
+	e.GET("/openapi.json", handlers.OpenAPI)
+	if opts.apiDocs {
+		e.GET("/docs", handlers.Docs)
+	}
+	if opts.forwardAuth {
+		// Proxies ask without API keys, and the policy is the gate.
+		e.Match(handlers.ForwardAuthMethods, "/auth", handler.ForwardAuth)
+		e.Match(handlers.ForwardAuthMethods, "/auth/*", handler.ForwardAuth)
+	}
+	var lookupMiddleware []echo.MiddlewareFunc
+	if opts.apiKeys != nil {
+		lookupMiddleware = append(lookupMiddleware, handlers.APIKey(opts.apiKeys))
+		e.GET("/admin/usage", handlers.KeyUsage(opts.apiKeys), handlers.APIKey(opts.apiKeys), handlers.AdminOnly)
+	}
+	// The policy applies after authentication, so unauthenticated clients
+	// learn nothing about it.
+	var fence []echo.MiddlewareFunc
+	if opts.enforcePolicy {
+		fence = append(fence, handlers.GeoFence(opts.policy, geoService))
+	}
+	lookupMiddleware = append(lookupMiddleware, fence...)
+
+	e.GET("/lookup/:ip", handler.Lookup, append(lookupMiddleware, handlers.Deprecated(legacyDeprecatedAt, "/v1"))...)
+
+	v1 := e.Group("/v1", lookupMiddleware...)
+	handler.RegisterV1(v1)
+	v1.GET("/ws", sockets.Serve)
+	if opts.policy != nil {
+		v1.POST("/evaluate", handler.Evaluate)
+	}
 
-	return e, geoService, nil
+	// The MaxMind-compatible routes authenticate the way MaxMind's clients
+	// do, with the API key as the license key.
+	var maxMindMiddleware []echo.MiddlewareFunc
+	if opts.apiKeys != nil {
+		maxMindMiddleware = append(maxMindMiddleware, handlers.MaxMindAuth(opts.apiKeys))
+	}
+	maxMind := e.Group(handlers.MaxMindPrefix, append(maxMindMiddleware, fence...)...)
+	for _, service := range []string{handlers.MaxMindCountry, handlers.MaxMindCity, handlers.MaxMindInsights} {
+		maxMind.GET("/"+service+"/:ip", handler.MaxMind(service))
+	}
+
+	// The compatibility APIs take API keys the way their clients pass them,
+	// too.
+	for _, name := range opts.compatAPIs {
+		var compatMiddleware []echo.MiddlewareFunc
+		switch name {
+		case handlers.CompatIPAPI:
+			if opts.apiKeys != nil {
+				compatMiddleware = append(compatMiddleware, handlers.APIKeyFrom(opts.apiKeys, handlers.IPAPIKey))
+			}
+			g := e.Group("/"+handlers.CompatIPAPI, append(compatMiddleware, fence...)...)
+			g.GET("/json", handler.IPAPI)
+			g.GET("/json/:query", handler.IPAPI)
+		case handlers.CompatIPInfo:
+			if opts.apiKeys != nil {
+				compatMiddleware = append(compatMiddleware, handlers.APIKeyFrom(opts.apiKeys, handlers.IPInfoToken))
+			}
+			g := e.Group("/"+handlers.CompatIPInfo, append(compatMiddleware, fence...)...)
+			g.GET("", handler.IPInfo)
+			g.GET("/:ip", handler.IPInfo)
+			g.GET("/:ip/:field", handler.IPInfo)
+		}
+	}
+
+	return &server{echo: e, service: geoService, sockets: sockets}, nil
+}
+
+// closeService closes the databases and overrides of a service, when
+// NewServer fails to return it or the server shuts down.
+func closeService(s *wherego.Service) error {
+	errs := []error{s.DB.Close()}
+	if s.ASNDB != nil {
+		errs = append(errs, s.ASNDB.Close())
+	}
+	if s.AnonymousIPDB != nil {
+		errs = append(errs, s.AnonymousIPDB.Close())
+	}
+	if s.Overrides != nil {
+		errs = append(errs, s.Overrides.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// checkOrigin lets browsers open WebSockets from the API's own origin and
+// from the CORS origins, which may contain "*" wildcards.
+func checkOrigin(allowed []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get(echo.HeaderOrigin)
+		if origin == "" {
+			return true
+		}
+		if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+			return true
+		}
+		for _, pattern := range allowed {
+			if pattern == "*" {
+				return true
+			}
+			if ok, _ := path.Match(pattern, origin); ok {
+				return true
+			}
+		}
+		return false
+	}
 }
 
 func main() {
-	e, geoService, err := NewServer("data/city.db")
+	var options []ServerOption
+	if os.Getenv("ALLOW_UNKNOWN_DB") == "true" {
+		options = append(options, WithGeoIPOptions(wherego.AllowUnknownDatabaseType()))
+	}
+	if size := os.Getenv("CACHE_SIZE"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil {
+			log.Fatalf("Invalid CACHE_SIZE %q: %v", size, err)
+		}
+		options = append(options, WithCache(n))
+	}
+	if os.Getenv("LOOKUP_EMBEDDED_IPV4") == "true" {
+		options = append(options, WithEmbeddedIPv4Lookup())
+	}
+	if os.Getenv("COMPRESSION") != "false" {
+		config := compress.Config{Encodings: splitList(os.Getenv("COMPRESSION_ENCODINGS"))}
+		if v := os.Getenv("COMPRESSION_MIN_LENGTH"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				log.Fatalf("Invalid COMPRESSION_MIN_LENGTH %q", v)
+			}
+			config.MinLength = n
+		}
+		for _, encoding := range config.Encodings {
+			if !slices.Contains(compress.DefaultEncodings, encoding) {
+				log.Fatalf("Invalid COMPRESSION_ENCODINGS entry %q", encoding)
+			}
+		}
+		options = append(options, WithCompression(config))
+	}
+	if v := cmp.Or(os.Getenv("WS_RATE_LIMIT"), "50"); v != "0" {
+		perSecond, err := strconv.ParseFloat(v, 64)
+		if err != nil || perSecond < 0 {
+			log.Fatalf("Invalid WS_RATE_LIMIT %q", v)
+		}
+		burst := 100
+		if v := os.Getenv("WS_RATE_BURST"); v != "" {
+			if burst, err = strconv.Atoi(v); err != nil || burst < 1 {
+				log.Fatalf("Invalid WS_RATE_BURST %q", v)
+			}
+		}
+		options = append(options, WithSocketRateLimit(perSecond, burst))
+	}
+	if v := os.Getenv("WS_PING_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil || interval <= 0 {
+			log.Fatalf("Invalid WS_PING_INTERVAL %q", v)
+		}
+		options = append(options, WithSocketPingInterval(interval))
+	}
+	var updateInterval time.Duration
+	if v := os.Getenv("DB_UPDATE_INTERVAL"); v != "" {
+		var err error
+		if updateInterval, err = time.ParseDuration(v); err != nil || updateInterval < 0 {
+			log.Fatalf("Invalid DB_UPDATE_INTERVAL %q", v)
+		}
+		options = append(options, WithUpdateInterval(updateInterval))
+	}
+	if os.Getenv("API_DOCS") == "true" {
+		options = append(options, WithAPIDocs())
+	}
+	if path := os.Getenv("OVERRIDES_FILE"); path != "" {
+		options = append(options, WithOverrides(path))
+	}
+	if path := os.Getenv("ASN_DB_PATH"); path != "" {
+		options = append(options, WithASNDatabase(path))
+	}
+	if path := os.Getenv("ANONYMOUS_IP_DB_PATH"); path != "" {
+		options = append(options, WithAnonymousIPDatabase(path))
+	}
+	if v := os.Getenv("COMPAT_APIS"); v != "" {
+		options = append(options, WithCompatAPIs(splitList(v)...))
+	}
+	if origins := os.Getenv("CORS_ALLOWED_ORIGINS"); origins != "" {
+		config := middleware.CORSConfig{
+			AllowOrigins: splitList(origins),
+			AllowMethods: splitList(os.Getenv("CORS_ALLOWED_METHODS")),
+			AllowHeaders: splitList(os.Getenv("CORS_ALLOWED_HEADERS")),
+			MaxAge:       600,
+		}
+		if v := os.Getenv("CORS_MAX_AGE"); v != "" {
+			maxAge, err := time.ParseDuration(v)
+			if err != nil || maxAge < 0 {
+				log.Fatalf("Invalid CORS_MAX_AGE %q", v)
+			}
+			config.MaxAge = int(maxAge.Seconds())
+		}
+		options = append(options, WithCORS(config))
+	}
+	if v := os.Getenv("TRUSTED_PROXIES"); v != "" {
+		var proxies []netip.Prefix
+		for _, cidr := range splitList(v) {
+			proxy, err := netip.ParsePrefix(cidr)
+			if err != nil {
+				log.Fatalf("Invalid TRUSTED_PROXIES entry %q: %v", cidr, err)
+			}
+			proxies = append(proxies, proxy.Masked())
+		}
+		options = append(options, WithTrustedProxies(proxies...))
+	}
+	var apiKeys *auth.Keys
+	if path := os.Getenv("API_KEYS_FILE"); path != "" {
+		var err error
+		if apiKeys, err = auth.LoadKeys(path); err != nil {
+			log.Fatalf("Failed to load API keys: %v", err)
+		}
+		options = append(options, WithAPIKeys(apiKeys))
+	}
+	var policy *rules.Policy
+	if path := os.Getenv("POLICY_FILE"); path != "" {
+		var err error
+		if policy, err = rules.Load(path); err != nil {
+			log.Fatalf("Failed to load policy: %v", err)
+		}
+		options = append(options, WithPolicy(policy))
+	}
+	if os.Getenv("POLICY_ENFORCE") == "true" {
+		options = append(options, WithPolicyEnforcement())
+	}
+	if os.Getenv("FORWARD_AUTH") == "true" {
+		options = append(options, WithForwardAuth())
+	}
+
+	srv, err := newServer("data/city.db", options...)
 	if err != nil {
 		log.Fatalf("Failed to initialize GeoIP service: %v", err)
 	}
+	e, geoService := srv.echo, srv.service
 	defer func() {
-		if err := geoService.DB.Close(); err != nil {
-			log.Printf("Failed to close GeoIP database: %v", err)
+		if err := closeService(geoService); err != nil {
+			log.Printf("Failed to close GeoIP databases: %v", err)
 		}
 	}()
 
+	if overrides := geoService.Overrides; overrides != nil {
+		interval := 30 * time.Second
+		if v := os.Getenv("OVERRIDES_RELOAD_INTERVAL"); v != "" {
+			if interval, err = time.ParseDuration(v); err != nil || interval <= 0 {
+				log.Fatalf("Invalid OVERRIDES_RELOAD_INTERVAL %q", v)
+			}
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go overrides.Watch(ctx, interval, func(err error) {
+			log.Printf("Failed to reload overrides: %v", err)
+		})
+		log.Printf("Loaded overrides from %s", overrides.Path())
+	}
+
+	if apiKeys != nil {
+		interval := 30 * time.Second
+		if v := os.Getenv("API_KEYS_RELOAD_INTERVAL"); v != "" {
+			if interval, err = time.ParseDuration(v); err != nil || interval <= 0 {
+				log.Fatalf("Invalid API_KEYS_RELOAD_INTERVAL %q", v)
+			}
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go apiKeys.Watch(ctx, interval, func(err error) {
+			log.Printf("Failed to reload API keys: %v", err)
+		})
+		log.Printf("Loaded %d API keys from %s", apiKeys.Len(), apiKeys.Path())
+	}
+
+	if policy != nil {
+		interval := 30 * time.Second
+		if v := os.Getenv("POLICY_RELOAD_INTERVAL"); v != "" {
+			if interval, err = time.ParseDuration(v); err != nil || interval <= 0 {
+				log.Fatalf("Invalid POLICY_RELOAD_INTERVAL %q", v)
+			}
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go policy.Watch(ctx, interval, func(err error) {
+			log.Printf("Failed to reload policy: %v", err)
+		})
+		log.Printf("Loaded %d policy rules from %s", policy.Len(), policy.Path())
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if addr := os.Getenv("DNS_ADDR"); addr != "" {
+		zone := cmp.Or(os.Getenv("DNS_ZONE"), "geo.local.")
+		if _, ok := dns.IsDomainName(zone); !ok {
+			log.Fatalf("Invalid DNS_ZONE %q", zone)
+		}
+		server := &geodns.Server{Service: geoService, Zone: zone, TTL: updateInterval}
+		go func() {
+			if err := server.ListenAndServe(ctx, addr); err != nil {
+				log.Fatalf("DNS server failed: %v", err)
+			}
+		}()
+		log.Printf("Serving DNS lookups under %s on %s", dns.Fqdn(zone), addr)
+	}
+
+	var respDone chan struct{}
+	if addr := os.Getenv("RESP_ADDR"); addr != "" {
+		server := &resp.Server{Service: geoService, Keys: apiKeys}
+		if v := os.Getenv("RESP_IDLE_TIMEOUT"); v != "" {
+			var err error
+			if server.IdleTimeout, err = time.ParseDuration(v); err != nil || server.IdleTimeout <= 0 {
+				log.Fatalf("Invalid RESP_IDLE_TIMEOUT %q", v)
+			}
+		}
+		if v := os.Getenv("RESP_WRITE_TIMEOUT"); v != "" {
+			var err error
+			if server.WriteTimeout, err = time.ParseDuration(v); err != nil || server.WriteTimeout <= 0 {
+				log.Fatalf("Invalid RESP_WRITE_TIMEOUT %q", v)
+			}
+		}
+		if v := os.Getenv("RESP_MAX_CONNS"); v != "" {
+			var err error
+			if server.MaxConns, err = strconv.Atoi(v); err != nil || server.MaxConns < 1 {
+				log.Fatalf("Invalid RESP_MAX_CONNS %q", v)
+			}
+		}
+		respDone = make(chan struct{})
+		go func() {
+			defer close(respDone)
+			if err := server.ListenAndServe(ctx, addr); err != nil {
+				log.Fatalf("RESP server failed: %v", err)
+			}
+		}()
+		log.Printf("Serving RESP lookups on %s", addr)
+	}
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
 	log.Printf("Starting server on :%s", port)
-	if err := e.Start(":" + port); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		log.Fatalf("Server failed: %v", err)
+	go func() {
+		if err := e.Start(":" + port); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Server failed: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Printf("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.shutdown(shutdownCtx); err != nil {
+		log.Printf("Shutdown failed: %v", err)
+	}
+	if respDone != nil {
+		// The RESP server stops with ctx, once its connections have
+		// answered the commands they sent.
+		select {
+		case <-respDone:
+		case <-shutdownCtx.Done():
+			log.Printf("RESP shutdown failed: %v", shutdownCtx.Err())
+		}
 	}
 }
 
-// JSONSerializer implements echo.JSONSerializer using json-iterator
-type JSONSerializer struct{}
-
-func (s *JSONSerializer) Serialize(c echo.Context, i interface{}, indent string) error {
-	enc := json.NewEncoder(c.Response())
-	if indent != "" {
-		enc.SetIndent("", indent)
+// splitList splits a comma-separated environment variable, dropping empty
+// entries.
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
 	}
-	return enc.Encode(i)
+	return list
 }
 
-func (s *JSONSerializer) Deserialize(c echo.Context, i interface{}) error {
-	return json.NewDecoder(c.Request().Body).Decode(i)
-}
+// JSONSerializer is the JSON serializer of the server.
+type JSONSerializer = handlers.JSONSerializer
@@ -2,12 +2,30 @@ package main
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/gorilla/websocket"
+	"github.com/gustavosett/WhereGo/internal/auth"
+	"github.com/gustavosett/WhereGo/internal/compress"
+	"github.com/gustavosett/WhereGo/internal/geoiptest"
+	"github.com/gustavosett/WhereGo/internal/handlers"
+	"github.com/gustavosett/WhereGo/internal/rules"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -119,6 +137,584 @@ func TestNewServer(t *testing.T) {
 		assert.Equal(t, 2, foundRoutes, "Expected /health and /lookup/:ip routes to be registered")
 	})
 
+	t.Run("Unknown Database Type", func(t *testing.T) {
+		dbFile := filepath.Join(t.TempDir(), "offices.mmdb")
+		dbBytes := geoiptest.MustBuild(geoiptest.Database{
+			DatabaseType: "Acme-Offices",
+			Networks: map[netip.Prefix]any{
+				netip.MustParsePrefix("10.20.0.0/16"): geoiptest.Map{"site": "Lisbon"},
+			},
+		})
+		require.NoError(t, os.WriteFile(dbFile, dbBytes, 0o600))
+
+		_, _, err := NewServer(dbFile)
+		assert.Error(t, err)
+
+		e, svc, err := NewServer(dbFile, WithGeoIPOptions(wherego.AllowUnknownDatabaseType()))
+		require.NoError(t, err)
+		defer func() {
+			closeErr := svc.DB.Close()
+			require.NoError(t, closeErr)
+		}()
+
+		req := httptest.NewRequest(http.MethodGet, "/lookup/10.20.0.1", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"site":"Lisbon"}`, rec.Body.String())
+	})
+
+	t.Run("With Cache", func(t *testing.T) {
+		e, svc, err := NewServer(writeSampleDB(t), WithCache(1000))
+		require.NoError(t, err)
+		defer func() {
+			closeErr := svc.DB.Close()
+			require.NoError(t, closeErr)
+		}()
+		require.NotNil(t, svc.Cache)
+
+		for i := 0; i < 2; i++ {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/lookup/81.2.69.160", nil))
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"ip_address":"81.2.69.160"`)
+		}
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "wherego_cache_hits_total 1\n")
+	})
+
+	t.Run("Versioned Routes", func(t *testing.T) {
+		e, svc, err := NewServer(writeSampleDB(t))
+		require.NoError(t, err)
+		defer func() {
+			closeErr := svc.DB.Close()
+			require.NoError(t, closeErr)
+		}()
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/lookup/8.8.8.8", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("Deprecation"))
+		assert.Contains(t, rec.Body.String(), `"country":{"iso_code":"US"`)
+
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/lookup/8.8.8.8", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotEmpty(t, rec.Header().Get("Deprecation"))
+		assert.Equal(t, `</v1/lookup/8.8.8.8>; rel="successor-version"`, rec.Header().Get("Link"))
+	})
+
+	t.Run("Response Formats", func(t *testing.T) {
+		e, svc, err := NewServer(writeSampleDB(t))
+		require.NoError(t, err)
+		defer func() {
+			closeErr := svc.DB.Close()
+			require.NoError(t, closeErr)
+		}()
+
+		for target, want := range map[string]string{
+			"/lookup/8.8.8.8?format=text&field=country.iso_code":    "US\n",
+			"/v1/lookup/8.8.8.8?format=text&field=country.iso_code": "US\n",
+			"/v1/lookup/8.8.8.8?format=xml":                         "<iso_code>US</iso_code>",
+		} {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+			assert.Equal(t, http.StatusOK, rec.Code, target)
+			assert.Contains(t, rec.Body.String(), want, target)
+		}
+	})
+
+	t.Run("Problem Responses", func(t *testing.T) {
+		e, svc, err := NewServer(writeSampleDB(t))
+		require.NoError(t, err)
+		defer func() {
+			closeErr := svc.DB.Close()
+			require.NoError(t, closeErr)
+		}()
+
+		for target, want := range map[string]string{
+			"/lookup/127.0.0.1": `"code":"ip_not_found"`,
+			"/lookup/bogus":     `"code":"invalid_ip"`,
+			"/nope":             `"code":"route_not_found"`,
+		} {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+			assert.Equal(t, "application/problem+json", rec.Header().Get(echo.HeaderContentType), target)
+			assert.Contains(t, rec.Body.String(), want, target)
+		}
+	})
+
+	t.Run("OpenAPI Covers Routes", func(t *testing.T) {
+		e, svc, err := NewServer(writeSampleDB(t), WithAnonymousIPDatabase(writeSampleAnonymousIPDB(t)),
+			WithAPIDocs(), WithAPIKeys(loadSampleKeys(t)),
+			WithCompatAPIs(handlers.CompatIPAPI, handlers.CompatIPInfo), WithPolicy(loadSamplePolicy(t)),
+			WithForwardAuth(), WithTrustedProxies(netip.MustParsePrefix("127.0.0.0/8")))
+		require.NoError(t, err)
+		defer func() {
+			closeErr := svc.DB.Close()
+			require.NoError(t, closeErr)
+		}()
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+		require.Equal(t, http.StatusOK, rec.Code, "the document is open")
+		var doc struct {
+			Paths map[string]map[string]any `json:"paths"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+
+		for _, route := range e.Routes() {
+			if route.Path == "/docs" || route.Method == echo.RouteNotFound {
+				// The docs page renders the document and the group
+				// catch-alls only serve 404s; neither is part of the API.
+				continue
+			}
+			// Envoy appends the original path to the wildcard of /auth/*.
+			path := strings.Replace(routeParam.ReplaceAllString(route.Path, "{$1}"), "*", "{path}", 1)
+			require.Contains(t, doc.Paths, path, "route %s is not documented", route.Path)
+			assert.Contains(t, doc.Paths[path], strings.ToLower(route.Method), "route %s %s is not documented", route.Method, route.Path)
+		}
+	})
+
+	t.Run("With API Docs", func(t *testing.T) {
+		e, svc, err := NewServer(writeSampleDB(t))
+		require.NoError(t, err)
+		defer func() {
+			closeErr := svc.DB.Close()
+			require.NoError(t, closeErr)
+		}()
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code, "docs are disabled by default")
+
+		e, svc, err = NewServer(writeSampleDB(t), WithAPIDocs())
+		require.NoError(t, err)
+		defer func() {
+			closeErr := svc.DB.Close()
+			require.NoError(t, closeErr)
+		}()
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "/openapi.json")
+	})
+
+	t.Run("With API Keys", func(t *testing.T) {
+		e, svc, err := NewServer(writeSampleDB(t), WithAPIKeys(loadSampleKeys(t)))
+		require.NoError(t, err)
+		defer func() {
+			closeErr := svc.DB.Close()
+			require.NoError(t, closeErr)
+		}()
+
+		for target, want := range map[string]int{
+			"/health":            http.StatusOK,
+			"/metrics":           http.StatusOK,
+			"/openapi.json":      http.StatusOK,
+			"/lookup/8.8.8.8":    http.StatusUnauthorized,
+			"/v1/lookup/8.8.8.8": http.StatusUnauthorized,
+			"/admin/usage":       http.StatusUnauthorized,
+		} {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+			assert.Equal(t, want, rec.Code, target)
+		}
+
+		for target, want := range map[string]int{
+			"/v1/lookup/8.8.8.8": http.StatusOK,
+			"/lookup/8.8.8.8":    http.StatusOK,
+			"/admin/usage":       http.StatusOK,
+		} {
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			req.Header.Set(handlers.HeaderAPIKey, "ops-secret")
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, want, rec.Code, target)
+		}
+	})
+
+	t.Run("With CORS", func(t *testing.T) {
+		e, svc, err := NewServer(writeSampleDB(t), WithAPIKeys(loadSampleKeys(t)), WithCORS(middleware.CORSConfig{
+			AllowOrigins: []string{"https://*.example.com"},
+			AllowHeaders: []string{handlers.HeaderAPIKey},
+			MaxAge:       600,
+		}))
+		require.NoError(t, err)
+		defer func() {
+			closeErr := svc.DB.Close()
+			require.NoError(t, closeErr)
+		}()
+
+		// Preflight requests carry no API key.
+		req := httptest.NewRequest(http.MethodOptions, "/v1/me", nil)
+		req.Header.Set(echo.HeaderOrigin, "https://app.example.com")
+		req.Header.Set(echo.HeaderAccessControlRequestMethod, http.MethodGet)
+		req.Header.Set(echo.HeaderAccessControlRequestHeaders, handlers.HeaderAPIKey)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, "https://app.example.com", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+		assert.Equal(t, handlers.HeaderAPIKey, rec.Header().Get(echo.HeaderAccessControlAllowHeaders))
+		assert.Equal(t, "600", rec.Header().Get(echo.HeaderAccessControlMaxAge))
+
+		req = httptest.NewRequest(http.MethodGet, "/lookup/8.8.8.8", nil)
+		req.Header.Set(echo.HeaderOrigin, "https://app.example.com")
+		req.Header.Set(handlers.HeaderAPIKey, "ops-secret")
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "https://app.example.com", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+		assert.Equal(t, "Deprecation,Link,Retry-After", rec.Header().Get(echo.HeaderAccessControlExposeHeaders))
+
+		req = httptest.NewRequest(http.MethodOptions, "/v1/me", nil)
+		req.Header.Set(echo.HeaderOrigin, "https://example.org")
+		req.Header.Set(echo.HeaderAccessControlRequestMethod, http.MethodGet)
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Empty(t, rec.Header().Get(echo.HeaderAccessControlAllowOrigin), "origin not allowed")
+	})
+
+	t.Run("Me Route", func(t *testing.T) {
+		e, svc, err := NewServer(writeSampleDB(t), WithTrustedProxies(netip.MustParsePrefix("10.0.0.0/8")))
+		require.NoError(t, err)
+		defer func() {
+			closeErr := svc.DB.Close()
+			require.NoError(t, closeErr)
+		}()
+
+		for remoteAddr, want := range map[string]string{
+			"10.1.2.3:4000":   `"ip_address":"81.2.69.160"`,
+			"192.0.2.10:4000": `"address_class":"documentation"`,
+		} {
+			req := httptest.NewRequest(http.MethodGet, "/v1/me", nil)
+			req.RemoteAddr = remoteAddr
+			req.Header.Set(echo.HeaderXForwardedFor, "81.2.69.160")
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Contains(t, rec.Body.String(), want, remoteAddr)
+		}
+	})
+
+	t.Run("Stream Route", func(t *testing.T) {
+		e, svc, err := NewServer(writeSampleDB(t), WithCompression(compress.Config{}))
+		require.NoError(t, err)
+		defer func() {
+			closeErr := svc.DB.Close()
+			require.NoError(t, closeErr)
+		}()
+
+		req := httptest.NewRequest(http.MethodPost, "/v1/lookup/stream", strings.NewReader("8.8.8.8\nbogus\n"))
+		req.Header.Set(echo.HeaderAcceptEncoding, "gzip")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "gzip", rec.Header().Get(echo.HeaderContentEncoding), "streams are compressed as they flush")
+
+		body, err := gzip.NewReader(rec.Body)
+		require.NoError(t, err)
+		lines, err := io.ReadAll(body)
+		require.NoError(t, err)
+		assert.Equal(t, 2, strings.Count(string(lines), "\n"))
+		assert.Contains(t, string(lines), `"code":"invalid_ip"`)
+	})
+
+	t.Run("WebSocket Route", func(t *testing.T) {
+		srv, err := newServer(writeSampleDB(t), WithCompression(compress.Config{}),
+			WithCORS(middleware.CORSConfig{AllowOrigins: []string{"https://*.example.com"}}),
+			WithSocketRateLimit(1, 1))
+		require.NoError(t, err)
+		defer func() {
+			closeErr := srv.service.DB.Close()
+			require.NoError(t, closeErr)
+		}()
+		e := srv.echo
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		e.Listener = ln
+		go func() { _ = e.Start("") }()
+		url := "ws://" + ln.Addr().String() + "/v1/ws"
+
+		_, res, err := websocket.DefaultDialer.Dial(url, http.Header{echo.HeaderOrigin: {"https://example.org"}})
+		require.Error(t, err)
+		require.NotNil(t, res)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode, "origin not allowed")
+
+		header := http.Header{echo.HeaderOrigin: {"https://app.example.com"}, echo.HeaderAcceptEncoding: {"gzip"}}
+		conn, _, err := websocket.DefaultDialer.Dial(url, header)
+		require.NoError(t, err)
+		defer conn.Close()
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		for _, code := range []string{`"country":{"iso_code":"US"`, `"code":"rate_limited"`} {
+			require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"id":1,"ip":"8.8.8.8"}`)))
+			_, msg, err := conn.ReadMessage()
+			require.NoError(t, err)
+			assert.Contains(t, string(msg), code)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdown := make(chan error, 1)
+		go func() { shutdown <- srv.shutdown(ctx) }()
+		_, _, err = conn.ReadMessage()
+		assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), err)
+		require.NoError(t, <-shutdown, "shutdown waits for the connection to end")
+	})
+
+	t.Run("MaxMind Routes", func(t *testing.T) {
+		e, svc, err := NewServer(writeSampleDB(t), WithAPIKeys(loadSampleKeys(t)))
+		require.NoError(t, err)
+		defer func() {
+			closeErr := svc.DB.Close()
+			require.NoError(t, closeErr)
+		}()
+
+		req := httptest.NewRequest(http.MethodGet, "/geoip/v2.1/city/81.2.69.160", nil)
+		req.SetBasicAuth("123456", "ops-secret")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/vnd.maxmind.com-city+json; charset=UTF-8; version=2.1", rec.Header().Get(echo.HeaderContentType))
+		assert.Contains(t, rec.Body.String(), `"city":{"names":{"de":"London","en":"London"}`)
+
+		req = httptest.NewRequest(http.MethodGet, "/geoip/v2.1/country/8.8.8.8", nil)
+		req.Header.Set(handlers.HeaderAPIKey, "ops-secret")
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, "MaxMind clients authenticate with Basic authentication")
+		assert.Contains(t, rec.Body.String(), `"code":"ACCOUNT_ID_REQUIRED"`)
+	})
+
+	t.Run("Compatibility APIs", func(t *testing.T) {
+		asnFile := filepath.Join(t.TempDir(), "asn.mmdb")
+		require.NoError(t, os.WriteFile(asnFile, geoiptest.MustBuild(geoiptest.SampleASN()), 0o600))
+		e, svc, err := NewServer(writeSampleDB(t), WithAPIKeys(loadSampleKeys(t)), WithASNDatabase(asnFile),
+			WithCompatAPIs(handlers.CompatIPAPI, handlers.CompatIPInfo))
+		require.NoError(t, err)
+		defer closeService(svc)
+
+		for _, tt := range []struct {
+			target     string
+			header     string
+			wantStatus int
+			wantBody   string
+		}{
+			{target: "/ip-api/json/8.8.8.8?fields=countryCode,as&key=ops-secret", wantStatus: http.StatusOK, wantBody: `{"countryCode":"US","as":"AS15169 GOOGLE"}`},
+			{target: "/ip-api/json/8.8.8.8", wantStatus: http.StatusUnauthorized, wantBody: `"code":"missing_api_key"`},
+			{target: "/ipinfo/8.8.8.8/org?token=ops-secret", wantStatus: http.StatusOK, wantBody: "AS15169 GOOGLE\n"},
+			{target: "/ipinfo/json", header: "Bearer ops-secret", wantStatus: http.StatusOK, wantBody: `"ip":"192.0.2.1"`},
+			{target: "/ipinfo/8.8.8.8", header: "Bearer wrong", wantStatus: http.StatusUnauthorized, wantBody: `"code":"invalid_api_key"`},
+		} {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set(echo.HeaderAuthorization, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code, tt.target)
+			assert.Contains(t, rec.Body.String(), tt.wantBody, tt.target)
+		}
+	})
+
+	t.Run("Failure Unknown Compatibility API", func(t *testing.T) {
+		_, _, err := NewServer(writeSampleDB(t), WithCompatAPIs("freegeoip"))
+		assert.EqualError(t, err, `unknown compatibility API "freegeoip"`)
+	})
+
+	t.Run("Geo-Fencing Policy", func(t *testing.T) {
+		e, svc, err := NewServer(writeSampleDB(t), WithAnonymousIPDatabase(writeSampleAnonymousIPDB(t)),
+			WithPolicy(loadSamplePolicy(t)), WithPolicyEnforcement(), WithCompatAPIs(handlers.CompatIPInfo))
+		require.NoError(t, err)
+		defer closeService(svc)
+
+		for _, tt := range []struct {
+			method     string
+			target     string
+			body       string
+			remoteAddr string
+			wantStatus int
+			wantBody   string
+		}{
+			{http.MethodGet, "/v1/lookup/81.2.69.160", "", "8.8.8.8:1234", http.StatusOK, `"iso_code":"GB"`},
+			{http.MethodGet, "/v1/lookup/8.8.8.8", "", "185.220.101.7:1234", http.StatusForbidden, `denied by rule \"no-tor\"`},
+			{http.MethodGet, "/v1/me", "", "81.2.69.160:1234", http.StatusForbidden, "denied by the default policy"},
+			{http.MethodGet, "/lookup/8.8.8.8", "", "81.2.69.160:1234", http.StatusForbidden, `"code":"access_denied"`},
+			{http.MethodGet, "/geoip/v2.1/country/8.8.8.8", "", "81.2.69.160:1234", http.StatusForbidden, `"code":"access_denied"`},
+			{http.MethodGet, "/ipinfo/8.8.8.8", "", "81.2.69.160:1234", http.StatusForbidden, `"code":"access_denied"`},
+			{http.MethodGet, "/health", "", "81.2.69.160:1234", http.StatusOK, `"ok"`},
+			{http.MethodPost, "/v1/evaluate", `{"ip":"185.220.101.7"}`, "8.8.8.8:1234", http.StatusOK,
+				`{"ip":"185.220.101.7","allowed":false,"action":"deny","rule":"no-tor","default":false}`},
+		} {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.RemoteAddr = tt.remoteAddr
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code, tt.target)
+			assert.Contains(t, rec.Body.String(), tt.wantBody, tt.target)
+		}
+	})
+
+	t.Run("Forward Auth", func(t *testing.T) {
+		e, svc, err := NewServer(writeSampleDB(t), WithAnonymousIPDatabase(writeSampleAnonymousIPDB(t)),
+			WithPolicy(loadSamplePolicy(t)), WithForwardAuth(), WithTrustedProxies(netip.MustParsePrefix("127.0.0.0/8")),
+			WithAPIKeys(loadSampleKeys(t)))
+		require.NoError(t, err)
+		defer closeService(svc)
+		srv := httptest.NewServer(e)
+		defer srv.Close()
+
+		for _, tt := range []struct {
+			method     string
+			path       string
+			forwarded  string
+			wantStatus int
+			wantHeader map[string]string
+		}{
+			{http.MethodGet, "/auth", "8.8.8.8", http.StatusOK,
+				map[string]string{wherego.HeaderGeoCountry: "US", handlers.HeaderGeoDecision: "allow", handlers.HeaderGeoRule: "us"}},
+			{http.MethodGet, "/auth", "185.220.101.7", http.StatusForbidden,
+				map[string]string{wherego.HeaderGeoIP: "185.220.101.7", handlers.HeaderGeoRule: "no-tor"}},
+			{http.MethodGet, "/auth", "81.2.69.160", http.StatusForbidden,
+				map[string]string{wherego.HeaderGeoCity: "London", handlers.HeaderGeoDecision: "deny"}},
+			{http.MethodPost, "/auth/orders/42", "8.8.8.8", http.StatusOK,
+				map[string]string{handlers.HeaderGeoDecision: "allow"}},
+		} {
+			req, err := http.NewRequest(tt.method, srv.URL+tt.path, nil)
+			require.NoError(t, err)
+			req.Header.Set("X-Forwarded-For", tt.forwarded)
+			res, err := srv.Client().Do(req)
+			require.NoError(t, err)
+			_ = res.Body.Close()
+			assert.Equal(t, tt.wantStatus, res.StatusCode, "%s %s", tt.path, tt.forwarded)
+			for name, value := range tt.wantHeader {
+				assert.Equal(t, value, res.Header.Get(name), "%s %s", name, tt.forwarded)
+			}
+		}
+	})
+
+	t.Run("Failure Forward Auth Without Policy", func(t *testing.T) {
+		_, _, err := NewServer(writeSampleDB(t), WithForwardAuth(), WithTrustedProxies(netip.MustParsePrefix("127.0.0.0/8")))
+		assert.EqualError(t, err, "forward auth needs a policy")
+	})
+
+	t.Run("Failure Forward Auth Without Trusted Proxies", func(t *testing.T) {
+		_, _, err := NewServer(writeSampleDB(t), WithPolicy(loadSamplePolicy(t)), WithForwardAuth())
+		assert.EqualError(t, err, "forward auth needs trusted proxies")
+	})
+
+	t.Run("Failure Policy Without Database", func(t *testing.T) {
+		_, _, err := NewServer(writeSampleDB(t), WithPolicy(loadSamplePolicy(t)))
+		var invalidMethod wherego.InvalidMethodError
+		assert.ErrorAs(t, err, &invalidMethod, "the policy needs Anonymous IP data")
+	})
+
+	t.Run("Failure Policy Enforcement Without Policy", func(t *testing.T) {
+		_, _, err := NewServer(writeSampleDB(t), WithPolicyEnforcement())
+		assert.EqualError(t, err, "policy enforcement needs a policy")
+	})
+
+	t.Run("With Update Interval", func(t *testing.T) {
+		e, svc, err := NewServer(writeSampleDB(t), WithUpdateInterval(24*time.Hour))
+		require.NoError(t, err)
+		defer func() {
+			closeErr := svc.DB.Close()
+			require.NoError(t, closeErr)
+		}()
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/lookup/8.8.8.8", nil))
+		assert.Equal(t, "public, max-age=86400", rec.Header().Get("Cache-Control"))
+
+		req := httptest.NewRequest(http.MethodGet, "/v1/lookup/8.8.8.8", nil)
+		req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+
+		e, svc, err = NewServer(writeSampleDB(t), WithUpdateInterval(24*time.Hour), WithAPIKeys(loadSampleKeys(t)))
+		require.NoError(t, err)
+		defer func() {
+			closeErr := svc.DB.Close()
+			require.NoError(t, closeErr)
+		}()
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/lookup/8.8.8.8?api_key=ops-secret", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "private, max-age=86400", rec.Header().Get("Cache-Control"), "keyed lookups stay out of shared caches")
+	})
+
+	t.Run("With Compression", func(t *testing.T) {
+		e, svc, err := NewServer(writeSampleDB(t), WithCompression(compress.Config{MinLength: 100}))
+		require.NoError(t, err)
+		defer func() {
+			closeErr := svc.DB.Close()
+			require.NoError(t, closeErr)
+		}()
+
+		req := httptest.NewRequest(http.MethodGet, "/v1/lookup/81.2.69.160", nil)
+		req.Header.Set(echo.HeaderAcceptEncoding, "gzip")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "gzip", rec.Header().Get(echo.HeaderContentEncoding))
+		assert.Contains(t, rec.Header().Values(echo.HeaderVary), echo.HeaderAcceptEncoding)
+
+		req = httptest.NewRequest(http.MethodGet, "/health", nil)
+		req.Header.Set(echo.HeaderAcceptEncoding, "gzip")
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Empty(t, rec.Header().Get(echo.HeaderContentEncoding), "small responses are not compressed")
+	})
+
+	t.Run("With Overrides", func(t *testing.T) {
+		overridesFile := filepath.Join(t.TempDir(), "overrides.yaml")
+		require.NoError(t, os.WriteFile(overridesFile,
+			[]byte("overrides: [{network: 10.0.0.0/8, labels: {datacenter: iad1}}]"), 0o600))
+
+		e, svc, err := NewServer(writeSampleDB(t), WithCache(1000), WithOverrides(overridesFile))
+		require.NoError(t, err)
+		defer func() {
+			closeErr := svc.DB.Close()
+			require.NoError(t, closeErr)
+		}()
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/lookup/10.1.2.3", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"labels":{"datacenter":"iad1"},"source":"override"`)
+
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/lookup/8.8.8.8", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"source":"database"`)
+	})
+
+	t.Run("With Embedded IPv4 Lookup", func(t *testing.T) {
+		e, svc, err := NewServer(writeSampleDB(t), WithEmbeddedIPv4Lookup())
+		require.NoError(t, err)
+		defer func() {
+			closeErr := svc.DB.Close()
+			require.NoError(t, closeErr)
+		}()
+		assert.True(t, svc.LookupEmbeddedIPv4)
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/lookup/2002:808:808::1", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"address_class":"6to4","embedded_ipv4":"8.8.8.8"`)
+		assert.Contains(t, rec.Body.String(), `"iso_code":"US"`)
+	})
+
+	t.Run("Failure Invalid Overrides", func(t *testing.T) {
+		e, svc, err := NewServer(writeSampleDB(t), WithOverrides("invalid/overrides.yaml"))
+		assert.Error(t, err)
+		assert.Nil(t, e)
+		assert.Nil(t, svc)
+	})
+
 	t.Run("Failure Invalid Path", func(t *testing.T) {
 		e, svc, err := NewServer("invalid/path/to/db.mmdb")
 		assert.Error(t, err)
@@ -149,3 +745,51 @@ func TestHealthCheck_Integration(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rec.Code)
 	assert.Contains(t, rec.Body.String(), "status")
 }
+
+// routeParam matches the parameters of Echo route paths, such as ":ip".
+var routeParam = regexp.MustCompile(`:(\w+)`)
+
+// loadSampleKeys loads a keys file with a single admin key, "ops-secret".
+func loadSampleKeys(t *testing.T) *auth.Keys {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	require.NoError(t, os.WriteFile(path,
+		[]byte("keys: [{name: ops, hash: "+auth.HashKey("ops-secret")+", admin: true}]"), 0o600))
+	keys, err := auth.LoadKeys(path)
+	require.NoError(t, err)
+	return keys
+}
+
+// writeSampleDB writes the geoiptest sample City database to a temporary
+// file and returns its path.
+func writeSampleDB(t *testing.T) string {
+	t.Helper()
+	dbFile := filepath.Join(t.TempDir(), "city.mmdb")
+	require.NoError(t, os.WriteFile(dbFile, geoiptest.MustBuild(geoiptest.SampleCity()), 0o600))
+	return dbFile
+}
+
+// loadSamplePolicy loads a policy that denies Tor exit nodes, allows the
+// US and denies everything else.
+func loadSamplePolicy(t *testing.T) *rules.Policy {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+default: deny
+rules:
+  - {name: no-tor, action: deny, when: anonymous.is_tor_exit_node}
+  - {name: us, action: allow, when: country == US}
+`), 0o600))
+	policy, err := rules.Load(path)
+	require.NoError(t, err)
+	return policy
+}
+
+// writeSampleAnonymousIPDB writes the geoiptest sample Anonymous IP
+// database to a temporary file and returns its path.
+func writeSampleAnonymousIPDB(t *testing.T) string {
+	t.Helper()
+	dbFile := filepath.Join(t.TempDir(), "anonymous-ip.mmdb")
+	require.NoError(t, os.WriteFile(dbFile, geoiptest.MustBuild(geoiptest.SampleAnonymousIP()), 0o600))
+	return dbFile
+}
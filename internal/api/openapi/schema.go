@@ -0,0 +1,150 @@
+// Package openapi generates the JSON Schemas of an OpenAPI 3.1 document
+// from Go types.
+//
+// Schemas follow the encoding/json rules: field names come from the json
+// struct tags, fields tagged "-" are skipped, embedded structs are
+// flattened, and types implementing encoding.TextMarshaler are strings.
+// Because they are derived from the types the API actually serializes, the
+// schemas cannot drift from the responses.
+package openapi
+
+import (
+	"encoding"
+	"reflect"
+	"strings"
+)
+
+// Schema is a JSON Schema object.
+type Schema = map[string]any
+
+var textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+
+// Components collects the named schemas referenced by a document.
+type Components struct {
+	// Schemas maps component names to their schemas.
+	Schemas map[string]Schema
+	name    func(reflect.Type) string
+}
+
+// NewComponents returns an empty set of components. name returns the
+// component name of a named struct type, or "" to inline its schema; a nil
+// name uses the type name. Anonymous structs are always inlined.
+func NewComponents(name func(reflect.Type) string) *Components {
+	if name == nil {
+		name = reflect.Type.Name
+	}
+	return &Components{Schemas: make(map[string]Schema), name: name}
+}
+
+// Ref returns the schema of T, like Schema.
+func Ref[T any](c *Components) Schema {
+	return c.Schema(reflect.TypeFor[T]())
+}
+
+// Schema returns the schema of t. Named struct types are registered as
+// components and referenced.
+func (c *Components) Schema(t reflect.Type) Schema {
+	if t.Kind() != reflect.Pointer && t.Implements(textMarshalerType) {
+		return Schema{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(c.Schema(t.Elem()))
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Schema{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return Schema{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "contentEncoding": "base64"}
+		}
+		return Schema{"type": "array", "items": c.Schema(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": c.Schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return c.structSchema(t)
+		}
+		name := c.name(t)
+		if name == "" {
+			return c.structSchema(t)
+		}
+		if _, ok := c.Schemas[name]; !ok {
+			// Register the name first so recursive types terminate.
+			c.Schemas[name] = nil
+			c.Schemas[name] = c.structSchema(t)
+		}
+		return Schema{"$ref": "#/components/schemas/" + name}
+	default:
+		// Interfaces and anything else encoding/json can hold.
+		return Schema{}
+	}
+}
+
+func (c *Components) structSchema(t reflect.Type) Schema {
+	properties := make(map[string]Schema)
+	var required []string
+	c.addFields(t, properties, &required)
+
+	s := Schema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+func (c *Components) addFields(t reflect.Type, properties map[string]Schema, required *[]string) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			c.addFields(f.Type, properties, required)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = c.Schema(f.Type)
+		if !hasOption(opts, "omitempty") && !hasOption(opts, "omitzero") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func hasOption(opts, option string) bool {
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+// nullable allows null in addition to s.
+func nullable(s Schema) Schema {
+	if typ, ok := s["type"].(string); ok {
+		n := make(Schema, len(s))
+		for k, v := range s {
+			n[k] = v
+		}
+		n["type"] = []string{typ, "null"}
+		return n
+	}
+	return Schema{"oneOf": []Schema{s, {"type": "null"}}}
+}
@@ -0,0 +1,77 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type embedded struct {
+	Shared string `json:"shared"`
+}
+
+type node struct {
+	embedded
+	Name     string            `json:"name"`
+	Count    uint16            `json:"count,omitempty"`
+	Offset   int               `json:"offset,omitzero"`
+	Ratio    *float64          `json:"ratio"`
+	Addr     netip.Addr        `json:"addr"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Children []node            `json:"children"`
+	Parent   *node             `json:"parent,omitempty"`
+	Raw      []byte            `json:"raw,omitempty"`
+	Any      any               `json:"any,omitempty"`
+	Inline   struct {
+		OK bool `json:"ok"`
+	} `json:"inline"`
+	Untagged string
+	Skipped  string `json:"-"`
+	private  string
+}
+
+func TestComponents_Schema(t *testing.T) {
+	c := NewComponents(nil)
+	ref := Ref[node](c)
+	assert.Equal(t, Schema{"$ref": "#/components/schemas/node"}, ref)
+
+	got, err := json.Marshal(c.Schemas)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"node": {
+			"type": "object",
+			"properties": {
+				"shared": {"type": "string"},
+				"name": {"type": "string"},
+				"count": {"type": "integer", "minimum": 0},
+				"offset": {"type": "integer"},
+				"ratio": {"type": ["number", "null"]},
+				"addr": {"type": "string"},
+				"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+				"children": {"type": "array", "items": {"$ref": "#/components/schemas/node"}},
+				"parent": {"oneOf": [{"$ref": "#/components/schemas/node"}, {"type": "null"}]},
+				"raw": {"type": "string", "contentEncoding": "base64"},
+				"any": {},
+				"inline": {"type": "object", "properties": {"ok": {"type": "boolean"}}, "required": ["ok"]},
+				"Untagged": {"type": "string"}
+			},
+			"required": ["shared", "name", "ratio", "addr", "children", "inline", "Untagged"]
+		}
+	}`, string(got))
+}
+
+func TestComponents_CustomNames(t *testing.T) {
+	c := NewComponents(func(t reflect.Type) string {
+		if t == reflect.TypeFor[embedded]() {
+			return ""
+		}
+		return "My" + t.Name()
+	})
+	assert.Equal(t, Schema{"$ref": "#/components/schemas/Mynode"}, Ref[node](c))
+	assert.Contains(t, Ref[embedded](c), "properties", "unnamed types are inlined")
+	assert.Len(t, c.Schemas, 1)
+}
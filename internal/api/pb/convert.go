@@ -0,0 +1,323 @@
+package pb
+
+import (
+	"net/netip"
+
+	v1 "github.com/gustavosett/WhereGo/internal/api/v1"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+)
+
+// The conversions below leave a message field unset when the model field
+// is zero, as the JSON encoding omits it, so no field is lost or added.
+
+// FromCity converts a City lookup result.
+func FromCity(c *wherego.City) *City {
+	m := &City{
+		Continent:          fromContinent(c.Continent),
+		Country:            fromCountry(c.Country),
+		RegisteredCountry:  fromCountry(c.RegisteredCountry),
+		RepresentedCountry: fromRepresentedCountry(c.RepresentedCountry),
+		Location:           fromLocation(c.Location),
+		Labels:             c.Labels,
+		Source:             c.Source,
+	}
+	if c.Traits != (wherego.CityTraits{}) {
+		m.Traits = &Traits{
+			IpAddress:    addrString(c.Traits.IPAddress),
+			Network:      prefixString(c.Traits.Network),
+			IsAnycast:    c.Traits.IsAnycast,
+			AddressClass: string(c.Traits.AddressClass),
+			EmbeddedIpv4: c.Traits.EmbeddedIPv4,
+		}
+	}
+	for _, sub := range c.Subdivisions {
+		m.Subdivisions = append(m.Subdivisions, &Subdivision{
+			IsoCode:   sub.ISOCode,
+			GeonameId: uint32(sub.GeoNameID),
+			Names:     fromNames(sub.Names),
+		})
+	}
+	if c.City != (wherego.CityRecord{}) {
+		m.City = &CityRecord{GeonameId: uint32(c.City.GeoNameID), Names: fromNames(c.City.Names)}
+	}
+	if c.Postal != (wherego.CityPostal{}) {
+		m.Postal = &Postal{Code: c.Postal.Code}
+	}
+	return m
+}
+
+// FromCountry converts a Country lookup result. Country databases have no
+// message of their own: the result is a City without the city fields.
+func FromCountry(c *wherego.Country) *City {
+	m := &City{
+		Continent:          fromContinent(c.Continent),
+		Country:            fromCountry(c.Country),
+		RegisteredCountry:  fromCountry(c.RegisteredCountry),
+		RepresentedCountry: fromRepresentedCountry(c.RepresentedCountry),
+	}
+	if c.Traits != (wherego.CountryTraits{}) {
+		m.Traits = &Traits{
+			IpAddress: addrString(c.Traits.IPAddress),
+			Network:   prefixString(c.Traits.Network),
+			IsAnycast: c.Traits.IsAnycast,
+		}
+	}
+	return m
+}
+
+// FromEnterprise converts an Enterprise lookup result, which is a City
+// with confidence values and the ISP fields in its traits.
+func FromEnterprise(e *wherego.Enterprise) *City {
+	m := &City{
+		Continent:          fromContinent(e.Continent),
+		RegisteredCountry:  fromCountry(e.RegisteredCountry),
+		RepresentedCountry: fromRepresentedCountry(e.RepresentedCountry),
+		Location:           fromLocation(e.Location),
+	}
+	if t := e.Traits; t != (wherego.EnterpriseTraits{}) {
+		m.Traits = &Traits{
+			IpAddress:                    addrString(t.IPAddress),
+			Network:                      prefixString(t.Network),
+			IsAnycast:                    t.IsAnycast,
+			AutonomousSystemNumber:       uint32(t.AutonomousSystemNumber),
+			AutonomousSystemOrganization: t.AutonomousSystemOrganization,
+			ConnectionType:               t.ConnectionType,
+			Domain:                       t.Domain,
+			Isp:                          t.ISP,
+			MobileCountryCode:            t.MobileCountryCode,
+			MobileNetworkCode:            t.MobileNetworkCode,
+			Organization:                 t.Organization,
+			UserType:                     t.UserType,
+			StaticIpScore:                t.StaticIPScore,
+			IsLegitimateProxy:            t.IsLegitimateProxy,
+		}
+	}
+	if c := e.Country; c != (wherego.EnterpriseCountryRecord{}) {
+		m.Country = &Country{
+			IsoCode:           c.ISOCode,
+			GeonameId:         uint32(c.GeoNameID),
+			IsInEuropeanUnion: c.IsInEuropeanUnion,
+			Names:             fromNames(c.Names),
+			Confidence:        uint32(c.Confidence),
+		}
+	}
+	for _, sub := range e.Subdivisions {
+		m.Subdivisions = append(m.Subdivisions, &Subdivision{
+			IsoCode:    sub.ISOCode,
+			GeonameId:  uint32(sub.GeoNameID),
+			Names:      fromNames(sub.Names),
+			Confidence: uint32(sub.Confidence),
+		})
+	}
+	if e.City != (wherego.EnterpriseCityRecord{}) {
+		m.City = &CityRecord{
+			GeonameId:  uint32(e.City.GeoNameID),
+			Names:      fromNames(e.City.Names),
+			Confidence: uint32(e.City.Confidence),
+		}
+	}
+	if e.Postal != (wherego.EnterprisePostal{}) {
+		m.Postal = &Postal{Code: e.Postal.Code, Confidence: uint32(e.Postal.Confidence)}
+	}
+	return m
+}
+
+// FromCityResponse converts a v1 lookup response. The v1 fields are a
+// subset of the City message.
+func FromCityResponse(r *v1.CityResponse) *City {
+	m := &City{
+		Traits: &Traits{
+			IpAddress:    r.Traits.IPAddress,
+			Network:      r.Traits.Network,
+			IsAnycast:    r.Traits.IsAnycast,
+			AddressClass: r.Traits.AddressClass,
+			EmbeddedIpv4: r.Traits.EmbeddedIPv4,
+		},
+		Labels: r.Labels,
+		Source: r.Source,
+	}
+	if c := r.Continent; c != nil {
+		m.Continent = &Continent{Code: c.Code, GeonameId: uint32(c.GeoNameID), Names: c.Names}
+	}
+	m.Country = fromV1Country(r.Country)
+	m.RegisteredCountry = fromV1Country(r.RegisteredCountry)
+	if c := r.RepresentedCountry; c != nil {
+		m.RepresentedCountry = &RepresentedCountry{
+			IsoCode:           c.ISOCode,
+			GeonameId:         uint32(c.GeoNameID),
+			IsInEuropeanUnion: c.IsInEuropeanUnion,
+			Type:              c.Type,
+			Names:             c.Names,
+		}
+	}
+	for _, sub := range r.Subdivisions {
+		m.Subdivisions = append(m.Subdivisions, &Subdivision{
+			IsoCode:   sub.ISOCode,
+			GeonameId: uint32(sub.GeoNameID),
+			Names:     sub.Names,
+		})
+	}
+	if c := r.City; c != nil {
+		m.City = &CityRecord{GeonameId: uint32(c.GeoNameID), Names: c.Names}
+	}
+	if p := r.Postal; p != nil {
+		m.Postal = &Postal{Code: p.Code}
+	}
+	if l := r.Location; l != nil {
+		m.Location = &Location{
+			Latitude:       l.Latitude,
+			Longitude:      l.Longitude,
+			AccuracyRadius: uint32(l.AccuracyRadius),
+			TimeZone:       l.TimeZone,
+		}
+	}
+	return m
+}
+
+// FromAnonymousIP converts an Anonymous IP lookup result.
+func FromAnonymousIP(a *wherego.AnonymousIP) *AnonymousIP {
+	return &AnonymousIP{
+		IpAddress:          addrString(a.IPAddress),
+		Network:            prefixString(a.Network),
+		IsAnonymous:        a.IsAnonymous,
+		IsAnonymousVpn:     a.IsAnonymousVPN,
+		IsHostingProvider:  a.IsHostingProvider,
+		IsPublicProxy:      a.IsPublicProxy,
+		IsResidentialProxy: a.IsResidentialProxy,
+		IsTorExitNode:      a.IsTorExitNode,
+	}
+}
+
+// FromASN converts an ASN lookup result.
+func FromASN(a *wherego.ASN) *ASN {
+	return &ASN{
+		IpAddress:                    addrString(a.IPAddress),
+		Network:                      prefixString(a.Network),
+		AutonomousSystemNumber:       uint32(a.AutonomousSystemNumber),
+		AutonomousSystemOrganization: a.AutonomousSystemOrganization,
+	}
+}
+
+// FromConnectionType converts a Connection Type lookup result.
+func FromConnectionType(c *wherego.ConnectionType) *ConnectionType {
+	return &ConnectionType{
+		IpAddress:      addrString(c.IPAddress),
+		Network:        prefixString(c.Network),
+		ConnectionType: c.ConnectionType,
+	}
+}
+
+// FromDomain converts a Domain lookup result.
+func FromDomain(d *wherego.Domain) *Domain {
+	return &Domain{
+		IpAddress: addrString(d.IPAddress),
+		Network:   prefixString(d.Network),
+		Domain:    d.Domain,
+	}
+}
+
+// FromISP converts an ISP lookup result.
+func FromISP(i *wherego.ISP) *ISP {
+	return &ISP{
+		IpAddress:                    addrString(i.IPAddress),
+		Network:                      prefixString(i.Network),
+		AutonomousSystemNumber:       uint32(i.AutonomousSystemNumber),
+		AutonomousSystemOrganization: i.AutonomousSystemOrganization,
+		Isp:                          i.ISP,
+		MobileCountryCode:            i.MobileCountryCode,
+		MobileNetworkCode:            i.MobileNetworkCode,
+		Organization:                 i.Organization,
+	}
+}
+
+func fromNames(n wherego.Names) map[string]string {
+	if !n.HasData() {
+		return nil
+	}
+	names := make(map[string]string, 8)
+	add := func(locale, name string) {
+		if name != "" {
+			names[locale] = name
+		}
+	}
+	add("de", n.German)
+	add("en", n.English)
+	add("es", n.Spanish)
+	add("fr", n.French)
+	add("ja", n.Japanese)
+	add("pt-BR", n.BrazilianPortuguese)
+	add("ru", n.Russian)
+	add("zh-CN", n.SimplifiedChinese)
+	return names
+}
+
+func fromContinent(c wherego.Continent) *Continent {
+	if !c.HasData() {
+		return nil
+	}
+	return &Continent{Code: c.Code, GeonameId: uint32(c.GeoNameID), Names: fromNames(c.Names)}
+}
+
+func fromCountry(c wherego.CountryRecord) *Country {
+	if !c.HasData() {
+		return nil
+	}
+	return &Country{
+		IsoCode:           c.ISOCode,
+		GeonameId:         uint32(c.GeoNameID),
+		IsInEuropeanUnion: c.IsInEuropeanUnion,
+		Names:             fromNames(c.Names),
+	}
+}
+
+func fromV1Country(c *v1.Country) *Country {
+	if c == nil {
+		return nil
+	}
+	return &Country{
+		IsoCode:           c.ISOCode,
+		GeonameId:         uint32(c.GeoNameID),
+		IsInEuropeanUnion: c.IsInEuropeanUnion,
+		Names:             c.Names,
+	}
+}
+
+func fromRepresentedCountry(c wherego.RepresentedCountry) *RepresentedCountry {
+	if !c.HasData() {
+		return nil
+	}
+	return &RepresentedCountry{
+		IsoCode:           c.ISOCode,
+		GeonameId:         uint32(c.GeoNameID),
+		IsInEuropeanUnion: c.IsInEuropeanUnion,
+		Type:              c.Type,
+		Names:             fromNames(c.Names),
+	}
+}
+
+func fromLocation(l wherego.Location) *Location {
+	if !l.HasData() {
+		return nil
+	}
+	return &Location{
+		Latitude:       l.Latitude,
+		Longitude:      l.Longitude,
+		AccuracyRadius: uint32(l.AccuracyRadius),
+		TimeZone:       l.TimeZone,
+		MetroCode:      uint32(l.MetroCode),
+	}
+}
+
+func addrString(addr netip.Addr) string {
+	if !addr.IsValid() {
+		return ""
+	}
+	return addr.String()
+}
+
+func prefixString(prefix netip.Prefix) string {
+	if !prefix.IsValid() {
+		return ""
+	}
+	return prefix.String()
+}
@@ -0,0 +1,132 @@
+package pb
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/netip"
+	"reflect"
+	"strconv"
+	"testing"
+
+	v1 "github.com/gustavosett/WhereGo/internal/api/v1"
+	"github.com/gustavosett/WhereGo/internal/format"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// fill sets every field reachable from v to a distinct non-zero value, so
+// a conversion that drops a field shows up in the comparison.
+func fill(v reflect.Value, n *int) {
+	*n++
+	switch v.Interface().(type) {
+	case netip.Addr:
+		v.Set(reflect.ValueOf(netip.AddrFrom4([4]byte{192, 0, 2, byte(*n)})))
+		return
+	case netip.Prefix:
+		v.Set(reflect.ValueOf(netip.PrefixFrom(netip.AddrFrom4([4]byte{192, 0, 2, 0}), 24)))
+		return
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString("s" + strconv.Itoa(*n))
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(*n))
+	case reflect.Float64:
+		v.SetFloat(float64(*n) + 0.5)
+	case reflect.Pointer:
+		v.Set(reflect.New(v.Type().Elem()))
+		fill(v.Elem(), n)
+	case reflect.Struct:
+		for i := range v.NumField() {
+			fill(v.Field(i), n)
+		}
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 2, 2))
+		for i := range v.Len() {
+			fill(v.Index(i), n)
+		}
+	case reflect.Map:
+		v.Set(reflect.MakeMap(v.Type()))
+		for _, key := range []string{"en", "pt-BR"} {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			fill(elem, n)
+			v.SetMapIndex(reflect.ValueOf(key), elem)
+		}
+	}
+}
+
+func filled[T any]() *T {
+	var v T
+	n := 0
+	fill(reflect.ValueOf(&v).Elem(), &n)
+	return &v
+}
+
+// assertSameJSON checks that m survives the wire format and that its JSON
+// mapping with proto field names is the JSON encoding of model.
+func assertSameJSON(t *testing.T, model any, m proto.Message) {
+	t.Helper()
+	wire, err := proto.Marshal(m)
+	require.NoError(t, err)
+	decoded := m.ProtoReflect().New().Interface()
+	require.NoError(t, proto.Unmarshal(wire, decoded))
+
+	fromProto, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(decoded)
+	require.NoError(t, err)
+	fromModel, err := json.Marshal(model)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(fromModel), string(fromProto))
+}
+
+func TestRoundTrip(t *testing.T) {
+	city := filled[wherego.City]()
+	city.Traits.AddressClass = wherego.AddressClass6to4
+	country := filled[wherego.Country]()
+	enterprise := filled[wherego.Enterprise]()
+	anonymousIP := filled[wherego.AnonymousIP]()
+	asn := filled[wherego.ASN]()
+	connectionType := filled[wherego.ConnectionType]()
+	domain := filled[wherego.Domain]()
+	isp := filled[wherego.ISP]()
+	response := filled[v1.CityResponse]()
+
+	tests := []struct {
+		name  string
+		model any
+		m     proto.Message
+	}{
+		{"City", city, FromCity(city)},
+		{"Empty City", &wherego.City{}, FromCity(&wherego.City{})},
+		{"Country", country, FromCountry(country)},
+		{"Enterprise", enterprise, FromEnterprise(enterprise)},
+		{"Anonymous IP", anonymousIP, FromAnonymousIP(anonymousIP)},
+		{"ASN", asn, FromASN(asn)},
+		{"Connection Type", connectionType, FromConnectionType(connectionType)},
+		{"Domain", domain, FromDomain(domain)},
+		{"ISP", isp, FromISP(isp)},
+		{"V1 Response", response, FromCityResponse(response)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertSameJSON(t, tt.model, tt.m)
+
+			var buf bytes.Buffer
+			require.NoError(t, format.Protobuf.Serialize(&buf, tt.model, format.Options{}), "the message is registered")
+			got := tt.m.ProtoReflect().New().Interface()
+			require.NoError(t, proto.Unmarshal(buf.Bytes(), got))
+			assert.True(t, proto.Equal(tt.m, got))
+		})
+	}
+}
+
+func TestFromCity_ZeroCoordinates(t *testing.T) {
+	zero := 0.0
+	m := FromCity(&wherego.City{Location: wherego.Location{Latitude: &zero, Longitude: &zero}})
+	require.NotNil(t, m.Location)
+	assert.NotNil(t, m.Location.Latitude, "a position on the equator is not a missing position")
+}
@@ -0,0 +1,5 @@
+// Package pb holds the Protocol Buffers messages of the lookup responses,
+// generated from geoip.proto, and their conversion from the geoip models.
+package pb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative geoip.proto
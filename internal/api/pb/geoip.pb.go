@@ -0,0 +1,1427 @@
+// Protocol Buffers messages for the lookup responses, served to clients
+// that send "Accept: application/x-protobuf" or "?format=protobuf".
+//
+// Field names match the JSON responses, so the JSON mapping of a message
+// with proto field names is the JSON response. Field numbers are a contract:
+// fields may be added, but never renumbered or reused.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        (unknown)
+// source: geoip.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Continent is the continent of the location.
+type Continent struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Code      string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	GeonameId uint32                 `protobuf:"varint,2,opt,name=geoname_id,json=geonameId,proto3" json:"geoname_id,omitempty"`
+	// Localized names by locale code, such as "en" or "pt-BR".
+	Names         map[string]string `protobuf:"bytes,3,rep,name=names,proto3" json:"names,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Continent) Reset() {
+	*x = Continent{}
+	mi := &file_geoip_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Continent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Continent) ProtoMessage() {}
+
+func (x *Continent) ProtoReflect() protoreflect.Message {
+	mi := &file_geoip_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Continent.ProtoReflect.Descriptor instead.
+func (*Continent) Descriptor() ([]byte, []int) {
+	return file_geoip_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Continent) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Continent) GetGeonameId() uint32 {
+	if x != nil {
+		return x.GeonameId
+	}
+	return 0
+}
+
+func (x *Continent) GetNames() map[string]string {
+	if x != nil {
+		return x.Names
+	}
+	return nil
+}
+
+// Country is a country record: where the address is located, or where its
+// network is registered.
+type Country struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	IsoCode           string                 `protobuf:"bytes,1,opt,name=iso_code,json=isoCode,proto3" json:"iso_code,omitempty"`
+	GeonameId         uint32                 `protobuf:"varint,2,opt,name=geoname_id,json=geonameId,proto3" json:"geoname_id,omitempty"`
+	IsInEuropeanUnion bool                   `protobuf:"varint,3,opt,name=is_in_european_union,json=isInEuropeanUnion,proto3" json:"is_in_european_union,omitempty"`
+	Names             map[string]string      `protobuf:"bytes,4,rep,name=names,proto3" json:"names,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Confidence is only set by Enterprise databases.
+	Confidence    uint32 `protobuf:"varint,5,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Country) Reset() {
+	*x = Country{}
+	mi := &file_geoip_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Country) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Country) ProtoMessage() {}
+
+func (x *Country) ProtoReflect() protoreflect.Message {
+	mi := &file_geoip_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Country.ProtoReflect.Descriptor instead.
+func (*Country) Descriptor() ([]byte, []int) {
+	return file_geoip_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Country) GetIsoCode() string {
+	if x != nil {
+		return x.IsoCode
+	}
+	return ""
+}
+
+func (x *Country) GetGeonameId() uint32 {
+	if x != nil {
+		return x.GeonameId
+	}
+	return 0
+}
+
+func (x *Country) GetIsInEuropeanUnion() bool {
+	if x != nil {
+		return x.IsInEuropeanUnion
+	}
+	return false
+}
+
+func (x *Country) GetNames() map[string]string {
+	if x != nil {
+		return x.Names
+	}
+	return nil
+}
+
+func (x *Country) GetConfidence() uint32 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+// RepresentedCountry is the country represented by something like a
+// military base or embassy.
+type RepresentedCountry struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	IsoCode           string                 `protobuf:"bytes,1,opt,name=iso_code,json=isoCode,proto3" json:"iso_code,omitempty"`
+	GeonameId         uint32                 `protobuf:"varint,2,opt,name=geoname_id,json=geonameId,proto3" json:"geoname_id,omitempty"`
+	IsInEuropeanUnion bool                   `protobuf:"varint,3,opt,name=is_in_european_union,json=isInEuropeanUnion,proto3" json:"is_in_european_union,omitempty"`
+	Type              string                 `protobuf:"bytes,4,opt,name=type,proto3" json:"type,omitempty"`
+	Names             map[string]string      `protobuf:"bytes,5,rep,name=names,proto3" json:"names,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *RepresentedCountry) Reset() {
+	*x = RepresentedCountry{}
+	mi := &file_geoip_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RepresentedCountry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RepresentedCountry) ProtoMessage() {}
+
+func (x *RepresentedCountry) ProtoReflect() protoreflect.Message {
+	mi := &file_geoip_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RepresentedCountry.ProtoReflect.Descriptor instead.
+func (*RepresentedCountry) Descriptor() ([]byte, []int) {
+	return file_geoip_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *RepresentedCountry) GetIsoCode() string {
+	if x != nil {
+		return x.IsoCode
+	}
+	return ""
+}
+
+func (x *RepresentedCountry) GetGeonameId() uint32 {
+	if x != nil {
+		return x.GeonameId
+	}
+	return 0
+}
+
+func (x *RepresentedCountry) GetIsInEuropeanUnion() bool {
+	if x != nil {
+		return x.IsInEuropeanUnion
+	}
+	return false
+}
+
+func (x *RepresentedCountry) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *RepresentedCountry) GetNames() map[string]string {
+	if x != nil {
+		return x.Names
+	}
+	return nil
+}
+
+// Subdivision is a first or second level administrative division.
+type Subdivision struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	IsoCode       string                 `protobuf:"bytes,1,opt,name=iso_code,json=isoCode,proto3" json:"iso_code,omitempty"`
+	GeonameId     uint32                 `protobuf:"varint,2,opt,name=geoname_id,json=geonameId,proto3" json:"geoname_id,omitempty"`
+	Names         map[string]string      `protobuf:"bytes,3,rep,name=names,proto3" json:"names,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Confidence    uint32                 `protobuf:"varint,4,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Subdivision) Reset() {
+	*x = Subdivision{}
+	mi := &file_geoip_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Subdivision) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Subdivision) ProtoMessage() {}
+
+func (x *Subdivision) ProtoReflect() protoreflect.Message {
+	mi := &file_geoip_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Subdivision.ProtoReflect.Descriptor instead.
+func (*Subdivision) Descriptor() ([]byte, []int) {
+	return file_geoip_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Subdivision) GetIsoCode() string {
+	if x != nil {
+		return x.IsoCode
+	}
+	return ""
+}
+
+func (x *Subdivision) GetGeonameId() uint32 {
+	if x != nil {
+		return x.GeonameId
+	}
+	return 0
+}
+
+func (x *Subdivision) GetNames() map[string]string {
+	if x != nil {
+		return x.Names
+	}
+	return nil
+}
+
+func (x *Subdivision) GetConfidence() uint32 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+// CityRecord is the city of the location.
+type CityRecord struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	GeonameId     uint32                 `protobuf:"varint,1,opt,name=geoname_id,json=geonameId,proto3" json:"geoname_id,omitempty"`
+	Names         map[string]string      `protobuf:"bytes,2,rep,name=names,proto3" json:"names,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Confidence    uint32                 `protobuf:"varint,3,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CityRecord) Reset() {
+	*x = CityRecord{}
+	mi := &file_geoip_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CityRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CityRecord) ProtoMessage() {}
+
+func (x *CityRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_geoip_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CityRecord.ProtoReflect.Descriptor instead.
+func (*CityRecord) Descriptor() ([]byte, []int) {
+	return file_geoip_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CityRecord) GetGeonameId() uint32 {
+	if x != nil {
+		return x.GeonameId
+	}
+	return 0
+}
+
+func (x *CityRecord) GetNames() map[string]string {
+	if x != nil {
+		return x.Names
+	}
+	return nil
+}
+
+func (x *CityRecord) GetConfidence() uint32 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+// Postal is the postal code of the location.
+type Postal struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Confidence    uint32                 `protobuf:"varint,2,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Postal) Reset() {
+	*x = Postal{}
+	mi := &file_geoip_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Postal) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Postal) ProtoMessage() {}
+
+func (x *Postal) ProtoReflect() protoreflect.Message {
+	mi := &file_geoip_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Postal.ProtoReflect.Descriptor instead.
+func (*Postal) Descriptor() ([]byte, []int) {
+	return file_geoip_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Postal) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Postal) GetConfidence() uint32 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+// Location is the approximate position of the address.
+type Location struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Latitude       *float64               `protobuf:"fixed64,1,opt,name=latitude,proto3,oneof" json:"latitude,omitempty"`
+	Longitude      *float64               `protobuf:"fixed64,2,opt,name=longitude,proto3,oneof" json:"longitude,omitempty"`
+	AccuracyRadius uint32                 `protobuf:"varint,3,opt,name=accuracy_radius,json=accuracyRadius,proto3" json:"accuracy_radius,omitempty"`
+	TimeZone       string                 `protobuf:"bytes,4,opt,name=time_zone,json=timeZone,proto3" json:"time_zone,omitempty"`
+	MetroCode      uint32                 `protobuf:"varint,5,opt,name=metro_code,json=metroCode,proto3" json:"metro_code,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Location) Reset() {
+	*x = Location{}
+	mi := &file_geoip_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Location) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Location) ProtoMessage() {}
+
+func (x *Location) ProtoReflect() protoreflect.Message {
+	mi := &file_geoip_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Location.ProtoReflect.Descriptor instead.
+func (*Location) Descriptor() ([]byte, []int) {
+	return file_geoip_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Location) GetLatitude() float64 {
+	if x != nil && x.Latitude != nil {
+		return *x.Latitude
+	}
+	return 0
+}
+
+func (x *Location) GetLongitude() float64 {
+	if x != nil && x.Longitude != nil {
+		return *x.Longitude
+	}
+	return 0
+}
+
+func (x *Location) GetAccuracyRadius() uint32 {
+	if x != nil {
+		return x.AccuracyRadius
+	}
+	return 0
+}
+
+func (x *Location) GetTimeZone() string {
+	if x != nil {
+		return x.TimeZone
+	}
+	return ""
+}
+
+func (x *Location) GetMetroCode() uint32 {
+	if x != nil {
+		return x.MetroCode
+	}
+	return 0
+}
+
+// Traits describes the address itself rather than its location.
+type Traits struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	IpAddress    string                 `protobuf:"bytes,1,opt,name=ip_address,json=ipAddress,proto3" json:"ip_address,omitempty"`
+	Network      string                 `protobuf:"bytes,2,opt,name=network,proto3" json:"network,omitempty"`
+	IsAnycast    bool                   `protobuf:"varint,3,opt,name=is_anycast,json=isAnycast,proto3" json:"is_anycast,omitempty"`
+	AddressClass string                 `protobuf:"bytes,4,opt,name=address_class,json=addressClass,proto3" json:"address_class,omitempty"`
+	EmbeddedIpv4 string                 `protobuf:"bytes,5,opt,name=embedded_ipv4,json=embeddedIpv4,proto3" json:"embedded_ipv4,omitempty"`
+	// The fields below are only set by Enterprise databases.
+	AutonomousSystemNumber       uint32  `protobuf:"varint,6,opt,name=autonomous_system_number,json=autonomousSystemNumber,proto3" json:"autonomous_system_number,omitempty"`
+	AutonomousSystemOrganization string  `protobuf:"bytes,7,opt,name=autonomous_system_organization,json=autonomousSystemOrganization,proto3" json:"autonomous_system_organization,omitempty"`
+	ConnectionType               string  `protobuf:"bytes,8,opt,name=connection_type,json=connectionType,proto3" json:"connection_type,omitempty"`
+	Domain                       string  `protobuf:"bytes,9,opt,name=domain,proto3" json:"domain,omitempty"`
+	Isp                          string  `protobuf:"bytes,10,opt,name=isp,proto3" json:"isp,omitempty"`
+	MobileCountryCode            string  `protobuf:"bytes,11,opt,name=mobile_country_code,json=mobileCountryCode,proto3" json:"mobile_country_code,omitempty"`
+	MobileNetworkCode            string  `protobuf:"bytes,12,opt,name=mobile_network_code,json=mobileNetworkCode,proto3" json:"mobile_network_code,omitempty"`
+	Organization                 string  `protobuf:"bytes,13,opt,name=organization,proto3" json:"organization,omitempty"`
+	UserType                     string  `protobuf:"bytes,14,opt,name=user_type,json=userType,proto3" json:"user_type,omitempty"`
+	StaticIpScore                float64 `protobuf:"fixed64,15,opt,name=static_ip_score,json=staticIpScore,proto3" json:"static_ip_score,omitempty"`
+	IsLegitimateProxy            bool    `protobuf:"varint,16,opt,name=is_legitimate_proxy,json=isLegitimateProxy,proto3" json:"is_legitimate_proxy,omitempty"`
+	unknownFields                protoimpl.UnknownFields
+	sizeCache                    protoimpl.SizeCache
+}
+
+func (x *Traits) Reset() {
+	*x = Traits{}
+	mi := &file_geoip_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Traits) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Traits) ProtoMessage() {}
+
+func (x *Traits) ProtoReflect() protoreflect.Message {
+	mi := &file_geoip_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Traits.ProtoReflect.Descriptor instead.
+func (*Traits) Descriptor() ([]byte, []int) {
+	return file_geoip_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Traits) GetIpAddress() string {
+	if x != nil {
+		return x.IpAddress
+	}
+	return ""
+}
+
+func (x *Traits) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+func (x *Traits) GetIsAnycast() bool {
+	if x != nil {
+		return x.IsAnycast
+	}
+	return false
+}
+
+func (x *Traits) GetAddressClass() string {
+	if x != nil {
+		return x.AddressClass
+	}
+	return ""
+}
+
+func (x *Traits) GetEmbeddedIpv4() string {
+	if x != nil {
+		return x.EmbeddedIpv4
+	}
+	return ""
+}
+
+func (x *Traits) GetAutonomousSystemNumber() uint32 {
+	if x != nil {
+		return x.AutonomousSystemNumber
+	}
+	return 0
+}
+
+func (x *Traits) GetAutonomousSystemOrganization() string {
+	if x != nil {
+		return x.AutonomousSystemOrganization
+	}
+	return ""
+}
+
+func (x *Traits) GetConnectionType() string {
+	if x != nil {
+		return x.ConnectionType
+	}
+	return ""
+}
+
+func (x *Traits) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *Traits) GetIsp() string {
+	if x != nil {
+		return x.Isp
+	}
+	return ""
+}
+
+func (x *Traits) GetMobileCountryCode() string {
+	if x != nil {
+		return x.MobileCountryCode
+	}
+	return ""
+}
+
+func (x *Traits) GetMobileNetworkCode() string {
+	if x != nil {
+		return x.MobileNetworkCode
+	}
+	return ""
+}
+
+func (x *Traits) GetOrganization() string {
+	if x != nil {
+		return x.Organization
+	}
+	return ""
+}
+
+func (x *Traits) GetUserType() string {
+	if x != nil {
+		return x.UserType
+	}
+	return ""
+}
+
+func (x *Traits) GetStaticIpScore() float64 {
+	if x != nil {
+		return x.StaticIpScore
+	}
+	return 0
+}
+
+func (x *Traits) GetIsLegitimateProxy() bool {
+	if x != nil {
+		return x.IsLegitimateProxy
+	}
+	return false
+}
+
+// City is the lookup of an address in a City, Country or Enterprise
+// database, and the response of the lookup routes.
+type City struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Traits             *Traits                `protobuf:"bytes,1,opt,name=traits,proto3" json:"traits,omitempty"`
+	Continent          *Continent             `protobuf:"bytes,2,opt,name=continent,proto3" json:"continent,omitempty"`
+	Country            *Country               `protobuf:"bytes,3,opt,name=country,proto3" json:"country,omitempty"`
+	RegisteredCountry  *Country               `protobuf:"bytes,4,opt,name=registered_country,json=registeredCountry,proto3" json:"registered_country,omitempty"`
+	RepresentedCountry *RepresentedCountry    `protobuf:"bytes,5,opt,name=represented_country,json=representedCountry,proto3" json:"represented_country,omitempty"`
+	Subdivisions       []*Subdivision         `protobuf:"bytes,6,rep,name=subdivisions,proto3" json:"subdivisions,omitempty"`
+	City               *CityRecord            `protobuf:"bytes,7,opt,name=city,proto3" json:"city,omitempty"`
+	Postal             *Postal                `protobuf:"bytes,8,opt,name=postal,proto3" json:"postal,omitempty"`
+	Location           *Location              `protobuf:"bytes,9,opt,name=location,proto3" json:"location,omitempty"`
+	Labels             map[string]string      `protobuf:"bytes,10,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Source             string                 `protobuf:"bytes,11,opt,name=source,proto3" json:"source,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *City) Reset() {
+	*x = City{}
+	mi := &file_geoip_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *City) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*City) ProtoMessage() {}
+
+func (x *City) ProtoReflect() protoreflect.Message {
+	mi := &file_geoip_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use City.ProtoReflect.Descriptor instead.
+func (*City) Descriptor() ([]byte, []int) {
+	return file_geoip_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *City) GetTraits() *Traits {
+	if x != nil {
+		return x.Traits
+	}
+	return nil
+}
+
+func (x *City) GetContinent() *Continent {
+	if x != nil {
+		return x.Continent
+	}
+	return nil
+}
+
+func (x *City) GetCountry() *Country {
+	if x != nil {
+		return x.Country
+	}
+	return nil
+}
+
+func (x *City) GetRegisteredCountry() *Country {
+	if x != nil {
+		return x.RegisteredCountry
+	}
+	return nil
+}
+
+func (x *City) GetRepresentedCountry() *RepresentedCountry {
+	if x != nil {
+		return x.RepresentedCountry
+	}
+	return nil
+}
+
+func (x *City) GetSubdivisions() []*Subdivision {
+	if x != nil {
+		return x.Subdivisions
+	}
+	return nil
+}
+
+func (x *City) GetCity() *CityRecord {
+	if x != nil {
+		return x.City
+	}
+	return nil
+}
+
+func (x *City) GetPostal() *Postal {
+	if x != nil {
+		return x.Postal
+	}
+	return nil
+}
+
+func (x *City) GetLocation() *Location {
+	if x != nil {
+		return x.Location
+	}
+	return nil
+}
+
+func (x *City) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *City) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+// AnonymousIP is the lookup of an address in an Anonymous IP database.
+type AnonymousIP struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	IpAddress          string                 `protobuf:"bytes,1,opt,name=ip_address,json=ipAddress,proto3" json:"ip_address,omitempty"`
+	Network            string                 `protobuf:"bytes,2,opt,name=network,proto3" json:"network,omitempty"`
+	IsAnonymous        bool                   `protobuf:"varint,3,opt,name=is_anonymous,json=isAnonymous,proto3" json:"is_anonymous,omitempty"`
+	IsAnonymousVpn     bool                   `protobuf:"varint,4,opt,name=is_anonymous_vpn,json=isAnonymousVpn,proto3" json:"is_anonymous_vpn,omitempty"`
+	IsHostingProvider  bool                   `protobuf:"varint,5,opt,name=is_hosting_provider,json=isHostingProvider,proto3" json:"is_hosting_provider,omitempty"`
+	IsPublicProxy      bool                   `protobuf:"varint,6,opt,name=is_public_proxy,json=isPublicProxy,proto3" json:"is_public_proxy,omitempty"`
+	IsResidentialProxy bool                   `protobuf:"varint,7,opt,name=is_residential_proxy,json=isResidentialProxy,proto3" json:"is_residential_proxy,omitempty"`
+	IsTorExitNode      bool                   `protobuf:"varint,8,opt,name=is_tor_exit_node,json=isTorExitNode,proto3" json:"is_tor_exit_node,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *AnonymousIP) Reset() {
+	*x = AnonymousIP{}
+	mi := &file_geoip_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnonymousIP) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnonymousIP) ProtoMessage() {}
+
+func (x *AnonymousIP) ProtoReflect() protoreflect.Message {
+	mi := &file_geoip_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnonymousIP.ProtoReflect.Descriptor instead.
+func (*AnonymousIP) Descriptor() ([]byte, []int) {
+	return file_geoip_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *AnonymousIP) GetIpAddress() string {
+	if x != nil {
+		return x.IpAddress
+	}
+	return ""
+}
+
+func (x *AnonymousIP) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+func (x *AnonymousIP) GetIsAnonymous() bool {
+	if x != nil {
+		return x.IsAnonymous
+	}
+	return false
+}
+
+func (x *AnonymousIP) GetIsAnonymousVpn() bool {
+	if x != nil {
+		return x.IsAnonymousVpn
+	}
+	return false
+}
+
+func (x *AnonymousIP) GetIsHostingProvider() bool {
+	if x != nil {
+		return x.IsHostingProvider
+	}
+	return false
+}
+
+func (x *AnonymousIP) GetIsPublicProxy() bool {
+	if x != nil {
+		return x.IsPublicProxy
+	}
+	return false
+}
+
+func (x *AnonymousIP) GetIsResidentialProxy() bool {
+	if x != nil {
+		return x.IsResidentialProxy
+	}
+	return false
+}
+
+func (x *AnonymousIP) GetIsTorExitNode() bool {
+	if x != nil {
+		return x.IsTorExitNode
+	}
+	return false
+}
+
+// ASN is the lookup of an address in an ASN database.
+type ASN struct {
+	state                        protoimpl.MessageState `protogen:"open.v1"`
+	IpAddress                    string                 `protobuf:"bytes,1,opt,name=ip_address,json=ipAddress,proto3" json:"ip_address,omitempty"`
+	Network                      string                 `protobuf:"bytes,2,opt,name=network,proto3" json:"network,omitempty"`
+	AutonomousSystemNumber       uint32                 `protobuf:"varint,3,opt,name=autonomous_system_number,json=autonomousSystemNumber,proto3" json:"autonomous_system_number,omitempty"`
+	AutonomousSystemOrganization string                 `protobuf:"bytes,4,opt,name=autonomous_system_organization,json=autonomousSystemOrganization,proto3" json:"autonomous_system_organization,omitempty"`
+	unknownFields                protoimpl.UnknownFields
+	sizeCache                    protoimpl.SizeCache
+}
+
+func (x *ASN) Reset() {
+	*x = ASN{}
+	mi := &file_geoip_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ASN) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ASN) ProtoMessage() {}
+
+func (x *ASN) ProtoReflect() protoreflect.Message {
+	mi := &file_geoip_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ASN.ProtoReflect.Descriptor instead.
+func (*ASN) Descriptor() ([]byte, []int) {
+	return file_geoip_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ASN) GetIpAddress() string {
+	if x != nil {
+		return x.IpAddress
+	}
+	return ""
+}
+
+func (x *ASN) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+func (x *ASN) GetAutonomousSystemNumber() uint32 {
+	if x != nil {
+		return x.AutonomousSystemNumber
+	}
+	return 0
+}
+
+func (x *ASN) GetAutonomousSystemOrganization() string {
+	if x != nil {
+		return x.AutonomousSystemOrganization
+	}
+	return ""
+}
+
+// ConnectionType is the lookup of an address in a Connection Type database.
+type ConnectionType struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	IpAddress      string                 `protobuf:"bytes,1,opt,name=ip_address,json=ipAddress,proto3" json:"ip_address,omitempty"`
+	Network        string                 `protobuf:"bytes,2,opt,name=network,proto3" json:"network,omitempty"`
+	ConnectionType string                 `protobuf:"bytes,3,opt,name=connection_type,json=connectionType,proto3" json:"connection_type,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *ConnectionType) Reset() {
+	*x = ConnectionType{}
+	mi := &file_geoip_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConnectionType) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConnectionType) ProtoMessage() {}
+
+func (x *ConnectionType) ProtoReflect() protoreflect.Message {
+	mi := &file_geoip_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConnectionType.ProtoReflect.Descriptor instead.
+func (*ConnectionType) Descriptor() ([]byte, []int) {
+	return file_geoip_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ConnectionType) GetIpAddress() string {
+	if x != nil {
+		return x.IpAddress
+	}
+	return ""
+}
+
+func (x *ConnectionType) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+func (x *ConnectionType) GetConnectionType() string {
+	if x != nil {
+		return x.ConnectionType
+	}
+	return ""
+}
+
+// Domain is the lookup of an address in a Domain database.
+type Domain struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	IpAddress     string                 `protobuf:"bytes,1,opt,name=ip_address,json=ipAddress,proto3" json:"ip_address,omitempty"`
+	Network       string                 `protobuf:"bytes,2,opt,name=network,proto3" json:"network,omitempty"`
+	Domain        string                 `protobuf:"bytes,3,opt,name=domain,proto3" json:"domain,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Domain) Reset() {
+	*x = Domain{}
+	mi := &file_geoip_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Domain) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Domain) ProtoMessage() {}
+
+func (x *Domain) ProtoReflect() protoreflect.Message {
+	mi := &file_geoip_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Domain.ProtoReflect.Descriptor instead.
+func (*Domain) Descriptor() ([]byte, []int) {
+	return file_geoip_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Domain) GetIpAddress() string {
+	if x != nil {
+		return x.IpAddress
+	}
+	return ""
+}
+
+func (x *Domain) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+func (x *Domain) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+// ISP is the lookup of an address in an ISP database.
+type ISP struct {
+	state                        protoimpl.MessageState `protogen:"open.v1"`
+	IpAddress                    string                 `protobuf:"bytes,1,opt,name=ip_address,json=ipAddress,proto3" json:"ip_address,omitempty"`
+	Network                      string                 `protobuf:"bytes,2,opt,name=network,proto3" json:"network,omitempty"`
+	AutonomousSystemNumber       uint32                 `protobuf:"varint,3,opt,name=autonomous_system_number,json=autonomousSystemNumber,proto3" json:"autonomous_system_number,omitempty"`
+	AutonomousSystemOrganization string                 `protobuf:"bytes,4,opt,name=autonomous_system_organization,json=autonomousSystemOrganization,proto3" json:"autonomous_system_organization,omitempty"`
+	Isp                          string                 `protobuf:"bytes,5,opt,name=isp,proto3" json:"isp,omitempty"`
+	MobileCountryCode            string                 `protobuf:"bytes,6,opt,name=mobile_country_code,json=mobileCountryCode,proto3" json:"mobile_country_code,omitempty"`
+	MobileNetworkCode            string                 `protobuf:"bytes,7,opt,name=mobile_network_code,json=mobileNetworkCode,proto3" json:"mobile_network_code,omitempty"`
+	Organization                 string                 `protobuf:"bytes,8,opt,name=organization,proto3" json:"organization,omitempty"`
+	unknownFields                protoimpl.UnknownFields
+	sizeCache                    protoimpl.SizeCache
+}
+
+func (x *ISP) Reset() {
+	*x = ISP{}
+	mi := &file_geoip_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ISP) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ISP) ProtoMessage() {}
+
+func (x *ISP) ProtoReflect() protoreflect.Message {
+	mi := &file_geoip_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ISP.ProtoReflect.Descriptor instead.
+func (*ISP) Descriptor() ([]byte, []int) {
+	return file_geoip_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ISP) GetIpAddress() string {
+	if x != nil {
+		return x.IpAddress
+	}
+	return ""
+}
+
+func (x *ISP) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+func (x *ISP) GetAutonomousSystemNumber() uint32 {
+	if x != nil {
+		return x.AutonomousSystemNumber
+	}
+	return 0
+}
+
+func (x *ISP) GetAutonomousSystemOrganization() string {
+	if x != nil {
+		return x.AutonomousSystemOrganization
+	}
+	return ""
+}
+
+func (x *ISP) GetIsp() string {
+	if x != nil {
+		return x.Isp
+	}
+	return ""
+}
+
+func (x *ISP) GetMobileCountryCode() string {
+	if x != nil {
+		return x.MobileCountryCode
+	}
+	return ""
+}
+
+func (x *ISP) GetMobileNetworkCode() string {
+	if x != nil {
+		return x.MobileNetworkCode
+	}
+	return ""
+}
+
+func (x *ISP) GetOrganization() string {
+	if x != nil {
+		return x.Organization
+	}
+	return ""
+}
+
+var File_geoip_proto protoreflect.FileDescriptor
+
+const file_geoip_proto_rawDesc = "" +
+	"\n" +
+	"\vgeoip.proto\x12\n" +
+	"wherego.v1\"\xb0\x01\n" +
+	"\tContinent\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x1d\n" +
+	"\n" +
+	"geoname_id\x18\x02 \x01(\rR\tgeonameId\x126\n" +
+	"\x05names\x18\x03 \x03(\v2 .wherego.v1.Continent.NamesEntryR\x05names\x1a8\n" +
+	"\n" +
+	"NamesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x84\x02\n" +
+	"\aCountry\x12\x19\n" +
+	"\biso_code\x18\x01 \x01(\tR\aisoCode\x12\x1d\n" +
+	"\n" +
+	"geoname_id\x18\x02 \x01(\rR\tgeonameId\x12/\n" +
+	"\x14is_in_european_union\x18\x03 \x01(\bR\x11isInEuropeanUnion\x124\n" +
+	"\x05names\x18\x04 \x03(\v2\x1e.wherego.v1.Country.NamesEntryR\x05names\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\x05 \x01(\rR\n" +
+	"confidence\x1a8\n" +
+	"\n" +
+	"NamesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x8e\x02\n" +
+	"\x12RepresentedCountry\x12\x19\n" +
+	"\biso_code\x18\x01 \x01(\tR\aisoCode\x12\x1d\n" +
+	"\n" +
+	"geoname_id\x18\x02 \x01(\rR\tgeonameId\x12/\n" +
+	"\x14is_in_european_union\x18\x03 \x01(\bR\x11isInEuropeanUnion\x12\x12\n" +
+	"\x04type\x18\x04 \x01(\tR\x04type\x12?\n" +
+	"\x05names\x18\x05 \x03(\v2).wherego.v1.RepresentedCountry.NamesEntryR\x05names\x1a8\n" +
+	"\n" +
+	"NamesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xdb\x01\n" +
+	"\vSubdivision\x12\x19\n" +
+	"\biso_code\x18\x01 \x01(\tR\aisoCode\x12\x1d\n" +
+	"\n" +
+	"geoname_id\x18\x02 \x01(\rR\tgeonameId\x128\n" +
+	"\x05names\x18\x03 \x03(\v2\".wherego.v1.Subdivision.NamesEntryR\x05names\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\x04 \x01(\rR\n" +
+	"confidence\x1a8\n" +
+	"\n" +
+	"NamesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xbe\x01\n" +
+	"\n" +
+	"CityRecord\x12\x1d\n" +
+	"\n" +
+	"geoname_id\x18\x01 \x01(\rR\tgeonameId\x127\n" +
+	"\x05names\x18\x02 \x03(\v2!.wherego.v1.CityRecord.NamesEntryR\x05names\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\x03 \x01(\rR\n" +
+	"confidence\x1a8\n" +
+	"\n" +
+	"NamesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"<\n" +
+	"\x06Postal\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\x02 \x01(\rR\n" +
+	"confidence\"\xce\x01\n" +
+	"\bLocation\x12\x1f\n" +
+	"\blatitude\x18\x01 \x01(\x01H\x00R\blatitude\x88\x01\x01\x12!\n" +
+	"\tlongitude\x18\x02 \x01(\x01H\x01R\tlongitude\x88\x01\x01\x12'\n" +
+	"\x0faccuracy_radius\x18\x03 \x01(\rR\x0eaccuracyRadius\x12\x1b\n" +
+	"\ttime_zone\x18\x04 \x01(\tR\btimeZone\x12\x1d\n" +
+	"\n" +
+	"metro_code\x18\x05 \x01(\rR\tmetroCodeB\v\n" +
+	"\t_latitudeB\f\n" +
+	"\n" +
+	"_longitude\"\xf6\x04\n" +
+	"\x06Traits\x12\x1d\n" +
+	"\n" +
+	"ip_address\x18\x01 \x01(\tR\tipAddress\x12\x18\n" +
+	"\anetwork\x18\x02 \x01(\tR\anetwork\x12\x1d\n" +
+	"\n" +
+	"is_anycast\x18\x03 \x01(\bR\tisAnycast\x12#\n" +
+	"\raddress_class\x18\x04 \x01(\tR\faddressClass\x12#\n" +
+	"\rembedded_ipv4\x18\x05 \x01(\tR\fembeddedIpv4\x128\n" +
+	"\x18autonomous_system_number\x18\x06 \x01(\rR\x16autonomousSystemNumber\x12D\n" +
+	"\x1eautonomous_system_organization\x18\a \x01(\tR\x1cautonomousSystemOrganization\x12'\n" +
+	"\x0fconnection_type\x18\b \x01(\tR\x0econnectionType\x12\x16\n" +
+	"\x06domain\x18\t \x01(\tR\x06domain\x12\x10\n" +
+	"\x03isp\x18\n" +
+	" \x01(\tR\x03isp\x12.\n" +
+	"\x13mobile_country_code\x18\v \x01(\tR\x11mobileCountryCode\x12.\n" +
+	"\x13mobile_network_code\x18\f \x01(\tR\x11mobileNetworkCode\x12\"\n" +
+	"\forganization\x18\r \x01(\tR\forganization\x12\x1b\n" +
+	"\tuser_type\x18\x0e \x01(\tR\buserType\x12&\n" +
+	"\x0fstatic_ip_score\x18\x0f \x01(\x01R\rstaticIpScore\x12.\n" +
+	"\x13is_legitimate_proxy\x18\x10 \x01(\bR\x11isLegitimateProxy\"\xfb\x04\n" +
+	"\x04City\x12*\n" +
+	"\x06traits\x18\x01 \x01(\v2\x12.wherego.v1.TraitsR\x06traits\x123\n" +
+	"\tcontinent\x18\x02 \x01(\v2\x15.wherego.v1.ContinentR\tcontinent\x12-\n" +
+	"\acountry\x18\x03 \x01(\v2\x13.wherego.v1.CountryR\acountry\x12B\n" +
+	"\x12registered_country\x18\x04 \x01(\v2\x13.wherego.v1.CountryR\x11registeredCountry\x12O\n" +
+	"\x13represented_country\x18\x05 \x01(\v2\x1e.wherego.v1.RepresentedCountryR\x12representedCountry\x12;\n" +
+	"\fsubdivisions\x18\x06 \x03(\v2\x17.wherego.v1.SubdivisionR\fsubdivisions\x12*\n" +
+	"\x04city\x18\a \x01(\v2\x16.wherego.v1.CityRecordR\x04city\x12*\n" +
+	"\x06postal\x18\b \x01(\v2\x12.wherego.v1.PostalR\x06postal\x120\n" +
+	"\blocation\x18\t \x01(\v2\x14.wherego.v1.LocationR\blocation\x124\n" +
+	"\x06labels\x18\n" +
+	" \x03(\v2\x1c.wherego.v1.City.LabelsEntryR\x06labels\x12\x16\n" +
+	"\x06source\x18\v \x01(\tR\x06source\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xc6\x02\n" +
+	"\vAnonymousIP\x12\x1d\n" +
+	"\n" +
+	"ip_address\x18\x01 \x01(\tR\tipAddress\x12\x18\n" +
+	"\anetwork\x18\x02 \x01(\tR\anetwork\x12!\n" +
+	"\fis_anonymous\x18\x03 \x01(\bR\visAnonymous\x12(\n" +
+	"\x10is_anonymous_vpn\x18\x04 \x01(\bR\x0eisAnonymousVpn\x12.\n" +
+	"\x13is_hosting_provider\x18\x05 \x01(\bR\x11isHostingProvider\x12&\n" +
+	"\x0fis_public_proxy\x18\x06 \x01(\bR\risPublicProxy\x120\n" +
+	"\x14is_residential_proxy\x18\a \x01(\bR\x12isResidentialProxy\x12'\n" +
+	"\x10is_tor_exit_node\x18\b \x01(\bR\risTorExitNode\"\xbe\x01\n" +
+	"\x03ASN\x12\x1d\n" +
+	"\n" +
+	"ip_address\x18\x01 \x01(\tR\tipAddress\x12\x18\n" +
+	"\anetwork\x18\x02 \x01(\tR\anetwork\x128\n" +
+	"\x18autonomous_system_number\x18\x03 \x01(\rR\x16autonomousSystemNumber\x12D\n" +
+	"\x1eautonomous_system_organization\x18\x04 \x01(\tR\x1cautonomousSystemOrganization\"r\n" +
+	"\x0eConnectionType\x12\x1d\n" +
+	"\n" +
+	"ip_address\x18\x01 \x01(\tR\tipAddress\x12\x18\n" +
+	"\anetwork\x18\x02 \x01(\tR\anetwork\x12'\n" +
+	"\x0fconnection_type\x18\x03 \x01(\tR\x0econnectionType\"Y\n" +
+	"\x06Domain\x12\x1d\n" +
+	"\n" +
+	"ip_address\x18\x01 \x01(\tR\tipAddress\x12\x18\n" +
+	"\anetwork\x18\x02 \x01(\tR\anetwork\x12\x16\n" +
+	"\x06domain\x18\x03 \x01(\tR\x06domain\"\xd4\x02\n" +
+	"\x03ISP\x12\x1d\n" +
+	"\n" +
+	"ip_address\x18\x01 \x01(\tR\tipAddress\x12\x18\n" +
+	"\anetwork\x18\x02 \x01(\tR\anetwork\x128\n" +
+	"\x18autonomous_system_number\x18\x03 \x01(\rR\x16autonomousSystemNumber\x12D\n" +
+	"\x1eautonomous_system_organization\x18\x04 \x01(\tR\x1cautonomousSystemOrganization\x12\x10\n" +
+	"\x03isp\x18\x05 \x01(\tR\x03isp\x12.\n" +
+	"\x13mobile_country_code\x18\x06 \x01(\tR\x11mobileCountryCode\x12.\n" +
+	"\x13mobile_network_code\x18\a \x01(\tR\x11mobileNetworkCode\x12\"\n" +
+	"\forganization\x18\b \x01(\tR\forganizationB0Z.github.com/gustavosett/WhereGo/internal/api/pbb\x06proto3"
+
+var (
+	file_geoip_proto_rawDescOnce sync.Once
+	file_geoip_proto_rawDescData []byte
+)
+
+func file_geoip_proto_rawDescGZIP() []byte {
+	file_geoip_proto_rawDescOnce.Do(func() {
+		file_geoip_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_geoip_proto_rawDesc), len(file_geoip_proto_rawDesc)))
+	})
+	return file_geoip_proto_rawDescData
+}
+
+var file_geoip_proto_msgTypes = make([]protoimpl.MessageInfo, 20)
+var file_geoip_proto_goTypes = []any{
+	(*Continent)(nil),          // 0: wherego.v1.Continent
+	(*Country)(nil),            // 1: wherego.v1.Country
+	(*RepresentedCountry)(nil), // 2: wherego.v1.RepresentedCountry
+	(*Subdivision)(nil),        // 3: wherego.v1.Subdivision
+	(*CityRecord)(nil),         // 4: wherego.v1.CityRecord
+	(*Postal)(nil),             // 5: wherego.v1.Postal
+	(*Location)(nil),           // 6: wherego.v1.Location
+	(*Traits)(nil),             // 7: wherego.v1.Traits
+	(*City)(nil),               // 8: wherego.v1.City
+	(*AnonymousIP)(nil),        // 9: wherego.v1.AnonymousIP
+	(*ASN)(nil),                // 10: wherego.v1.ASN
+	(*ConnectionType)(nil),     // 11: wherego.v1.ConnectionType
+	(*Domain)(nil),             // 12: wherego.v1.Domain
+	(*ISP)(nil),                // 13: wherego.v1.ISP
+	nil,                        // 14: wherego.v1.Continent.NamesEntry
+	nil,                        // 15: wherego.v1.Country.NamesEntry
+	nil,                        // 16: wherego.v1.RepresentedCountry.NamesEntry
+	nil,                        // 17: wherego.v1.Subdivision.NamesEntry
+	nil,                        // 18: wherego.v1.CityRecord.NamesEntry
+	nil,                        // 19: wherego.v1.City.LabelsEntry
+}
+var file_geoip_proto_depIdxs = []int32{
+	14, // 0: wherego.v1.Continent.names:type_name -> wherego.v1.Continent.NamesEntry
+	15, // 1: wherego.v1.Country.names:type_name -> wherego.v1.Country.NamesEntry
+	16, // 2: wherego.v1.RepresentedCountry.names:type_name -> wherego.v1.RepresentedCountry.NamesEntry
+	17, // 3: wherego.v1.Subdivision.names:type_name -> wherego.v1.Subdivision.NamesEntry
+	18, // 4: wherego.v1.CityRecord.names:type_name -> wherego.v1.CityRecord.NamesEntry
+	7,  // 5: wherego.v1.City.traits:type_name -> wherego.v1.Traits
+	0,  // 6: wherego.v1.City.continent:type_name -> wherego.v1.Continent
+	1,  // 7: wherego.v1.City.country:type_name -> wherego.v1.Country
+	1,  // 8: wherego.v1.City.registered_country:type_name -> wherego.v1.Country
+	2,  // 9: wherego.v1.City.represented_country:type_name -> wherego.v1.RepresentedCountry
+	3,  // 10: wherego.v1.City.subdivisions:type_name -> wherego.v1.Subdivision
+	4,  // 11: wherego.v1.City.city:type_name -> wherego.v1.CityRecord
+	5,  // 12: wherego.v1.City.postal:type_name -> wherego.v1.Postal
+	6,  // 13: wherego.v1.City.location:type_name -> wherego.v1.Location
+	19, // 14: wherego.v1.City.labels:type_name -> wherego.v1.City.LabelsEntry
+	15, // [15:15] is the sub-list for method output_type
+	15, // [15:15] is the sub-list for method input_type
+	15, // [15:15] is the sub-list for extension type_name
+	15, // [15:15] is the sub-list for extension extendee
+	0,  // [0:15] is the sub-list for field type_name
+}
+
+func init() { file_geoip_proto_init() }
+func file_geoip_proto_init() {
+	if File_geoip_proto != nil {
+		return
+	}
+	file_geoip_proto_msgTypes[6].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_geoip_proto_rawDesc), len(file_geoip_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   20,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_geoip_proto_goTypes,
+		DependencyIndexes: file_geoip_proto_depIdxs,
+		MessageInfos:      file_geoip_proto_msgTypes,
+	}.Build()
+	File_geoip_proto = out.File
+	file_geoip_proto_goTypes = nil
+	file_geoip_proto_depIdxs = nil
+}
@@ -0,0 +1,22 @@
+package pb
+
+import (
+	v1 "github.com/gustavosett/WhereGo/internal/api/v1"
+	"github.com/gustavosett/WhereGo/internal/format"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"google.golang.org/protobuf/proto"
+)
+
+// The Protobuf serializer of package format writes the models with the
+// messages of this package.
+func init() {
+	format.RegisterProtoMessage(func(r *v1.CityResponse) proto.Message { return FromCityResponse(r) })
+	format.RegisterProtoMessage(func(c *wherego.City) proto.Message { return FromCity(c) })
+	format.RegisterProtoMessage(func(c *wherego.Country) proto.Message { return FromCountry(c) })
+	format.RegisterProtoMessage(func(e *wherego.Enterprise) proto.Message { return FromEnterprise(e) })
+	format.RegisterProtoMessage(func(a *wherego.AnonymousIP) proto.Message { return FromAnonymousIP(a) })
+	format.RegisterProtoMessage(func(a *wherego.ASN) proto.Message { return FromASN(a) })
+	format.RegisterProtoMessage(func(c *wherego.ConnectionType) proto.Message { return FromConnectionType(c) })
+	format.RegisterProtoMessage(func(d *wherego.Domain) proto.Message { return FromDomain(d) })
+	format.RegisterProtoMessage(func(i *wherego.ISP) proto.Message { return FromISP(i) })
+}
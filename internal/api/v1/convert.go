@@ -0,0 +1,108 @@
+package v1
+
+import "github.com/gustavosett/WhereGo/pkg/wherego"
+
+// NewCityResponse converts a City lookup result to its v1 response.
+func NewCityResponse(c *wherego.City) *CityResponse {
+	r := &CityResponse{
+		Traits: Traits{
+			IsAnycast:    c.Traits.IsAnycast,
+			AddressClass: string(c.Traits.AddressClass),
+			EmbeddedIPv4: c.Traits.EmbeddedIPv4,
+		},
+		Labels: c.Labels,
+		Source: c.Source,
+	}
+	if c.Traits.IPAddress.IsValid() {
+		r.Traits.IPAddress = c.Traits.IPAddress.String()
+	}
+	if c.Traits.Network.IsValid() {
+		r.Traits.Network = c.Traits.Network.String()
+	}
+
+	if c.Continent.HasData() {
+		r.Continent = &Continent{
+			Code:      c.Continent.Code,
+			GeoNameID: c.Continent.GeoNameID,
+			Names:     newNames(c.Continent.Names),
+		}
+	}
+	r.Country = newCountry(c.Country)
+	r.RegisteredCountry = newCountry(c.RegisteredCountry)
+	if rc := c.RepresentedCountry; rc.HasData() {
+		r.RepresentedCountry = &RepresentedCountry{
+			ISOCode:           rc.ISOCode,
+			GeoNameID:         rc.GeoNameID,
+			IsInEuropeanUnion: rc.IsInEuropeanUnion,
+			Type:              rc.Type,
+			Names:             newNames(rc.Names),
+		}
+	}
+	for _, sub := range c.Subdivisions {
+		if !sub.HasData() {
+			continue
+		}
+		r.Subdivisions = append(r.Subdivisions, Subdivision{
+			ISOCode:   sub.ISOCode,
+			GeoNameID: sub.GeoNameID,
+			Names:     newNames(sub.Names),
+		})
+	}
+	if c.City.HasData() {
+		r.City = &CityRecord{GeoNameID: c.City.GeoNameID, Names: newNames(c.City.Names)}
+	}
+	if c.Postal.HasData() {
+		r.Postal = &Postal{Code: c.Postal.Code}
+	}
+	if l := c.Location; l.Latitude != nil || l.Longitude != nil || l.AccuracyRadius != 0 || l.TimeZone != "" {
+		r.Location = &Location{
+			Latitude:       l.Latitude,
+			Longitude:      l.Longitude,
+			AccuracyRadius: l.AccuracyRadius,
+			TimeZone:       l.TimeZone,
+		}
+	}
+	return r
+}
+
+// NewCountryResponse converts a City lookup result to a v1 response of
+// country precision, without the subdivisions, city, postal code and
+// location.
+func NewCountryResponse(c *wherego.City) *CityResponse {
+	r := NewCityResponse(c)
+	r.Subdivisions, r.City, r.Postal, r.Location = nil, nil, nil, nil
+	return r
+}
+
+func newCountry(c wherego.CountryRecord) *Country {
+	if !c.HasData() {
+		return nil
+	}
+	return &Country{
+		ISOCode:           c.ISOCode,
+		GeoNameID:         c.GeoNameID,
+		IsInEuropeanUnion: c.IsInEuropeanUnion,
+		Names:             newNames(c.Names),
+	}
+}
+
+func newNames(n wherego.Names) Names {
+	if !n.HasData() {
+		return nil
+	}
+	names := make(Names, 8)
+	add := func(locale, name string) {
+		if name != "" {
+			names[locale] = name
+		}
+	}
+	add("de", n.German)
+	add("en", n.English)
+	add("es", n.Spanish)
+	add("fr", n.French)
+	add("ja", n.Japanese)
+	add("pt-BR", n.BrazilianPortuguese)
+	add("ru", n.Russian)
+	add("zh-CN", n.SimplifiedChinese)
+	return names
+}
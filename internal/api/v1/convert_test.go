@@ -0,0 +1,76 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/netip"
+	"testing"
+
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCityResponse(t *testing.T) {
+	lat, lon := 52.5, 13.4
+	city := &wherego.City{
+		Traits: wherego.CityTraits{
+			IPAddress:    netip.MustParseAddr("2002:808:808::1"),
+			Network:      netip.MustParsePrefix("8.8.8.0/24"),
+			IsAnycast:    true,
+			AddressClass: wherego.AddressClass6to4,
+			EmbeddedIPv4: "8.8.8.8",
+		},
+		Continent:          wherego.Continent{Code: "EU", GeoNameID: 1, Names: wherego.Names{English: "Europe"}},
+		Country:            wherego.CountryRecord{ISOCode: "DE", GeoNameID: 2, IsInEuropeanUnion: true},
+		RepresentedCountry: wherego.RepresentedCountry{ISOCode: "US", Type: "military"},
+		Subdivisions:       []wherego.CitySubdivision{{}, {ISOCode: "BE"}},
+		City:               wherego.CityRecord{Names: wherego.Names{German: "Berlin", BrazilianPortuguese: "Berlim"}},
+		Postal:             wherego.CityPostal{Code: "10115"},
+		Location:           wherego.Location{Latitude: &lat, Longitude: &lon, MetroCode: 5},
+		Labels:             map[string]string{"dc": "ber1"},
+		Source:             wherego.SourceOverrideDatabase,
+	}
+
+	got, err := json.Marshal(NewCityResponse(city))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"traits": {
+			"ip_address": "2002:808:808::1",
+			"network": "8.8.8.0/24",
+			"is_anycast": true,
+			"address_class": "6to4",
+			"embedded_ipv4": "8.8.8.8"
+		},
+		"continent": {"code": "EU", "geoname_id": 1, "names": {"en": "Europe"}},
+		"country": {"iso_code": "DE", "geoname_id": 2, "is_in_european_union": true},
+		"represented_country": {"iso_code": "US", "type": "military"},
+		"subdivisions": [{"iso_code": "BE"}],
+		"city": {"names": {"de": "Berlin", "pt-BR": "Berlim"}},
+		"postal": {"code": "10115"},
+		"location": {"latitude": 52.5, "longitude": 13.4},
+		"labels": {"dc": "ber1"},
+		"source": "override+database"
+	}`, string(got))
+}
+
+func TestNewCityResponse_Empty(t *testing.T) {
+	got, err := json.Marshal(NewCityResponse(&wherego.City{}))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"traits": {"ip_address": ""}}`, string(got))
+}
+
+func TestNewCountryResponse(t *testing.T) {
+	lat := 52.5
+	city := &wherego.City{
+		Traits:       wherego.CityTraits{IPAddress: netip.MustParseAddr("8.8.8.8")},
+		Country:      wherego.CountryRecord{ISOCode: "DE"},
+		Subdivisions: []wherego.CitySubdivision{{ISOCode: "BE"}},
+		City:         wherego.CityRecord{Names: wherego.Names{English: "Berlin"}},
+		Postal:       wherego.CityPostal{Code: "10115"},
+		Location:     wherego.Location{Latitude: &lat},
+	}
+
+	got, err := json.Marshal(NewCountryResponse(city))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"traits": {"ip_address": "8.8.8.8"}, "country": {"iso_code": "DE"}}`, string(got))
+}
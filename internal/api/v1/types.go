@@ -0,0 +1,161 @@
+// Package v1 defines the response types of the /v1 API.
+//
+// These types are the v1 wire contract. They are deliberately separate
+// from the geoip model structs, which follow the MaxMind DB layout and may
+// change with it: a field may be added here, but never renamed, removed or
+// given a different meaning. Empty values are omitted rather than sent as
+// zero values.
+package v1
+
+// Names maps locale codes, such as "en" or "pt-BR", to localized names.
+type Names map[string]string
+
+// Continent is the continent of the location.
+type Continent struct {
+	Code      string `json:"code,omitempty"`
+	GeoNameID uint   `json:"geoname_id,omitempty"`
+	Names     Names  `json:"names,omitempty"`
+}
+
+// Country is a country record: where the address is located, or where its
+// network is registered.
+type Country struct {
+	ISOCode           string `json:"iso_code,omitempty"`
+	GeoNameID         uint   `json:"geoname_id,omitempty"`
+	IsInEuropeanUnion bool   `json:"is_in_european_union,omitempty"`
+	Names             Names  `json:"names,omitempty"`
+}
+
+// RepresentedCountry is the country represented by something like a
+// military base or embassy.
+type RepresentedCountry struct {
+	ISOCode           string `json:"iso_code,omitempty"`
+	GeoNameID         uint   `json:"geoname_id,omitempty"`
+	IsInEuropeanUnion bool   `json:"is_in_european_union,omitempty"`
+	Type              string `json:"type,omitempty"`
+	Names             Names  `json:"names,omitempty"`
+}
+
+// Subdivision is a first or second level administrative division.
+type Subdivision struct {
+	ISOCode   string `json:"iso_code,omitempty"`
+	GeoNameID uint   `json:"geoname_id,omitempty"`
+	Names     Names  `json:"names,omitempty"`
+}
+
+// CityRecord is the city of the location.
+type CityRecord struct {
+	GeoNameID uint  `json:"geoname_id,omitempty"`
+	Names     Names `json:"names,omitempty"`
+}
+
+// Postal is the postal code of the location.
+type Postal struct {
+	Code string `json:"code,omitempty"`
+}
+
+// Location is the approximate position of the address.
+type Location struct {
+	Latitude       *float64 `json:"latitude,omitempty"`
+	Longitude      *float64 `json:"longitude,omitempty"`
+	AccuracyRadius uint16   `json:"accuracy_radius,omitempty"`
+	TimeZone       string   `json:"time_zone,omitempty"`
+}
+
+// Traits describes the address itself rather than its location.
+type Traits struct {
+	IPAddress    string `json:"ip_address"`
+	Network      string `json:"network,omitempty"`
+	IsAnycast    bool   `json:"is_anycast,omitempty"`
+	AddressClass string `json:"address_class,omitempty"`
+	EmbeddedIPv4 string `json:"embedded_ipv4,omitempty"`
+}
+
+// CityResponse is the response of GET /v1/lookup/{ip}.
+type CityResponse struct {
+	Traits             Traits              `json:"traits"`
+	Continent          *Continent          `json:"continent,omitempty"`
+	Country            *Country            `json:"country,omitempty"`
+	RegisteredCountry  *Country            `json:"registered_country,omitempty"`
+	RepresentedCountry *RepresentedCountry `json:"represented_country,omitempty"`
+	Subdivisions       []Subdivision       `json:"subdivisions,omitempty"`
+	City               *CityRecord         `json:"city,omitempty"`
+	Postal             *Postal             `json:"postal,omitempty"`
+	Location           *Location           `json:"location,omitempty"`
+	Labels             map[string]string   `json:"labels,omitempty"`
+	// Source is "database", "override" or "override+database" when the
+	// server has overrides configured.
+	Source string `json:"source,omitempty"`
+}
+
+// StreamResult is one line of the response of POST /v1/lookup/stream, for
+// one input line.
+type StreamResult struct {
+	// Input is the input line, without surrounding white space.
+	Input string `json:"input"`
+	// Result is the lookup of Input, unless it failed.
+	Result *CityResponse `json:"result,omitempty"`
+	// Record is the lookup of Input in a database of a custom type, which
+	// has no fixed schema.
+	Record map[string]any `json:"record,omitempty"`
+	// Error tells why the lookup of Input failed.
+	Error *StreamError `json:"error,omitempty"`
+}
+
+// StreamError is a failed lookup in a stream. Its members mean the same as
+// in the problem details of a single lookup.
+type StreamError struct {
+	Status       int    `json:"status"`
+	Code         string `json:"code"`
+	Detail       string `json:"detail,omitempty"`
+	AddressClass string `json:"address_class,omitempty"`
+}
+
+// SocketRequest is a lookup sent as a text message over the /v1/ws
+// WebSocket.
+type SocketRequest struct {
+	// ID is sent back unchanged in the response, to match responses to
+	// requests. It may be any JSON value.
+	ID any `json:"id,omitempty"`
+	// IP is the address to look up.
+	IP string `json:"ip"`
+	// Type is the lookup to make: "city", the default, "country" for a
+	// result without the subdivisions, city, postal code and location, or
+	// "record" for the database record as stored.
+	Type string `json:"type,omitempty"`
+}
+
+// SocketResponse is the message sent back for a SocketRequest. Responses
+// are sent in request order.
+type SocketResponse struct {
+	ID   any    `json:"id,omitempty"`
+	Type string `json:"type,omitempty"`
+	// Result is the city or country lookup, unless it failed.
+	Result *CityResponse `json:"result,omitempty"`
+	// Record is the record lookup, or the lookup of any type in a database
+	// of a custom type, which has no fixed schema.
+	Record map[string]any `json:"record,omitempty"`
+	// Error tells why the lookup failed.
+	Error *StreamError `json:"error,omitempty"`
+}
+
+// EvaluateRequest is the body of POST /v1/evaluate.
+type EvaluateRequest struct {
+	// IP is the address to evaluate. The caller's address is evaluated
+	// when it is empty.
+	IP string `json:"ip,omitempty"`
+}
+
+// Decision is the response of POST /v1/evaluate: whether the geo-fencing
+// policy allows an address. Allowed and Default are always sent.
+type Decision struct {
+	IP      string `json:"ip"`
+	Allowed bool   `json:"allowed"`
+	// Action is "allow" or "deny".
+	Action string `json:"action"`
+	// Rule is the name of the rule that matched.
+	Rule string `json:"rule,omitempty"`
+	// Default is true when no rule matched and the policy's default action
+	// applied.
+	Default bool `json:"default"`
+}
@@ -0,0 +1,385 @@
+// Package auth authenticates API keys and enforces their quotas.
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// hashPrefix prefixes the hashes in the keys file, so other algorithms can
+// be added later without ambiguity.
+const hashPrefix = "sha256:"
+
+var (
+	// ErrRouteNotAllowed is returned by Keys.Use when the key may not call
+	// the route.
+	ErrRouteNotAllowed = errors.New("route not allowed for API key")
+	// ErrQuotaExceeded is returned by Keys.Use when the daily or monthly
+	// quota of the key is used up.
+	ErrQuotaExceeded = errors.New("API key quota exceeded")
+)
+
+// HashKey returns the hash of an API key as stored in the keys file.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hashPrefix + hex.EncodeToString(sum[:])
+}
+
+// Key is an API key as configured in the keys file.
+type Key struct {
+	// Name identifies the key in usage reports.
+	Name string `yaml:"name"`
+	// Hash is the HashKey of the secret key.
+	Hash string `yaml:"hash"`
+	// Routes restricts the key to these route paths, such as
+	// "/v1/lookup/:ip". A trailing "*" matches any suffix. An empty list
+	// allows every route.
+	Routes []string `yaml:"routes"`
+	// DailyQuota and MonthlyQuota limit the requests per UTC calendar day
+	// and month. Zero means unlimited.
+	DailyQuota   int64 `yaml:"daily_quota"`
+	MonthlyQuota int64 `yaml:"monthly_quota"`
+	// Admin grants access to the admin endpoints.
+	Admin bool `yaml:"admin"`
+	// AccountID is the account ID MaxMind web service clients must send
+	// with the key as their license key. Empty accepts any account ID.
+	AccountID string `yaml:"account_id"`
+}
+
+// AllowsRoute reports whether the key may call the route with the given
+// path pattern.
+func (k *Key) AllowsRoute(route string) bool {
+	if len(k.Routes) == 0 {
+		return true
+	}
+	for _, pattern := range k.Routes {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(route, prefix) {
+				return true
+			}
+		} else if route == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// ResetsAt returns when the quota that refused a request at time now
+// resets: the next UTC midnight if the daily quota is used up, otherwise
+// the start of the next UTC month.
+func (k *Key) ResetsAt(u Usage, now time.Time) time.Time {
+	now = now.UTC()
+	if k.DailyQuota > 0 && u.Daily >= k.DailyQuota {
+		return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Usage reports the requests made with a key. Counters are kept in memory
+// and start from zero when the server starts.
+type Usage struct {
+	Name string `json:"name"`
+	// Day is the current UTC day, formatted as 2006-01-02.
+	Day        string `json:"day"`
+	Daily      int64  `json:"daily"`
+	DailyQuota int64  `json:"daily_quota,omitempty"`
+	// Month is the current UTC month, formatted as 2006-01.
+	Month        string `json:"month"`
+	Monthly      int64  `json:"monthly"`
+	MonthlyQuota int64  `json:"monthly_quota,omitempty"`
+	// Total counts the accepted requests since the server started.
+	Total int64 `json:"total"`
+	// Rejected counts the requests refused for quota or route reasons.
+	Rejected int64 `json:"rejected"`
+}
+
+// Remaining returns the requests left before the daily or monthly quota,
+// whichever runs out first, is used up. It reports false when the key has
+// no quota.
+func (u Usage) Remaining() (int64, bool) {
+	remaining, limited := int64(0), false
+	if u.DailyQuota > 0 {
+		remaining, limited = max(u.DailyQuota-u.Daily, 0), true
+	}
+	if u.MonthlyQuota > 0 {
+		left := max(u.MonthlyQuota-u.Monthly, 0)
+		if !limited || left < remaining {
+			remaining = left
+		}
+		limited = true
+	}
+	return remaining, limited
+}
+
+type counter struct {
+	day, month     string
+	daily, monthly int64
+	total          int64
+	rejected       int64
+}
+
+// roll resets the counters whose period has ended.
+func (c *counter) roll(now time.Time) {
+	now = now.UTC()
+	if day := now.Format(time.DateOnly); c.day != day {
+		c.day, c.daily = day, 0
+	}
+	if month := now.Format("2006-01"); c.month != month {
+		c.month, c.monthly = month, 0
+	}
+}
+
+// Keys is a set of API keys loaded from a YAML or JSON file:
+//
+//	keys:
+//	  - name: payments
+//	    hash: sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+//	    routes: ["/v1/*"]
+//	    daily_quota: 100000
+//	    monthly_quota: 2000000
+//	  - name: ops
+//	    hash: sha256:...
+//	    admin: true
+//
+// Only hashes of the keys are stored, see HashKey. Reload and Watch
+// replace the keys atomically; usage counters are kept by key name, so they
+// survive reloads.
+type Keys struct {
+	path  string
+	table atomic.Pointer[keyTable]
+
+	// mu serializes reloads.
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+
+	usageMu sync.Mutex
+	usage   map[string]*counter
+}
+
+type keyTable struct {
+	byHash map[[sha256.Size]byte]*Key
+	// names is sorted.
+	names []string
+}
+
+type keysFile struct {
+	Keys []*Key `yaml:"keys"`
+}
+
+// LoadKeys reads the keys file at path.
+func LoadKeys(path string) (*Keys, error) {
+	k := &Keys{path: path, usage: make(map[string]*counter)}
+	if err := k.Reload(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Path returns the file the keys are loaded from.
+func (k *Keys) Path() string {
+	return k.path
+}
+
+// Len returns the number of keys.
+func (k *Keys) Len() int {
+	return len(k.table.Load().names)
+}
+
+// Reload reads the keys file again. On error the current keys stay in
+// place.
+func (k *Keys) Reload() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	info, err := os.Stat(k.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(k.path)
+	if err != nil {
+		return err
+	}
+	table, err := parseKeys(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", k.path, err)
+	}
+	k.table.Store(table)
+	k.modTime, k.size = info.ModTime(), info.Size()
+	return nil
+}
+
+// Watch polls the keys file every interval and reloads it when its
+// modification time or size changes, until ctx is done. Reload errors are
+// passed to onError, which may be nil.
+func (k *Keys) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !k.changed() {
+			continue
+		}
+		if err := k.Reload(); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+func (k *Keys) changed() bool {
+	info, err := os.Stat(k.path)
+	if err != nil {
+		// Report the error through Reload.
+		return true
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return !info.ModTime().Equal(k.modTime) || info.Size() != k.size
+}
+
+// Authenticate returns the key matching secret. The returned Key must not
+// be modified.
+func (k *Keys) Authenticate(secret string) (*Key, bool) {
+	if secret == "" {
+		return nil, false
+	}
+	key, ok := k.table.Load().byHash[sha256.Sum256([]byte(secret))]
+	return key, ok
+}
+
+// Use records a request by key to route at time now. It returns
+// ErrRouteNotAllowed or ErrQuotaExceeded, without counting the request
+// against the quota, when the request must be refused.
+func (k *Keys) Use(key *Key, route string, now time.Time) (Usage, error) {
+	k.usageMu.Lock()
+	defer k.usageMu.Unlock()
+
+	c := k.usage[key.Name]
+	if c == nil {
+		c = &counter{}
+		k.usage[key.Name] = c
+	}
+	c.roll(now)
+
+	var err error
+	switch {
+	case !key.AllowsRoute(route):
+		err = ErrRouteNotAllowed
+	case key.DailyQuota > 0 && c.daily >= key.DailyQuota,
+		key.MonthlyQuota > 0 && c.monthly >= key.MonthlyQuota:
+		err = ErrQuotaExceeded
+	}
+	if err != nil {
+		c.rejected++
+	} else {
+		c.daily++
+		c.monthly++
+		c.total++
+	}
+	return c.report(key), err
+}
+
+// Usage returns the usage of every configured key at time now, sorted by
+// name.
+func (k *Keys) Usage(now time.Time) []Usage {
+	table := k.table.Load()
+	byName := make(map[string]*Key, len(table.byHash))
+	for _, key := range table.byHash {
+		byName[key.Name] = key
+	}
+
+	k.usageMu.Lock()
+	defer k.usageMu.Unlock()
+	usage := make([]Usage, 0, len(table.names))
+	for _, name := range table.names {
+		c := k.usage[name]
+		if c == nil {
+			c = &counter{}
+			k.usage[name] = c
+		}
+		c.roll(now)
+		usage = append(usage, c.report(byName[name]))
+	}
+	return usage
+}
+
+func (c *counter) report(key *Key) Usage {
+	return Usage{
+		Name:         key.Name,
+		Day:          c.day,
+		Daily:        c.daily,
+		DailyQuota:   key.DailyQuota,
+		Month:        c.month,
+		Monthly:      c.monthly,
+		MonthlyQuota: key.MonthlyQuota,
+		Total:        c.total,
+		Rejected:     c.rejected,
+	}
+}
+
+func parseKeys(data []byte) (*keyTable, error) {
+	var file keysFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil {
+		return nil, err
+	}
+
+	table := &keyTable{byHash: make(map[[sha256.Size]byte]*Key, len(file.Keys))}
+	seen := make(map[string]bool, len(file.Keys))
+	for i, key := range file.Keys {
+		if key == nil || key.Name == "" {
+			return nil, fmt.Errorf("key %d: missing name", i)
+		}
+		if seen[key.Name] {
+			return nil, fmt.Errorf("key %q: duplicate name", key.Name)
+		}
+		seen[key.Name] = true
+
+		hash, err := parseHash(key.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", key.Name, err)
+		}
+		if _, ok := table.byHash[hash]; ok {
+			return nil, fmt.Errorf("key %q: duplicate hash", key.Name)
+		}
+		if key.DailyQuota < 0 || key.MonthlyQuota < 0 {
+			return nil, fmt.Errorf("key %q: negative quota", key.Name)
+		}
+		table.byHash[hash] = key
+		table.names = append(table.names, key.Name)
+	}
+	sort.Strings(table.names)
+	return table, nil
+}
+
+func parseHash(s string) ([sha256.Size]byte, error) {
+	var hash [sha256.Size]byte
+	digest, ok := strings.CutPrefix(s, hashPrefix)
+	if !ok {
+		return hash, fmt.Errorf("hash %q: want %s followed by the hex digest", s, hashPrefix)
+	}
+	if len(digest) != hex.EncodedLen(sha256.Size) {
+		return hash, fmt.Errorf("hash %q: invalid SHA-256 hex digest", s)
+	}
+	if _, err := hex.Decode(hash[:], []byte(digest)); err != nil {
+		return hash, fmt.Errorf("hash %q: invalid SHA-256 hex digest", s)
+	}
+	return hash, nil
+}
@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var sampleKeys = fmt.Sprintf(`
+keys:
+  - name: payments
+    hash: %s
+    routes: ["/v1/*"]
+    daily_quota: 2
+    monthly_quota: 3
+  - name: ops
+    hash: %s
+    admin: true
+`, HashKey("payments-secret"), HashKey("ops-secret"))
+
+func writeKeys(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestHashKey(t *testing.T) {
+	assert.Equal(t, "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", HashKey("test"))
+}
+
+func TestLoadKeys(t *testing.T) {
+	k, err := LoadKeys(writeKeys(t, sampleKeys))
+	require.NoError(t, err)
+	assert.Equal(t, 2, k.Len())
+
+	tests := []struct {
+		name   string
+		secret string
+		want   string
+	}{
+		{"Known Key", "payments-secret", "payments"},
+		{"Admin Key", "ops-secret", "ops"},
+		{"Unknown Key", "nope", ""},
+		{"Empty Key", "", ""},
+		{"Hash Is Not A Key", HashKey("ops-secret"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, ok := k.Authenticate(tt.secret)
+			assert.Equal(t, tt.want != "", ok)
+			if ok {
+				assert.Equal(t, tt.want, key.Name)
+			}
+		})
+	}
+}
+
+func TestLoadKeys_Invalid(t *testing.T) {
+	hash := HashKey("secret")
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"Missing Name", "keys: [{hash: " + hash + "}]"},
+		{"Duplicate Name", "keys: [{name: a, hash: " + hash + "}, {name: a, hash: " + HashKey("other") + "}]"},
+		{"Duplicate Hash", "keys: [{name: a, hash: " + hash + "}, {name: b, hash: " + hash + "}]"},
+		{"Plain Text Key", "keys: [{name: a, hash: secret}]"},
+		{"Short Digest", "keys: [{name: a, hash: sha256:abcd}]"},
+		{"Long Digest", "keys: [{name: a, hash: " + hash + "00}]"},
+		{"Not Hex", "keys: [{name: a, hash: sha256:" + strings.Repeat("z", 64) + "}]"},
+		{"Negative Quota", "keys: [{name: a, hash: " + hash + ", daily_quota: -1}]"},
+		{"Unknown Field", "keys: [{name: a, hash: " + hash + ", quota: 5}]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadKeys(writeKeys(t, tt.content))
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := LoadKeys(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestKey_AllowsRoute(t *testing.T) {
+	key := &Key{Routes: []string{"/v1/*", "/lookup/:ip"}}
+	assert.True(t, key.AllowsRoute("/v1/lookup/:ip"))
+	assert.True(t, key.AllowsRoute("/lookup/:ip"))
+	assert.False(t, key.AllowsRoute("/lookup"))
+	assert.False(t, key.AllowsRoute("/admin/usage"))
+	assert.True(t, (&Key{}).AllowsRoute("/admin/usage"), "no routes allow every route")
+}
+
+func TestKeys_Use(t *testing.T) {
+	k, err := LoadKeys(writeKeys(t, sampleKeys))
+	require.NoError(t, err)
+	key, ok := k.Authenticate("payments-secret")
+	require.True(t, ok)
+
+	day1 := time.Date(2026, time.January, 31, 23, 0, 0, 0, time.UTC)
+	day2 := day1.Add(2 * time.Hour)
+
+	_, err = k.Use(key, "/lookup/:ip", day1)
+	assert.ErrorIs(t, err, ErrRouteNotAllowed)
+
+	for range 2 {
+		_, err = k.Use(key, "/v1/lookup/:ip", day1)
+		require.NoError(t, err)
+	}
+	usage, err := k.Use(key, "/v1/lookup/:ip", day1)
+	assert.ErrorIs(t, err, ErrQuotaExceeded, "daily quota")
+	assert.Equal(t, day1.Add(time.Hour), key.ResetsAt(usage, day1))
+
+	// The next day is in the next month, which resets both counters.
+	for range 2 {
+		_, err = k.Use(key, "/v1/lookup/:ip", day2)
+		require.NoError(t, err)
+	}
+	usage, err = k.Use(key, "/v1/lookup/:ip", day2)
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	assert.Equal(t, Usage{
+		Name: "payments", Day: "2026-02-01", Daily: 2, DailyQuota: 2,
+		Month: "2026-02", Monthly: 2, MonthlyQuota: 3, Total: 4, Rejected: 3,
+	}, usage)
+
+	// The monthly quota outlasts the day.
+	day3 := day2.Add(24 * time.Hour)
+	_, err = k.Use(key, "/v1/lookup/:ip", day3)
+	require.NoError(t, err)
+	usage, err = k.Use(key, "/v1/lookup/:ip", day3)
+	assert.ErrorIs(t, err, ErrQuotaExceeded, "monthly quota")
+	assert.Equal(t, time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC), key.ResetsAt(usage, day3))
+}
+
+func TestKeys_Usage(t *testing.T) {
+	path := writeKeys(t, sampleKeys)
+	k, err := LoadKeys(path)
+	require.NoError(t, err)
+	now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+
+	key, _ := k.Authenticate("ops-secret")
+	_, err = k.Use(key, "/admin/usage", now)
+	require.NoError(t, err)
+
+	usage := k.Usage(now)
+	require.Len(t, usage, 2)
+	assert.Equal(t, "ops", usage[0].Name)
+	assert.Equal(t, int64(1), usage[0].Total)
+	assert.Equal(t, "payments", usage[1].Name, "unused keys are listed")
+	assert.Equal(t, "2026-10-19", usage[1].Day)
+	assert.Zero(t, usage[1].Total)
+
+	// Counters survive reloads, removed keys are no longer listed.
+	require.NoError(t, os.WriteFile(path, []byte("keys: [{name: ops, hash: "+HashKey("new-ops-secret")+"}]"), 0o600))
+	require.NoError(t, k.Reload())
+	_, ok := k.Authenticate("ops-secret")
+	assert.False(t, ok, "the old secret is revoked")
+	usage = k.Usage(now)
+	require.Len(t, usage, 1)
+	assert.Equal(t, int64(1), usage[0].Total)
+}
+
+func TestUsage_Remaining(t *testing.T) {
+	tests := []struct {
+		name        string
+		usage       Usage
+		want        int64
+		wantLimited bool
+	}{
+		{name: "Unlimited", usage: Usage{Daily: 5, Monthly: 5}},
+		{name: "Daily", usage: Usage{Daily: 3, DailyQuota: 10, Monthly: 3}, want: 7, wantLimited: true},
+		{name: "Monthly", usage: Usage{Daily: 3, Monthly: 95, MonthlyQuota: 100}, want: 5, wantLimited: true},
+		{name: "Lower Of Both", usage: Usage{Daily: 3, DailyQuota: 10, Monthly: 98, MonthlyQuota: 100}, want: 2, wantLimited: true},
+		{name: "Used Up", usage: Usage{Daily: 12, DailyQuota: 10}, want: 0, wantLimited: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, limited := tt.usage.Remaining()
+			assert.Equal(t, tt.wantLimited, limited)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestKeys_Watch(t *testing.T) {
+	path := writeKeys(t, sampleKeys)
+	k, err := LoadKeys(path)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 10)
+	go k.Watch(ctx, 10*time.Millisecond, func(err error) { errs <- err })
+
+	require.NoError(t, os.WriteFile(path, []byte("keys: [{name: a, hash: "+HashKey("a")+"}]"), 0o600))
+	assert.Eventually(t, func() bool {
+		_, ok := k.Authenticate("a")
+		return ok
+	}, 2*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, os.WriteFile(path, []byte("keys: [{name: a}]"), 0o600))
+	select {
+	case err := <-errs:
+		assert.Error(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("reload error not reported")
+	}
+	_, ok := k.Authenticate("a")
+	assert.True(t, ok, "the previous keys stay in place")
+}
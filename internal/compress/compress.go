@@ -0,0 +1,305 @@
+// Package compress provides content-negotiated response compression for
+// Echo.
+//
+// The middleware picks zstd, gzip or brotli from the request's
+// Accept-Encoding header. Responses are buffered until they reach
+// Config.MinLength, so small bodies, which gain little and cost CPU, go out
+// uncompressed. Larger and streamed responses are compressed on the fly:
+// a handler that flushes starts compression right away and every flush
+// reaches the client.
+package compress
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// Content codings supported by the middleware.
+const (
+	Zstd   = "zstd"
+	Brotli = "br"
+	Gzip   = "gzip"
+)
+
+// DefaultMinLength is the default Config.MinLength. Below about a
+// kilobyte, the bytes saved do not pay for the CPU time.
+const DefaultMinLength = 1024
+
+// Config configures the middleware.
+type Config struct {
+	// Skipper skips the middleware for some requests.
+	Skipper middleware.Skipper
+	// MinLength is the smallest body, in bytes, that is compressed. Zero
+	// means DefaultMinLength.
+	MinLength int
+	// Encodings lists the content codings to offer, in order of
+	// preference when the client accepts several equally. Nil means
+	// DefaultEncodings.
+	Encodings []string
+}
+
+// DefaultEncodings is the preference order used when Config.Encodings is
+// nil. zstd compresses about as well as brotli at a fraction of the CPU
+// cost, so brotli comes last and only serves clients that accept nothing
+// else; see BenchmarkMiddleware.
+var DefaultEncodings = []string{Zstd, Gzip, Brotli}
+
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// The levels favor speed: responses are compressed on the request path.
+var encoderPools = map[string]*sync.Pool{
+	Zstd: {New: func() any {
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest),
+			zstd.WithEncoderConcurrency(1), zstd.WithWindowSize(1<<16), zstd.WithLowerEncoderMem(true))
+		return w
+	}},
+	Brotli: {New: func() any {
+		return brotli.NewWriterOptions(nil, brotli.WriterOptions{Quality: 4, LGWin: 16})
+	}},
+	Gzip: {New: func() any {
+		w, _ := gzip.NewWriterLevel(nil, gzip.BestSpeed)
+		return w
+	}},
+}
+
+// Middleware returns a compression middleware. It panics on an unknown
+// encoding.
+func Middleware(config Config) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	if config.MinLength <= 0 {
+		config.MinLength = DefaultMinLength
+	}
+	if config.Encodings == nil {
+		config.Encodings = DefaultEncodings
+	}
+	for _, encoding := range config.Encodings {
+		if encoderPools[encoding] == nil {
+			panic("compress: unknown encoding " + strconv.Quote(encoding))
+		}
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+			res := c.Response()
+			res.Header().Add(echo.HeaderVary, echo.HeaderAcceptEncoding)
+			encoding := Negotiate(c.Request().Header.Get(echo.HeaderAcceptEncoding), config.Encodings)
+			if encoding == "" || c.Request().Method == http.MethodHead {
+				return next(c)
+			}
+
+			w := &responseWriter{
+				ResponseWriter: res.Writer,
+				encoding:       encoding,
+				minLength:      config.MinLength,
+			}
+			res.Writer = w
+			defer func() {
+				res.Writer = w.ResponseWriter
+			}()
+
+			err := next(c)
+			if closeErr := w.Close(); err == nil {
+				err = closeErr
+			}
+			return err
+		}
+	}
+}
+
+// Negotiate returns the content coding from offers that best matches an
+// Accept-Encoding header, or "" if none is acceptable. Higher quality
+// values win; ties go to the coding listed first in offers.
+func Negotiate(acceptEncoding string, offers []string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q := quality(acceptEncoding, offer)
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// quality returns the quality value the header assigns to coding, falling
+// back to the "*" entry.
+func quality(header, coding string) float64 {
+	wildcard := -1.0
+	for header != "" {
+		var entry string
+		entry, header, _ = strings.Cut(header, ",")
+		name, params, _ := strings.Cut(entry, ";")
+		name = strings.TrimSpace(name)
+		if !strings.EqualFold(name, coding) && name != "*" {
+			continue
+		}
+		q := 1.0
+		for params != "" {
+			var param string
+			param, params, _ = strings.Cut(params, ";")
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(key, "q") {
+				if v, err := strconv.ParseFloat(value, 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if name != "*" {
+			return q
+		}
+		wildcard = q
+	}
+	return max(wildcard, 0)
+}
+
+// compressible reports whether responses of the given media type are worth
+// compressing. Images, archives and other binary formats are usually
+// compressed already.
+func compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	for _, suffix := range []string{"json", "xml", "javascript", "yaml", "csv", "ndjson", "x-protobuf"} {
+		if strings.HasSuffix(mediaType, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// responseWriter buffers the start of a response until it knows whether
+// to compress it.
+type responseWriter struct {
+	http.ResponseWriter
+	encoding  string
+	minLength int
+
+	// status is the status passed to WriteHeader before the decision.
+	status  int
+	buf     []byte
+	decided bool
+	enc     encoder
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.decided {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status = status
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		if len(w.buf)+len(p) < w.minLength {
+			w.buf = append(w.buf, p...)
+			return len(p), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	if w.enc != nil {
+		return w.enc.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// decide sends the header, compressing the body if it is worth it, and
+// writes out the buffered start of the body.
+func (w *responseWriter) decide(large bool) error {
+	w.decided = true
+	header := w.ResponseWriter.Header()
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if large && status != http.StatusNoContent && status != http.StatusNotModified &&
+		header.Get(echo.HeaderContentEncoding) == "" && compressible(header.Get(echo.HeaderContentType)) {
+		header.Set(echo.HeaderContentEncoding, w.encoding)
+		header.Del(echo.HeaderContentLength)
+		w.enc = encoderPools[w.encoding].Get().(encoder)
+		w.enc.Reset(w.ResponseWriter)
+	}
+	if w.status != 0 || len(w.buf) > 0 || large {
+		w.ResponseWriter.WriteHeader(status)
+	}
+	if len(w.buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.enc != nil {
+		_, err = w.enc.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+	return err
+}
+
+// Flush starts compression, since a flushing handler streams a response of
+// unknown length, and sends everything written so far to the client.
+func (w *responseWriter) Flush() {
+	if !w.decided {
+		if err := w.decide(true); err != nil {
+			return
+		}
+	}
+	if w.enc != nil {
+		if err := w.enc.Flush(); err != nil {
+			return
+		}
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Close writes out a response that stayed under the threshold and
+// finishes the compressed stream otherwise.
+func (w *responseWriter) Close() error {
+	if !w.decided {
+		return w.decide(false)
+	}
+	if w.enc == nil {
+		return nil
+	}
+	err := w.enc.Close()
+	w.enc.Reset(nil)
+	encoderPools[w.encoding].Put(w.enc)
+	w.enc = nil
+	return err
+}
+
+// Hijack lets protocol upgrades, such as WebSocket, bypass compression.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.decided = true
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Unwrap supports http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
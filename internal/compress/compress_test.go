@@ -0,0 +1,211 @@
+package compress
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		want           string
+	}{
+		{"None", "", ""},
+		{"Identity Only", "identity", ""},
+		{"Gzip", "gzip, deflate", Gzip},
+		{"Server Preference On Ties", "gzip, br, zstd", Zstd},
+		{"Browser", "gzip, deflate, br", Gzip},
+		{"Brotli Only", "br, deflate", Brotli},
+		{"Quality Wins", "zstd;q=0.5, gzip;q=0.8", Gzip},
+		{"Refused", "zstd;q=0, br;q=0, gzip", Gzip},
+		{"Wildcard", "*", Zstd},
+		{"Wildcard With Exclusion", "*;q=0.5, zstd;q=0", Gzip},
+		{"Case Insensitive", "GZIP", Gzip},
+		{"Unparsable Quality", "gzip;q=high", Gzip},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Negotiate(tt.acceptEncoding, DefaultEncodings))
+		})
+	}
+	assert.Equal(t, Gzip, Negotiate("zstd, gzip", []string{Gzip}), "only offered encodings")
+}
+
+func decode(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+	var r io.Reader
+	switch encoding {
+	case Zstd:
+		d, err := zstd.NewReader(bytes.NewReader(body))
+		require.NoError(t, err)
+		defer d.Close()
+		r = d
+	case Brotli:
+		r = brotli.NewReader(bytes.NewReader(body))
+	case Gzip:
+		d, err := gzip.NewReader(bytes.NewReader(body))
+		require.NoError(t, err)
+		r = d
+	default:
+		return string(body)
+	}
+	decoded, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(decoded)
+}
+
+func serve(t *testing.T, config Config, req *http.Request, handler echo.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+	e := echo.New()
+	e.Use(Middleware(config))
+	e.Any("/", handler)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddleware(t *testing.T) {
+	large := `{"names":"` + strings.Repeat("Nordamerika North America ", 100) + `"}`
+	small := `{"status":"ok"}`
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		body           string
+		wantEncoding   string
+	}{
+		{"Zstd", "zstd, br, gzip", echo.MIMEApplicationJSON, large, Zstd},
+		{"Brotli", "br", echo.MIMEApplicationJSON, large, Brotli},
+		{"Gzip", "gzip", echo.MIMEApplicationJSON, large, Gzip},
+		{"Problem JSON", "gzip", "application/problem+json", large, Gzip},
+		{"Text", "gzip", echo.MIMETextPlainCharsetUTF8, large, Gzip},
+		{"Under Threshold", "gzip", echo.MIMEApplicationJSON, small, ""},
+		{"Not Accepted", "", echo.MIMEApplicationJSON, large, ""},
+		{"Incompressible Type", "gzip", "image/png", large, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(echo.HeaderAcceptEncoding, tt.acceptEncoding)
+			rec := serve(t, Config{}, req, func(c echo.Context) error {
+				return c.Blob(http.StatusCreated, tt.contentType, []byte(tt.body))
+			})
+
+			assert.Equal(t, http.StatusCreated, rec.Code)
+			assert.Equal(t, tt.wantEncoding, rec.Header().Get(echo.HeaderContentEncoding))
+			assert.Equal(t, echo.HeaderAcceptEncoding, rec.Header().Get(echo.HeaderVary))
+			assert.Equal(t, tt.body, decode(t, tt.wantEncoding, rec.Body.Bytes()))
+			if tt.wantEncoding != "" {
+				assert.Less(t, rec.Body.Len(), len(tt.body))
+			}
+		})
+	}
+}
+
+func TestMiddleware_MinLength(t *testing.T) {
+	body := strings.Repeat("a", 100)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(echo.HeaderAcceptEncoding, "gzip")
+	rec := serve(t, Config{MinLength: 50, Encodings: []string{Gzip}}, req, func(c echo.Context) error {
+		return c.String(http.StatusOK, body)
+	})
+	assert.Equal(t, Gzip, rec.Header().Get(echo.HeaderContentEncoding))
+	assert.Equal(t, body, decode(t, Gzip, rec.Body.Bytes()))
+}
+
+func TestMiddleware_PassThrough(t *testing.T) {
+	large := strings.Repeat("x", 4096)
+
+	t.Run("Already Encoded", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(echo.HeaderAcceptEncoding, "gzip")
+		rec := serve(t, Config{}, req, func(c echo.Context) error {
+			c.Response().Header().Set(echo.HeaderContentEncoding, "custom")
+			return c.String(http.StatusOK, large)
+		})
+		assert.Equal(t, "custom", rec.Header().Get(echo.HeaderContentEncoding))
+		assert.Equal(t, large, rec.Body.String())
+	})
+
+	t.Run("Not Modified", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(echo.HeaderAcceptEncoding, "gzip")
+		rec := serve(t, Config{}, req, func(c echo.Context) error {
+			return c.NoContent(http.StatusNotModified)
+		})
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+		assert.Empty(t, rec.Header().Get(echo.HeaderContentEncoding))
+		assert.Zero(t, rec.Body.Len())
+	})
+
+	t.Run("Head", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodHead, "/", nil)
+		req.Header.Set(echo.HeaderAcceptEncoding, "gzip")
+		rec := serve(t, Config{}, req, func(c echo.Context) error {
+			return c.NoContent(http.StatusOK)
+		})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get(echo.HeaderContentEncoding))
+	})
+
+	t.Run("Error Handler", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(echo.HeaderAcceptEncoding, "gzip")
+		rec := serve(t, Config{}, req, func(c echo.Context) error {
+			return echo.NewHTTPError(http.StatusTeapot, "short and stout")
+		})
+		assert.Equal(t, http.StatusTeapot, rec.Code)
+		assert.Contains(t, rec.Body.String(), "short and stout")
+	})
+}
+
+func TestMiddleware_Streaming(t *testing.T) {
+	for _, encoding := range DefaultEncodings {
+		t.Run(encoding, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(echo.HeaderAcceptEncoding, encoding)
+
+			var flushed []int
+			rec := httptest.NewRecorder()
+			e := echo.New()
+			e.Use(Middleware(Config{}))
+			e.GET("/", func(c echo.Context) error {
+				c.Response().Header().Set(echo.HeaderContentType, "application/x-ndjson")
+				c.Response().WriteHeader(http.StatusOK)
+				for i := range 3 {
+					_, err := c.Response().Write([]byte(`{"line":` + strconv.Itoa(i) + "}\n"))
+					require.NoError(t, err)
+					c.Response().Flush()
+					flushed = append(flushed, rec.Body.Len())
+				}
+				return nil
+			})
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, encoding, rec.Header().Get(echo.HeaderContentEncoding), "flushing starts compression")
+			assert.True(t, rec.Flushed)
+			for i := 1; i < len(flushed); i++ {
+				assert.Greater(t, flushed[i], flushed[i-1], "every flush reaches the client")
+			}
+			assert.Equal(t, "{\"line\":0}\n{\"line\":1}\n{\"line\":2}\n", decode(t, encoding, rec.Body.Bytes()))
+		})
+	}
+}
+
+func TestMiddleware_UnknownEncoding(t *testing.T) {
+	assert.Panics(t, func() { Middleware(Config{Encodings: []string{"deflate"}}) })
+}
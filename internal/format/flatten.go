@@ -0,0 +1,252 @@
+package format
+
+import (
+	"encoding"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Field is one value of a flattened response.
+type Field struct {
+	// Key is the dotted path of the value, such as "city.names.en" or
+	// "subdivisions.0.iso_code".
+	Key   string
+	Value string
+}
+
+// Flatten turns v into a list of dotted keys and their values, in struct
+// field order with map keys sorted. Every struct field is listed, with an
+// empty value when it is nil or omitted as empty, so records of one type
+// share most keys; map entries and slice elements are listed as present.
+func Flatten(v any) []Field {
+	var fields []Field
+	flatten(&fields, "", reflect.ValueOf(v))
+	return fields
+}
+
+var textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+
+func flatten(fields *[]Field, key string, v reflect.Value) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			if v.Kind() == reflect.Pointer {
+				flattenEmpty(fields, key, v.Type().Elem())
+			} else if key != "" {
+				*fields = append(*fields, Field{Key: key})
+			}
+			return
+		}
+		if v.Type().Implements(textMarshalerType) {
+			break
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return
+	}
+	if value, ok := scalar(v); ok {
+		*fields = append(*fields, Field{Key: key, Value: value})
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		for _, f := range structFields(v.Type()) {
+			fv := v.FieldByIndex(f.index)
+			if f.omitEmpty && fv.IsZero() {
+				flattenEmpty(fields, join(key, f.name), fv.Type())
+				continue
+			}
+			flatten(fields, join(key, f.name), fv)
+		}
+	case reflect.Map:
+		names, values := mapEntries(v)
+		for i, name := range names {
+			flatten(fields, join(key, name), values[i])
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			flatten(fields, join(key, strconv.Itoa(i)), v.Index(i))
+		}
+	}
+}
+
+// mapEntries returns the keys of a map, formatted and sorted, and their
+// values.
+func mapEntries(v reflect.Value) ([]string, []reflect.Value) {
+	keys := v.MapKeys()
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = fmt.Sprint(k.Interface())
+	}
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int { return strings.Compare(names[a], names[b]) })
+	sortedNames := make([]string, len(keys))
+	values := make([]reflect.Value, len(keys))
+	for i, j := range order {
+		sortedNames[i], values[i] = names[j], v.MapIndex(keys[j])
+	}
+	return sortedNames, values
+}
+
+// flattenEmpty lists the keys of a value of type t that is absent.
+func flattenEmpty(fields *[]Field, key string, t reflect.Type) {
+	for t.Kind() == reflect.Pointer && !t.Implements(textMarshalerType) {
+		t = t.Elem()
+	}
+	switch {
+	case leaf(t):
+		*fields = append(*fields, Field{Key: key})
+	case t.Kind() == reflect.Struct:
+		for _, f := range structFields(t) {
+			flattenEmpty(fields, join(key, f.name), t.FieldByIndex(f.index).Type)
+		}
+	}
+}
+
+// scalar formats a value that is not a container.
+func scalar(v reflect.Value) (string, bool) {
+	if v.Type().Implements(textMarshalerType) {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err == nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), true
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return base64.StdEncoding.EncodeToString(v.Bytes()), true
+		}
+	}
+	return "", false
+}
+
+// leaf reports whether values of type t are written as a single value.
+func leaf(t reflect.Type) bool {
+	if t.Implements(textMarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Interface:
+		return false
+	case reflect.Slice, reflect.Array:
+		return t.Elem().Kind() == reflect.Uint8
+	}
+	return true
+}
+
+// Lookup returns the value at the dotted path of a flattened v. Paths that
+// the type of v allows but that have no value, such as the city of an
+// address that is only known to the country level, return "".
+func Lookup(v any, path string) (string, error) {
+	if path == "" {
+		return "", ErrMissingField
+	}
+	for _, f := range Flatten(v) {
+		if f.Key == path {
+			return f.Value, nil
+		}
+	}
+	if v == nil || !validPath(reflect.TypeOf(v), strings.Split(path, ".")) {
+		return "", fmt.Errorf("%w %q", ErrUnknownField, path)
+	}
+	return "", nil
+}
+
+// validPath reports whether path names a single value in values of type t.
+func validPath(t reflect.Type, path []string) bool {
+	for len(path) > 0 {
+		for t.Kind() == reflect.Pointer && !t.Implements(textMarshalerType) {
+			t = t.Elem()
+		}
+		if leaf(t) {
+			return false
+		}
+		switch t.Kind() {
+		case reflect.Interface:
+			// The dynamic type is unknown, anything may be there.
+			return true
+		case reflect.Struct:
+			i := slices.IndexFunc(structFields(t), func(f field) bool { return f.name == path[0] })
+			if i < 0 {
+				return false
+			}
+			t = t.FieldByIndex(structFields(t)[i].index).Type
+		case reflect.Map:
+			t = t.Elem()
+		case reflect.Slice, reflect.Array:
+			if _, err := strconv.Atoi(path[0]); err != nil {
+				return false
+			}
+			t = t.Elem()
+		}
+		path = path[1:]
+	}
+	for t.Kind() == reflect.Pointer && !t.Implements(textMarshalerType) {
+		t = t.Elem()
+	}
+	return leaf(t) || t.Kind() == reflect.Interface
+}
+
+// field is a struct field as encoding/json sees it.
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// structFields returns the fields encoding/json encodes for a struct type,
+// named after their json tags. Embedded structs without a tag are inlined;
+// embedded pointers are not supported.
+func structFields(t reflect.Type) []field {
+	var fields []field
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			for _, f := range structFields(sf.Type) {
+				f.index = append([]int{i}, f.index...)
+				fields = append(fields, f)
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, field{
+			name:      name,
+			index:     []int{i},
+			omitEmpty: strings.Contains(","+opts+",", ",omitempty,") || strings.Contains(","+opts+",", ",omitzero,"),
+		})
+	}
+	return fields
+}
+
+func join(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
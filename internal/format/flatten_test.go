@@ -0,0 +1,66 @@
+package format
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlatten(t *testing.T) {
+	type inner struct {
+		Code string `json:"code,omitempty"`
+	}
+	type embedded struct {
+		Shared bool `json:"shared"`
+	}
+	type record struct {
+		embedded
+		Addr    netip.Addr     `json:"addr"`
+		Inner   *inner         `json:"inner,omitempty"`
+		Skipped string         `json:"-"`
+		Count   uint           `json:"count,omitzero"`
+		Tags    []string       `json:"tags"`
+		Extra   map[string]any `json:"extra,omitempty"`
+		Plain   float64
+		hidden  string
+		Names   map[string]string `json:"names"`
+	}
+
+	got := Flatten(&record{
+		Addr:   netip.MustParseAddr("2001:db8::1"),
+		Tags:   []string{"a", "b"},
+		Extra:  map[string]any{"nested": map[string]any{"n": 1.5}, "nil": nil},
+		Plain:  0.25,
+		hidden: "x",
+		Names:  map[string]string{"en": "London", "de": "London"},
+	})
+	assert.Equal(t, []Field{
+		{"shared", "false"},
+		{"addr", "2001:db8::1"},
+		{"inner.code", ""},
+		{"count", ""},
+		{"tags.0", "a"},
+		{"tags.1", "b"},
+		{"extra.nested.n", "1.5"},
+		{"extra.nil", ""},
+		{"Plain", "0.25"},
+		{"names.de", "London"},
+		{"names.en", "London"},
+	}, got)
+
+	assert.Empty(t, Flatten(nil))
+	assert.Equal(t, []Field{{"", "x"}}, Flatten("x"))
+}
+
+func TestLookup_Record(t *testing.T) {
+	record := map[string]any{"country": map[string]any{"iso_code": "US"}}
+
+	v, err := Lookup(record, "country.iso_code")
+	assert.NoError(t, err)
+	assert.Equal(t, "US", v)
+
+	v, err = Lookup(record, "city.names.en")
+	assert.NoError(t, err, "records of custom databases have no schema to check against")
+	assert.Empty(t, v)
+}
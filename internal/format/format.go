@@ -0,0 +1,181 @@
+// Package format serializes API responses as JSON, CSV, XML, MessagePack,
+// Protocol Buffers or plain text.
+//
+// Formats are kept in a Registry and picked by name, from a format query
+// parameter, or by negotiation with the request's Accept header. The
+// non-JSON formats work on any value the encoding/json package can encode
+// and name fields after its struct tags, so a field is called the same in
+// every format.
+package format
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrMissingField is returned by the text format when no field is
+	// given.
+	ErrMissingField = errors.New("format: no field given")
+	// ErrUnknownField is returned by the text format when the field does
+	// not name a single value of the response.
+	ErrUnknownField = errors.New("format: unknown field")
+)
+
+// Options tune the output of a Serializer.
+type Options struct {
+	// Indent, if not empty, pretty-prints JSON and XML output.
+	Indent string
+	// Field is the dotted path of the value written by the text format,
+	// such as "country.iso_code".
+	Field string
+}
+
+// Serializer writes values in one format.
+type Serializer interface {
+	Serialize(w io.Writer, v any, opts Options) error
+}
+
+// Format is a response format.
+type Format struct {
+	// Name selects the format by name, such as "csv".
+	Name string
+	// MediaType is the Content-Type of responses in the format.
+	MediaType string
+	// Aliases are further media types that select the format in an Accept
+	// header, such as "text/xml".
+	Aliases []string
+	Serializer
+}
+
+// Registry holds the formats a server offers. Its methods must not be
+// called concurrently with Register.
+type Registry struct {
+	formats []*Format
+}
+
+// NewRegistry returns a registry of formats. The first format is the
+// default.
+func NewRegistry(formats ...*Format) *Registry {
+	r := &Registry{}
+	for _, f := range formats {
+		r.Register(f)
+	}
+	return r
+}
+
+// Default returns a registry of the built-in formats, with JSON as the
+// default.
+func Default() *Registry {
+	return NewRegistry(
+		&Format{Name: "json", MediaType: "application/json", Serializer: JSON},
+		&Format{Name: "csv", MediaType: "text/csv; charset=utf-8", Serializer: CSV},
+		&Format{Name: "xml", MediaType: "application/xml; charset=utf-8", Aliases: []string{"text/xml"}, Serializer: XML},
+		&Format{
+			Name: "msgpack", MediaType: "application/msgpack",
+			Aliases: []string{"application/x-msgpack", "application/vnd.msgpack"}, Serializer: MsgPack,
+		},
+		&Format{
+			Name: "protobuf", MediaType: "application/x-protobuf",
+			Aliases: []string{"application/protobuf", "application/vnd.google.protobuf"}, Serializer: Protobuf,
+		},
+		&Format{Name: "text", MediaType: "text/plain; charset=utf-8", Serializer: Text},
+	)
+}
+
+// Register adds a format, replacing a format of the same name.
+func (r *Registry) Register(f *Format) {
+	for i, existing := range r.formats {
+		if existing.Name == f.Name {
+			r.formats[i] = f
+			return
+		}
+	}
+	r.formats = append(r.formats, f)
+}
+
+// Default returns the default format, or nil if the registry is empty.
+func (r *Registry) Default() *Format {
+	if len(r.formats) == 0 {
+		return nil
+	}
+	return r.formats[0]
+}
+
+// Lookup returns the format with the given name.
+func (r *Registry) Lookup(name string) (*Format, bool) {
+	for _, f := range r.formats {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// Names returns the names of the formats in order.
+func (r *Registry) Names() []string {
+	names := make([]string, len(r.formats))
+	for i, f := range r.formats {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// Negotiate returns the format that best matches an Accept header. Higher
+// quality values win; ties go to the format registered first. Like many
+// APIs, it falls back to the default format rather than fail when the
+// header accepts none of them.
+func (r *Registry) Negotiate(accept string) *Format {
+	if accept == "" {
+		return r.Default()
+	}
+	best, bestQ := r.Default(), 0.0
+	for _, f := range r.formats {
+		q := quality(accept, f.MediaType)
+		for _, alias := range f.Aliases {
+			q = max(q, quality(accept, alias))
+		}
+		if q > bestQ {
+			best, bestQ = f, q
+		}
+	}
+	return best
+}
+
+// quality returns the quality value an Accept header assigns to a media
+// type. The most specific matching media range wins, as in RFC 9110.
+func quality(accept, mediaType string) float64 {
+	mediaType, _, _ = strings.Cut(mediaType, ";")
+	typ, _, _ := strings.Cut(mediaType, "/")
+	q, specificity := 0.0, -1
+	for accept != "" {
+		var entry string
+		entry, accept, _ = strings.Cut(accept, ",")
+		mediaRange, params, err := mime.ParseMediaType(entry)
+		if err != nil {
+			continue
+		}
+		s := 0
+		switch {
+		case mediaRange == mediaType:
+			s = 2
+		case mediaRange == typ+"/*":
+			s = 1
+		case mediaRange != "*/*":
+			continue
+		}
+		if s <= specificity {
+			continue
+		}
+		specificity, q = s, 1
+		if v, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+	}
+	return q
+}
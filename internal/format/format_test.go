@@ -0,0 +1,190 @@
+package format
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	v1 "github.com/gustavosett/WhereGo/internal/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func sampleResponse() *v1.CityResponse {
+	lat, lon := 51.5142, -0.0931
+	return &v1.CityResponse{
+		Traits:       v1.Traits{IPAddress: "81.2.69.160", Network: "81.2.69.160/27"},
+		Country:      &v1.Country{ISOCode: "GB", GeoNameID: 2635167, Names: v1.Names{"en": "United Kingdom", "de": "Vereinigtes Königreich"}},
+		Subdivisions: []v1.Subdivision{{ISOCode: "ENG"}},
+		Location:     &v1.Location{Latitude: &lat, Longitude: &lon},
+		Labels:       map[string]string{"rack <1>": "a,b"},
+	}
+}
+
+func TestRegistry_Negotiate(t *testing.T) {
+	r := Default()
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{"No Header", "", "json"},
+		{"Anything", "*/*", "json"},
+		{"CSV", "text/csv", "csv"},
+		{"XML Alias", "text/xml", "xml"},
+		{"MessagePack", "application/x-msgpack", "msgpack"},
+		{"Protocol Buffers", "application/protobuf", "protobuf"},
+		{"Plain Text", "text/plain", "text"},
+		{"Quality Wins", "application/json;q=0.5, application/xml", "xml"},
+		{"Specific Range Wins", "text/*, text/csv;q=0", "xml"},
+		{"Registration Order On Ties", "text/*", "csv"},
+		{"Browser", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "xml"},
+		{"Nothing Acceptable", "image/png", "json"},
+		{"Malformed Entries Are Skipped", "/, text/csv", "csv"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, r.Negotiate(tt.accept).Name)
+		})
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	assert.Nil(t, r.Default())
+
+	r.Register(&Format{Name: "json", Serializer: JSON})
+	r.Register(&Format{Name: "csv", Serializer: CSV})
+	r.Register(&Format{Name: "json", MediaType: "application/vnd.api+json", Serializer: JSON})
+	assert.Equal(t, []string{"json", "csv"}, r.Names())
+	f, ok := r.Lookup("json")
+	require.True(t, ok)
+	assert.Equal(t, "application/vnd.api+json", f.MediaType, "registering a name again replaces the format")
+	_, ok = r.Lookup("yaml")
+	assert.False(t, ok)
+}
+
+func serialize(t *testing.T, s Serializer, v any, opts Options) string {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, s.Serialize(&buf, v, opts))
+	return buf.String()
+}
+
+func TestCSV(t *testing.T) {
+	got := serialize(t, CSV, sampleResponse(), Options{})
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], "traits.ip_address,traits.network,traits.is_anycast,"))
+	assert.Contains(t, lines[0], ",city.geoname_id,", "absent records keep their columns")
+	assert.Contains(t, lines[0], ",country.names.de,country.names.en,")
+	assert.True(t, strings.HasPrefix(lines[1], "81.2.69.160,81.2.69.160/27,,"))
+	assert.Contains(t, lines[1], `,"a,b",`, "values are quoted")
+}
+
+func TestXML(t *testing.T) {
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<response>
+  <traits>
+    <ip_address>81.2.69.160</ip_address>
+    <network>81.2.69.160/27</network>
+  </traits>
+  <country>
+    <iso_code>GB</iso_code>
+    <geoname_id>2635167</geoname_id>
+    <names>
+      <entry key="de">Vereinigtes Königreich</entry>
+      <entry key="en">United Kingdom</entry>
+    </names>
+  </country>
+  <subdivisions>
+    <iso_code>ENG</iso_code>
+  </subdivisions>
+  <location>
+    <latitude>51.5142</latitude>
+    <longitude>-0.0931</longitude>
+  </location>
+  <labels>
+    <entry key="rack &lt;1&gt;">a,b</entry>
+  </labels>
+</response>
+`, serialize(t, XML, sampleResponse(), Options{Indent: "  "}))
+
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<response><entry key="a"><entry key="b">1</entry></entry><entry key="c">x</entry><entry key="c">y</entry></response>
+`, serialize(t, XML, map[string]any{"a": map[string]any{"b": 1}, "c": []any{"x", "y"}}, Options{}))
+}
+
+func TestMsgPack(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, MsgPack.Serialize(&buf, sampleResponse(), Options{}))
+
+	var got map[string]any
+	require.NoError(t, msgpack.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, map[string]any{"ip_address": "81.2.69.160", "network": "81.2.69.160/27"}, got["traits"],
+		"keys follow the JSON names and empty fields are omitted")
+	assert.Equal(t, "GB", got["country"].(map[string]any)["iso_code"])
+	assert.NotContains(t, got, "city")
+}
+
+type protoSample struct{ name string }
+
+func TestProtobuf(t *testing.T) {
+	RegisterProtoMessage(func(s *protoSample) proto.Message { return wrapperspb.String(s.name) })
+
+	var buf bytes.Buffer
+	require.NoError(t, Protobuf.Serialize(&buf, &protoSample{name: "Lisbon"}, Options{}))
+	var name wrapperspb.StringValue
+	require.NoError(t, proto.Unmarshal(buf.Bytes(), &name))
+	assert.Equal(t, "Lisbon", name.GetValue())
+
+	buf.Reset()
+	require.NoError(t, Protobuf.Serialize(&buf, wrapperspb.Bool(true), Options{}))
+	var flag wrapperspb.BoolValue
+	require.NoError(t, proto.Unmarshal(buf.Bytes(), &flag))
+	assert.True(t, flag.GetValue(), "messages are written as they are")
+
+	buf.Reset()
+	require.NoError(t, Protobuf.Serialize(&buf, map[string]any{"site": "Lisbon", "floor": uint64(3)}, Options{}))
+	var record structpb.Struct
+	require.NoError(t, proto.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, map[string]any{"site": "Lisbon", "floor": 3.0}, record.AsMap())
+
+	assert.Error(t, Protobuf.Serialize(&buf, struct{}{}, Options{}), "values without a message")
+	assert.Error(t, Protobuf.Serialize(&buf, protoSample{}, Options{}), "conversions are registered per type")
+}
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name    string
+		field   string
+		want    string
+		wantErr error
+	}{
+		{"Field", "country.iso_code", "GB\n", nil},
+		{"Number", "location.latitude", "51.5142\n", nil},
+		{"Map Entry", "country.names.en", "United Kingdom\n", nil},
+		{"Slice Element", "subdivisions.0.iso_code", "ENG\n", nil},
+		{"Absent Value", "city.names.en", "\n", nil},
+		{"Missing Field", "", "", ErrMissingField},
+		{"Unknown Field", "country.code", "", ErrUnknownField},
+		{"Not A Single Value", "country.names", "", ErrUnknownField},
+		{"Bad Index", "subdivisions.first.iso_code", "", ErrUnknownField},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := Text.Serialize(&buf, sampleResponse(), Options{Field: tt.field})
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
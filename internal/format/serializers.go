@@ -0,0 +1,129 @@
+package format
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// The built-in serializers.
+var (
+	// JSON writes values as encoding/json does.
+	JSON Serializer = jsonSerializer{}
+	// CSV writes a header row of the flattened keys and a row of their
+	// values. See Flatten.
+	CSV Serializer = csvSerializer{}
+	// XML writes values as elements named after the json tags of their
+	// fields. See xmlSerializer.
+	XML Serializer = xmlSerializer{}
+	// MsgPack writes values as MessagePack maps keyed like the JSON output.
+	MsgPack Serializer = msgPackSerializer{}
+	// Protobuf writes values as the Protocol Buffers messages registered
+	// for their types with RegisterProtoMessage, and records of custom
+	// databases as a google.protobuf.Struct.
+	Protobuf Serializer = protobufSerializer{}
+	// Text writes the value at Options.Field followed by a newline, such as
+	// "US\n" for "country.iso_code".
+	Text Serializer = textSerializer{}
+)
+
+type jsonSerializer struct{}
+
+func (jsonSerializer) Serialize(w io.Writer, v any, opts Options) error {
+	enc := json.NewEncoder(w)
+	if opts.Indent != "" {
+		enc.SetIndent("", opts.Indent)
+	}
+	return enc.Encode(v)
+}
+
+type csvSerializer struct{}
+
+func (csvSerializer) Serialize(w io.Writer, v any, _ Options) error {
+	fields := Flatten(v)
+	header := make([]string, len(fields))
+	row := make([]string, len(fields))
+	for i, f := range fields {
+		header[i], row[i] = f.Key, f.Value
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	if err := cw.Write(row); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type msgPackSerializer struct{}
+
+func (msgPackSerializer) Serialize(w io.Writer, v any, _ Options) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	return enc.Encode(v)
+}
+
+type textSerializer struct{}
+
+func (textSerializer) Serialize(w io.Writer, v any, opts Options) error {
+	value, err := Lookup(v, opts.Field)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, value+"\n")
+	return err
+}
+
+// protoMessages holds the conversions registered with
+// RegisterProtoMessage, by the type they convert.
+var protoMessages sync.Map
+
+// RegisterProtoMessage registers convert as the conversion of values of
+// type T to the Protocol Buffers message the Protobuf serializer writes.
+// The packages defining the messages register their conversions in init,
+// so this package does not depend on the types it serializes.
+func RegisterProtoMessage[T any](convert func(T) proto.Message) {
+	protoMessages.Store(reflect.TypeFor[T](), func(v any) proto.Message {
+		return convert(v.(T))
+	})
+}
+
+type protobufSerializer struct{}
+
+func (protobufSerializer) Serialize(w io.Writer, v any, _ Options) error {
+	var m proto.Message
+	switch v := v.(type) {
+	case proto.Message:
+		m = v
+	case map[string]any:
+		record, err := structpb.NewStruct(v)
+		if err != nil {
+			return err
+		}
+		m = record
+	default:
+		convert, ok := protoMessages.Load(reflect.TypeOf(v))
+		if !ok {
+			return fmt.Errorf("format: no protobuf message for %T", v)
+		}
+		m = convert.(func(any) proto.Message)(v)
+	}
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
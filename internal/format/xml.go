@@ -0,0 +1,85 @@
+package format
+
+import (
+	"encoding/xml"
+	"io"
+	"reflect"
+)
+
+// xmlRoot is the name of the document element.
+const xmlRoot = "response"
+
+// xmlSerializer writes values under a <response> element. Struct fields
+// become elements named after their json tags and empty fields are left
+// out as in the JSON output. Slices repeat their element, as encoding/xml
+// does, and map entries become <entry key="..."> elements, since map keys
+// such as label names need not be valid XML names.
+type xmlSerializer struct{}
+
+func (xmlSerializer) Serialize(w io.Writer, v any, opts Options) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", opts.Indent)
+	if err := encodeXML(enc, xml.StartElement{Name: xml.Name{Local: xmlRoot}}, reflect.ValueOf(v)); err != nil {
+		return err
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func encodeXML(enc *xml.Encoder, start xml.StartElement, v reflect.Value) error {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() || v.Type().Implements(textMarshalerType) {
+			break
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() || (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		return nil
+	}
+
+	if value, ok := scalar(v); ok {
+		return enc.EncodeElement(value, start)
+	}
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		for i := range v.Len() {
+			if err := encodeXML(enc, start, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		for _, f := range structFields(v.Type()) {
+			fv := v.FieldByIndex(f.index)
+			if f.omitEmpty && fv.IsZero() {
+				continue
+			}
+			if err := encodeXML(enc, xml.StartElement{Name: xml.Name{Local: f.name}}, fv); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		names, values := mapEntries(v)
+		for i, name := range names {
+			entry := xml.StartElement{
+				Name: xml.Name{Local: "entry"},
+				Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: name}},
+			}
+			if err := encodeXML(enc, entry, values[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
@@ -0,0 +1,262 @@
+// Package geodns answers geolocation lookups over DNS, for clients that
+// can only make DNS queries.
+//
+// Lookups are TXT queries for the address written the way reverse DNS and
+// DNS blocklists write it, followed by the lookup type and the zone of the
+// server:
+//
+//	4.3.2.1.country.geo.local.  TXT  "GB"
+//	4.3.2.1.city.geo.local.     TXT  "London"
+//	8.8.8.8.asn.geo.local.      TXT  "15169" "GOOGLE"
+//
+// IPv4 addresses are written as their four octets in reverse order, IPv6
+// addresses as their 32 nibbles in reverse order, as in ip6.arpa.
+// Addresses the database has no value for do not exist (NXDOMAIN).
+package geodns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/miekg/dns"
+)
+
+// Lookup types, the label between the address and the zone.
+const (
+	TypeCountry = "country"
+	TypeCity    = "city"
+	TypeASN     = "asn"
+)
+
+// errNoName is returned for names that are not lookups.
+var errNoName = errors.New("geodns: no such name")
+
+// DefaultTTL is the TTL of answers when the update interval of the
+// database is not known.
+const DefaultTTL = 5 * time.Minute
+
+// Server answers lookups under a zone. It implements dns.Handler.
+type Server struct {
+	Service *wherego.Service
+	// Zone is the domain lookups are made under, such as "geo.local.".
+	Zone string
+	// TTL is how long resolvers may cache answers, typically the update
+	// interval of the database. Zero means DefaultTTL.
+	TTL time.Duration
+}
+
+// ListenAndServe answers queries on addr over UDP and TCP until ctx is
+// done.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		_ = pc.Close()
+		return err
+	}
+	return s.Serve(ctx, pc, ln)
+}
+
+// Serve answers queries arriving on pc over UDP and on ln over TCP until ctx
+// is done, then closes both. It returns nil when ctx ends it.
+func (s *Server) Serve(ctx context.Context, pc net.PacketConn, ln net.Listener) error {
+	servers := []*dns.Server{
+		{PacketConn: pc, Handler: s},
+		{Listener: ln, Handler: s},
+	}
+	errs := make(chan error, len(servers))
+	var err error
+	running := 0
+	for _, srv := range servers {
+		started := make(chan struct{})
+		srv.NotifyStartedFunc = func() { close(started) }
+		go func() { errs <- srv.ActivateAndServe() }()
+		select {
+		case <-started:
+			running++
+		case err = <-errs:
+		}
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		select {
+		case <-ctx.Done():
+		case err = <-errs:
+			running--
+		}
+	}
+
+	for _, srv := range servers {
+		// Servers that did not start report so; there is nothing to stop.
+		_ = srv.Shutdown()
+	}
+	for range running {
+		<-errs
+	}
+	_ = pc.Close()
+	_ = ln.Close()
+	return err
+}
+
+// ServeDNS answers a query.
+func (s *Server) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	switch {
+	case r.Opcode != dns.OpcodeQuery:
+		m.Rcode = dns.RcodeNotImplemented
+	case len(r.Question) != 1:
+		m.Rcode = dns.RcodeFormatError
+	default:
+		s.answer(m, r.Question[0])
+	}
+	_ = w.WriteMsg(m)
+}
+
+// answer fills in the answer to q.
+func (s *Server) answer(m *dns.Msg, q dns.Question) {
+	zone := dns.CanonicalName(s.Zone)
+	name := dns.CanonicalName(q.Name)
+	if q.Qclass != dns.ClassINET || !dns.IsSubDomain(zone, name) {
+		m.Rcode = dns.RcodeRefused
+		return
+	}
+	m.Authoritative = true
+
+	labels := dns.SplitDomainName(strings.TrimSuffix(name, zone))
+	if len(labels) == 0 {
+		// The zone itself has an SOA record only.
+		if q.Qtype == dns.TypeSOA {
+			m.Answer = append(m.Answer, s.soa(zone))
+		} else {
+			m.Ns = append(m.Ns, s.soa(zone))
+		}
+		return
+	}
+
+	txt, err := s.lookup(labels[len(labels)-1], labels[:len(labels)-1])
+	switch {
+	case errors.Is(err, errNoName), errors.Is(err, wherego.ErrNotFound):
+		m.Rcode = dns.RcodeNameError
+		m.Ns = append(m.Ns, s.soa(zone))
+	case err != nil:
+		m.Rcode = dns.RcodeServerFailure
+	case q.Qtype != dns.TypeTXT && q.Qtype != dns.TypeANY:
+		// The name exists, with no records of the type asked for.
+		m.Ns = append(m.Ns, s.soa(zone))
+	default:
+		m.Answer = append(m.Answer, &dns.TXT{
+			Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: s.ttl()},
+			Txt: txt,
+		})
+	}
+}
+
+// lookup returns the TXT strings of a lookup of the given type for the
+// address written in labels. It returns errNoName for unknown types and
+// addresses that are not written correctly, and
+// wherego.ErrNotFound when the database has no value for the address.
+func (s *Server) lookup(typ string, labels []string) ([]string, error) {
+	addr, ok := parseReverse(labels)
+	if !ok {
+		return nil, errNoName
+	}
+
+	var txt []string
+	switch typ {
+	case TypeCountry, TypeCity:
+		city, err := s.Service.LookupIP(addr.String())
+		if err != nil {
+			return nil, err
+		}
+		value := city.Country.ISOCode
+		if typ == TypeCity {
+			value = city.City.Names.English
+		}
+		if value != "" {
+			txt = []string{value}
+		}
+	case TypeASN:
+		asn, err := s.Service.LookupASN(addr)
+		if err != nil {
+			return nil, err
+		}
+		txt = []string{strconv.FormatUint(uint64(asn.AutonomousSystemNumber), 10)}
+		if asn.AutonomousSystemOrganization != "" {
+			txt = append(txt, asn.AutonomousSystemOrganization)
+		}
+	default:
+		return nil, errNoName
+	}
+	if len(txt) == 0 {
+		return nil, wherego.ErrNotFound
+	}
+	return txt, nil
+}
+
+// parseReverse parses an address written as the reversed octets of an
+// IPv4 address or the reversed nibbles of an IPv6 address.
+func parseReverse(labels []string) (netip.Addr, bool) {
+	switch len(labels) {
+	case 4:
+		var a [4]byte
+		for i, label := range labels {
+			n, err := strconv.ParseUint(label, 10, 8)
+			if err != nil || len(label) > 1 && label[0] == '0' {
+				return netip.Addr{}, false
+			}
+			a[3-i] = byte(n)
+		}
+		return netip.AddrFrom4(a), true
+	case 32:
+		var a [16]byte
+		for i, label := range labels {
+			if len(label) != 1 {
+				return netip.Addr{}, false
+			}
+			n, err := strconv.ParseUint(label, 16, 4)
+			if err != nil {
+				return netip.Addr{}, false
+			}
+			j := 31 - i
+			a[j/2] |= byte(n) << (4 * (1 - j%2))
+		}
+		return netip.AddrFrom16(a), true
+	}
+	return netip.Addr{}, false
+}
+
+// soa returns the SOA record of the zone, which resolvers also use to cache
+// names that do not exist. The serial is the build time of the database.
+func (s *Server) soa(zone string) *dns.SOA {
+	ttl := s.ttl()
+	return &dns.SOA{
+		Hdr:     dns.RR_Header{Name: zone, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: ttl},
+		Ns:      "ns." + zone,
+		Mbox:    "hostmaster." + zone,
+		Serial:  uint32(s.Service.DB.Metadata().BuildEpoch),
+		Refresh: ttl,
+		Retry:   ttl,
+		Expire:  ttl,
+		Minttl:  ttl,
+	}
+}
+
+func (s *Server) ttl() uint32 {
+	ttl := s.TTL
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return uint32(min(ttl/time.Second, 1<<31-1))
+}
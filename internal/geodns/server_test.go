@@ -0,0 +1,214 @@
+package geodns
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/gustavosett/WhereGo/internal/geoiptest"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startServer serves db on local UDP and TCP ports and returns their
+// addresses.
+func startServer(t *testing.T, db geoiptest.Database, ttl time.Duration) (udpAddr, tcpAddr string) {
+	t.Helper()
+	return serve(t, &wherego.Service{DB: openDB(t, db)}, ttl)
+}
+
+func openDB(t *testing.T, db geoiptest.Database) *wherego.Reader {
+	t.Helper()
+	reader, err := wherego.OpenBytes(geoiptest.MustBuild(db))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = reader.Close() })
+	return reader
+}
+
+// serve serves svc on local UDP and TCP ports and returns their addresses.
+func serve(t *testing.T, svc *wherego.Service, ttl time.Duration) (udpAddr, tcpAddr string) {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &Server{Service: svc, Zone: "Geo.Local", TTL: ttl}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx, pc, ln) }()
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-done)
+	})
+	return pc.LocalAddr().String(), ln.Addr().String()
+}
+
+func exchange(t *testing.T, network, addr, name string, qtype uint16) *dns.Msg {
+	t.Helper()
+	m := new(dns.Msg)
+	m.SetQuestion(name, qtype)
+	client := &dns.Client{Net: network, Timeout: 5 * time.Second}
+	r, _, err := client.Exchange(m, addr)
+	require.NoError(t, err)
+	return r
+}
+
+func TestServer(t *testing.T) {
+	udpAddr, tcpAddr := startServer(t, geoiptest.SampleCity(), 24*time.Hour)
+
+	tests := []struct {
+		name      string
+		qname     string
+		qtype     uint16
+		wantRcode int
+		wantTXT   []string
+	}{
+		{name: "Country", qname: "160.69.2.81.country.geo.local.", qtype: dns.TypeTXT, wantTXT: []string{"GB"}},
+		{name: "City", qname: "160.69.2.81.city.geo.local.", qtype: dns.TypeTXT, wantTXT: []string{"London"}},
+		{name: "Case Insensitive", qname: "8.8.8.8.COUNTRY.GEO.LOCAL.", qtype: dns.TypeTXT, wantTXT: []string{"US"}},
+		{
+			name:  "IPv6 Nibbles",
+			qname: "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.7.4.6.0.6.2.country.geo.local.",
+			qtype: dns.TypeTXT, wantTXT: []string{"US"},
+		},
+		{name: "Any", qname: "8.8.8.8.country.geo.local.", qtype: dns.TypeANY, wantTXT: []string{"US"}},
+		{name: "No City", qname: "8.8.8.8.city.geo.local.", qtype: dns.TypeTXT, wantRcode: dns.RcodeNameError},
+		{name: "Not Found", qname: "1.0.0.127.country.geo.local.", qtype: dns.TypeTXT, wantRcode: dns.RcodeNameError},
+		{name: "Unknown Type", qname: "8.8.8.8.region.geo.local.", qtype: dns.TypeTXT, wantRcode: dns.RcodeNameError},
+		{name: "Leading Zero", qname: "08.8.8.8.country.geo.local.", qtype: dns.TypeTXT, wantRcode: dns.RcodeNameError},
+		{name: "Short Address", qname: "8.8.8.country.geo.local.", qtype: dns.TypeTXT, wantRcode: dns.RcodeNameError},
+		{name: "Other Record Type", qname: "8.8.8.8.country.geo.local.", qtype: dns.TypeA},
+		{name: "Unsupported Database", qname: "8.8.8.8.asn.geo.local.", qtype: dns.TypeTXT, wantRcode: dns.RcodeServerFailure},
+		{name: "Outside Zone", qname: "8.8.8.8.country.example.com.", qtype: dns.TypeTXT, wantRcode: dns.RcodeRefused},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, network := range []string{"udp", "tcp"} {
+				addr := udpAddr
+				if network == "tcp" {
+					addr = tcpAddr
+				}
+				r := exchange(t, network, addr, tt.qname, tt.qtype)
+				assert.Equal(t, tt.wantRcode, r.Rcode, network)
+				if tt.wantTXT == nil {
+					assert.Empty(t, r.Answer, network)
+					continue
+				}
+				require.Len(t, r.Answer, 1, network)
+				txt, ok := r.Answer[0].(*dns.TXT)
+				require.True(t, ok, network)
+				assert.Equal(t, tt.wantTXT, txt.Txt, network)
+				assert.Equal(t, uint32(86400), txt.Hdr.Ttl, network)
+				assert.True(t, r.Authoritative, network)
+			}
+		})
+	}
+}
+
+func TestServer_NegativeAnswers(t *testing.T) {
+	udpAddr, _ := startServer(t, geoiptest.SampleCity(), 0)
+
+	for _, name := range []string{"1.0.0.127.country.geo.local.", "8.8.8.8.country.geo.local."} {
+		r := exchange(t, "udp", udpAddr, name, dns.TypeAAAA)
+		require.Len(t, r.Ns, 1, "an SOA record for negative caching")
+		soa, ok := r.Ns[0].(*dns.SOA)
+		require.True(t, ok)
+		assert.Equal(t, "geo.local.", soa.Hdr.Name)
+		assert.Equal(t, uint32(1735689600), soa.Serial, "the build time of the database")
+		assert.Equal(t, uint32(DefaultTTL/time.Second), soa.Minttl)
+	}
+
+	r := exchange(t, "udp", udpAddr, "geo.local.", dns.TypeSOA)
+	assert.Equal(t, dns.RcodeSuccess, r.Rcode)
+	require.Len(t, r.Answer, 1)
+	assert.IsType(t, &dns.SOA{}, r.Answer[0])
+}
+
+func TestServer_ASN(t *testing.T) {
+	udpAddr, _ := startServer(t, geoiptest.Database{
+		DatabaseType: "GeoLite2-ASN",
+		Networks: map[netip.Prefix]any{
+			geoiptest.USNetwork: geoiptest.Map{
+				"autonomous_system_number":       uint32(15169),
+				"autonomous_system_organization": "GOOGLE",
+			},
+		},
+	}, time.Hour)
+
+	r := exchange(t, "udp", udpAddr, "8.8.8.8.asn.geo.local.", dns.TypeTXT)
+	require.Len(t, r.Answer, 1)
+	assert.Equal(t, []string{"15169", "GOOGLE"}, r.Answer[0].(*dns.TXT).Txt)
+
+	r = exchange(t, "udp", udpAddr, "8.8.8.8.country.geo.local.", dns.TypeTXT)
+	assert.Equal(t, dns.RcodeServerFailure, r.Rcode, "the database has no countries")
+}
+
+// The server runs with a City database and the ASN database of
+// ASN_DB_PATH next to it, which asn lookups must use.
+func TestServer_ASNDatabase(t *testing.T) {
+	udpAddr, _ := serve(t, &wherego.Service{
+		DB:    openDB(t, geoiptest.SampleCity()),
+		ASNDB: openDB(t, geoiptest.SampleASN()),
+	}, time.Hour)
+
+	tests := []struct {
+		name      string
+		qname     string
+		wantRcode int
+		wantTXT   []string
+	}{
+		{"ASN", "8.8.8.8.asn.geo.local.", dns.RcodeSuccess, []string{"15169", "GOOGLE"}},
+		{"Other Network", "160.69.2.81.asn.geo.local.", dns.RcodeSuccess, []string{"20712", "Andrews & Arnold Ltd"}},
+		{"Country From City Database", "8.8.8.8.country.geo.local.", dns.RcodeSuccess, []string{"US"}},
+		{"Not Found", "1.0.0.127.asn.geo.local.", dns.RcodeNameError, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := exchange(t, "udp", udpAddr, tt.qname, dns.TypeTXT)
+			assert.Equal(t, tt.wantRcode, r.Rcode)
+			if tt.wantTXT == nil {
+				assert.Empty(t, r.Answer)
+				return
+			}
+			require.Len(t, r.Answer, 1)
+			assert.Equal(t, tt.wantTXT, r.Answer[0].(*dns.TXT).Txt)
+		})
+	}
+}
+
+func TestParseReverse(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels []string
+		want   string
+	}{
+		{name: "IPv4", labels: []string{"4", "3", "2", "1"}, want: "1.2.3.4"},
+		{name: "IPv4 Out Of Range", labels: []string{"256", "3", "2", "1"}},
+		{
+			name: "IPv6",
+			labels: []string{
+				"1", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0",
+				"0", "0", "0", "0", "0", "0", "0", "0", "8", "b", "d", "0", "1", "0", "0", "2",
+			},
+			want: "2001:db8::1",
+		},
+		{name: "IPv6 Bad Nibble", labels: append([]string{"g"}, make([]string, 31)...)},
+		{name: "Neither", labels: []string{"1", "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, ok := parseReverse(tt.labels)
+			if tt.want == "" {
+				assert.False(t, ok)
+				return
+			}
+			require.True(t, ok)
+			assert.Equal(t, netip.MustParseAddr(tt.want), addr)
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package geoiptest
+
+import "net/netip"
+
+// Sample networks contained in SampleCity.
+var (
+	USNetwork   = netip.MustParsePrefix("8.8.8.0/24")
+	GBNetwork   = netip.MustParsePrefix("81.2.69.0/24")
+	IPv6Network = netip.MustParsePrefix("2606:4700::/32")
+)
+
+// TorNetwork is a network of Tor exit nodes in SampleAnonymousIP, which
+// SampleCity has no data for.
+var TorNetwork = netip.MustParsePrefix("185.220.101.0/24")
+
+// SampleCity returns a small GeoLite2-City database with a US network, a
+// London network and an IPv6 network.
+func SampleCity() Database {
+	return Database{
+		DatabaseType: "GeoLite2-City",
+		BuildEpoch:   1735689600,
+		Networks: map[netip.Prefix]any{
+			USNetwork: Map{
+				"continent": Map{
+					"code":       "NA",
+					"geoname_id": uint32(6255149),
+					"names":      Map{"en": "North America", "de": "Nordamerika"},
+				},
+				"country": Map{
+					"geoname_id": uint32(6252001),
+					"iso_code":   "US",
+					"names":      Map{"en": "United States", "de": "USA"},
+				},
+				"registered_country": Map{
+					"geoname_id": uint32(6252001),
+					"iso_code":   "US",
+					"names":      Map{"en": "United States", "de": "USA"},
+				},
+				"location": Map{
+					"accuracy_radius": uint16(1000),
+					"latitude":        37.751,
+					"longitude":       -97.822,
+					"time_zone":       "America/Chicago",
+				},
+			},
+			GBNetwork: Map{
+				"city": Map{
+					"geoname_id": uint32(2643743),
+					"names":      Map{"en": "London", "de": "London"},
+				},
+				"continent": Map{
+					"code":       "EU",
+					"geoname_id": uint32(6255148),
+					"names":      Map{"en": "Europe"},
+				},
+				"country": Map{
+					"geoname_id": uint32(2635167),
+					"iso_code":   "GB",
+					"names":      Map{"en": "United Kingdom"},
+				},
+				"registered_country": Map{
+					"geoname_id":           uint32(3017382),
+					"iso_code":             "FR",
+					"is_in_european_union": true,
+					"names":                Map{"en": "France"},
+				},
+				"location": Map{
+					"accuracy_radius": uint16(10),
+					"latitude":        51.5142,
+					"longitude":       -0.0931,
+					"time_zone":       "Europe/London",
+				},
+				"postal": Map{"code": "EC2V"},
+				"subdivisions": []any{
+					Map{
+						"geoname_id": uint32(6269131),
+						"iso_code":   "ENG",
+						"names":      Map{"en": "England"},
+					},
+				},
+			},
+			IPv6Network: Map{
+				"continent": Map{"code": "NA", "geoname_id": uint32(6255149)},
+				"country": Map{
+					"geoname_id": uint32(6252001),
+					"iso_code":   "US",
+					"names":      Map{"en": "United States"},
+				},
+				"traits": Map{"is_anycast": true},
+			},
+		},
+	}
+}
+
+// SampleASN returns a small GeoLite2-ASN database with the US and London
+// networks of SampleCity.
+func SampleASN() Database {
+	return Database{
+		DatabaseType: "GeoLite2-ASN",
+		BuildEpoch:   1735689600,
+		Networks: map[netip.Prefix]any{
+			USNetwork: Map{
+				"autonomous_system_number":       uint32(15169),
+				"autonomous_system_organization": "GOOGLE",
+			},
+			GBNetwork: Map{
+				"autonomous_system_number":       uint32(20712),
+				"autonomous_system_organization": "Andrews & Arnold Ltd",
+			},
+		},
+	}
+}
+
+// SampleAnonymousIP returns a small GeoIP2-Anonymous-IP database that flags
+// the US network as a hosting provider and TorNetwork as Tor exit nodes.
+func SampleAnonymousIP() Database {
+	return Database{
+		DatabaseType: "GeoIP2-Anonymous-IP",
+		BuildEpoch:   1735689600,
+		Networks: map[netip.Prefix]any{
+			USNetwork: Map{
+				"is_anonymous":        true,
+				"is_hosting_provider": true,
+			},
+			TorNetwork: Map{
+				"is_anonymous":     true,
+				"is_tor_exit_node": true,
+			},
+		},
+	}
+}
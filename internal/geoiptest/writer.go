@@ -0,0 +1,311 @@
+// Package geoiptest builds small in-memory MaxMind DB files for tests.
+//
+// The real GeoLite2 databases are not distributed with the repository, so
+// tests that need actual lookups use Build to produce a database containing
+// only the networks they care about. The output can be passed directly to
+// geoip.OpenBytes.
+package geoiptest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/netip"
+	"sort"
+)
+
+const (
+	recordSize = 32
+	ipVersion  = 6
+)
+
+var metadataStartMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// Map is a MaxMind DB map. Values may be string, bool, float64, int, uint,
+// uint16, uint32, uint64, []any, map[string]any or Map.
+type Map = map[string]any
+
+// Database describes the contents of a test database.
+type Database struct {
+	// DatabaseType is written to the metadata, e.g. "GeoLite2-City".
+	DatabaseType string
+	// BuildEpoch is written to the metadata. Zero means 1.
+	BuildEpoch uint64
+	// Networks maps CIDRs to their records. IPv4 prefixes are stored in the
+	// IPv4-mapped part of the tree, like the MaxMind databases do.
+	Networks map[netip.Prefix]any
+}
+
+// Build returns the database encoded in the MaxMind DB format.
+func Build(db Database) ([]byte, error) {
+	root := &node{}
+
+	// Insert the less specific networks first so more specific networks
+	// carve holes out of them.
+	prefixes := make([]netip.Prefix, 0, len(db.Networks))
+	for p := range db.Networks {
+		prefixes = append(prefixes, p)
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return prefixes[i].Bits() < prefixes[j].Bits() })
+	for _, p := range prefixes {
+		var addr [16]byte
+		bits := p.Bits()
+		if p.Addr().Is4() {
+			a4 := p.Masked().Addr().As4()
+			copy(addr[12:], a4[:])
+			bits += 96
+		} else {
+			addr = p.Masked().Addr().As16()
+		}
+		if bits == 0 {
+			return nil, fmt.Errorf("geoiptest: cannot insert %s", p)
+		}
+		root.insert(addr, bits, 0, &leaf{value: db.Networks[p]})
+	}
+
+	var data bytes.Buffer
+	nodes := root.number()
+	nodeCount := uint32(len(nodes))
+
+	tree := make([]byte, 0, len(nodes)*recordSize/4)
+	for _, n := range nodes {
+		for _, c := range n.children {
+			record := nodeCount
+			if c != nil {
+				var err error
+				if record, err = c.record(nodeCount, &data); err != nil {
+					return nil, err
+				}
+			}
+			tree = binary.BigEndian.AppendUint32(tree, record)
+		}
+	}
+
+	epoch := db.BuildEpoch
+	if epoch == 0 {
+		epoch = 1
+	}
+	var meta bytes.Buffer
+	err := encode(&meta, Map{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 epoch,
+		"database_type":               db.DatabaseType,
+		"description":                 Map{"en": "WhereGo test database"},
+		"ip_version":                  uint16(ipVersion),
+		"languages":                   []any{"en"},
+		"node_count":                  nodeCount,
+		"record_size":                 uint16(recordSize),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(tree)+16+data.Len()+len(metadataStartMarker)+meta.Len())
+	out = append(out, tree...)
+	out = append(out, make([]byte, 16)...)
+	out = append(out, data.Bytes()...)
+	out = append(out, metadataStartMarker...)
+	out = append(out, meta.Bytes()...)
+	return out, nil
+}
+
+// MustBuild is like Build but panics on error.
+func MustBuild(db Database) []byte {
+	b, err := Build(db)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// child is either a *node or a *leaf. A nil child has no data.
+type child interface {
+	record(nodeCount uint32, data *bytes.Buffer) (uint32, error)
+}
+
+type node struct {
+	children [2]child
+	id       uint32
+}
+
+type leaf struct {
+	value  any
+	offset int
+}
+
+func (n *node) insert(addr [16]byte, bits, depth int, l *leaf) {
+	bit := (addr[depth/8] >> (7 - depth%8)) & 1
+	if depth == bits-1 {
+		n.children[bit] = l
+		return
+	}
+	next, ok := n.children[bit].(*node)
+	if !ok {
+		next = &node{}
+		// Push an existing, less specific record down both branches.
+		if existing, isLeaf := n.children[bit].(*leaf); isLeaf {
+			next.children = [2]child{existing, existing}
+		}
+		n.children[bit] = next
+	}
+	next.insert(addr, bits, depth+1, l)
+}
+
+// number assigns node ids in breadth-first order and returns the nodes.
+func (n *node) number() []*node {
+	var nodes []*node
+	queue := []*node{n}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		cur.id = uint32(len(nodes))
+		nodes = append(nodes, cur)
+		for _, c := range cur.children {
+			if next, ok := c.(*node); ok {
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nodes
+}
+
+func (n *node) record(uint32, *bytes.Buffer) (uint32, error) {
+	return n.id, nil
+}
+
+func (l *leaf) record(nodeCount uint32, data *bytes.Buffer) (uint32, error) {
+	if l.offset == 0 {
+		// Offset zero is a valid position in the data section, so store
+		// it shifted by one to tell it apart from "not yet written".
+		l.offset = data.Len() + 1
+		if err := encode(data, l.value); err != nil {
+			return 0, err
+		}
+	}
+	return nodeCount + 16 + uint32(l.offset-1), nil
+}
+
+const (
+	typeString  = 2
+	typeDouble  = 3
+	typeUint16  = 5
+	typeUint32  = 6
+	typeMap     = 7
+	typeInt32   = 8
+	typeUint64  = 9
+	typeArray   = 11
+	typeBoolean = 14
+)
+
+func encode(w *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case string:
+		writeControl(w, typeString, len(v))
+		w.WriteString(v)
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		writeControl(w, typeBoolean, size)
+	case float64:
+		writeControl(w, typeDouble, 8)
+		_ = binary.Write(w, binary.BigEndian, math.Float64bits(v))
+	case int:
+		if v < 0 {
+			if v < math.MinInt32 {
+				return fmt.Errorf("geoiptest: int %d out of range", v)
+			}
+			writeControl(w, typeInt32, 4)
+			_ = binary.Write(w, binary.BigEndian, int32(v))
+			return nil
+		}
+		return encode(w, uint64(v))
+	case uint:
+		return encode(w, uint64(v))
+	case uint16:
+		writeUint(w, typeUint16, uint64(v))
+	case uint32:
+		writeUint(w, typeUint32, uint64(v))
+	case uint64:
+		if v <= math.MaxUint32 {
+			writeUint(w, typeUint32, v)
+		} else {
+			writeUint(w, typeUint64, v)
+		}
+	case []any:
+		writeControl(w, typeArray, len(v))
+		for _, item := range v {
+			if err := encode(w, item); err != nil {
+				return err
+			}
+		}
+	case []string:
+		items := make([]any, len(v))
+		for i, s := range v {
+			items[i] = s
+		}
+		return encode(w, items)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		writeControl(w, typeMap, len(v))
+		for _, k := range keys {
+			if err := encode(w, k); err != nil {
+				return err
+			}
+			if err := encode(w, v[k]); err != nil {
+				return err
+			}
+		}
+	case map[string]string:
+		m := make(Map, len(v))
+		for k, s := range v {
+			m[k] = s
+		}
+		return encode(w, m)
+	default:
+		return fmt.Errorf("geoiptest: unsupported type %T", v)
+	}
+	return nil
+}
+
+func writeUint(w *bytes.Buffer, typeNum int, v uint64) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	raw := bytes.TrimLeft(buf[:], "\x00")
+	writeControl(w, typeNum, len(raw))
+	w.Write(raw)
+}
+
+func writeControl(w *bytes.Buffer, typeNum, size int) {
+	var sizeBits byte
+	var ext []byte
+	switch {
+	case size < 29:
+		sizeBits = byte(size)
+	case size < 285:
+		sizeBits = 29
+		ext = []byte{byte(size - 29)}
+	case size < 65821:
+		sizeBits = 30
+		s := size - 285
+		ext = []byte{byte(s >> 8), byte(s)}
+	default:
+		sizeBits = 31
+		s := size - 65821
+		ext = []byte{byte(s >> 16), byte(s >> 8), byte(s)}
+	}
+	if typeNum <= 7 {
+		w.WriteByte(byte(typeNum<<5) | sizeBits)
+	} else {
+		w.WriteByte(sizeBits)
+		w.WriteByte(byte(typeNum - 7))
+	}
+	w.Write(ext)
+}
@@ -0,0 +1,65 @@
+package geoiptest
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuild_RoundTrip(t *testing.T) {
+	b, err := Build(Database{
+		DatabaseType: "Test-DB",
+		BuildEpoch:   1700000000,
+		Networks: map[netip.Prefix]any{
+			netip.MustParsePrefix("10.0.0.0/8"):     Map{"name": "wide"},
+			netip.MustParsePrefix("10.1.0.0/16"):    Map{"name": "narrow", "id": uint32(7), "ok": true},
+			netip.MustParsePrefix("2001:db8::/32"):  Map{"lat": 1.5, "tags": []any{"a", "b"}},
+			netip.MustParsePrefix("203.0.113.0/24"): "plain",
+		},
+	})
+	require.NoError(t, err)
+
+	db, err := maxminddb.OpenBytes(b)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+
+	assert.Equal(t, "Test-DB", db.Metadata.DatabaseType)
+	assert.Equal(t, uint(1700000000), db.Metadata.BuildEpoch)
+	require.NoError(t, db.Verify())
+
+	tests := []struct {
+		ip     string
+		prefix string
+		want   any
+	}{
+		{"10.2.3.4", "10.2.0.0/15", map[string]any{"name": "wide"}},
+		{"10.1.2.3", "10.1.0.0/16", map[string]any{"name": "narrow", "id": uint64(7), "ok": true}},
+		{"2001:db8::1", "2001:db8::/32", map[string]any{"lat": 1.5, "tags": []any{"a", "b"}}},
+		{"203.0.113.9", "203.0.113.0/24", "plain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			result := db.Lookup(netip.MustParseAddr(tt.ip))
+			require.True(t, result.Found())
+			var got any
+			require.NoError(t, result.Decode(&got))
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.prefix, result.Prefix().String())
+		})
+	}
+
+	assert.False(t, db.Lookup(netip.MustParseAddr("192.0.2.1")).Found())
+}
+
+func TestBuild_UnsupportedType(t *testing.T) {
+	_, err := Build(Database{Networks: map[netip.Prefix]any{
+		netip.MustParsePrefix("10.0.0.0/8"): struct{}{},
+	}})
+	assert.Error(t, err)
+	assert.Panics(t, func() {
+		MustBuild(Database{Networks: map[netip.Prefix]any{netip.MustParsePrefix("::/0"): "x"}})
+	})
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gustavosett/WhereGo/internal/auth"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// HeaderAPIKey is the request header carrying the API key.
+	HeaderAPIKey = "X-API-Key"
+	// QueryAPIKey is the query parameter carrying the API key, for clients
+	// that cannot set headers.
+	QueryAPIKey = "api_key"
+
+	apiKeyContextKey  = "wherego.api_key"
+	apiKeysContextKey = "wherego.api_keys"
+)
+
+// APIKey requires a valid API key from keys on every request, counts the
+// request against the quota of the key and makes the key available through
+// APIKeyFromContext.
+func APIKey(keys *auth.Keys) echo.MiddlewareFunc {
+	return APIKeyFrom(keys, nil)
+}
+
+// APIKeyFrom is APIKey for routes whose clients pass the key elsewhere, such
+// as in the token query parameter ipinfo clients send. When a request has
+// no key in the X-API-Key header or the api_key query parameter, it takes
+// the key returned by secret.
+func APIKeyFrom(keys *auth.Keys, secret func(echo.Context) string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			secret := requestAPIKey(c, secret)
+			if secret == "" {
+				return WriteProblem(c, NewProblem(http.StatusUnauthorized, CodeMissingAPIKey,
+					"Pass an API key in the "+HeaderAPIKey+" header or the "+QueryAPIKey+" query parameter."))
+			}
+			key, ok := keys.Authenticate(secret)
+			if !ok {
+				return WriteProblem(c, NewProblem(http.StatusUnauthorized, CodeInvalidAPIKey, "The API key is not valid."))
+			}
+
+			now := time.Now()
+			usage, err := keys.Use(key, c.Path(), now)
+			if p := keyUseProblem(err); p != nil {
+				if errors.Is(err, auth.ErrQuotaExceeded) {
+					retryAfter := key.ResetsAt(usage, now).Sub(now)
+					c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(int(retryAfter.Seconds())+1))
+				}
+				return WriteProblem(c, p)
+			}
+
+			c.Set(apiKeyContextKey, key)
+			c.Set(apiKeysContextKey, keys)
+			return next(c)
+		}
+	}
+}
+
+// keyUseProblem returns the problem of a request auth.Keys.Use refused
+// with err, or nil if err is nil.
+func keyUseProblem(err error) *Problem {
+	switch {
+	case errors.Is(err, auth.ErrRouteNotAllowed):
+		return NewProblem(http.StatusForbidden, CodeRouteNotAllowed, "The API key may not call this route.")
+	case errors.Is(err, auth.ErrQuotaExceeded):
+		return NewProblem(http.StatusTooManyRequests, CodeQuotaExceeded, "The quota of the API key is used up.")
+	}
+	return nil
+}
+
+// lookupQuota counts the lookups of a request that serves many of them,
+// such as a lookup stream or a WebSocket connection, against the quota of
+// the API key that authenticated the request. The request itself was
+// counted by APIKey and pays for the first lookup.
+type lookupQuota struct {
+	c echo.Context
+	// started reports whether the first lookup was made.
+	started bool
+}
+
+func newLookupQuota(c echo.Context) *lookupQuota {
+	return &lookupQuota{c: c}
+}
+
+// use counts one lookup. It returns the problem to answer the lookup with
+// instead when the key may not make it, such as a quota_exceeded problem,
+// and nil on routes without API keys.
+func (q *lookupQuota) use() *Problem {
+	if !q.started {
+		q.started = true
+		return nil
+	}
+	key, ok := APIKeyFromContext(q.c)
+	if !ok {
+		return nil
+	}
+	keys := q.c.Get(apiKeysContextKey).(*auth.Keys)
+	_, err := keys.Use(key, q.c.Path(), time.Now())
+	return keyUseProblem(err)
+}
+
+func requestAPIKey(c echo.Context, fallback func(echo.Context) string) string {
+	secret := c.Request().Header.Get(HeaderAPIKey)
+	if secret == "" && c.Request().URL.RawQuery != "" {
+		secret = c.QueryParam(QueryAPIKey)
+	}
+	if secret == "" && fallback != nil {
+		secret = fallback(c)
+	}
+	return secret
+}
+
+// APIKeyFromContext returns the key that authenticated the request, if the
+// route requires one.
+func APIKeyFromContext(c echo.Context) (*auth.Key, bool) {
+	key, ok := c.Get(apiKeyContextKey).(*auth.Key)
+	return key, ok
+}
+
+// AdminOnly restricts a route to admin keys. It must run after APIKey.
+func AdminOnly(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if key, ok := APIKeyFromContext(c); !ok || !key.Admin {
+			return WriteProblem(c, NewProblem(http.StatusForbidden, CodeRouteNotAllowed,
+				"The route requires an admin API key."))
+		}
+		return next(c)
+	}
+}
+
+// UsageReport lists the usage counters of API keys.
+type UsageReport struct {
+	Keys []auth.Usage `json:"keys"`
+}
+
+// KeyUsage serves the usage counters of every API key.
+func KeyUsage(keys *auth.Keys) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, UsageReport{Keys: keys.Usage(time.Now())})
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/gustavosett/WhereGo/internal/auth"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAuthServer(t *testing.T) (*echo.Echo, *auth.Keys) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+keys:
+  - name: team
+    hash: `+auth.HashKey("team-secret")+`
+    routes: ["/v1/*"]
+    daily_quota: 2
+  - name: ops
+    hash: `+auth.HashKey("ops-secret")+`
+    admin: true
+`), 0o600))
+	keys, err := auth.LoadKeys(path)
+	require.NoError(t, err)
+
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	ok := func(c echo.Context) error {
+		key, _ := APIKeyFromContext(c)
+		return c.String(http.StatusOK, key.Name)
+	}
+	e.GET("/health", HealthCheck)
+	e.GET("/v1/lookup/:ip", ok, APIKey(keys))
+	e.GET("/lookup/:ip", ok, APIKey(keys))
+	e.GET("/admin/usage", KeyUsage(keys), APIKey(keys), AdminOnly)
+	return e, keys
+}
+
+func TestAPIKey(t *testing.T) {
+	e, _ := newAuthServer(t)
+
+	tests := []struct {
+		name     string
+		target   string
+		header   string
+		wantCode int
+		wantBody string
+	}{
+		{"Open Route", "/health", "", http.StatusOK, `"status":"ok"`},
+		{"Missing Key", "/v1/lookup/8.8.8.8", "", http.StatusUnauthorized, `"code":"missing_api_key"`},
+		{"Invalid Key", "/v1/lookup/8.8.8.8", "wrong", http.StatusUnauthorized, `"code":"invalid_api_key"`},
+		{"Header Key", "/v1/lookup/8.8.8.8", "team-secret", http.StatusOK, "team"},
+		{"Query Key", "/v1/lookup/8.8.8.8?api_key=ops-secret", "", http.StatusOK, "ops"},
+		{"Route Not Allowed", "/lookup/8.8.8.8", "team-secret", http.StatusForbidden, `"code":"route_not_allowed"`},
+		{"Admin Route Needs Admin Key", "/admin/usage", "team-secret", http.StatusForbidden, `"code":"route_not_allowed"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set(HeaderAPIKey, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.wantBody)
+			if tt.wantCode != http.StatusOK {
+				assert.Equal(t, MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+			}
+		})
+	}
+}
+
+func TestAPIKey_Quota(t *testing.T) {
+	e, _ := newAuthServer(t)
+
+	get := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/lookup/8.8.8.8", nil)
+		req.Header.Set(HeaderAPIKey, "team-secret")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	assert.Equal(t, http.StatusOK, get().Code)
+	assert.Equal(t, http.StatusOK, get().Code)
+
+	rec := get()
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"quota_exceeded"`)
+	retryAfter, err := strconv.Atoi(rec.Header().Get(echo.HeaderRetryAfter))
+	require.NoError(t, err)
+	assert.Positive(t, retryAfter)
+	assert.LessOrEqual(t, retryAfter, 24*60*60+1)
+}
+
+func TestKeyUsage(t *testing.T) {
+	e, _ := newAuthServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/lookup/8.8.8.8", nil)
+	req.Header.Set(HeaderAPIKey, "team-secret")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/usage", nil)
+	req.Header.Set(HeaderAPIKey, "ops-secret")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var report UsageReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	require.Len(t, report.Keys, 2)
+	assert.Equal(t, "ops", report.Keys[0].Name)
+	assert.Equal(t, int64(1), report.Keys[0].Total)
+	assert.Equal(t, "team", report.Keys[1].Name)
+	assert.Equal(t, int64(1), report.Keys[1].Daily)
+	assert.Equal(t, int64(2), report.Keys[1].DailyQuota)
+}
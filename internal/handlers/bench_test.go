@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gustavosett/WhereGo/internal/geoiptest"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Allocation budgets for GeoIPHandler.Lookup, on top of what the lookup
+// itself costs (see the geoip package). Setting the Content-Type header
+// costs one allocation, the ETag header two.
+var handlerAllocBudgets = []struct {
+	name   string
+	cached bool
+	allocs float64
+}{
+	{"Uncached", false, 4},
+	{"Cache Hit", true, 3},
+}
+
+// lookupRunner returns a function that serves /lookup/8.8.8.8 through h,
+// reusing one echo context like the echo router does.
+func lookupRunner(tb testing.TB, cached bool) func() *httptest.ResponseRecorder {
+	tb.Helper()
+	db, err := wherego.OpenBytes(geoiptest.MustBuild(geoiptest.SampleCity()))
+	require.NoError(tb, err)
+	tb.Cleanup(func() { _ = db.Close() })
+
+	svc := &wherego.Service{DB: db}
+	if cached {
+		svc.Cache = wherego.NewCache(10)
+	}
+	h := &GeoIPHandler{GeoService: svc}
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/lookup/8.8.8.8", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	return func() *httptest.ResponseRecorder {
+		rec.Body.Reset()
+		c.Reset(req, rec)
+		c.SetParamNames("ip")
+		c.SetParamValues("8.8.8.8")
+		if err := h.Lookup(c); err != nil {
+			tb.Fatal(err)
+		}
+		return rec
+	}
+}
+
+func TestLookup_AllocationBudget(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocation counts are not stable under the race detector")
+	}
+	for _, tt := range handlerAllocBudgets {
+		t.Run(tt.name, func(t *testing.T) {
+			run := lookupRunner(t, tt.cached)
+			require.Equal(t, http.StatusOK, run().Code)
+
+			allocs := testing.AllocsPerRun(100, func() { run() })
+			assert.LessOrEqual(t, allocs, tt.allocs, "allocations per request regressed")
+		})
+	}
+}
+
+func BenchmarkGeoIPHandler_Lookup(b *testing.B) {
+	for _, tt := range handlerAllocBudgets {
+		b.Run(tt.name, func(b *testing.B) {
+			run := lookupRunner(b, tt.cached)
+			b.ReportAllocs()
+			for b.Loop() {
+				run()
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/labstack/echo/v4"
+)
+
+// Canonical header keys, for setting shared header values directly.
+const (
+	headerETag         = "Etag"
+	headerCacheControl = "Cache-Control"
+)
+
+// privateNoStore is the Cache-Control of responses that depend on who asks,
+// such as /v1/me.
+var privateNoStore = []string{"private, no-store"}
+
+// cacheControl returns the Cache-Control header of lookup responses. The
+// slice is shared by all responses and must not be modified.
+func (h *GeoIPHandler) cacheControl() []string {
+	h.cacheControlOnce.Do(func() {
+		scope := "public"
+		if h.Private {
+			scope = "private"
+		}
+		value := "no-cache"
+		if seconds := int64(h.CacheMaxAge.Seconds()); seconds > 0 {
+			value = scope + ", max-age=" + strconv.FormatInt(seconds, 10)
+		} else if h.Private {
+			value = "private, no-cache"
+		}
+		h.cacheControlHeader = []string{value}
+	})
+	return h.cacheControlHeader
+}
+
+// notModified sets the caching headers of a lookup of ip and reports
+// whether the client's copy, named by If-None-Match, is still current. It
+// sets no headers when ip is not valid, leaving the error to the lookup.
+// Addresses without a record get a Cache-Control but no ETag, so their
+// 404 may be cached but is never answered with a 304. A non-empty
+// variant, such as a format name, tells the ETags of different
+// representations of the lookup apart.
+func (h *GeoIPHandler) notModified(c echo.Context, ip, variant string) bool {
+	buf := bufferPool.Get().(*[]byte)
+	defer bufferPool.Put(buf)
+	etag, err := h.GeoService.AppendETag((*buf)[:0], ip)
+	*buf = etag[:0]
+	if errors.Is(err, wherego.ErrNotFound) {
+		c.Response().Header()[headerCacheControl] = h.cacheControl()
+		return false
+	}
+	if err != nil {
+		return false
+	}
+	if variant != "" {
+		etag = append(append(append(etag[:len(etag)-1], '-'), variant...), '"')
+	}
+
+	header := c.Response().Header()
+	header[headerETag] = []string{string(etag)}
+	header[headerCacheControl] = h.cacheControl()
+	return etagMatches(c.Request().Header.Get("If-None-Match"), etag)
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag,
+// using the weak comparison of RFC 9110.
+func etagMatches(ifNoneMatch string, etag []byte) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	opaque := bytes.TrimPrefix(etag, []byte("W/"))
+	for ifNoneMatch != "" {
+		var tag string
+		tag, ifNoneMatch, _ = strings.Cut(ifNoneMatch, ",")
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == string(opaque) {
+			return true
+		}
+	}
+	return false
+}
+
+// clearCacheHeaders removes the caching headers from a failed response.
+func clearCacheHeaders(c echo.Context) {
+	header := c.Response().Header()
+	delete(header, headerETag)
+	delete(header, headerCacheControl)
+}
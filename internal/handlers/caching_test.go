@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gustavosett/WhereGo/internal/geoiptest"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCachingServer(t *testing.T, maxAge time.Duration) *echo.Echo {
+	t.Helper()
+	return newCachingHandlerServer(t, &GeoIPHandler{CacheMaxAge: maxAge})
+}
+
+func newCachingHandlerServer(t *testing.T, h *GeoIPHandler) *echo.Echo {
+	t.Helper()
+	db, err := wherego.OpenBytes(geoiptest.MustBuild(geoiptest.SampleCity()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	h.GeoService = &wherego.Service{DB: db}
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.GET("/lookup/:ip", h.Lookup)
+	e.GET("/v1/lookup/:ip", h.LookupV1)
+	e.GET("/v1/me", h.LookupMe)
+	return e
+}
+
+func TestLookup_ConditionalRequests(t *testing.T) {
+	e := newCachingServer(t, 7*24*time.Hour)
+	const etag = `W/"spduo0-8.8.8.0/24"`
+
+	for _, target := range []string{"/lookup/8.8.8.8", "/lookup/8.8.8.8?pretty", "/v1/lookup/8.8.8.8"} {
+		t.Run(target, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, etag, rec.Header().Get("ETag"))
+			assert.Equal(t, "public, max-age=604800", rec.Header().Get("Cache-Control"))
+
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			req.Header.Set("If-None-Match", etag)
+			rec = httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusNotModified, rec.Code)
+			assert.Empty(t, rec.Body.String())
+			assert.Equal(t, etag, rec.Header().Get("ETag"))
+			assert.Equal(t, "public, max-age=604800", rec.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+func TestLookup_ConditionalNotFound(t *testing.T) {
+	e := newCachingServer(t, 7*24*time.Hour)
+
+	for _, ifNoneMatch := range []string{"*", `W/"spduo0-127.0.0.0/8"`} {
+		for _, target := range []string{"/lookup/127.0.0.1", "/v1/lookup/127.0.0.1"} {
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			req.Header.Set("If-None-Match", ifNoneMatch)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusNotFound, rec.Code, "%s %s", target, ifNoneMatch)
+			assert.Empty(t, rec.Header().Get("ETag"), "%s %s", target, ifNoneMatch)
+		}
+	}
+}
+
+func TestLookup_CacheHeaders(t *testing.T) {
+	e := newCachingServer(t, 0)
+
+	tests := []struct {
+		name         string
+		target       string
+		status       int
+		etag         bool
+		cacheControl string
+	}{
+		{"Revalidate By Default", "/v1/lookup/8.8.8.8", http.StatusOK, true, "no-cache"},
+		{"Not Found Is Cacheable", "/v1/lookup/127.0.0.1", http.StatusNotFound, false, "no-cache"},
+		{"Invalid IP", "/v1/lookup/bogus", http.StatusBadRequest, false, ""},
+		{"Caller Lookup", "/v1/me", http.StatusOK, false, "private, no-store"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.RemoteAddr = "81.2.69.160:4000"
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, tt.etag, rec.Header().Get("ETag") != "")
+			assert.Equal(t, tt.cacheControl, rec.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+func TestLookup_PrivateCacheHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		maxAge time.Duration
+		target string
+		want   string
+	}{
+		{"Max Age", 24 * time.Hour, "/v1/lookup/8.8.8.8", "private, max-age=86400"},
+		{"Key In Query", 24 * time.Hour, "/v1/lookup/8.8.8.8?api_key=secret", "private, max-age=86400"},
+		{"Legacy Route", 24 * time.Hour, "/lookup/8.8.8.8", "private, max-age=86400"},
+		{"Revalidate", 0, "/v1/lookup/8.8.8.8", "private, no-cache"},
+		{"Not Found", 24 * time.Hour, "/v1/lookup/127.0.0.1", "private, max-age=86400"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newCachingHandlerServer(t, &GeoIPHandler{CacheMaxAge: tt.maxAge, Private: true})
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			assert.Equal(t, tt.want, rec.Header().Get("Cache-Control"))
+			if rec.Code == http.StatusOK {
+				assert.NotEmpty(t, rec.Header().Get("ETag"), "clients may still revalidate")
+			}
+		})
+	}
+}
+
+func TestEtagMatches(t *testing.T) {
+	etag := []byte(`W/"spduo0-8.8.8.0/24"`)
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		want        bool
+	}{
+		{"Empty", "", false},
+		{"Exact", `W/"spduo0-8.8.8.0/24"`, true},
+		{"Strong Form", `"spduo0-8.8.8.0/24"`, true},
+		{"Any", "*", true},
+		{"List", `"abc", W/"spduo0-8.8.8.0/24"`, true},
+		{"Other Build", `W/"spduo1-8.8.8.0/24"`, false},
+		{"Unquoted", `spduo0-8.8.8.0/24`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, etagMatches(tt.ifNoneMatch, etag))
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"errors"
+	"net/netip"
+	"strconv"
+
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+)
+
+// Compatibility APIs, which mimic the JSON APIs of other geolocation
+// providers under a route prefix of the same name, so applications written
+// against them can switch by changing their base URL.
+const (
+	CompatIPAPI  = "ip-api"
+	CompatIPInfo = "ipinfo"
+)
+
+// compatLookup returns the City and ASN data of addr for the compatibility
+// APIs, which combine both in one flat response. Either may be nil when
+// the database has none; it returns wherego.ErrNotFound when both are.
+func (h *GeoIPHandler) compatLookup(addr netip.Addr) (*wherego.City, *wherego.ASN, error) {
+	city, cityErr := h.GeoService.LookupIP(addr.String())
+	asn, asnErr := h.GeoService.LookupASN(addr)
+	var invalidMethod wherego.InvalidMethodError
+	for _, err := range []error{cityErr, asnErr} {
+		if err != nil && !errors.Is(err, wherego.ErrNotFound) && !errors.As(err, &invalidMethod) {
+			return nil, nil, err
+		}
+	}
+	if city == nil && asn == nil {
+		if errors.As(cityErr, &invalidMethod) && errors.As(asnErr, &invalidMethod) {
+			return nil, nil, cityErr
+		}
+		return nil, nil, wherego.ErrNotFound
+	}
+	return city, asn, nil
+}
+
+// asOrganization formats an autonomous system as both APIs do, such as
+// "AS15169 GOOGLE".
+func asOrganization(asn *wherego.ASN) string {
+	if asn == nil || asn.AutonomousSystemNumber == 0 {
+		return ""
+	}
+	org := "AS" + strconv.FormatUint(uint64(asn.AutonomousSystemNumber), 10)
+	if asn.AutonomousSystemOrganization != "" {
+		org += " " + asn.AutonomousSystemOrganization
+	}
+	return org
+}
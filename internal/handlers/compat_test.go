@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gustavosett/WhereGo/internal/geoiptest"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCompatServer(t *testing.T) *echo.Echo {
+	t.Helper()
+	city, err := wherego.OpenBytes(geoiptest.MustBuild(geoiptest.SampleCity()))
+	require.NoError(t, err)
+	asn, err := wherego.OpenBytes(geoiptest.MustBuild(geoiptest.SampleASN()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = city.Close()
+		_ = asn.Close()
+	})
+
+	h := &GeoIPHandler{GeoService: &wherego.Service{DB: city, ASNDB: asn}}
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.IPExtractor = echo.ExtractIPDirect()
+	e.GET("/ip-api/json", h.IPAPI)
+	e.GET("/ip-api/json/:query", h.IPAPI)
+	e.GET("/ipinfo", h.IPInfo)
+	e.GET("/ipinfo/:ip", h.IPInfo)
+	e.GET("/ipinfo/:ip/:field", h.IPInfo)
+	return e
+}
+
+func serveCompat(e *echo.Echo, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.RemoteAddr = "8.8.8.8:4321"
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIPAPI(t *testing.T) {
+	e := newCompatServer(t)
+
+	tests := []struct {
+		name   string
+		target string
+		want   string
+	}{
+		{
+			name:   "Default Fields",
+			target: "/ip-api/json/81.2.69.160",
+			want: `{"status":"success","country":"United Kingdom","countryCode":"GB","region":"ENG","regionName":"England",` +
+				`"city":"London","zip":"EC2V","lat":51.5142,"lon":-0.0931,"timezone":"Europe/London",` +
+				`"isp":"Andrews & Arnold Ltd","org":"Andrews & Arnold Ltd","as":"AS20712 Andrews & Arnold Ltd","query":"81.2.69.160"}`,
+		},
+		{
+			name:   "Field Names",
+			target: "/ip-api/json/8.8.8.8?fields=query,countryCode,as,bogus",
+			want:   `{"countryCode":"US","as":"AS15169 GOOGLE","query":"8.8.8.8"}`,
+		},
+		{
+			name:   "Numeric Fields",
+			target: "/ip-api/json/8.8.8.8?fields=2097154",
+			want:   `{"continentCode":"NA","countryCode":"US"}`,
+		},
+		{
+			name:   "Language",
+			target: "/ip-api/json/8.8.8.8?fields=country,continent&lang=de",
+			want:   `{"continent":"Nordamerika","country":"USA"}`,
+		},
+		{
+			name:   "Unsupported Fields Are Empty",
+			target: "/ip-api/json/8.8.8.8?fields=city,mobile,proxy",
+			want:   `{"city":"","mobile":false,"proxy":false}`,
+		},
+		{
+			name:   "Caller",
+			target: "/ip-api/json?fields=query",
+			want:   `{"query":"8.8.8.8"}`,
+		},
+		{
+			name:   "Invalid Query",
+			target: "/ip-api/json/example.com",
+			want:   `{"status":"fail","message":"invalid query","query":"example.com"}`,
+		},
+		{
+			name:   "Private Range",
+			target: "/ip-api/json/192.168.1.1",
+			want:   `{"status":"fail","message":"private range","query":"192.168.1.1"}`,
+		},
+		{
+			name:   "Reserved Range",
+			target: "/ip-api/json/127.0.0.1",
+			want:   `{"status":"fail","message":"reserved range","query":"127.0.0.1"}`,
+		},
+		{
+			name:   "No Data",
+			target: "/ip-api/json/1.1.1.1",
+			want:   `{"status":"fail","message":"no data","query":"1.1.1.1"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveCompat(e, tt.target)
+			assert.Equal(t, http.StatusOK, rec.Code, "ip-api.com reports failures in the body")
+			assert.JSONEq(t, tt.want, rec.Body.String())
+		})
+	}
+
+	t.Run("Field Order", func(t *testing.T) {
+		rec := serveCompat(e, "/ip-api/json/8.8.8.8?fields=query,status,country")
+		assert.Equal(t, `{"status":"success","country":"United States","query":"8.8.8.8"}`, rec.Body.String())
+	})
+}
+
+func TestParseIPAPIFields(t *testing.T) {
+	assert.Equal(t, uint32(ipAPIDefaultFields), parseIPAPIFields(""))
+	assert.Equal(t, parseIPAPIFields("status,message,country,countryCode,region,regionName,city,zip,lat,lon,timezone,isp,org,as,query"),
+		uint32(ipAPIDefaultFields))
+	assert.Equal(t, uint32(1<<13|1<<1), parseIPAPIFields("query, countryCode"))
+	assert.Equal(t, uint32(66846719), parseIPAPIFields("66846719"))
+	assert.Zero(t, parseIPAPIFields("bogus"))
+}
+
+func TestTimeZoneOffset(t *testing.T) {
+	assert.Zero(t, timeZoneOffset(""))
+	assert.Zero(t, timeZoneOffset("UTC"))
+	assert.Equal(t, 9*60*60, timeZoneOffset("Asia/Tokyo"))
+	assert.Zero(t, timeZoneOffset("Not/A_Zone"))
+	assert.Zero(t, timeZoneOffset("Not/A_Zone"), "unknown zones are cached too")
+
+	v, ok := timeZones.Load("Asia/Tokyo")
+	require.True(t, ok)
+	assert.Equal(t, "Asia/Tokyo", v.(*time.Location).String())
+	v, ok = timeZones.Load("Not/A_Zone")
+	require.True(t, ok)
+	assert.Nil(t, v)
+}
+
+func TestIPInfo(t *testing.T) {
+	e := newCompatServer(t)
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantJSON   string
+		wantText   string
+	}{
+		{
+			name:       "Lookup",
+			target:     "/ipinfo/81.2.69.160",
+			wantStatus: http.StatusOK,
+			wantJSON: `{"ip":"81.2.69.160","city":"London","region":"England","country":"GB","loc":"51.5142,-0.0931",` +
+				`"org":"AS20712 Andrews & Arnold Ltd","postal":"EC2V","timezone":"Europe/London"}`,
+		},
+		{
+			name:       "Lookup JSON",
+			target:     "/ipinfo/8.8.8.8/json",
+			wantStatus: http.StatusOK,
+			wantJSON:   `{"ip":"8.8.8.8","country":"US","loc":"37.7510,-97.8220","org":"AS15169 GOOGLE","timezone":"America/Chicago"}`,
+		},
+		{name: "Field", target: "/ipinfo/81.2.69.160/city", wantStatus: http.StatusOK, wantText: "London\n"},
+		{name: "Empty Field", target: "/ipinfo/8.8.8.8/postal", wantStatus: http.StatusOK, wantText: "\n"},
+		{name: "Caller", target: "/ipinfo/json", wantStatus: http.StatusOK, wantJSON: `{"ip":"8.8.8.8","country":"US","loc":"37.7510,-97.8220","org":"AS15169 GOOGLE","timezone":"America/Chicago"}`},
+		{name: "Caller Field", target: "/ipinfo/country", wantStatus: http.StatusOK, wantText: "US\n"},
+		{name: "Caller Root", target: "/ipinfo", wantStatus: http.StatusOK, wantJSON: `{"ip":"8.8.8.8","country":"US","loc":"37.7510,-97.8220","org":"AS15169 GOOGLE","timezone":"America/Chicago"}`},
+		{name: "Bogon", target: "/ipinfo/10.0.0.1", wantStatus: http.StatusOK, wantJSON: `{"ip":"10.0.0.1","bogon":true}`},
+		{name: "No Data", target: "/ipinfo/1.1.1.1", wantStatus: http.StatusOK, wantJSON: `{"ip":"1.1.1.1"}`},
+		{
+			name:       "Wrong IP",
+			target:     "/ipinfo/bogus",
+			wantStatus: http.StatusNotFound,
+			wantJSON:   `{"status":404,"error":{"title":"Wrong ip","message":"Please provide a valid IP address"}}`,
+		},
+		{
+			name:       "Wrong Field",
+			target:     "/ipinfo/8.8.8.8/bogus",
+			wantStatus: http.StatusNotFound,
+			wantJSON:   `{"status":404,"error":{"title":"Wrong field","message":"Please provide a valid field name"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveCompat(e, tt.target)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantText != "" {
+				assert.Equal(t, tt.wantText, rec.Body.String())
+				return
+			}
+			assert.JSONEq(t, tt.wantJSON, rec.Body.String())
+		})
+	}
+}
+
+func TestIPInfoToken(t *testing.T) {
+	e := echo.New()
+	for _, tt := range []struct {
+		name   string
+		target string
+		header string
+		want   string
+	}{
+		{name: "Bearer", target: "/ipinfo/8.8.8.8", header: "Bearer secret", want: "secret"},
+		{name: "Query", target: "/ipinfo/8.8.8.8?token=secret", want: "secret"},
+		{name: "None", target: "/ipinfo/8.8.8.8", header: "Basic abc"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set(echo.HeaderAuthorization, tt.header)
+			}
+			assert.Equal(t, tt.want, IPInfoToken(e.NewContext(req, httptest.NewRecorder())))
+		})
+	}
+}
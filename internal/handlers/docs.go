@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"bytes"
+	"cmp"
+	"html/template"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// docsPage renders the API reference from the OpenAPI document on the
+// server. It loads no scripts or other resources, so the page does not
+// depend on a CDN and is served with a Content-Security-Policy that
+// forbids them.
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <title>{{.Title}} API {{.Version}}</title>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <style>
+    body { font-family: system-ui, sans-serif; margin: 0 auto; max-width: 60rem; padding: 1rem; line-height: 1.5; }
+    code { font-size: 0.95em; }
+    section { border-top: 1px solid #ddd; padding: 0.5rem 0; }
+    h3 code { background: #eef; border-radius: 0.25rem; padding: 0.1rem 0.4rem; }
+    table { border-collapse: collapse; width: 100%; }
+    th, td { border-bottom: 1px solid #eee; padding: 0.25rem 0.5rem; text-align: left; vertical-align: top; }
+  </style>
+</head>
+<body>
+  <h1>{{.Title}} API <small>{{.Version}}</small></h1>
+  <p>{{.Description}} The schemas of requests and responses are in the <a href="/openapi.json">OpenAPI document</a>.</p>
+  {{- range .Operations}}
+  <section id="{{.ID}}">
+    <h3><code>{{.Method}}</code> {{.Path}}</h3>
+    {{- with .Summary}}
+    <p><strong>{{.}}</strong></p>
+    {{- end}}
+    {{- with .Description}}
+    <p>{{.}}</p>
+    {{- end}}
+    {{- with .Parameters}}
+    <table>
+      <tr><th>Parameter</th><th>In</th><th>Description</th></tr>
+      {{- range .}}
+      <tr><td><code>{{.Name}}</code>{{if .Required}} (required){{end}}</td><td>{{.In}}</td><td>{{.Description}}</td></tr>
+      {{- end}}
+    </table>
+    {{- end}}
+    <table>
+      <tr><th>Status</th><th>Media types</th><th>Description</th></tr>
+      {{- range .Responses}}
+      <tr><td>{{.Status}}</td><td>{{range $i, $t := .MediaTypes}}{{if $i}}, {{end}}<code>{{$t}}</code>{{end}}</td><td>{{.Description}}</td></tr>
+      {{- end}}
+    </table>
+  </section>
+  {{- end}}
+</body>
+</html>
+`
+
+// docsPolicy is the Content-Security-Policy of the docs page, which only
+// needs its inline styles.
+const docsPolicy = "default-src 'none'; style-src 'unsafe-inline'; base-uri 'none'; form-action 'none'; frame-ancestors 'none'"
+
+type docsData struct {
+	Title       string
+	Version     string
+	Description string
+	Operations  []docsOperation
+}
+
+type docsOperation struct {
+	ID          string
+	Method      string
+	Path        string
+	Summary     string
+	Description string
+	Parameters  []docsParameter
+	Responses   []docsResponse
+}
+
+type docsParameter struct {
+	Name        string
+	In          string
+	Required    bool
+	Description string
+}
+
+type docsResponse struct {
+	Status      string
+	MediaTypes  []string
+	Description string
+}
+
+// docsMethods are the methods of OpenAPI path items, in the order the page
+// lists them.
+var docsMethods = []string{"get", "head", "post", "put", "patch", "delete", "options"}
+
+// newDocsData collects what the docs page shows from an OpenAPI document.
+func newDocsData(doc map[string]any) docsData {
+	info, _ := doc["info"].(map[string]any)
+	data := docsData{
+		Title:       docString(info["title"]),
+		Version:     docString(info["version"]),
+		Description: docString(info["description"]),
+	}
+	paths, _ := doc["paths"].(map[string]any)
+	for path, item := range paths {
+		item, _ := item.(map[string]any)
+		for _, method := range docsMethods {
+			op, ok := item[method].(map[string]any)
+			if !ok {
+				continue
+			}
+			data.Operations = append(data.Operations, newDocsOperation(method, path, op))
+		}
+	}
+	slices.SortFunc(data.Operations, func(a, b docsOperation) int {
+		return cmp.Or(strings.Compare(a.Path, b.Path),
+			cmp.Compare(slices.Index(docsMethods, strings.ToLower(a.Method)), slices.Index(docsMethods, strings.ToLower(b.Method))))
+	})
+	return data
+}
+
+func newDocsOperation(method, path string, op map[string]any) docsOperation {
+	o := docsOperation{
+		ID:          docString(op["operationId"]),
+		Method:      strings.ToUpper(method),
+		Path:        path,
+		Summary:     docString(op["summary"]),
+		Description: docString(op["description"]),
+	}
+	params, _ := op["parameters"].([]any)
+	for _, p := range params {
+		p, _ := p.(map[string]any)
+		required, _ := p["required"].(bool)
+		o.Parameters = append(o.Parameters, docsParameter{
+			Name:        docString(p["name"]),
+			In:          docString(p["in"]),
+			Required:    required,
+			Description: docString(p["description"]),
+		})
+	}
+	responses, _ := op["responses"].(map[string]any)
+	for status, r := range responses {
+		r, _ := r.(map[string]any)
+		content, _ := r["content"].(map[string]any)
+		mediaTypes := make([]string, 0, len(content))
+		for t := range content {
+			mediaTypes = append(mediaTypes, t)
+		}
+		slices.Sort(mediaTypes)
+		o.Responses = append(o.Responses, docsResponse{Status: status, MediaTypes: mediaTypes, Description: docString(r["description"])})
+	}
+	slices.SortFunc(o.Responses, func(a, b docsResponse) int { return strings.Compare(a.Status, b.Status) })
+	return o
+}
+
+func docString(v any) string {
+	s, _ := v.(string)
+	return s
+}
+
+var docsHTML = sync.OnceValues(func() ([]byte, error) {
+	tmpl, err := template.New("docs").Parse(docsPage)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, newDocsData(OpenAPIDocument())); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+})
+
+// Docs serves an API reference page for the OpenAPI document.
+func Docs(c echo.Context) error {
+	page, err := docsHTML()
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set("Content-Security-Policy", docsPolicy)
+	return c.HTMLBlob(http.StatusOK, page)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+
+	v1 "github.com/gustavosett/WhereGo/internal/api/v1"
+	"github.com/gustavosett/WhereGo/internal/rules"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/labstack/echo/v4"
+)
+
+// Evaluate serves POST /v1/evaluate, which decides whether h.Policy allows
+// the address in the request body, or the caller's address when the body
+// is empty or has no address.
+func (h *GeoIPHandler) Evaluate(c echo.Context) error {
+	var req v1.EvaluateRequest
+	if err := (&echo.DefaultBinder{}).BindBody(c, &req); err != nil {
+		return err
+	}
+	ip := req.IP
+	if ip == "" {
+		c.Response().Header()[headerCacheControl] = privateNoStore
+		ip = c.RealIP()
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return lookupError(c, ip, wherego.ErrInvalidIP)
+	}
+	decision, err := h.Policy.Evaluate(h.GeoService, addr)
+	if err != nil {
+		return lookupError(c, ip, err)
+	}
+	return c.JSON(http.StatusOK, v1.Decision{
+		IP:      addr.String(),
+		Allowed: decision.Allowed(),
+		Action:  string(decision.Action),
+		Rule:    decision.Rule,
+		Default: decision.Default(),
+	})
+}
+
+// GeoFence rejects requests whose client address, as reported by Echo's
+// IPExtractor, policy denies, with a 403 problem naming the rule that
+// matched.
+func GeoFence(policy *rules.Policy, svc *wherego.Service) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ip := c.RealIP()
+			addr, err := netip.ParseAddr(ip)
+			if err != nil {
+				return lookupError(c, ip, wherego.ErrInvalidIP)
+			}
+			decision, err := policy.Evaluate(svc, addr)
+			if err != nil {
+				return lookupError(c, ip, err)
+			}
+			if !decision.Allowed() {
+				return WriteProblem(c, deniedProblem(decision))
+			}
+			return next(c)
+		}
+	}
+}
+
+// deniedProblem is the problem of requests denied by decision.
+func deniedProblem(decision rules.Decision) *Problem {
+	detail := "Access from the address is denied by the default policy."
+	if !decision.Default() {
+		detail = fmt.Sprintf("Access from the address is denied by rule %q.", decision.Rule)
+	}
+	return NewProblem(http.StatusForbidden, CodeAccessDenied, detail)
+}
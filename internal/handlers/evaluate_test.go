@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	v1 "github.com/gustavosett/WhereGo/internal/api/v1"
+	"github.com/gustavosett/WhereGo/internal/geoiptest"
+	"github.com/gustavosett/WhereGo/internal/rules"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPolicy = `
+default: deny
+rules:
+  - name: no-tor
+    action: deny
+    when: anonymous.is_tor_exit_node
+  - name: north-america
+    action: allow
+    when: country in [US, CA] and asn == 15169
+`
+
+func newEvaluateServer(t *testing.T) *echo.Echo {
+	t.Helper()
+	svc := &wherego.Service{}
+	for _, db := range []struct {
+		reader **wherego.Reader
+		data   geoiptest.Database
+	}{
+		{&svc.DB, geoiptest.SampleCity()},
+		{&svc.ASNDB, geoiptest.SampleASN()},
+		{&svc.AnonymousIPDB, geoiptest.SampleAnonymousIP()},
+	} {
+		reader, err := wherego.OpenBytes(geoiptest.MustBuild(db.data))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = reader.Close() })
+		*db.reader = reader
+	}
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testPolicy), 0o600))
+	policy, err := rules.Load(path)
+	require.NoError(t, err)
+
+	h := &GeoIPHandler{GeoService: svc, Policy: policy}
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.IPExtractor = IPExtractor([]netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")})
+	e.POST("/v1/evaluate", h.Evaluate)
+	e.Match(ForwardAuthMethods, "/auth", h.ForwardAuth)
+	e.GET("/fenced", func(c echo.Context) error {
+		return c.String(http.StatusOK, "ok")
+	}, GeoFence(policy, svc))
+	return e
+}
+
+func TestEvaluate(t *testing.T) {
+	e := newEvaluateServer(t)
+
+	tests := []struct {
+		name       string
+		body       string
+		remoteAddr string
+		want       v1.Decision
+	}{
+		{"Allowed", `{"ip": "8.8.8.8"}`, "", v1.Decision{IP: "8.8.8.8", Allowed: true, Action: "allow", Rule: "north-america"}},
+		{"Denied By Rule", `{"ip": "185.220.101.7"}`, "", v1.Decision{IP: "185.220.101.7", Action: "deny", Rule: "no-tor"}},
+		{"Denied By Default", `{"ip": "81.2.69.160"}`, "", v1.Decision{IP: "81.2.69.160", Action: "deny", Default: true}},
+		{"Caller", "", "8.8.8.8:4321", v1.Decision{IP: "8.8.8.8", Allowed: true, Action: "allow", Rule: "north-america"}},
+		{"Caller Without Address", "{}", "81.2.69.160:4321", v1.Decision{IP: "81.2.69.160", Action: "deny", Default: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/evaluate", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			if tt.remoteAddr != "" {
+				req.RemoteAddr = tt.remoteAddr
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+			var got v1.Decision
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+			assert.Equal(t, tt.want, got)
+			assert.Contains(t, rec.Body.String(), `"allowed":`, "allowed is always sent")
+			if tt.remoteAddr != "" {
+				assert.Equal(t, "private, no-store", rec.Header().Get("Cache-Control"))
+			}
+		})
+	}
+
+	t.Run("Invalid IP", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/v1/evaluate", strings.NewReader(`{"ip": "bogus"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"code":"invalid_ip"`)
+	})
+
+	t.Run("Malformed Body", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/v1/evaluate", strings.NewReader(`{"ip":`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"code":"bad_request"`)
+	})
+}
+
+func TestGeoFence(t *testing.T) {
+	e := newEvaluateServer(t)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		status     int
+		detail     string
+	}{
+		{"Allowed", "8.8.8.8:4321", http.StatusOK, ""},
+		{"Denied By Rule", "185.220.101.7:4321", http.StatusForbidden, `Access from the address is denied by rule "no-tor".`},
+		{"Denied By Default", "81.2.69.160:4321", http.StatusForbidden, "Access from the address is denied by the default policy."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/fenced", nil)
+			req.RemoteAddr = tt.remoteAddr
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, tt.status, rec.Code)
+			if tt.status == http.StatusOK {
+				assert.Equal(t, "ok", rec.Body.String())
+				return
+			}
+			var p Problem
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &p))
+			assert.Equal(t, CodeAccessDenied, p.Code)
+			assert.Equal(t, tt.detail, p.Detail)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+
+	// Registers the Protocol Buffers messages of the lookup results.
+	_ "github.com/gustavosett/WhereGo/internal/api/pb"
+	"github.com/gustavosett/WhereGo/internal/format"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// QueryFormat is the query parameter that selects a response format by
+	// name, overriding the Accept header.
+	QueryFormat = "format"
+	// QueryField is the query parameter naming the value served in the
+	// text format, such as "country.iso_code".
+	QueryField = "field"
+)
+
+// responseFormat returns the format a lookup response is sent in, or nil
+// for the default JSON response. Errors are always sent as problem JSON.
+func (h *GeoIPHandler) responseFormat(c echo.Context) (*format.Format, *Problem) {
+	if h.Formats == nil {
+		return nil, nil
+	}
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+
+	var f *format.Format
+	if name := queryParam(c, QueryFormat); name != "" {
+		var ok bool
+		if f, ok = h.Formats.Lookup(name); !ok {
+			return nil, NewProblem(http.StatusBadRequest, CodeUnsupportedFormat,
+				"The format must be one of "+strings.Join(h.Formats.Names(), ", ")+".")
+		}
+	} else {
+		f = h.Formats.Negotiate(c.Request().Header.Get(echo.HeaderAccept))
+	}
+	if f == h.Formats.Default() {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// queryParam returns a query parameter without parsing an empty query
+// string, which allocates.
+func queryParam(c echo.Context, name string) string {
+	if c.Request().URL.RawQuery == "" {
+		return ""
+	}
+	return c.QueryParam(name)
+}
+
+// formatVariant names the representation of a format in ETags, since
+// formats negotiated by the Accept header share a URL.
+func formatVariant(f *format.Format) string {
+	if f == nil {
+		return ""
+	}
+	return f.Name
+}
+
+// writeFormatted sends v in format f.
+func (h *GeoIPHandler) writeFormatted(c echo.Context, f *format.Format, v any) error {
+	opts := format.Options{Field: queryParam(c, QueryField)}
+	if c.Request().URL.RawQuery != "" && c.QueryParams().Has("pretty") {
+		opts.Indent = "  "
+	}
+	var buf bytes.Buffer
+	if err := f.Serialize(&buf, v, opts); err != nil {
+		switch {
+		case errors.Is(err, format.ErrMissingField):
+			return fieldProblem(c, "Pass the value to return in the "+QueryField+" query parameter, such as "+
+				QueryField+"=country.iso_code.")
+		case errors.Is(err, format.ErrUnknownField):
+			return fieldProblem(c, "The "+QueryField+" query parameter does not name a single value of the response.")
+		}
+		return err
+	}
+	return c.Blob(http.StatusOK, f.MediaType, buf.Bytes())
+}
+
+func fieldProblem(c echo.Context, detail string) error {
+	clearCacheHeaders(c)
+	return WriteProblem(c, NewProblem(http.StatusBadRequest, CodeInvalidField, detail))
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gustavosett/WhereGo/internal/api/pb"
+	"github.com/gustavosett/WhereGo/internal/format"
+	"github.com/gustavosett/WhereGo/internal/geoiptest"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func newFormatServer(t *testing.T) *echo.Echo {
+	t.Helper()
+	db, err := wherego.OpenBytes(geoiptest.MustBuild(geoiptest.SampleCity()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	h := &GeoIPHandler{GeoService: &wherego.Service{DB: db}, Formats: format.Default()}
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.GET("/lookup/:ip", h.Lookup)
+	e.GET("/v1/lookup/:ip", h.LookupV1)
+	return e
+}
+
+// The CSV and XML layouts are part of the v1 contract, like the JSON one.
+func TestLookupV1_GoldenFormats(t *testing.T) {
+	e := newFormatServer(t)
+	for _, name := range []string{"csv", "xml"} {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/lookup/81.2.69.160?pretty&format="+name, nil))
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assertGolden(t, filepath.Join("v1", "city."+name), rec.Body.Bytes())
+		})
+	}
+}
+
+func TestLookup_Formats(t *testing.T) {
+	e := newFormatServer(t)
+
+	tests := []struct {
+		name            string
+		target          string
+		accept          string
+		wantCode        int
+		wantContentType string
+		wantBody        string
+	}{
+		{"Default JSON", "/v1/lookup/81.2.69.160", "", http.StatusOK, echo.MIMEApplicationJSON, `"iso_code":"GB"`},
+		{"Format Parameter", "/v1/lookup/81.2.69.160?format=csv", "", http.StatusOK, "text/csv; charset=utf-8", "traits.ip_address,"},
+		{"Parameter Beats Accept", "/v1/lookup/81.2.69.160?format=json", "text/csv", http.StatusOK, echo.MIMEApplicationJSON, `"iso_code":"GB"`},
+		{"Accept Header", "/v1/lookup/81.2.69.160", "application/xml", http.StatusOK, "application/xml; charset=utf-8", "<iso_code>GB</iso_code>"},
+		{"Text Field", "/v1/lookup/81.2.69.160?format=text&field=country.iso_code", "", http.StatusOK, "text/plain; charset=utf-8", "GB\n"},
+		{"Legacy Text Field", "/lookup/81.2.69.160?format=text&field=country.iso_code", "", http.StatusOK, "text/plain; charset=utf-8", "GB\n"},
+		{"Legacy CSV", "/lookup/81.2.69.160?format=csv", "", http.StatusOK, "text/csv; charset=utf-8", "traits.ip_address,"},
+		{"Missing Field", "/v1/lookup/81.2.69.160?format=text", "", http.StatusBadRequest, MIMEApplicationProblemJSON, `"code":"invalid_field"`},
+		{"Unknown Field", "/v1/lookup/81.2.69.160?format=text&field=country", "", http.StatusBadRequest, MIMEApplicationProblemJSON, `"code":"invalid_field"`},
+		{"Unknown Format", "/v1/lookup/81.2.69.160?format=yaml", "", http.StatusBadRequest, MIMEApplicationProblemJSON, `"code":"unsupported_format"`},
+		{"Errors Stay JSON", "/v1/lookup/bogus?format=csv", "", http.StatusBadRequest, MIMEApplicationProblemJSON, `"code":"invalid_ip"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.accept != "" {
+				req.Header.Set(echo.HeaderAccept, tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, tt.wantContentType, rec.Header().Get(echo.HeaderContentType))
+			assert.Contains(t, rec.Body.String(), tt.wantBody)
+			assert.Equal(t, echo.HeaderAccept, rec.Header().Get(echo.HeaderVary))
+			if tt.wantCode != http.StatusOK {
+				assert.Empty(t, rec.Header().Get(echo.HeaderCacheControl))
+			}
+		})
+	}
+}
+
+func TestLookup_FormatsMsgPack(t *testing.T) {
+	e := newFormatServer(t)
+	req := httptest.NewRequest(http.MethodGet, "/v1/lookup/81.2.69.160", nil)
+	req.Header.Set(echo.HeaderAccept, "application/msgpack")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/msgpack", rec.Header().Get(echo.HeaderContentType))
+
+	var got struct {
+		Country struct {
+			ISOCode string `msgpack:"iso_code"`
+		} `msgpack:"country"`
+	}
+	require.NoError(t, msgpack.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, "GB", got.Country.ISOCode)
+}
+
+// A protobuf response carries every field of the JSON response of the same
+// route.
+func TestLookup_FormatsProtobuf(t *testing.T) {
+	e := newFormatServer(t)
+	for _, target := range []string{"/v1/lookup/81.2.69.160", "/lookup/81.2.69.160", "/v1/lookup/2606:4700::1111"} {
+		t.Run(target, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+			require.Equal(t, http.StatusOK, rec.Code)
+			want := rec.Body.String()
+
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			req.Header.Set(echo.HeaderAccept, "application/x-protobuf")
+			rec = httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "application/x-protobuf", rec.Header().Get(echo.HeaderContentType))
+			var city pb.City
+			require.NoError(t, proto.Unmarshal(rec.Body.Bytes(), &city))
+
+			got, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(&city)
+			require.NoError(t, err)
+			assertJSONSubset(t, want, string(got))
+		})
+	}
+}
+
+// assertJSONSubset checks that got has the non-empty values of want. The
+// legacy JSON response writes empty fields, which protobuf leaves out.
+func assertJSONSubset(t *testing.T, want, got string) {
+	t.Helper()
+	var wantValue, gotValue any
+	require.NoError(t, json.Unmarshal([]byte(want), &wantValue))
+	require.NoError(t, json.Unmarshal([]byte(got), &gotValue))
+	assert.Equal(t, prune(wantValue), gotValue)
+}
+
+// prune drops empty values, as protobuf does for unset fields.
+func prune(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if e = prune(e); e == nil {
+				delete(v, k)
+			} else {
+				v[k] = e
+			}
+		}
+		if len(v) == 0 {
+			return nil
+		}
+	case []any:
+		for i, e := range v {
+			if e = prune(e); e == nil {
+				e = map[string]any{}
+			}
+			v[i] = e
+		}
+		if len(v) == 0 {
+			return nil
+		}
+	case string, float64, bool:
+		if v == "" || v == 0.0 || v == false {
+			return nil
+		}
+	}
+	return v
+}
+
+func TestLookup_FormatsETag(t *testing.T) {
+	e := newFormatServer(t)
+	get := func(accept, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/lookup/81.2.69.160", nil)
+		req.Header.Set(echo.HeaderAccept, accept)
+		req.Header.Set("If-None-Match", ifNoneMatch)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	jsonETag := get(echo.MIMEApplicationJSON, "").Header().Get(headerETag)
+	csvETag := get("text/csv", "").Header().Get(headerETag)
+	assert.Equal(t, `W/"spduo0-81.2.69.0/24"`, jsonETag, "JSON keeps the plain ETag")
+	assert.Equal(t, `W/"spduo0-81.2.69.0/24-csv"`, csvETag)
+
+	assert.Equal(t, http.StatusNotModified, get("text/csv", csvETag).Code)
+	assert.Equal(t, http.StatusOK, get("text/csv", jsonETag).Code, "a cached JSON response is no CSV response")
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+	"net/netip"
+	"strconv"
+
+	"github.com/gustavosett/WhereGo/internal/rules"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/labstack/echo/v4"
+)
+
+// Headers /auth sets besides the location headers of package wherego.
+const (
+	// HeaderGeoASN is the autonomous system number of the client address,
+	// when the server has ASN data.
+	HeaderGeoASN = "X-Geo-ASN"
+	// HeaderGeoASOrganization is the organization of the autonomous system.
+	HeaderGeoASOrganization = "X-Geo-AS-Organization"
+	// HeaderGeoDecision is the action of the policy, "allow" or "deny".
+	HeaderGeoDecision = "X-Geo-Decision"
+	// HeaderGeoRule is the name of the policy rule that matched. It is not
+	// set when the default action applied.
+	HeaderGeoRule = "X-Geo-Rule"
+)
+
+// ForwardAuthMethods are the methods /auth answers. Nginx auth_request and
+// Traefik ForwardAuth ask with GET; Envoy's HTTP ext_authz asks with the
+// method of the original request.
+var ForwardAuthMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// ForwardAuth serves /auth for reverse proxies that ask before forwarding a
+// request, such as Nginx auth_request, Traefik ForwardAuth and Envoy's HTTP
+// ext_authz. Only the HTTP service of ext_authz is supported; there is no
+// gRPC envoy.service.auth.v3 Authorization service. It answers 200 when h.Policy allows the client address and a
+// 403 problem when it denies it, with the location of the address and the
+// decision in X-Geo-* headers the proxy can pass on. The client address
+// comes from Echo's IPExtractor, so the proxy must be trusted for its
+// X-Forwarded-For header to count.
+func (h *GeoIPHandler) ForwardAuth(c echo.Context) error {
+	header := c.Response().Header()
+	header[headerCacheControl] = privateNoStore
+	ip := c.RealIP()
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return lookupError(c, ip, wherego.ErrInvalidIP)
+	}
+	addr = addr.Unmap()
+
+	sources := rules.SourceCity
+	if h.GeoService.ASNDB != nil {
+		sources |= rules.SourceASN
+	}
+	decision, attrs, err := h.Policy.EvaluateWith(h.GeoService, addr, sources)
+	if err != nil {
+		return lookupError(c, ip, err)
+	}
+	SetGeoHeaders(header, addr, attrs.City)
+	if attrs.ASN != nil && attrs.ASN.AutonomousSystemNumber != 0 {
+		header.Set(HeaderGeoASN, strconv.FormatUint(uint64(attrs.ASN.AutonomousSystemNumber), 10))
+		if org := attrs.ASN.AutonomousSystemOrganization; org != "" {
+			header.Set(HeaderGeoASOrganization, org)
+		}
+	}
+	header.Set(HeaderGeoDecision, string(decision.Action))
+	if !decision.Default() {
+		header.Set(HeaderGeoRule, decision.Rule)
+	}
+
+	if !decision.Allowed() {
+		return WriteProblem(c, deniedProblem(decision))
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// SetGeoHeaders sets the X-Geo-* location headers of addr from its City
+// data, which may be nil. Headers without data are not set.
+func SetGeoHeaders(h http.Header, addr netip.Addr, city *wherego.City) {
+	set := func(name, value string) {
+		if value != "" {
+			h.Set(name, value)
+		}
+	}
+	set(wherego.HeaderGeoIP, addr.String())
+	if city == nil {
+		return
+	}
+	set(wherego.HeaderGeoCountry, city.Country.ISOCode)
+	set(wherego.HeaderGeoContinent, city.Continent.Code)
+	if len(city.Subdivisions) > 0 {
+		set(wherego.HeaderGeoRegion, city.Subdivisions[0].ISOCode)
+	}
+	set(wherego.HeaderGeoCity, city.City.Names.English)
+	set(wherego.HeaderGeoPostal, city.Postal.Code)
+	if city.Location.HasCoordinates() {
+		set(wherego.HeaderGeoLatitude, strconv.FormatFloat(*city.Location.Latitude, 'f', -1, 64))
+		set(wherego.HeaderGeoLongitude, strconv.FormatFloat(*city.Location.Longitude, 'f', -1, 64))
+	}
+	set(wherego.HeaderGeoTimeZone, city.Location.TimeZone)
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForwardAuth(t *testing.T) {
+	e := newEvaluateServer(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		remoteAddr string
+		forwarded  string
+		status     int
+		headers    map[string]string
+	}{
+		{
+			name:       "Allowed",
+			method:     http.MethodGet,
+			remoteAddr: "127.0.0.1:4321",
+			forwarded:  "8.8.8.8",
+			status:     http.StatusOK,
+			headers: map[string]string{
+				wherego.HeaderGeoIP: "8.8.8.8", wherego.HeaderGeoCountry: "US", wherego.HeaderGeoContinent: "NA",
+				wherego.HeaderGeoLatitude: "37.751", wherego.HeaderGeoLongitude: "-97.822", wherego.HeaderGeoTimeZone: "America/Chicago",
+				HeaderGeoASN: "15169", HeaderGeoASOrganization: "GOOGLE",
+				HeaderGeoDecision: "allow", HeaderGeoRule: "north-america",
+			},
+		},
+		{
+			name:       "Denied By Rule",
+			method:     http.MethodGet,
+			remoteAddr: "127.0.0.1:4321",
+			forwarded:  "185.220.101.7",
+			status:     http.StatusForbidden,
+			headers:    map[string]string{wherego.HeaderGeoIP: "185.220.101.7", HeaderGeoDecision: "deny", HeaderGeoRule: "no-tor"},
+		},
+		{
+			name:       "Denied By Default",
+			method:     http.MethodPost,
+			remoteAddr: "127.0.0.1:4321",
+			forwarded:  "8.8.4.4, 81.2.69.160",
+			status:     http.StatusForbidden,
+			headers: map[string]string{
+				wherego.HeaderGeoIP: "81.2.69.160", wherego.HeaderGeoCountry: "GB", wherego.HeaderGeoCity: "London",
+				HeaderGeoDecision: "deny", HeaderGeoRule: "",
+			},
+		},
+		{
+			name:       "Forwarded Header Ignored From Untrusted Peer",
+			method:     http.MethodGet,
+			remoteAddr: "81.2.69.160:4321",
+			forwarded:  "8.8.8.8",
+			status:     http.StatusForbidden,
+			headers:    map[string]string{wherego.HeaderGeoIP: "81.2.69.160", HeaderGeoDecision: "deny"},
+		},
+		{
+			name:       "Head",
+			method:     http.MethodHead,
+			remoteAddr: "127.0.0.1:4321",
+			forwarded:  "8.8.8.8",
+			status:     http.StatusOK,
+			headers:    map[string]string{HeaderGeoDecision: "allow"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/auth", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Forwarded-For", tt.forwarded)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, "private, no-store", rec.Header().Get("Cache-Control"))
+			for name, value := range tt.headers {
+				assert.Equal(t, value, rec.Header().Get(name), name)
+			}
+			if tt.status == http.StatusForbidden {
+				assert.Contains(t, rec.Body.String(), `"code":"access_denied"`)
+			}
+		})
+	}
+}
@@ -1,31 +1,130 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/gustavosett/WhereGo/internal/geoip"
+	"github.com/gustavosett/WhereGo/internal/format"
+	"github.com/gustavosett/WhereGo/internal/rules"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
 	"github.com/labstack/echo/v4"
 )
 
 type GeoIPHandler struct {
-	GeoService *geoip.Service
+	GeoService *wherego.Service
+	// CacheMaxAge is how long clients and shared caches may reuse a lookup
+	// response, typically the database update interval. Zero makes them
+	// revalidate every response with its ETag. It must be set before the
+	// handler serves requests.
+	CacheMaxAge time.Duration
+	// Private keeps lookup responses out of shared caches, for routes behind
+	// API keys or a geo-fencing policy: a proxy cache would otherwise serve
+	// them to clients that were never checked, including responses to
+	// requests with the key in the api_key query parameter.
+	Private bool
+	// Formats are the response formats offered besides JSON, picked with
+	// the format query parameter or the Accept header. Nil serves JSON
+	// only.
+	Formats *format.Registry
+	// Policy is the geo-fencing policy POST /v1/evaluate decides with.
+	Policy *rules.Policy
+
+	cacheControlOnce   sync.Once
+	cacheControlHeader []string
 }
 
-var (
-	errInvalidIP = map[string]string{"error": "invalid IP address"}
-	errNoData    = map[string]string{"error": "no data found for the given IP"}
-	healthOK     = map[string]string{"status": "ok"}
-)
+var healthOK = map[string]string{"status": "ok"}
+
+// bufferPool holds response buffers for the JSON fast path. A full City
+// response is 1.5-2KB, so buffers rarely need to grow.
+var bufferPool = sync.Pool{New: func() any {
+	b := make([]byte, 0, 2048)
+	return &b
+}}
 
 func (h *GeoIPHandler) Lookup(c echo.Context) error {
-	result, err := h.GeoService.LookupIP(c.Param("ip"))
-	if err != nil {
-		if err == geoip.ErrInvalidIP {
-			return c.JSON(http.StatusBadRequest, errInvalidIP)
+	f, p := h.responseFormat(c)
+	if p != nil {
+		return WriteProblem(c, p)
+	}
+	if h.notModified(c, c.Param("ip"), formatVariant(f)) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	if !h.GeoService.DB.KnownDatabaseType() {
+		// Custom databases have no fixed schema, serve the decoded record.
+		result, err := h.GeoService.LookupRecord(c.Param("ip"))
+		if err != nil {
+			return lookupError(c, c.Param("ip"), err)
 		}
-		return c.JSON(http.StatusNotFound, errNoData)
+		if f != nil {
+			return h.writeFormatted(c, f, result)
+		}
+		return c.JSON(http.StatusOK, result)
+	}
+
+	if f != nil {
+		result, err := h.GeoService.LookupIP(c.Param("ip"))
+		if err != nil {
+			return lookupError(c, c.Param("ip"), err)
+		}
+		return h.writeFormatted(c, f, result)
 	}
-	return c.JSON(http.StatusOK, result)
+
+	// Only look at the query string when there is one; parsing it
+	// allocates even when it is empty.
+	if c.Request().URL.RawQuery != "" && c.QueryParams().Has("pretty") {
+		result, err := h.GeoService.LookupIP(c.Param("ip"))
+		if err != nil {
+			return lookupError(c, c.Param("ip"), err)
+		}
+		return c.JSON(http.StatusOK, result)
+	}
+
+	buf := bufferPool.Get().(*[]byte)
+	defer bufferPool.Put(buf)
+	body, err := h.GeoService.AppendIPJSON((*buf)[:0], c.Param("ip"))
+	*buf = body[:0]
+	if err != nil {
+		return lookupError(c, c.Param("ip"), err)
+	}
+	return c.JSONBlob(http.StatusOK, body)
+}
+
+func lookupError(c echo.Context, ip string, err error) error {
+	p := lookupProblem(c, ip, err)
+	// Tags validate records; a problem has none, and a matching
+	// If-None-Match must not turn it into a 304.
+	delete(c.Response().Header(), headerETag)
+	if p.Status >= http.StatusInternalServerError {
+		// Only a definite answer, such as "not found", may be cached.
+		clearCacheHeaders(c)
+	}
+	return WriteProblem(c, p)
+}
+
+// Metrics exposes the lookup cache counters in the Prometheus text format.
+func (h *GeoIPHandler) Metrics(c echo.Context) error {
+	var b strings.Builder
+	if h.GeoService.Cache != nil {
+		stats := h.GeoService.Cache.Stats()
+		writeMetric(&b, "wherego_cache_hits_total", "counter", "Lookups served from the cache.", float64(stats.Hits))
+		writeMetric(&b, "wherego_cache_misses_total", "counter", "Lookups that missed the cache.", float64(stats.Misses))
+		writeMetric(&b, "wherego_cache_evictions_total", "counter", "Entries evicted from the cache.", float64(stats.Evictions))
+		writeMetric(&b, "wherego_cache_entries", "gauge", "Entries currently in the cache.", float64(stats.Entries))
+		writeMetric(&b, "wherego_cache_capacity", "gauge", "Maximum number of cache entries.", float64(stats.Capacity))
+		writeMetric(&b, "wherego_cache_hit_ratio", "gauge", "Fraction of lookups served from the cache.", stats.HitRatio())
+	}
+	return c.String(http.StatusOK, b.String())
+}
+
+func writeMetric(b *strings.Builder, name, kind, help string, value float64) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n%s %s\n",
+		name, help, name, kind, name, strconv.FormatFloat(value, 'g', -1, 64))
 }
 
 func HealthCheck(c echo.Context) error {
@@ -5,10 +5,12 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"strings"
 	"testing"
 
-	"github.com/gustavosett/WhereGo/internal/geoip"
+	"github.com/gustavosett/WhereGo/internal/geoiptest"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/require"
 )
@@ -42,7 +44,7 @@ func TestHealthCheck(t *testing.T) {
 
 func TestLookupIntegration(t *testing.T) {
 	dbPath := "../../data/city.db"
-	service, err := geoip.NewService(dbPath)
+	service, err := wherego.NewService(dbPath)
 	if err != nil {
 		t.Skipf("Skipping integration test: could not open database at %s: %v", dbPath, err)
 	}
@@ -64,7 +66,7 @@ func TestLookupIntegration(t *testing.T) {
 		t.Errorf("Expected status 200 for valid IP, got %d", rec.Code)
 	}
 
-	var result geoip.City
+	var result wherego.City
 	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
 		t.Errorf("Failed to unmarshal response: %v", err)
 	}
@@ -81,7 +83,7 @@ func TestLookupIntegration(t *testing.T) {
 
 func TestLookupDBError(t *testing.T) {
 	dbPath := "../../data/city.db"
-	service, err := geoip.NewService(dbPath)
+	service, err := wherego.NewService(dbPath)
 	if err != nil {
 		t.Skipf("Skipping integration test: could not open database at %s: %v", dbPath, err)
 	}
@@ -97,7 +99,81 @@ func TestLookupDBError(t *testing.T) {
 	rec := httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
 
-	if rec.Code != http.StatusNotFound {
-		t.Errorf("Expected status 404 for DB error, got %d", rec.Code)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500 for DB error, got %d", rec.Code)
 	}
 }
+
+func TestLookupCustomDatabase(t *testing.T) {
+	dbBytes := geoiptest.MustBuild(geoiptest.Database{
+		DatabaseType: "Acme-Offices",
+		Networks: map[netip.Prefix]any{
+			netip.MustParsePrefix("10.20.0.0/16"): geoiptest.Map{"site": "Lisbon"},
+		},
+	})
+	db, err := wherego.OpenBytes(dbBytes, wherego.AllowUnknownDatabaseType())
+	require.NoError(t, err)
+
+	h := &GeoIPHandler{GeoService: &wherego.Service{DB: db}}
+	e := echo.New()
+	e.GET("/lookup/:ip", h.Lookup)
+
+	req := httptest.NewRequest(http.MethodGet, "/lookup/10.20.0.1", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"site":"Lisbon"}`, rec.Body.String())
+}
+
+func TestLookupCached(t *testing.T) {
+	db, err := wherego.OpenBytes(geoiptest.MustBuild(geoiptest.SampleCity()))
+	require.NoError(t, err)
+
+	uncached := &GeoIPHandler{GeoService: &wherego.Service{DB: db}}
+	cached := &GeoIPHandler{GeoService: &wherego.Service{DB: db, Cache: wherego.NewCache(100)}}
+
+	serve := func(h *GeoIPHandler, target string) *httptest.ResponseRecorder {
+		e := echo.New()
+		e.GET("/lookup/:ip", h.Lookup)
+		e.GET("/metrics", h.Metrics)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	for _, target := range []string{"/lookup/81.2.69.160", "/lookup/81.2.69.160", "/lookup/8.8.8.8?pretty"} {
+		want := serve(uncached, target)
+		got := serve(cached, target)
+		require.Equal(t, http.StatusOK, got.Code)
+		require.Equal(t, want.Header().Get("Content-Type"), got.Header().Get("Content-Type"))
+
+		// The echo default serializer honors omitzero and jsoniter does
+		// not, so compare the decoded results.
+		var wantCity, gotCity wherego.City
+		require.NoError(t, json.Unmarshal(want.Body.Bytes(), &wantCity))
+		require.NoError(t, json.Unmarshal(got.Body.Bytes(), &gotCity))
+		require.Equal(t, wantCity, gotCity)
+	}
+
+	rec := serve(cached, "/lookup/invalid-ip")
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = serve(cached, "/metrics")
+	require.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	// The pretty-printed lookup skips the pre-serialized JSON but still
+	// goes through the cache.
+	require.Contains(t, body, "wherego_cache_hits_total 1\n")
+	require.Contains(t, body, "wherego_cache_misses_total 2\n")
+	require.Contains(t, body, "wherego_cache_entries 2\n")
+	require.Contains(t, body, "# TYPE wherego_cache_hit_ratio gauge\n")
+
+	rec = serve(uncached, "/metrics")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, rec.Body.String())
+
+	require.NoError(t, db.Close())
+	rec = serve(cached, "/lookup/81.2.69.160")
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+}
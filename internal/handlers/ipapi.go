@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/labstack/echo/v4"
+)
+
+// ip-api.com query parameters.
+const (
+	// QueryIPAPIFields selects the response fields, by name or as the sum
+	// of their numeric values.
+	QueryIPAPIFields = "fields"
+	// QueryIPAPILang selects the language of names, such as "de".
+	QueryIPAPILang = "lang"
+	// QueryIPAPIKey is the query parameter ip-api.com pro clients pass
+	// their key in.
+	QueryIPAPIKey = "key"
+)
+
+// ipAPIRecord is the data of an ip-api.com response.
+type ipAPIRecord struct {
+	query string
+	lang  string
+	city  wherego.City
+	asn   wherego.ASN
+}
+
+// ipAPIField is a field of the ip-api.com response, with its numeric value
+// for the fields query parameter.
+type ipAPIField struct {
+	name  string
+	bit   uint32
+	value func(r *ipAPIRecord) any
+}
+
+// ipAPIFields are the response fields in the order ip-api.com writes them.
+// district, currency, reverse, mobile, proxy and hosting have no source in
+// the MaxMind databases and are always empty.
+var ipAPIFields = []ipAPIField{
+	{"status", 1 << 14, func(*ipAPIRecord) any { return "success" }},
+	{"message", 1 << 15, nil},
+	{"continent", 1 << 20, func(r *ipAPIRecord) any { return r.city.Continent.Names.Localized(r.lang) }},
+	{"continentCode", 1 << 21, func(r *ipAPIRecord) any { return r.city.Continent.Code }},
+	{"country", 1 << 0, func(r *ipAPIRecord) any { return r.city.Country.Names.Localized(r.lang) }},
+	{"countryCode", 1 << 1, func(r *ipAPIRecord) any { return r.city.Country.ISOCode }},
+	{"region", 1 << 2, func(r *ipAPIRecord) any { return r.region().ISOCode }},
+	{"regionName", 1 << 3, func(r *ipAPIRecord) any { return r.region().Names.Localized(r.lang) }},
+	{"city", 1 << 4, func(r *ipAPIRecord) any { return r.city.City.Names.Localized(r.lang) }},
+	{"district", 1 << 19, func(*ipAPIRecord) any { return "" }},
+	{"zip", 1 << 5, func(r *ipAPIRecord) any { return r.city.Postal.Code }},
+	{"lat", 1 << 6, func(r *ipAPIRecord) any { return deref(r.city.Location.Latitude) }},
+	{"lon", 1 << 7, func(r *ipAPIRecord) any { return deref(r.city.Location.Longitude) }},
+	{"timezone", 1 << 8, func(r *ipAPIRecord) any { return r.city.Location.TimeZone }},
+	{"offset", 1 << 25, func(r *ipAPIRecord) any { return timeZoneOffset(r.city.Location.TimeZone) }},
+	{"currency", 1 << 23, func(*ipAPIRecord) any { return "" }},
+	{"isp", 1 << 9, func(r *ipAPIRecord) any { return r.asn.AutonomousSystemOrganization }},
+	{"org", 1 << 10, func(r *ipAPIRecord) any { return r.asn.AutonomousSystemOrganization }},
+	{"as", 1 << 11, func(r *ipAPIRecord) any { return asOrganization(&r.asn) }},
+	{"asname", 1 << 22, func(r *ipAPIRecord) any { return r.asn.AutonomousSystemOrganization }},
+	{"reverse", 1 << 12, func(*ipAPIRecord) any { return "" }},
+	{"mobile", 1 << 16, func(*ipAPIRecord) any { return false }},
+	{"proxy", 1 << 17, func(*ipAPIRecord) any { return false }},
+	{"hosting", 1 << 24, func(*ipAPIRecord) any { return false }},
+	{"query", 1 << 13, func(r *ipAPIRecord) any { return r.query }},
+}
+
+// ipAPIDefaultFields are the fields ip-api.com sends when the request does
+// not select any: status, message, country, countryCode, region,
+// regionName, city, zip, lat, lon, timezone, isp, org, as and query.
+const ipAPIDefaultFields = 61439
+
+func (r *ipAPIRecord) region() wherego.CitySubdivision {
+	if len(r.city.Subdivisions) == 0 {
+		return wherego.CitySubdivision{}
+	}
+	return r.city.Subdivisions[0]
+}
+
+func deref[T any](p *T) T {
+	var v T
+	if p != nil {
+		v = *p
+	}
+	return v
+}
+
+// timeZones caches the locations of time zone names, nil for unknown
+// names. time.LoadLocation reads and parses the zone file on every call;
+// the names come from the database, so there are a few hundred at most.
+var timeZones sync.Map // string -> *time.Location
+
+// timeZoneOffset returns the current UTC offset of an IANA time zone in
+// seconds, or zero if the zone is not known.
+func timeZoneOffset(name string) int {
+	if name == "" {
+		return 0
+	}
+	v, ok := timeZones.Load(name)
+	if !ok {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			loc = nil
+		}
+		v, _ = timeZones.LoadOrStore(name, loc)
+	}
+	loc := v.(*time.Location)
+	if loc == nil {
+		return 0
+	}
+	_, offset := time.Now().In(loc).Zone()
+	return offset
+}
+
+// parseIPAPIFields parses the fields query parameter: a comma-separated
+// list of field names or the sum of their numeric values. Unknown names are
+// ignored, as ip-api.com does.
+func parseIPAPIFields(s string) uint32 {
+	if s == "" {
+		return ipAPIDefaultFields
+	}
+	if n, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return uint32(n)
+	}
+	var mask uint32
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		for _, f := range ipAPIFields {
+			if f.name == name {
+				mask |= f.bit
+			}
+		}
+	}
+	return mask
+}
+
+// IPAPI serves GET /ip-api/json/{query} in the JSON format of ip-api.com,
+// from the City and ASN data of the address. An empty query looks up the
+// caller's address. As on ip-api.com, failed lookups are successful
+// responses with a "fail" status and a message: "invalid query",
+// "private range" or "reserved range", and "no data" when the databases
+// have nothing for a public address.
+func (h *GeoIPHandler) IPAPI(c echo.Context) error {
+	query := c.Param("query")
+	if query == "" {
+		c.Response().Header()[headerCacheControl] = privateNoStore
+		query = c.RealIP()
+	}
+	var fields uint32 = ipAPIDefaultFields
+	var lang string
+	if c.Request().URL.RawQuery != "" {
+		fields = parseIPAPIFields(c.QueryParam(QueryIPAPIFields))
+		lang = c.QueryParam(QueryIPAPILang)
+	}
+
+	addr, err := netip.ParseAddr(query)
+	if err != nil {
+		return writeIPAPIFail(c, query, "invalid query")
+	}
+	city, asn, err := h.compatLookup(addr)
+	switch {
+	case errors.Is(err, wherego.ErrNotFound):
+		switch wherego.ClassifyAddress(addr) {
+		case "":
+			return writeIPAPIFail(c, query, "no data")
+		case wherego.AddressClassPrivate, wherego.AddressClassCGNAT:
+			return writeIPAPIFail(c, query, "private range")
+		default:
+			return writeIPAPIFail(c, query, "reserved range")
+		}
+	case err != nil:
+		return lookupError(c, query, err)
+	}
+
+	r := &ipAPIRecord{query: query, lang: lang}
+	if city != nil {
+		r.city = *city
+	}
+	if asn != nil {
+		r.asn = *asn
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for _, f := range ipAPIFields {
+		if fields&f.bit == 0 || f.value == nil {
+			continue
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		value, err := json.Marshal(f.value(r))
+		if err != nil {
+			return err
+		}
+		buf.WriteString(strconv.Quote(f.name))
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return c.JSONBlob(http.StatusOK, buf.Bytes())
+}
+
+// IPAPIFailure is the ip-api.com response to a failed lookup.
+type IPAPIFailure struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Query   string `json:"query"`
+}
+
+func writeIPAPIFail(c echo.Context, query, message string) error {
+	return c.JSON(http.StatusOK, IPAPIFailure{Status: "fail", Message: message, Query: query})
+}
+
+// IPAPIKey returns the key ip-api.com pro clients pass, for APIKeyFrom.
+func IPAPIKey(c echo.Context) string {
+	return c.QueryParam(QueryIPAPIKey)
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/labstack/echo/v4"
+)
+
+// QueryIPInfoToken is the query parameter ipinfo.io clients pass their
+// token in. They may send it as a bearer token instead.
+const QueryIPInfoToken = "token"
+
+// IPInfoResponse is the response of ipinfo.io, of which the fields with a
+// source in the MaxMind databases are served.
+type IPInfoResponse struct {
+	IP       string `json:"ip"`
+	Bogon    bool   `json:"bogon,omitempty"`
+	City     string `json:"city,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Country  string `json:"country,omitempty"`
+	Loc      string `json:"loc,omitempty"`
+	Org      string `json:"org,omitempty"`
+	Postal   string `json:"postal,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// ipInfoFields are the fields that can be requested alone, as
+// /{ip}/{field}, in the order of the response.
+var ipInfoFields = []string{"ip", "city", "region", "country", "loc", "org", "postal", "timezone"}
+
+// field returns the value of the field named name.
+func (r *IPInfoResponse) field(name string) string {
+	switch name {
+	case "ip":
+		return r.IP
+	case "city":
+		return r.City
+	case "region":
+		return r.Region
+	case "country":
+		return r.Country
+	case "loc":
+		return r.Loc
+	case "org":
+		return r.Org
+	case "postal":
+		return r.Postal
+	case "timezone":
+		return r.Timezone
+	}
+	return ""
+}
+
+// IPInfoError is the body of ipinfo.io errors.
+type IPInfoError struct {
+	Status int `json:"status"`
+	Error  struct {
+		Title   string `json:"title"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func writeIPInfoError(c echo.Context, status int, title, message string) error {
+	body := IPInfoError{Status: status}
+	body.Error.Title = title
+	body.Error.Message = message
+	return c.JSON(status, body)
+}
+
+// IPInfo serves the ipinfo.io routes under /ipinfo from the City and ASN
+// data of an address:
+//
+//	/ipinfo, /ipinfo/json         the caller's address
+//	/ipinfo/{ip}, /ipinfo/{ip}/json
+//	/ipinfo/{field}               a field of the caller's address
+//	/ipinfo/{ip}/{field}          a field as text, such as "US\n"
+//
+// Special-purpose addresses without data are bogons, as on ipinfo.io.
+func (h *GeoIPHandler) IPInfo(c echo.Context) error {
+	ip, field := c.Param("ip"), c.Param("field")
+	if field == "" && (ip == "json" || slices.Contains(ipInfoFields, ip)) {
+		// /ipinfo/json and /ipinfo/{field} are about the caller.
+		ip, field = "", ip
+	}
+	if field == "json" {
+		field = ""
+	}
+	if ip == "" {
+		c.Response().Header()[headerCacheControl] = privateNoStore
+		ip = c.RealIP()
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return writeIPInfoError(c, http.StatusNotFound, "Wrong ip", "Please provide a valid IP address")
+	}
+	if field != "" && !slices.Contains(ipInfoFields, field) {
+		return writeIPInfoError(c, http.StatusNotFound, "Wrong field", "Please provide a valid field name")
+	}
+
+	r := &IPInfoResponse{IP: addr.String()}
+	city, asn, err := h.compatLookup(addr)
+	switch {
+	case errors.Is(err, wherego.ErrNotFound):
+		r.Bogon = wherego.ClassifyAddress(addr) != ""
+	case err != nil:
+		return lookupError(c, ip, err)
+	default:
+		r.Org = asOrganization(asn)
+		if city != nil {
+			r.City = city.City.Names.English
+			if len(city.Subdivisions) > 0 {
+				r.Region = city.Subdivisions[0].Names.English
+			}
+			r.Country = city.Country.ISOCode
+			if loc := city.Location; loc.Latitude != nil && loc.Longitude != nil {
+				r.Loc = strconv.FormatFloat(*loc.Latitude, 'f', 4, 64) + "," +
+					strconv.FormatFloat(*loc.Longitude, 'f', 4, 64)
+			}
+			r.Postal = city.Postal.Code
+			r.Timezone = city.Location.TimeZone
+		}
+	}
+
+	if field != "" {
+		return c.String(http.StatusOK, r.field(field)+"\n")
+	}
+	return c.JSON(http.StatusOK, r)
+}
+
+// IPInfoToken returns the token ipinfo.io clients pass, as a bearer token
+// or in the token query parameter, for APIKeyFrom.
+func IPInfoToken(c echo.Context) string {
+	if token, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer "); ok {
+		return token
+	}
+	return c.QueryParam(QueryIPInfoToken)
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"github.com/gustavosett/WhereGo/internal/auth"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/labstack/echo/v4"
+)
+
+// The routes under MaxMindPrefix mimic the GeoIP2 Precision web services,
+// so applications using MaxMind's official clients only need to change the
+// host they call. Requests, responses and errors follow
+// https://dev.maxmind.com/geoip/docs/web-services/.
+const MaxMindPrefix = "/geoip/v2.1"
+
+// MaxMind web services, the path segment after MaxMindPrefix.
+const (
+	MaxMindCountry  = "country"
+	MaxMindCity     = "city"
+	MaxMindInsights = "insights"
+)
+
+// maxMindMe in place of an address looks up the caller's address.
+const maxMindMe = "me"
+
+// MaxMind error codes. Clients map them to exceptions, so they are the
+// codes MaxMind documents.
+const (
+	MaxMindCodeIPInvalid            = "IP_ADDRESS_INVALID"
+	MaxMindCodeIPReserved           = "IP_ADDRESS_RESERVED"
+	MaxMindCodeIPNotFound           = "IP_ADDRESS_NOT_FOUND"
+	MaxMindCodeAccountIDRequired    = "ACCOUNT_ID_REQUIRED"
+	MaxMindCodeLicenseKeyRequired   = "LICENSE_KEY_REQUIRED"
+	MaxMindCodeAuthorizationInvalid = "AUTHORIZATION_INVALID"
+	MaxMindCodePermissionRequired   = "PERMISSION_REQUIRED"
+	MaxMindCodeInsufficientFunds    = "INSUFFICIENT_FUNDS"
+	// MaxMindCodeServerError is not a MaxMind code; MaxMind answers server
+	// errors without a body, which clients report by status alone.
+	MaxMindCodeServerError = "SERVER_ERROR"
+)
+
+const maxMindErrorContentType = "application/vnd.maxmind.com-error+json; charset=UTF-8; version=2.0"
+
+// MaxMindError is the body of MaxMind web service errors.
+type MaxMindError struct {
+	Code  string `json:"code"`
+	Error string `json:"error"`
+}
+
+// MaxMindMeta is the "maxmind" object of MaxMind web service responses.
+type MaxMindMeta struct {
+	// QueriesRemaining is left out when the API key has no quota.
+	QueriesRemaining *int64 `json:"queries_remaining,omitempty"`
+}
+
+type maxMindCountryResponse struct {
+	*wherego.Country
+	MaxMind *MaxMindMeta `json:"maxmind,omitempty"`
+}
+
+type maxMindCityResponse struct {
+	*wherego.City
+	MaxMind *MaxMindMeta `json:"maxmind,omitempty"`
+}
+
+type maxMindInsightsResponse struct {
+	*wherego.Enterprise
+	MaxMind *MaxMindMeta `json:"maxmind,omitempty"`
+}
+
+const maxMindMetaContextKey = "wherego.maxmind"
+
+// writeMaxMind sends v with encoding/json rather than the JSON serializer
+// of the server: MaxMind leaves out empty fields, which encoding/json does
+// for the omitzero fields of the models and json-iterator does not.
+func writeMaxMind(c echo.Context, status int, contentType string, v any) error {
+	c.Response().Header().Set(echo.HeaderContentType, contentType)
+	c.Response().WriteHeader(status)
+	return json.NewEncoder(c.Response()).Encode(v)
+}
+
+func writeMaxMindError(c echo.Context, status int, code, message string) error {
+	return writeMaxMind(c, status, maxMindErrorContentType, MaxMindError{Code: code, Error: message})
+}
+
+// MaxMindAuth authenticates requests the way the MaxMind web services do:
+// with HTTP Basic authentication, the account ID as the user name and the
+// license key as the password. The license key is an API key from keys,
+// and must come with the AccountID of the key when it has one. Like
+// APIKey, it counts the request against the quota of the key and makes the
+// key available through APIKeyFromContext; responses report the queries
+// left.
+func MaxMindAuth(keys *auth.Keys) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			accountID, licenseKey, _ := c.Request().BasicAuth()
+			switch {
+			case accountID == "":
+				return maxMindUnauthorized(c, MaxMindCodeAccountIDRequired,
+					"You have not supplied a MaxMind account ID in the Authorization header.")
+			case licenseKey == "":
+				return maxMindUnauthorized(c, MaxMindCodeLicenseKeyRequired,
+					"You have not supplied a MaxMind license key in the Authorization header.")
+			}
+			key, ok := keys.Authenticate(licenseKey)
+			if !ok || key.AccountID != "" && key.AccountID != accountID {
+				return maxMindUnauthorized(c, MaxMindCodeAuthorizationInvalid,
+					"You have supplied an invalid MaxMind account ID and/or license key in the Authorization header.")
+			}
+
+			usage, err := keys.Use(key, c.Path(), time.Now())
+			switch {
+			case errors.Is(err, auth.ErrRouteNotAllowed):
+				return writeMaxMindError(c, http.StatusForbidden, MaxMindCodePermissionRequired,
+					"You do not have permission to use the service.")
+			case errors.Is(err, auth.ErrQuotaExceeded):
+				return writeMaxMindError(c, http.StatusPaymentRequired, MaxMindCodeInsufficientFunds,
+					"The license key you have provided is out of queries.")
+			}
+
+			c.Set(apiKeyContextKey, key)
+			if remaining, ok := usage.Remaining(); ok {
+				c.Set(maxMindMetaContextKey, &MaxMindMeta{QueriesRemaining: &remaining})
+			}
+			return next(c)
+		}
+	}
+}
+
+func maxMindUnauthorized(c echo.Context, code, message string) error {
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Basic realm="GeoIP2"`)
+	return writeMaxMindError(c, http.StatusUnauthorized, code, message)
+}
+
+// MaxMind serves GET MaxMindPrefix/{service}/{ip} for one of the MaxMind
+// web services. The ip "me" looks up the caller's address.
+//
+// Country and City answer from the City lookup, without the fields
+// MaxMind's responses do not have. Insights answers from Enterprise
+// databases, and like City from other databases.
+func (h *GeoIPHandler) MaxMind(service string) echo.HandlerFunc {
+	contentType := "application/vnd.maxmind.com-" + service + "+json; charset=UTF-8; version=2.1"
+	return func(c echo.Context) error {
+		ip := c.Param("ip")
+		if ip == maxMindMe {
+			ip = c.RealIP()
+		}
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			return writeMaxMindError(c, http.StatusBadRequest, MaxMindCodeIPInvalid,
+				fmt.Sprintf("The value %q is not a valid IP address.", ip))
+		}
+		// Responses differ per caller and per remaining queries.
+		c.Response().Header()[headerCacheControl] = privateNoStore
+		meta, _ := c.Get(maxMindMetaContextKey).(*MaxMindMeta)
+
+		var result any
+		if service == MaxMindInsights {
+			result, err = h.maxMindInsights(addr, meta)
+		} else {
+			result, err = h.maxMindCity(service, addr, meta)
+		}
+		if err != nil {
+			return maxMindLookupError(c, addr, err)
+		}
+		return writeMaxMind(c, http.StatusOK, contentType, result)
+	}
+}
+
+func (h *GeoIPHandler) maxMindCity(service string, addr netip.Addr, meta *MaxMindMeta) (any, error) {
+	city, err := h.GeoService.LookupIP(addr.String())
+	if err != nil {
+		return nil, err
+	}
+	if service == MaxMindCountry {
+		return maxMindCountryResponse{Country: &wherego.Country{
+			Traits: wherego.CountryTraits{
+				IPAddress: city.Traits.IPAddress,
+				Network:   city.Traits.Network,
+				IsAnycast: city.Traits.IsAnycast,
+			},
+			Continent:          city.Continent,
+			RepresentedCountry: city.RepresentedCountry,
+			Country:            city.Country,
+			RegisteredCountry:  city.RegisteredCountry,
+		}, MaxMind: meta}, nil
+	}
+	// Copy the result: cached results are shared.
+	response := *city
+	response.Labels = nil
+	response.Source = ""
+	response.Traits.AddressClass = ""
+	response.Traits.EmbeddedIPv4 = ""
+	return maxMindCityResponse{City: &response, MaxMind: meta}, nil
+}
+
+func (h *GeoIPHandler) maxMindInsights(addr netip.Addr, meta *MaxMindMeta) (any, error) {
+	enterprise, err := h.GeoService.LookupEnterprise(addr)
+	var invalidMethod wherego.InvalidMethodError
+	switch {
+	case errors.As(err, &invalidMethod):
+		return h.maxMindCity(MaxMindInsights, addr, meta)
+	case err != nil:
+		return nil, err
+	}
+	return maxMindInsightsResponse{Enterprise: enterprise, MaxMind: meta}, nil
+}
+
+// maxMindLookupError maps the error of a lookup of addr to a MaxMind
+// error. Special-purpose addresses without data are reserved, as MaxMind
+// reports them.
+func maxMindLookupError(c echo.Context, addr netip.Addr, err error) error {
+	var invalidMethod wherego.InvalidMethodError
+	switch {
+	case errors.Is(err, wherego.ErrNotFound) && wherego.ClassifyAddress(addr) != "":
+		return writeMaxMindError(c, http.StatusBadRequest, MaxMindCodeIPReserved,
+			fmt.Sprintf("The IP address you provided (%s) is a reserved IP address (private, multicast, etc.).", addr))
+	case errors.Is(err, wherego.ErrNotFound):
+		return writeMaxMindError(c, http.StatusNotFound, MaxMindCodeIPNotFound,
+			fmt.Sprintf("The address %s is not in our database.", addr))
+	case errors.As(err, &invalidMethod):
+		return writeMaxMindError(c, http.StatusInternalServerError, MaxMindCodeServerError, err.Error())
+	default:
+		c.Logger().Errorf("lookup %s: %v", addr, err)
+		return writeMaxMindError(c, http.StatusInternalServerError, MaxMindCodeServerError, "The lookup failed.")
+	}
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gustavosett/WhereGo/internal/auth"
+	"github.com/gustavosett/WhereGo/internal/geoiptest"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMaxMindServer(t *testing.T, db geoiptest.Database, middleware ...echo.MiddlewareFunc) *echo.Echo {
+	t.Helper()
+	reader, err := wherego.OpenBytes(geoiptest.MustBuild(db))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = reader.Close() })
+
+	h := &GeoIPHandler{GeoService: &wherego.Service{DB: reader}}
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.IPExtractor = echo.ExtractIPDirect()
+	g := e.Group(MaxMindPrefix, middleware...)
+	for _, service := range []string{MaxMindCountry, MaxMindCity, MaxMindInsights} {
+		g.GET("/"+service+"/:ip", h.MaxMind(service))
+	}
+	return e
+}
+
+func serveMaxMind(e *echo.Echo, target string, setup func(*http.Request)) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.RemoteAddr = "81.2.69.160:4321"
+	if setup != nil {
+		setup(req)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMaxMind(t *testing.T) {
+	e := newMaxMindServer(t, geoiptest.SampleCity())
+
+	tests := []struct {
+		name            string
+		target          string
+		wantStatus      int
+		wantContentType string
+		wantContains    []string
+		wantMissing     []string
+	}{
+		{
+			name:            "City",
+			target:          "/geoip/v2.1/city/81.2.69.160",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/vnd.maxmind.com-city+json; charset=UTF-8; version=2.1",
+			wantContains:    []string{`"geoname_id":2643743`, `"postal":{"code":"EC2V"}`, `"ip_address":"81.2.69.160"`},
+			wantMissing:     []string{"maxmind", "address_class"},
+		},
+		{
+			name:            "Country",
+			target:          "/geoip/v2.1/country/81.2.69.160",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/vnd.maxmind.com-country+json; charset=UTF-8; version=2.1",
+			wantContains:    []string{`"iso_code":"GB"`, `"registered_country":{"names":{"en":"France"}`, `"network":"81.2.69.0/24"`},
+			wantMissing:     []string{`"city"`, `"location"`, `"postal"`, `"subdivisions"`},
+		},
+		{
+			name:            "Insights From City Database",
+			target:          "/geoip/v2.1/insights/8.8.8.8",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/vnd.maxmind.com-insights+json; charset=UTF-8; version=2.1",
+			wantContains:    []string{`"iso_code":"US"`, `"time_zone":"America/Chicago"`},
+		},
+		{
+			name:         "Me",
+			target:       "/geoip/v2.1/city/me",
+			wantStatus:   http.StatusOK,
+			wantContains: []string{`"ip_address":"81.2.69.160"`},
+		},
+		{
+			name:            "Invalid IP",
+			target:          "/geoip/v2.1/city/bogus",
+			wantStatus:      http.StatusBadRequest,
+			wantContentType: maxMindErrorContentType,
+			wantContains:    []string{`"code":"IP_ADDRESS_INVALID"`, `The value \"bogus\" is not a valid IP address.`},
+		},
+		{
+			name:         "Reserved IP",
+			target:       "/geoip/v2.1/country/10.0.0.1",
+			wantStatus:   http.StatusBadRequest,
+			wantContains: []string{`"code":"IP_ADDRESS_RESERVED"`, "(10.0.0.1)"},
+		},
+		{
+			name:         "Not Found",
+			target:       "/geoip/v2.1/insights/1.1.1.1",
+			wantStatus:   http.StatusNotFound,
+			wantContains: []string{`"code":"IP_ADDRESS_NOT_FOUND"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveMaxMind(e, tt.target, nil)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantContentType != "" {
+				assert.Equal(t, tt.wantContentType, rec.Header().Get(echo.HeaderContentType))
+			}
+			for _, s := range tt.wantContains {
+				assert.Contains(t, rec.Body.String(), s)
+			}
+			for _, s := range tt.wantMissing {
+				assert.NotContains(t, rec.Body.String(), s)
+			}
+		})
+	}
+}
+
+func TestMaxMind_Insights(t *testing.T) {
+	e := newMaxMindServer(t, geoiptest.Database{
+		DatabaseType: "GeoIP2-Enterprise",
+		Networks: map[netip.Prefix]any{
+			geoiptest.USNetwork: geoiptest.Map{
+				"country": geoiptest.Map{"iso_code": "US", "confidence": uint16(99)},
+				"traits":  geoiptest.Map{"isp": "Google", "user_type": "hosting"},
+			},
+		},
+	})
+
+	rec := serveMaxMind(e, "/geoip/v2.1/insights/8.8.8.8", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var got wherego.Enterprise
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, uint8(99), got.Country.Confidence)
+	assert.Equal(t, "Google", got.Traits.ISP)
+	assert.Equal(t, "hosting", got.Traits.UserType)
+	assert.Equal(t, netip.MustParsePrefix("8.8.8.0/24"), got.Traits.Network)
+
+	rec = serveMaxMind(e, "/geoip/v2.1/city/8.8.8.8", nil)
+	require.Equal(t, http.StatusOK, rec.Code, "Enterprise databases answer City lookups")
+	assert.NotContains(t, rec.Body.String(), "isp")
+}
+
+func TestMaxMindAuth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+keys:
+  - name: legacy
+    hash: `+auth.HashKey("license-key")+`
+    account_id: "123456"
+    daily_quota: 3
+  - name: any-account
+    hash: `+auth.HashKey("other-key")+`
+    routes: ["/geoip/v2.1/country/:ip"]
+`), 0o600))
+	keys, err := auth.LoadKeys(path)
+	require.NoError(t, err)
+	e := newMaxMindServer(t, geoiptest.SampleCity(), MaxMindAuth(keys))
+
+	tests := []struct {
+		name       string
+		target     string
+		account    string
+		license    string
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "No Credentials", target: "/geoip/v2.1/city/8.8.8.8", wantStatus: http.StatusUnauthorized, wantCode: MaxMindCodeAccountIDRequired},
+		{name: "No License Key", target: "/geoip/v2.1/city/8.8.8.8", account: "123456", wantStatus: http.StatusUnauthorized, wantCode: MaxMindCodeLicenseKeyRequired},
+		{name: "Wrong License Key", target: "/geoip/v2.1/city/8.8.8.8", account: "123456", license: "wrong", wantStatus: http.StatusUnauthorized, wantCode: MaxMindCodeAuthorizationInvalid},
+		{name: "Wrong Account", target: "/geoip/v2.1/city/8.8.8.8", account: "654321", license: "license-key", wantStatus: http.StatusUnauthorized, wantCode: MaxMindCodeAuthorizationInvalid},
+		{name: "Any Account", target: "/geoip/v2.1/country/8.8.8.8", account: "42", license: "other-key", wantStatus: http.StatusOK},
+		{name: "Service Not Allowed", target: "/geoip/v2.1/city/8.8.8.8", account: "42", license: "other-key", wantStatus: http.StatusForbidden, wantCode: MaxMindCodePermissionRequired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveMaxMind(e, tt.target, func(req *http.Request) {
+				if tt.account != "" {
+					req.SetBasicAuth(tt.account, tt.license)
+				}
+			})
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantCode == "" {
+				assert.NotContains(t, rec.Body.String(), "queries_remaining", "the key has no quota")
+				return
+			}
+			var got MaxMindError
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+			assert.Equal(t, tt.wantCode, got.Code)
+			assert.NotEmpty(t, got.Error)
+			if tt.wantStatus == http.StatusUnauthorized {
+				assert.Equal(t, `Basic realm="GeoIP2"`, rec.Header().Get(echo.HeaderWWWAuthenticate))
+			}
+		})
+	}
+
+	t.Run("Queries Remaining", func(t *testing.T) {
+		auth := func(req *http.Request) { req.SetBasicAuth("123456", "license-key") }
+		for _, want := range []int64{2, 1, 0} {
+			rec := serveMaxMind(e, "/geoip/v2.1/city/8.8.8.8", auth)
+			require.Equal(t, http.StatusOK, rec.Code)
+			var got struct {
+				MaxMind MaxMindMeta `json:"maxmind"`
+			}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+			require.NotNil(t, got.MaxMind.QueriesRemaining)
+			assert.Equal(t, want, *got.MaxMind.QueriesRemaining)
+		}
+
+		rec := serveMaxMind(e, "/geoip/v2.1/city/8.8.8.8", auth)
+		assert.Equal(t, http.StatusPaymentRequired, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"code":"INSUFFICIENT_FUNDS"`)
+	})
+}
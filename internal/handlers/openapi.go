@@ -0,0 +1,541 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gustavosett/WhereGo/internal/api/openapi"
+	v1 "github.com/gustavosett/WhereGo/internal/api/v1"
+	"github.com/gustavosett/WhereGo/internal/format"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/labstack/echo/v4"
+)
+
+// APIVersion is the version of the API described by the OpenAPI document.
+const APIVersion = "1.0.0"
+
+var v1PkgPath = reflect.TypeFor[v1.CityResponse]().PkgPath()
+
+// schemaName names the OpenAPI components. The v1 types get a prefix so
+// they do not clash with the geoip models of the same name.
+func schemaName(t reflect.Type) string {
+	if t.PkgPath() == v1PkgPath {
+		return "V1" + t.Name()
+	}
+	return t.Name()
+}
+
+// OpenAPIDocument returns the OpenAPI 3.1 document describing the API.
+func OpenAPIDocument() map[string]any {
+	components := openapi.NewComponents(schemaName)
+	problem := openapi.Ref[Problem](components)
+	// Database models that are not served by a route yet, but are part of
+	// the schema clients generate code from.
+	openapi.Ref[wherego.Country](components)
+	openapi.Ref[wherego.ASN](components)
+	// The messages of the WebSocket, which OpenAPI cannot describe.
+	openapi.Ref[v1.SocketRequest](components)
+	openapi.Ref[v1.SocketResponse](components)
+
+	ipParam := map[string]any{
+		"name":        "ip",
+		"in":          "path",
+		"required":    true,
+		"description": "IPv4 or IPv6 address to look up.",
+		"schema":      map[string]any{"type": "string"},
+	}
+	ifNoneMatch := map[string]any{
+		"name":        "If-None-Match",
+		"in":          "header",
+		"description": "ETag of a cached response. The response is 304 Not Modified while it is current.",
+		"schema":      map[string]any{"type": "string"},
+	}
+	formatParam := map[string]any{
+		"name":        QueryFormat,
+		"in":          "query",
+		"description": "Response format. Overrides the Accept header, which selects among the same formats by media type.",
+		"schema":      map[string]any{"type": "string", "enum": format.Default().Names()},
+	}
+	fieldParam := map[string]any{
+		"name":        QueryField,
+		"in":          "query",
+		"description": "Dotted path of the value returned in the text format, such as country.iso_code.",
+		"schema":      map[string]any{"type": "string"},
+	}
+	notModified := map[string]any{
+		"304": map[string]any{"description": "The cached response named by If-None-Match is current."},
+	}
+	authErrors := map[string]any{
+		"401": problemResponse(problem, "The API key is missing or not valid."),
+		"403": problemResponse(problem, "The API key may not call the route."),
+		"429": problemResponse(problem, "The quota of the API key is used up."),
+	}
+	lookupErrors := map[string]any{
+		"400": problemResponse(problem, "The IP address, format or field is not valid."),
+		"404": problemResponse(problem, "No data found for the IP address."),
+		"500": problemResponse(problem, "The lookup failed."),
+	}
+	withErrors := func(ok map[string]any, groups ...map[string]any) map[string]any {
+		responses := map[string]any{"200": ok}
+		for _, errs := range groups {
+			for status, r := range errs {
+				responses[status] = r
+			}
+		}
+		return responses
+	}
+	// API keys are only required when the server is configured with them,
+	// hence the empty requirement.
+	optionalKey := []any{
+		map[string]any{"apiKeyHeader": []string{}},
+		map[string]any{"apiKeyQuery": []string{}},
+		map[string]any{},
+	}
+	requiredKey := optionalKey[:2]
+	maxMindError := openapi.Ref[MaxMindError](components)
+	maxMindMeta := openapi.Ref[MaxMindMeta](components)
+	maxMindIPParam := map[string]any{
+		"name":        "ip",
+		"in":          "path",
+		"required":    true,
+		"description": "IPv4 or IPv6 address to look up, or \"me\" for the address of the caller.",
+		"schema":      map[string]any{"type": "string"},
+	}
+	maxMindOperation := func(service, summary string, model map[string]any) map[string]any {
+		errorResponse := func(description string) map[string]any {
+			return contentResponse("application/vnd.maxmind.com-error+json", maxMindError, description)
+		}
+		return map[string]any{
+			"get": map[string]any{
+				"operationId": "maxMind" + strings.ToUpper(service[:1]) + service[1:],
+				"summary":     summary,
+				"description": "Compatible with the GeoIP2 " + service + " web service and MaxMind's clients. " +
+					"When API keys are configured, clients authenticate with HTTP Basic authentication: " +
+					"the account ID as the user name and an API key as the license key.",
+				"tags":       []string{"maxmind"},
+				"parameters": []any{maxMindIPParam},
+				"security":   []any{map[string]any{"maxMindBasic": []string{}}, map[string]any{}},
+				"responses": map[string]any{
+					"200": contentResponse("application/vnd.maxmind.com-"+service+"+json", map[string]any{
+						"allOf": []any{model, map[string]any{
+							"type":       "object",
+							"properties": map[string]any{"maxmind": maxMindMeta},
+						}},
+					}, "The location of the IP address."),
+					"400": errorResponse("IP_ADDRESS_INVALID or IP_ADDRESS_RESERVED."),
+					"401": errorResponse("ACCOUNT_ID_REQUIRED, LICENSE_KEY_REQUIRED or AUTHORIZATION_INVALID."),
+					"402": errorResponse("INSUFFICIENT_FUNDS: the quota of the API key is used up."),
+					"403": errorResponse("PERMISSION_REQUIRED: the API key may not call the service."),
+					"404": errorResponse("IP_ADDRESS_NOT_FOUND."),
+					"500": errorResponse("The lookup failed."),
+				},
+			},
+		}
+	}
+
+	// The compatibility APIs, which are only served when configured.
+	compatSecurity := func(schemes ...string) []any {
+		security := slices.Clone(optionalKey[:2])
+		for _, scheme := range schemes {
+			security = append(security, map[string]any{scheme: []string{}})
+		}
+		return append(security, map[string]any{})
+	}
+	ipAPIParams := []any{
+		map[string]any{
+			"name":        QueryIPAPIFields,
+			"in":          "query",
+			"description": "Comma-separated response fields, or the sum of their numeric values as on ip-api.com.",
+			"schema":      map[string]any{"type": "string"},
+		},
+		map[string]any{
+			"name":        QueryIPAPILang,
+			"in":          "query",
+			"description": "Language of the names, such as de or pt-BR. Names fall back to English.",
+			"schema":      map[string]any{"type": "string"},
+		},
+	}
+	ipAPIResponses := map[string]any{
+		"200": jsonResponse(map[string]any{"oneOf": []any{ipAPISchema(), openapi.Ref[IPAPIFailure](components)}},
+			"The selected fields, or a fail status and message when the lookup failed."),
+		"401": authErrors["401"],
+		"403": authErrors["403"],
+		"429": authErrors["429"],
+	}
+	ipAPIOperation := func(operationID, summary string, params []any, responses map[string]any) map[string]any {
+		return map[string]any{
+			"get": map[string]any{
+				"operationId": operationID,
+				"summary":     summary,
+				"description": "Only served when the ip-api compatibility API is enabled.",
+				"tags":        []string{"compatibility"},
+				"parameters":  params,
+				"security":    compatSecurity("ipAPIKey"),
+				"responses":   responses,
+			},
+		}
+	}
+	ipInfoResponse := openapi.Ref[IPInfoResponse](components)
+	ipInfoResponses := map[string]any{
+		"200": jsonResponse(ipInfoResponse, "The location of the IP address."),
+		"401": authErrors["401"],
+		"403": authErrors["403"],
+		"404": jsonResponse(openapi.Ref[IPInfoError](components), "The IP address or field is not valid."),
+		"429": authErrors["429"],
+	}
+	ipInfoOperation := func(operationID, summary, description string, params []any, responses map[string]any) map[string]any {
+		return map[string]any{
+			"get": map[string]any{
+				"operationId": operationID,
+				"summary":     summary,
+				"description": strings.TrimSpace("Only served when the ipinfo compatibility API is enabled. " + description),
+				"tags":        []string{"compatibility"},
+				"parameters":  params,
+				"security":    compatSecurity("ipInfoToken", "ipInfoTokenQuery"),
+				"responses":   responses,
+			},
+		}
+	}
+
+	// The forward-auth endpoint answers every method of ForwardAuthMethods
+	// the same way.
+	geoHeaders := map[string]any{}
+	for name, description := range map[string]string{
+		wherego.HeaderGeoIP:        "The client address the decision is for.",
+		wherego.HeaderGeoCountry:   "ISO 3166-1 alpha-2 code of the country.",
+		wherego.HeaderGeoContinent: "Code of the continent.",
+		wherego.HeaderGeoRegion:    "ISO 3166-2 code of the largest subdivision, without the country.",
+		wherego.HeaderGeoCity:      "English name of the city.",
+		wherego.HeaderGeoPostal:    "Postal code.",
+		wherego.HeaderGeoLatitude:  "Approximate latitude.",
+		wherego.HeaderGeoLongitude: "Approximate longitude.",
+		wherego.HeaderGeoTimeZone:  "IANA time zone.",
+		HeaderGeoASN:               "Autonomous system number, when the server has ASN data.",
+		HeaderGeoASOrganization:    "Organization of the autonomous system.",
+		HeaderGeoDecision:          "The action of the policy, allow or deny.",
+		HeaderGeoRule:              "The name of the rule that matched, unless the default action applied.",
+	} {
+		geoHeaders[name] = map[string]any{"description": description, "schema": map[string]any{"type": "string"}}
+	}
+	denied := problemResponse(problem, "The policy denies the client address.")
+	denied["headers"] = geoHeaders
+	forwardAuth := func(operationID string, params []any) map[string]any {
+		ops := map[string]any{}
+		for _, method := range ForwardAuthMethods {
+			ops[strings.ToLower(method)] = map[string]any{
+				"operationId": operationID + method[:1] + strings.ToLower(method[1:]),
+				"summary":     "Decide whether a reverse proxy forwards a request",
+				"description": "For Nginx auth_request, Traefik ForwardAuth and Envoy's HTTP ext_authz. " +
+					"Decides with the geo-fencing policy for the client address, taken from X-Forwarded-For " +
+					"when the proxy is trusted. Only served when forward auth is enabled.",
+				"tags":       []string{"policy"},
+				"parameters": params,
+				"responses": map[string]any{
+					"200": map[string]any{"description": "The policy allows the client address.", "headers": geoHeaders},
+					"400": problemResponse(problem, "The client address is not valid."),
+					"403": denied,
+					"500": problemResponse(problem, "The lookup failed."),
+				},
+			}
+		}
+		return ops
+	}
+
+	paths := map[string]any{
+		"/auth": forwardAuth("forwardAuth", []any{}),
+		"/auth/{path}": forwardAuth("forwardAuthPath", []any{map[string]any{
+			"name":        "path",
+			"in":          "path",
+			"required":    true,
+			"description": "The path of the original request, which Envoy appends to the path prefix.",
+			"schema":      map[string]any{"type": "string"},
+		}}),
+		"/v1/lookup/{ip}": map[string]any{
+			"get": map[string]any{
+				"operationId": "lookupV1",
+				"summary":     "Look up the location of an IP address",
+				"tags":        []string{"lookup"},
+				"parameters":  []any{ipParam, formatParam, fieldParam, ifNoneMatch},
+				"security":    optionalKey,
+				"responses": withErrors(formattedResponse(
+					openapi.Ref[v1.CityResponse](components), "The location of the IP address."),
+					notModified, lookupErrors, authErrors),
+			},
+		},
+		"/v1/lookup/stream": map[string]any{
+			"post": map[string]any{
+				"operationId": "lookupStream",
+				"summary":     "Look up a stream of IP addresses",
+				"description": "Reads one IP address per line and writes one result line per address as it goes. " +
+					"Failed lookups are reported on their line and the stream continues. Blank lines are skipped.",
+				"tags":     []string{"lookup"},
+				"security": optionalKey,
+				"requestBody": map[string]any{
+					"required": true,
+					"content": map[string]any{
+						"text/plain": map[string]any{"schema": map[string]any{"type": "string"}},
+					},
+				},
+				"responses": withErrors(contentResponse(MIMEApplicationNDJSON,
+					openapi.Ref[v1.StreamResult](components), "One result per input line, as newline-delimited JSON."),
+					authErrors),
+			},
+		},
+		"/v1/ws": map[string]any{
+			"get": map[string]any{
+				"operationId": "lookupSocket",
+				"summary":     "Look up IP addresses over a WebSocket",
+				"description": "Upgrades to a WebSocket on which the client sends V1SocketRequest text messages " +
+					"and gets a V1SocketResponse message back for each, in order, carrying the request's id. " +
+					"Failed and rate limited requests are answered with an error and the connection stays open. " +
+					"The server pings idle connections and closes them with status 1001 when it shuts down.",
+				"tags":     []string{"lookup"},
+				"security": optionalKey,
+				"responses": map[string]any{
+					"101": map[string]any{"description": "The connection is upgraded to a WebSocket."},
+					"400": problemResponse(problem, "The request is not a WebSocket handshake."),
+					"401": authErrors["401"],
+					"403": problemResponse(problem, "The origin may not open WebSockets, or the API key may not call the route."),
+					"429": authErrors["429"],
+				},
+			},
+		},
+		"/v1/me": map[string]any{
+			"get": map[string]any{
+				"operationId": "lookupMe",
+				"summary":     "Look up the location of the caller",
+				"description": "Looks up the address the request comes from, taken from X-Forwarded-For when the server trusts the proxy.",
+				"tags":        []string{"lookup"},
+				"parameters":  []any{formatParam, fieldParam},
+				"security":    optionalKey,
+				"responses": withErrors(formattedResponse(
+					openapi.Ref[v1.CityResponse](components), "The location of the caller."),
+					lookupErrors, authErrors),
+			},
+		},
+		"/v1/evaluate": map[string]any{
+			"post": map[string]any{
+				"operationId": "evaluate",
+				"summary":     "Decide whether the geo-fencing policy allows an IP address",
+				"description": "Evaluates the rules of the policy in order; the first rule that matches decides. " +
+					"Evaluates the caller's address when the request has no body or no ip. " +
+					"Only served when a policy is configured.",
+				"tags":     []string{"policy"},
+				"security": optionalKey,
+				"requestBody": map[string]any{
+					"content": map[string]any{
+						echo.MIMEApplicationJSON: map[string]any{"schema": openapi.Ref[v1.EvaluateRequest](components)},
+					},
+				},
+				"responses": withErrors(jsonResponse(
+					openapi.Ref[v1.Decision](components), "The decision of the policy."),
+					map[string]any{
+						"400": problemResponse(problem, "The body or the IP address is not valid."),
+						"500": problemResponse(problem, "The lookup failed."),
+					}, authErrors),
+			},
+		},
+		"/lookup/{ip}": map[string]any{
+			"get": map[string]any{
+				"operationId": "lookup",
+				"summary":     "Look up an IP address in the legacy response format",
+				"description": "Deprecated in favor of /v1/lookup/{ip}. The response mirrors the MaxMind database layout.",
+				"deprecated":  true,
+				"tags":        []string{"lookup"},
+				"parameters":  []any{ipParam, formatParam, fieldParam, ifNoneMatch},
+				"security":    optionalKey,
+				"responses": withErrors(formattedResponse(
+					openapi.Ref[wherego.City](components), "The database record of the IP address."),
+					notModified, lookupErrors, authErrors),
+			},
+		},
+		"/geoip/v2.1/country/{ip}": maxMindOperation(MaxMindCountry,
+			"Look up the country of an IP address, as the GeoIP2 Country web service",
+			openapi.Ref[wherego.Country](components)),
+		"/geoip/v2.1/city/{ip}": maxMindOperation(MaxMindCity,
+			"Look up the location of an IP address, as the GeoIP2 City web service",
+			openapi.Ref[wherego.City](components)),
+		"/geoip/v2.1/insights/{ip}": maxMindOperation(MaxMindInsights,
+			"Look up the location of an IP address, as the GeoIP2 Insights web service",
+			map[string]any{
+				"description": "Enterprise databases answer with their record, other databases as the City service.",
+				"oneOf":       []any{openapi.Ref[wherego.Enterprise](components), openapi.Ref[wherego.City](components)},
+			}),
+		"/ip-api/json/{query}": ipAPIOperation("ipAPILookup", "Look up an IP address in the ip-api.com format",
+			append([]any{map[string]any{
+				"name":        "query",
+				"in":          "path",
+				"required":    true,
+				"description": "IPv4 or IPv6 address to look up.",
+				"schema":      map[string]any{"type": "string"},
+			}}, ipAPIParams...), ipAPIResponses),
+		"/ip-api/json": ipAPIOperation("ipAPILookupCaller", "Look up the caller in the ip-api.com format",
+			ipAPIParams, ipAPIResponses),
+		"/ipinfo/{ip}": ipInfoOperation("ipInfoLookup", "Look up an IP address in the ipinfo.io format",
+			"Serves the caller's address instead for /ipinfo/json, and the value of one field as text for "+
+				"/ipinfo/{field}, such as /ipinfo/country.",
+			[]any{ipParam}, ipInfoResponses),
+		"/ipinfo": ipInfoOperation("ipInfoLookupCaller", "Look up the caller in the ipinfo.io format", "",
+			nil, ipInfoResponses),
+		"/ipinfo/{ip}/{field}": ipInfoOperation("ipInfoField", "Look up one field of an IP address in the ipinfo.io format",
+			"Serves the value of the field as a line of text, or the whole response when the field is \"json\".",
+			[]any{ipParam, map[string]any{
+				"name":     "field",
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]any{"type": "string", "enum": append(slices.Clone(ipInfoFields), "json")},
+			}}, map[string]any{
+				"200": map[string]any{
+					"description": "The value of the field, or the whole response for \"json\".",
+					"content": map[string]any{
+						"text/plain":             map[string]any{"schema": map[string]any{"type": "string"}},
+						echo.MIMEApplicationJSON: map[string]any{"schema": ipInfoResponse},
+					},
+				},
+				"404": ipInfoResponses["404"],
+			}),
+		"/health": map[string]any{
+			"get": map[string]any{
+				"operationId": "health",
+				"summary":     "Health check",
+				"tags":        []string{"operations"},
+				"responses": map[string]any{
+					"200": jsonResponse(map[string]any{
+						"type":       "object",
+						"properties": map[string]any{"status": map[string]any{"type": "string", "const": "ok"}},
+						"required":   []string{"status"},
+					}, "The server is healthy."),
+				},
+			},
+		},
+		"/metrics": map[string]any{
+			"get": map[string]any{
+				"operationId": "metrics",
+				"summary":     "Prometheus metrics",
+				"tags":        []string{"operations"},
+				"responses": map[string]any{
+					"200": contentResponse("text/plain", map[string]any{"type": "string"},
+						"Metrics in the Prometheus text format."),
+				},
+			},
+		},
+		"/admin/usage": map[string]any{
+			"get": map[string]any{
+				"operationId": "keyUsage",
+				"summary":     "Usage counters of the API keys",
+				"description": "Only served when API keys are configured, to admin keys.",
+				"tags":        []string{"admin"},
+				"security":    requiredKey,
+				"responses": withErrors(jsonResponse(
+					openapi.Ref[UsageReport](components), "The usage of every API key."),
+					authErrors),
+			},
+		},
+		"/openapi.json": map[string]any{
+			"get": map[string]any{
+				"operationId": "openAPI",
+				"summary":     "This OpenAPI document",
+				"tags":        []string{"operations"},
+				"responses": map[string]any{
+					"200": jsonResponse(map[string]any{"type": "object"}, "The OpenAPI document."),
+				},
+			},
+		},
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "WhereGo",
+			"version":     APIVersion,
+			"description": "IP geolocation API backed by MaxMind databases.",
+			"license":     map[string]any{"name": "MIT", "identifier": "MIT"},
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": components.Schemas,
+			"securitySchemes": map[string]any{
+				"apiKeyHeader":     map[string]any{"type": "apiKey", "in": "header", "name": HeaderAPIKey},
+				"apiKeyQuery":      map[string]any{"type": "apiKey", "in": "query", "name": QueryAPIKey},
+				"maxMindBasic":     map[string]any{"type": "http", "scheme": "basic"},
+				"ipAPIKey":         map[string]any{"type": "apiKey", "in": "query", "name": QueryIPAPIKey},
+				"ipInfoToken":      map[string]any{"type": "http", "scheme": "bearer"},
+				"ipInfoTokenQuery": map[string]any{"type": "apiKey", "in": "query", "name": QueryIPInfoToken},
+			},
+		},
+	}
+}
+
+// ipAPISchema describes the ip-api.com response, whose fields are selected
+// by the request.
+func ipAPISchema() map[string]any {
+	properties := map[string]any{}
+	for _, f := range ipAPIFields {
+		schema := map[string]any{"type": "string"}
+		if f.value != nil {
+			switch f.value(&ipAPIRecord{}).(type) {
+			case float64:
+				schema["type"] = "number"
+			case int:
+				schema["type"] = "integer"
+			case bool:
+				schema["type"] = "boolean"
+			}
+		}
+		properties[f.name] = schema
+	}
+	return map[string]any{"type": "object", "properties": properties}
+}
+
+func jsonResponse(schema map[string]any, description string) map[string]any {
+	return contentResponse(echo.MIMEApplicationJSON, schema, description)
+}
+
+// formattedResponse describes a lookup response, which is JSON matching
+// schema or one of the other formats of format.Default. The Protocol
+// Buffers schema is geoip.proto.
+func formattedResponse(schema map[string]any, description string) map[string]any {
+	formats := format.Default()
+	content := map[string]any{}
+	for _, name := range formats.Names() {
+		f, _ := formats.Lookup(name)
+		mediaType, _, _ := strings.Cut(f.MediaType, ";")
+		switch name {
+		case "json":
+			content[mediaType] = map[string]any{"schema": schema}
+		case "msgpack", "protobuf":
+			content[mediaType] = map[string]any{"schema": map[string]any{"type": "string", "contentMediaType": mediaType}}
+		default:
+			content[mediaType] = map[string]any{"schema": map[string]any{"type": "string"}}
+		}
+	}
+	return map[string]any{"description": description, "content": content}
+}
+
+func problemResponse(schema map[string]any, description string) map[string]any {
+	return contentResponse(MIMEApplicationProblemJSON, schema, description)
+}
+
+func contentResponse(mediaType string, schema map[string]any, description string) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content":     map[string]any{mediaType: map[string]any{"schema": schema}},
+	}
+}
+
+var openAPIJSON = sync.OnceValues(func() ([]byte, error) {
+	return json.Marshal(OpenAPIDocument())
+})
+
+// OpenAPI serves the OpenAPI document.
+func OpenAPI(c echo.Context) error {
+	doc, err := openAPIJSON()
+	if err != nil {
+		return err
+	}
+	return c.JSONBlob(http.StatusOK, doc)
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	v1 "github.com/gustavosett/WhereGo/internal/api/v1"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveOpenAPI(t *testing.T) map[string]any {
+	t.Helper()
+	e := echo.New()
+	e.GET("/openapi.json", OpenAPI)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, strings.HasPrefix(rec.Header().Get(echo.HeaderContentType), echo.MIMEApplicationJSON))
+
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+	return doc
+}
+
+func TestOpenAPI(t *testing.T) {
+	doc := serveOpenAPI(t)
+	assert.Equal(t, "3.1.0", doc["openapi"])
+	schemas := doc["components"].(map[string]any)["schemas"].(map[string]any)
+
+	// Every reference must resolve.
+	var refs []string
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				refs = append(refs, ref)
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(doc)
+	require.NotEmpty(t, refs)
+	for _, ref := range refs {
+		name, ok := strings.CutPrefix(ref, "#/components/schemas/")
+		require.True(t, ok, ref)
+		assert.Contains(t, schemas, name, "dangling reference %s", ref)
+	}
+
+	for _, name := range []string{"City", "Country", "ASN", "V1CityResponse", "Problem"} {
+		assert.Contains(t, schemas, name)
+	}
+}
+
+// The schemas are generated from the models; this guards the generator
+// against silently dropping fields the API serializes.
+func TestOpenAPI_MatchesModels(t *testing.T) {
+	schemas := serveOpenAPI(t)["components"].(map[string]any)["schemas"].(map[string]any)
+
+	for name, model := range map[string]any{
+		"City":              wherego.City{},
+		"Country":           wherego.Country{},
+		"ASN":               wherego.ASN{},
+		"CityTraits":        wherego.CityTraits{},
+		"Location":          wherego.Location{},
+		"V1CityResponse":    v1.CityResponse{},
+		"V1Traits":          v1.Traits{},
+		"V1StreamResult":    v1.StreamResult{},
+		"V1StreamError":     v1.StreamError{},
+		"V1SocketRequest":   v1.SocketRequest{},
+		"V1SocketResponse":  v1.SocketResponse{},
+		"V1EvaluateRequest": v1.EvaluateRequest{},
+		"V1Decision":        v1.Decision{},
+		"Problem":           Problem{},
+		"Enterprise":        wherego.Enterprise{},
+		"MaxMindError":      MaxMindError{},
+		"MaxMindMeta":       MaxMindMeta{},
+		"IPAPIFailure":      IPAPIFailure{},
+		"IPInfoResponse":    IPInfoResponse{},
+		"IPInfoError":       IPInfoError{},
+	} {
+		t.Run(name, func(t *testing.T) {
+			encoded, err := json.Marshal(model)
+			require.NoError(t, err)
+			var fields map[string]any
+			require.NoError(t, json.Unmarshal(encoded, &fields))
+
+			require.Contains(t, schemas, name)
+			properties := schemas[name].(map[string]any)["properties"].(map[string]any)
+			for _, f := range reflect.VisibleFields(reflect.TypeOf(model)) {
+				tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+				if tag == "-" || !f.IsExported() {
+					continue
+				}
+				assert.Contains(t, properties, tag, "field %s.%s is not documented", name, f.Name)
+			}
+			for field := range fields {
+				assert.Contains(t, properties, field)
+			}
+		})
+	}
+}
+
+func TestDocs(t *testing.T) {
+	e := echo.New()
+	e.GET("/docs", Docs)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, docsPolicy, rec.Header().Get("Content-Security-Policy"))
+
+	body := rec.Body.String()
+	assert.NotContains(t, body, "<script", "the page loads no scripts")
+	assert.Contains(t, body, `<a href="/openapi.json">`)
+	assert.Contains(t, body, `<section id="lookupV1">`)
+	assert.Contains(t, body, "<h3><code>GET</code> /v1/lookup/{ip}</h3>")
+	assert.Contains(t, body, "<tr><td><code>ip</code> (required)</td><td>path</td>")
+	assert.Contains(t, body, "<tr><td>404</td><td><code>application/problem")
+}
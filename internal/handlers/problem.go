@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/netip"
+
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/labstack/echo/v4"
+)
+
+// MIMEApplicationProblemJSON is the media type of RFC 7807 problem details.
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+// Problem codes. They are part of the API and never change meaning, so
+// clients can switch on them instead of parsing messages.
+const (
+	CodeInvalidIP           = "invalid_ip"
+	CodeNotFound            = "ip_not_found"
+	CodeUnsupportedDatabase = "unsupported_database"
+	CodeLookupFailed        = "lookup_failed"
+	CodeBadRequest          = "bad_request"
+	CodeRouteNotFound       = "route_not_found"
+	CodeMethodNotAllowed    = "method_not_allowed"
+	CodeInternal            = "internal_error"
+	CodeMissingAPIKey       = "missing_api_key"
+	CodeInvalidAPIKey       = "invalid_api_key"
+	CodeRouteNotAllowed     = "route_not_allowed"
+	CodeQuotaExceeded       = "quota_exceeded"
+	CodeUnsupportedFormat   = "unsupported_format"
+	CodeInvalidField        = "invalid_field"
+	CodeRateLimited         = "rate_limited"
+	CodeAccessDenied        = "access_denied"
+)
+
+// Problem is an RFC 7807 problem details object. Type is always
+// "about:blank", so Title is the HTTP status text and Code identifies the
+// problem.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Code is a stable, machine-readable identifier of the problem.
+	Code string `json:"code"`
+	// AddressClass is set for lookups of special-purpose addresses that
+	// have no data, to explain why.
+	AddressClass wherego.AddressClass `json:"address_class,omitempty"`
+}
+
+// NewProblem returns a Problem for status with the given code and detail.
+func NewProblem(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// WriteProblem sends p as an application/problem+json response.
+func WriteProblem(c echo.Context, p *Problem) error {
+	if p.Instance == "" {
+		p.Instance = c.Request().URL.Path
+	}
+	c.Response().Header().Set(echo.HeaderContentType, MIMEApplicationProblemJSON)
+	return c.JSON(p.Status, p)
+}
+
+// lookupProblem maps the error of a lookup of ip to a Problem.
+func lookupProblem(c echo.Context, ip string, err error) *Problem {
+	var invalidMethod wherego.InvalidMethodError
+	switch {
+	case errors.Is(err, wherego.ErrInvalidIP):
+		return NewProblem(http.StatusBadRequest, CodeInvalidIP, "The IP address is not valid.")
+	case errors.Is(err, wherego.ErrNotFound):
+		p := NewProblem(http.StatusNotFound, CodeNotFound, "No data found for the IP address.")
+		if addr, err := netip.ParseAddr(ip); err == nil {
+			p.AddressClass = wherego.ClassifyAddress(addr)
+		}
+		return p
+	case errors.As(err, &invalidMethod):
+		return NewProblem(http.StatusInternalServerError, CodeUnsupportedDatabase, err.Error())
+	default:
+		c.Logger().Errorf("lookup %s: %v", ip, err)
+		return NewProblem(http.StatusInternalServerError, CodeLookupFailed, "The lookup failed.")
+	}
+}
+
+// HTTPErrorHandler is an echo.HTTPErrorHandler that reports errors as
+// problem details.
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	var p *Problem
+	var he *echo.HTTPError
+	switch {
+	case errors.As(err, &he):
+		var code string
+		switch {
+		case he.Code == http.StatusNotFound:
+			code = CodeRouteNotFound
+		case he.Code == http.StatusMethodNotAllowed:
+			code = CodeMethodNotAllowed
+		case he.Code < http.StatusInternalServerError:
+			code = CodeBadRequest
+		default:
+			code = CodeInternal
+		}
+		p = NewProblem(he.Code, code, "")
+		if msg, ok := he.Message.(string); ok && he.Code < http.StatusInternalServerError {
+			p.Detail = msg
+		}
+	default:
+		c.Logger().Error(err)
+		p = NewProblem(http.StatusInternalServerError, CodeInternal, "")
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(p.Status)
+	} else {
+		err = WriteProblem(c, p)
+	}
+	if err != nil {
+		c.Logger().Error(err)
+	}
+}
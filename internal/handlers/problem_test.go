@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/gustavosett/WhereGo/internal/geoiptest"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newProblemServer(t *testing.T, db geoiptest.Database, options ...wherego.Option) (*echo.Echo, *wherego.Reader) {
+	t.Helper()
+	r, err := wherego.OpenBytes(geoiptest.MustBuild(db), options...)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = r.Close() })
+
+	h := &GeoIPHandler{GeoService: &wherego.Service{DB: r}}
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.GET("/lookup/:ip", h.Lookup)
+	e.GET("/fail", func(echo.Context) error { return errors.New("boom") })
+	return e, r
+}
+
+func serveProblem(t *testing.T, e *echo.Echo, method, target string) (int, Problem) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	assert.Equal(t, MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+
+	var p Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &p))
+	assert.Equal(t, "about:blank", p.Type)
+	assert.Equal(t, http.StatusText(rec.Code), p.Title)
+	assert.Equal(t, rec.Code, p.Status)
+	return rec.Code, p
+}
+
+func TestLookupProblems(t *testing.T) {
+	e, _ := newProblemServer(t, geoiptest.SampleCity())
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		status int
+		code   string
+		class  wherego.AddressClass
+	}{
+		{"Invalid IP", http.MethodGet, "/lookup/invalid-ip", http.StatusBadRequest, CodeInvalidIP, ""},
+		{"Not Found", http.MethodGet, "/lookup/1.1.1.1", http.StatusNotFound, CodeNotFound, ""},
+		{"Not Found Pretty", http.MethodGet, "/lookup/1.1.1.1?pretty", http.StatusNotFound, CodeNotFound, ""},
+		{"Special-Purpose Address", http.MethodGet, "/lookup/127.0.0.1", http.StatusNotFound, CodeNotFound, wherego.AddressClassLoopback},
+		{"Unknown Route", http.MethodGet, "/nope", http.StatusNotFound, CodeRouteNotFound, ""},
+		{"Method Not Allowed", http.MethodPost, "/lookup/8.8.8.8", http.StatusMethodNotAllowed, CodeMethodNotAllowed, ""},
+		{"Handler Error", http.MethodGet, "/fail", http.StatusInternalServerError, CodeInternal, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, p := serveProblem(t, e, tt.method, tt.target)
+			assert.Equal(t, tt.status, status)
+			assert.Equal(t, tt.code, p.Code)
+			assert.Equal(t, tt.class, p.AddressClass)
+		})
+	}
+}
+
+func TestLookupProblems_ServerErrors(t *testing.T) {
+	t.Run("Unsupported Database", func(t *testing.T) {
+		e, _ := newProblemServer(t, geoiptest.Database{
+			DatabaseType: "GeoLite2-ASN",
+			Networks: map[netip.Prefix]any{
+				geoiptest.USNetwork: geoiptest.Map{"autonomous_system_number": 15169},
+			},
+		})
+		status, p := serveProblem(t, e, http.MethodGet, "/lookup/8.8.8.8")
+		assert.Equal(t, http.StatusInternalServerError, status)
+		assert.Equal(t, CodeUnsupportedDatabase, p.Code)
+	})
+
+	t.Run("Closed Database", func(t *testing.T) {
+		e, r := newProblemServer(t, geoiptest.SampleCity())
+		require.NoError(t, r.Close())
+		status, p := serveProblem(t, e, http.MethodGet, "/lookup/8.8.8.8")
+		assert.Equal(t, http.StatusInternalServerError, status)
+		assert.Equal(t, CodeLookupFailed, p.Code)
+		assert.Equal(t, "/lookup/8.8.8.8", p.Instance)
+	})
+
+	t.Run("Custom Database Not Found", func(t *testing.T) {
+		e, _ := newProblemServer(t, geoiptest.Database{
+			DatabaseType: "Acme-Offices",
+			Networks: map[netip.Prefix]any{
+				netip.MustParsePrefix("10.20.0.0/16"): geoiptest.Map{"site": "Lisbon"},
+			},
+		}, wherego.AllowUnknownDatabaseType())
+		status, p := serveProblem(t, e, http.MethodGet, "/lookup/10.21.0.1")
+		assert.Equal(t, http.StatusNotFound, status)
+		assert.Equal(t, CodeNotFound, p.Code)
+		assert.Equal(t, wherego.AddressClassPrivate, p.AddressClass)
+	})
+}
+
+func TestHTTPErrorHandler_Head(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/nope", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Empty(t, rec.Body.String())
+}
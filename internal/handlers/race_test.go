@@ -0,0 +1,7 @@
+//go:build race
+
+package handlers
+
+// The race detector randomly drops sync.Pool items, which makes allocation
+// counts meaningless.
+const raceEnabled = true
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net"
+	"net/netip"
+
+	"github.com/gustavosett/WhereGo/internal/format"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/labstack/echo/v4"
+)
+
+// JSONSerializer implements echo.JSONSerializer with format.JSON, the
+// default of the response formats, and json-iterator for request bodies.
+type JSONSerializer struct{}
+
+func (s *JSONSerializer) Serialize(c echo.Context, i interface{}, indent string) error {
+	return format.JSON.Serialize(c.Response(), i, format.Options{Indent: indent})
+}
+
+func (s *JSONSerializer) Deserialize(c echo.Context, i interface{}) error {
+	return jsoniter.ConfigCompatibleWithStandardLibrary.NewDecoder(c.Request().Body).Decode(i)
+}
+
+// IPExtractor returns the echo.IPExtractor for the client address: the
+// remote address of the connection, or the X-Forwarded-For header when the
+// request comes through one of proxies.
+func IPExtractor(proxies []netip.Prefix) echo.IPExtractor {
+	if len(proxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, proxy := range proxies {
+		options = append(options, echo.TrustIPRange(&net.IPNet{
+			IP:   proxy.Addr().AsSlice(),
+			Mask: net.CIDRMask(proxy.Bits(), proxy.Addr().BitLen()),
+		}))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
+
+// RegisterV1 adds the v1 lookup routes of h to g, which is mounted at
+// /v1: GET /lookup/:ip, POST /lookup/stream and GET /me.
+func (h *GeoIPHandler) RegisterV1(g *echo.Group) {
+	g.GET("/lookup/:ip", h.LookupV1)
+	g.POST("/lookup/stream", h.LookupStream)
+	g.GET("/me", h.LookupMe)
+}
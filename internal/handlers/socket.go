@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	v1 "github.com/gustavosett/WhereGo/internal/api/v1"
+	"github.com/gustavosett/WhereGo/internal/format"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/time/rate"
+)
+
+// Lookup types of v1.SocketRequest.
+const (
+	SocketTypeCity    = "city"
+	SocketTypeCountry = "country"
+	SocketTypeRecord  = "record"
+)
+
+// DefaultPingInterval is how often SocketHandler pings its clients by
+// default.
+const DefaultPingInterval = 30 * time.Second
+
+const (
+	// maxSocketMessage is the largest request message. Larger messages
+	// close the connection.
+	maxSocketMessage = 4096
+	// socketWriteWait is how long a client may take to receive a message.
+	socketWriteWait = 10 * time.Second
+	// socketCloseWait is how long connections closed by Shutdown wait for
+	// the client to answer the close message.
+	socketCloseWait = 5 * time.Second
+)
+
+// SocketHandler serves lookups over WebSocket connections at GET /v1/ws.
+// Clients send v1.SocketRequest text messages and get a v1.SocketResponse
+// message back for each, in order. A request that cannot be served is
+// answered with an error and the connection stays open. On routes with API
+// keys, every lookup request counts as a request against the quotas of the
+// key; once the quota is used up, requests are answered with a
+// quota_exceeded error.
+type SocketHandler struct {
+	Lookups *GeoIPHandler
+	// Rate is how many requests per second a connection may send, with
+	// bursts of up to Burst requests. Requests over the limit are answered
+	// with a rate_limited error. Zero means no limit.
+	Rate  rate.Limit
+	Burst int
+	// PingInterval is how often the server pings the client. Connections
+	// on which nothing, not even a pong, arrives for two intervals are
+	// dropped. Zero means DefaultPingInterval.
+	PingInterval time.Duration
+	// CheckOrigin reports whether a browser may connect from the origin
+	// of the request. Nil only allows pages of the API's own origin.
+	CheckOrigin func(r *http.Request) bool
+
+	mu       sync.Mutex
+	conns    map[*websocket.Conn]struct{}
+	shutdown bool
+	// active counts the connections being served.
+	active sync.WaitGroup
+}
+
+// Serve serves GET /v1/ws.
+func (s *SocketHandler) Serve(c echo.Context) error {
+	upgrader := websocket.Upgrader{
+		CheckOrigin: s.CheckOrigin,
+		Error: func(_ http.ResponseWriter, _ *http.Request, status int, reason error) {
+			detail := strings.TrimPrefix(reason.Error(), "websocket: ")
+			_ = WriteProblem(c, NewProblem(status, CodeBadRequest, "The WebSocket handshake failed: "+detail+"."))
+		},
+	}
+	conn, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		// Upgrade has answered the request.
+		return nil
+	}
+	defer conn.Close()
+	if !s.track(conn) {
+		closeSocket(conn)
+		return nil
+	}
+	defer s.untrack(conn)
+
+	interval := cmp.Or(s.PingInterval, DefaultPingInterval)
+	alive := func(string) error { return s.await(conn, 2*interval) }
+	conn.SetReadLimit(maxSocketMessage)
+	conn.SetPongHandler(alive)
+	_ = alive("")
+
+	done := make(chan struct{})
+	defer close(done)
+	go ping(conn, interval, done)
+
+	limiter := rate.NewLimiter(rate.Inf, 0)
+	if s.Rate > 0 {
+		limiter = rate.NewLimiter(s.Rate, max(s.Burst, 1))
+	}
+	quota := newLookupQuota(c)
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			// The client closed the connection, went away or stopped
+			// answering pings. The connection is hijacked, so there is no
+			// response to report an error in.
+			return nil
+		}
+		_ = alive("")
+
+		res := s.respond(c, msg, limiter, quota)
+		_ = conn.SetWriteDeadline(time.Now().Add(socketWriteWait))
+		w, err := conn.NextWriter(websocket.TextMessage)
+		if err != nil {
+			return nil
+		}
+		if err := format.JSON.Serialize(w, res, format.Options{}); err != nil {
+			return nil
+		}
+		if err := w.Close(); err != nil {
+			return nil
+		}
+	}
+}
+
+// respond answers one request message.
+func (s *SocketHandler) respond(c echo.Context, msg []byte, limiter *rate.Limiter, quota *lookupQuota) *v1.SocketResponse {
+	var req v1.SocketRequest
+	dec := json.NewDecoder(bytes.NewReader(msg))
+	// Keep numeric IDs as sent, even beyond the precision of a float64.
+	dec.UseNumber()
+	if err := dec.Decode(&req); err != nil {
+		return &v1.SocketResponse{Error: streamError(NewProblem(http.StatusBadRequest, CodeBadRequest,
+			"The message is not a JSON lookup request."))}
+	}
+	res := &v1.SocketResponse{ID: req.ID, Type: cmp.Or(req.Type, SocketTypeCity)}
+	if !limiter.Allow() {
+		res.Error = streamError(NewProblem(http.StatusTooManyRequests, CodeRateLimited,
+			"The connection sends requests too fast."))
+		return res
+	}
+
+	if res.Type != SocketTypeCity && res.Type != SocketTypeCountry && res.Type != SocketTypeRecord {
+		res.Error = streamError(NewProblem(http.StatusBadRequest, CodeBadRequest,
+			"The type must be one of "+SocketTypeCity+", "+SocketTypeCountry+", "+SocketTypeRecord+"."))
+		return res
+	}
+	if p := quota.use(); p != nil {
+		res.Error = streamError(p)
+		return res
+	}
+
+	service := s.Lookups.GeoService
+	var err error
+	switch {
+	case res.Type == SocketTypeRecord || !service.DB.KnownDatabaseType():
+		// Custom databases have no fixed schema, serve the decoded record.
+		res.Record, err = service.LookupRecord(req.IP)
+	default:
+		var city *wherego.City
+		if city, err = service.LookupIP(req.IP); err == nil {
+			if res.Type == SocketTypeCountry {
+				res.Result = v1.NewCountryResponse(city)
+			} else {
+				res.Result = v1.NewCityResponse(city)
+			}
+		}
+	}
+	if err != nil {
+		res.Error = streamError(lookupProblem(c, req.IP, err))
+	}
+	return res
+}
+
+// ping pings the client every interval until done is closed.
+func ping(conn *websocket.Conn, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteWait)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// track registers an open connection, unless the server is shutting down.
+func (s *SocketHandler) track(conn *websocket.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shutdown {
+		return false
+	}
+	if s.conns == nil {
+		s.conns = make(map[*websocket.Conn]struct{})
+	}
+	s.conns[conn] = struct{}{}
+	s.active.Add(1)
+	return true
+}
+
+// await moves the read deadline of conn timeout ahead, unless the server
+// is shutting down: then closeSocket has set the deadline for the client
+// to answer the close message, which messages and pongs must not extend.
+func (s *SocketHandler) await(conn *websocket.Conn, timeout time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shutdown {
+		return nil
+	}
+	return conn.SetReadDeadline(time.Now().Add(timeout))
+}
+
+func (s *SocketHandler) untrack(conn *websocket.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, conn)
+	s.active.Done()
+}
+
+// Shutdown sends a "going away" close message on every connection, refuses
+// new ones and waits until the connections have ended or ctx is done, in
+// which case it returns the error of ctx. Connections end when the client
+// answers the close message, or after a few seconds if it does not.
+// http.Server.Shutdown does not know about upgraded connections, so call
+// Shutdown next to it.
+func (s *SocketHandler) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.shutdown = true
+	conns := make([]*websocket.Conn, 0, len(s.conns))
+	for conn := range s.conns {
+		conns = append(conns, conn)
+	}
+	s.mu.Unlock()
+
+	for _, conn := range conns {
+		closeSocket(conn)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.active.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// closeSocket starts the closing handshake on conn. The client's answer
+// ends the read loop of the connection.
+func closeSocket(conn *websocket.Conn) {
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(socketWriteWait))
+	_ = conn.SetReadDeadline(time.Now().Add(socketCloseWait))
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	v1 "github.com/gustavosett/WhereGo/internal/api/v1"
+	"github.com/gustavosett/WhereGo/internal/geoiptest"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSocketServer(t *testing.T, s *SocketHandler) *httptest.Server {
+	t.Helper()
+	db, err := wherego.OpenBytes(geoiptest.MustBuild(geoiptest.SampleCity()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	s.Lookups = &GeoIPHandler{GeoService: &wherego.Service{DB: db}}
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.GET("/v1/ws", s.Serve)
+	srv := httptest.NewServer(e)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func dialSocket(t *testing.T, srv *httptest.Server) *websocket.Conn {
+	t.Helper()
+	conn, res, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/v1/ws", nil)
+	require.NoError(t, err)
+	_ = res.Body.Close()
+	t.Cleanup(func() { _ = conn.Close() })
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	return conn
+}
+
+func lookupSocket(t *testing.T, conn *websocket.Conn, msg string) v1.SocketResponse {
+	t.Helper()
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(msg)))
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	var res v1.SocketResponse
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+	require.NoError(t, dec.Decode(&res), string(data))
+	return res
+}
+
+func TestSocketHandler(t *testing.T) {
+	conn := dialSocket(t, newSocketServer(t, &SocketHandler{}))
+
+	tests := []struct {
+		name     string
+		msg      string
+		wantID   any
+		wantType string
+		wantCode string
+		check    func(t *testing.T, res v1.SocketResponse)
+	}{
+		{
+			name: "City", msg: `{"id": "a", "ip": "81.2.69.160"}`, wantID: "a", wantType: "city",
+			check: func(t *testing.T, res v1.SocketResponse) {
+				require.NotNil(t, res.Result)
+				assert.Equal(t, "GB", res.Result.Country.ISOCode)
+				require.NotNil(t, res.Result.City)
+				assert.Equal(t, "London", res.Result.City.Names["en"])
+			},
+		},
+		{
+			name: "Country With Numeric ID", msg: `{"id": 12345678901234567890, "ip": "81.2.69.160", "type": "country"}`,
+			wantID: json.Number("12345678901234567890"), wantType: "country",
+			check: func(t *testing.T, res v1.SocketResponse) {
+				require.NotNil(t, res.Result)
+				assert.Equal(t, "GB", res.Result.Country.ISOCode)
+				assert.Nil(t, res.Result.City)
+				assert.Nil(t, res.Result.Location)
+			},
+		},
+		{
+			name: "Record", msg: `{"id": 2, "ip": "81.2.69.160", "type": "record"}`,
+			wantID: json.Number("2"), wantType: "record",
+			check: func(t *testing.T, res v1.SocketResponse) {
+				assert.Nil(t, res.Result)
+				assert.Contains(t, res.Record, "country")
+			},
+		},
+		{name: "Invalid IP", msg: `{"id": 3, "ip": "bogus"}`, wantID: json.Number("3"), wantType: "city", wantCode: CodeInvalidIP},
+		{name: "Not Found", msg: `{"id": 4, "ip": "127.0.0.1"}`, wantID: json.Number("4"), wantType: "city", wantCode: CodeNotFound},
+		{name: "Unknown Type", msg: `{"id": 5, "ip": "8.8.8.8", "type": "asn"}`, wantID: json.Number("5"), wantType: "asn", wantCode: CodeBadRequest},
+		{name: "Invalid JSON", msg: `8.8.8.8`, wantCode: CodeBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := lookupSocket(t, conn, tt.msg)
+			assert.Equal(t, tt.wantID, res.ID)
+			assert.Equal(t, tt.wantType, res.Type)
+			if tt.wantCode != "" {
+				require.NotNil(t, res.Error)
+				assert.Equal(t, tt.wantCode, res.Error.Code)
+				assert.Nil(t, res.Result)
+				return
+			}
+			assert.Nil(t, res.Error)
+			tt.check(t, res)
+		})
+	}
+}
+
+func TestSocketHandler_RateLimit(t *testing.T) {
+	conn := dialSocket(t, newSocketServer(t, &SocketHandler{Rate: 0.001, Burst: 2}))
+
+	for range 2 {
+		res := lookupSocket(t, conn, `{"ip": "8.8.8.8"}`)
+		assert.Nil(t, res.Error)
+	}
+	res := lookupSocket(t, conn, `{"id": 3, "ip": "8.8.8.8"}`)
+	require.NotNil(t, res.Error)
+	assert.Equal(t, http.StatusTooManyRequests, res.Error.Status)
+	assert.Equal(t, CodeRateLimited, res.Error.Code)
+	assert.Equal(t, json.Number("3"), res.ID)
+}
+
+func TestSocketHandler_Ping(t *testing.T) {
+	conn := dialSocket(t, newSocketServer(t, &SocketHandler{PingInterval: 10 * time.Millisecond}))
+
+	pinged := make(chan struct{}, 1)
+	conn.SetPingHandler(func(string) error {
+		select {
+		case pinged <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	select {
+	case <-pinged:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no ping")
+	}
+}
+
+func TestSocketHandler_DropsSilentClients(t *testing.T) {
+	conn := dialSocket(t, newSocketServer(t, &SocketHandler{PingInterval: 10 * time.Millisecond}))
+
+	// Without a pong, the server drops the connection after two intervals,
+	// long before the client's own read deadline.
+	conn.SetPingHandler(func(string) error { return nil })
+	_, _, err := conn.ReadMessage()
+	require.Error(t, err)
+	var netErr net.Error
+	assert.False(t, errors.As(err, &netErr) && netErr.Timeout(), err)
+}
+
+func TestSocketHandler_Shutdown(t *testing.T) {
+	s := &SocketHandler{}
+	srv := newSocketServer(t, s)
+	conn := dialSocket(t, srv)
+	lookupSocket(t, conn, `{"ip": "8.8.8.8"}`)
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- s.Shutdown(context.Background()) }()
+	_, _, err := conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), err)
+	// Shutdown returns once the connection has ended.
+	require.NoError(t, <-shutdown)
+
+	// New connections are closed right away.
+	conn = dialSocket(t, srv)
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), err)
+}
+
+func TestSocketHandler_ShutdownTimeout(t *testing.T) {
+	s := &SocketHandler{}
+	conn := dialSocket(t, newSocketServer(t, s))
+	lookupSocket(t, conn, `{"ip": "8.8.8.8"}`)
+
+	// The client does not read, so it never answers the close message.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, s.Shutdown(ctx), context.DeadlineExceeded)
+}
+
+func TestSocketHandler_ShutdownChattyClient(t *testing.T) {
+	s := &SocketHandler{PingInterval: time.Minute}
+	conn := dialSocket(t, newSocketServer(t, s))
+	lookupSocket(t, conn, `{"ip": "8.8.8.8"}`)
+
+	// The client keeps sending without reading, so it never answers the
+	// close message; what it sends must not keep the connection open.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			deadline := time.Now().Add(time.Second)
+			if conn.WriteControl(websocket.PongMessage, nil, deadline) != nil {
+				return
+			}
+		}
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), socketCloseWait+2*time.Second)
+	defer cancel()
+	started := time.Now()
+	require.NoError(t, s.Shutdown(ctx))
+	assert.Less(t, time.Since(started), socketCloseWait+time.Second)
+}
+
+func TestSocketHandler_Quota(t *testing.T) {
+	e, keys := newAuthServer(t)
+	s := &SocketHandler{}
+	newSocketServer(t, s)
+	e.GET("/v1/ws", s.Serve, APIKey(keys))
+	srv := httptest.NewServer(e)
+	t.Cleanup(srv.Close)
+
+	header := http.Header{HeaderAPIKey: {"team-secret"}}
+	conn, res, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/v1/ws", header)
+	require.NoError(t, err)
+	_ = res.Body.Close()
+	t.Cleanup(func() { _ = conn.Close() })
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	// The key has a daily quota of two requests: the upgrade pays for the
+	// first lookup, the second lookup uses up the quota.
+	assert.Nil(t, lookupSocket(t, conn, `{"ip": "8.8.8.8"}`).Error)
+	assert.Nil(t, lookupSocket(t, conn, `{"ip": "8.8.8.8"}`).Error)
+	for range 2 {
+		got := lookupSocket(t, conn, `{"ip": "8.8.8.8"}`)
+		assert.Nil(t, got.Result)
+		assert.Equal(t, &v1.StreamError{Status: http.StatusTooManyRequests, Code: CodeQuotaExceeded, Detail: "The quota of the API key is used up."}, got.Error)
+	}
+}
+
+func TestSocketHandler_NotUpgrade(t *testing.T) {
+	srv := newSocketServer(t, &SocketHandler{})
+
+	res, err := http.Get(srv.URL + "/v1/ws")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	assert.Equal(t, MIMEApplicationProblemJSON, res.Header.Get(echo.HeaderContentType))
+	var p Problem
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&p))
+	assert.Equal(t, CodeBadRequest, p.Code)
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	v1 "github.com/gustavosett/WhereGo/internal/api/v1"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/labstack/echo/v4"
+)
+
+// MIMEApplicationNDJSON is the media type of newline-delimited JSON.
+const MIMEApplicationNDJSON = "application/x-ndjson"
+
+// maxStreamLine is the longest input line of a lookup stream. Addresses
+// take at most 45 bytes; longer lines are reported and skipped.
+const maxStreamLine = 4096
+
+// LookupStream serves POST /v1/lookup/stream. It reads one address per
+// line from the request body and writes one v1.StreamResult line per
+// address as it goes, skipping blank lines. A failed lookup is reported on
+// its line and the stream continues.
+//
+// Memory use does not grow with the body: each line is looked up and
+// written before the next one is read, so a client that reads slowly also
+// slows down the upload. Results are flushed whenever the input received
+// so far is used up.
+//
+// On routes with API keys, every address counts as a request against the
+// quotas of the key. When the quota runs out, the stream ends with a
+// quota_exceeded error on the line of the first address not looked up.
+func (h *GeoIPHandler) LookupStream(c echo.Context) error {
+	res := c.Response()
+	// Read the body while writing the response, which HTTP/1 servers
+	// do not allow by default.
+	if err := http.NewResponseController(res).EnableFullDuplex(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	res.Header().Set(echo.HeaderContentType, MIMEApplicationNDJSON)
+	res.WriteHeader(http.StatusOK)
+
+	quota := newLookupQuota(c)
+	r := bufio.NewReaderSize(c.Request().Body, maxStreamLine)
+	for {
+		line, tooLong, err := readLine(r)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				res.Flush()
+				return nil
+			}
+			// The client went away or the upload broke off; the response
+			// is committed, so there is no one to tell.
+			return err
+		}
+		if len(line) == 0 && !tooLong {
+			continue
+		}
+
+		if p := quota.use(); p != nil {
+			result := &v1.StreamResult{Input: string(line), Error: streamError(p)}
+			if err := c.Echo().JSONSerializer.Serialize(c, result, ""); err != nil {
+				return err
+			}
+			res.Flush()
+			return nil
+		}
+		result := h.streamResult(c, string(line), tooLong)
+		if err := c.Echo().JSONSerializer.Serialize(c, result, ""); err != nil {
+			return err
+		}
+		if r.Buffered() == 0 {
+			res.Flush()
+		}
+	}
+}
+
+// readLine returns the next line of r without surrounding white space. Of
+// lines longer than maxStreamLine, it returns the start and discards the
+// rest.
+func readLine(r *bufio.Reader) (line []byte, tooLong bool, err error) {
+	line, err = r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		line = bytes.Clone(line[:64])
+		for errors.Is(err, bufio.ErrBufferFull) {
+			_, err = r.ReadSlice('\n')
+		}
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+		return bytes.TrimSpace(line), true, err
+	}
+	if errors.Is(err, io.EOF) && len(line) > 0 {
+		// The last line need not end with a newline.
+		err = nil
+	}
+	return bytes.TrimSpace(line), false, err
+}
+
+func (h *GeoIPHandler) streamResult(c echo.Context, ip string, tooLong bool) *v1.StreamResult {
+	result := &v1.StreamResult{Input: ip}
+	if tooLong {
+		result.Error = &v1.StreamError{
+			Status: http.StatusBadRequest,
+			Code:   CodeInvalidIP,
+			Detail: "The line is too long to be an IP address.",
+		}
+		return result
+	}
+
+	var err error
+	if h.GeoService.DB.KnownDatabaseType() {
+		var city *wherego.City
+		if city, err = h.GeoService.LookupIP(ip); err == nil {
+			result.Result = v1.NewCityResponse(city)
+		}
+	} else {
+		result.Record, err = h.GeoService.LookupRecord(ip)
+	}
+	if err != nil {
+		result.Error = streamError(lookupProblem(c, ip, err))
+	}
+	return result
+}
+
+// streamError reports a problem of one lookup among many.
+func streamError(p *Problem) *v1.StreamError {
+	return &v1.StreamError{
+		Status:       p.Status,
+		Code:         p.Code,
+		Detail:       p.Detail,
+		AddressClass: string(p.AddressClass),
+	}
+}
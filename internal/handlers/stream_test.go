@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	v1 "github.com/gustavosett/WhereGo/internal/api/v1"
+	"github.com/gustavosett/WhereGo/internal/geoiptest"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStreamServer(t *testing.T) *echo.Echo {
+	t.Helper()
+	db, err := wherego.OpenBytes(geoiptest.MustBuild(geoiptest.SampleCity()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	h := &GeoIPHandler{GeoService: &wherego.Service{DB: db}}
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.POST("/v1/lookup/stream", h.LookupStream)
+	return e
+}
+
+func decodeStream(t *testing.T, body string) []v1.StreamResult {
+	t.Helper()
+	var results []v1.StreamResult
+	for line := range strings.Lines(body) {
+		var r v1.StreamResult
+		require.NoError(t, json.Unmarshal([]byte(line), &r), line)
+		results = append(results, r)
+	}
+	return results
+}
+
+func TestLookupStream(t *testing.T) {
+	e := newStreamServer(t)
+	body := "81.2.69.160\n\n  bogus \r\n127.0.0.1\n" + strings.Repeat("9", 10000) + "\n8.8.8.8"
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/lookup/stream", strings.NewReader(body)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, MIMEApplicationNDJSON, rec.Header().Get(echo.HeaderContentType))
+	assert.True(t, rec.Flushed)
+
+	results := decodeStream(t, rec.Body.String())
+	require.Len(t, results, 5, "one line per input line, blank lines skipped")
+
+	assert.Equal(t, "81.2.69.160", results[0].Input)
+	require.NotNil(t, results[0].Result)
+	assert.Equal(t, "GB", results[0].Result.Country.ISOCode)
+
+	assert.Equal(t, "bogus", results[1].Input)
+	assert.Equal(t, &v1.StreamError{Status: http.StatusBadRequest, Code: CodeInvalidIP, Detail: "The IP address is not valid."}, results[1].Error)
+
+	assert.Equal(t, CodeNotFound, results[2].Error.Code)
+	assert.Equal(t, "loopback", results[2].Error.AddressClass)
+
+	assert.Len(t, results[3].Input, 64, "long lines are cut short")
+	assert.Equal(t, CodeInvalidIP, results[3].Error.Code)
+
+	assert.Equal(t, "US", results[4].Result.Country.ISOCode, "the last line needs no newline")
+}
+
+// The results of a line reach the client before the next line is sent, so
+// a client can pipe an unbounded input through one connection.
+func TestLookupStream_FullDuplex(t *testing.T) {
+	server := httptest.NewServer(newStreamServer(t))
+	defer server.Close()
+
+	bodyReader, bodyWriter := io.Pipe()
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/lookup/stream", bodyReader)
+	require.NoError(t, err)
+	responses := make(chan *http.Response, 1)
+	go func() {
+		res, err := http.DefaultClient.Do(req)
+		if !assert.NoError(t, err) {
+			close(responses)
+			return
+		}
+		responses <- res
+	}()
+
+	_, err = io.WriteString(bodyWriter, "81.2.69.160\n")
+	require.NoError(t, err)
+	res, ok := <-responses
+	require.True(t, ok)
+	defer func() { _ = res.Body.Close() }()
+	assert.Equal(t, []string{"chunked"}, res.TransferEncoding)
+	lines := bufio.NewScanner(res.Body)
+
+	for _, ip := range []string{"81.2.69.160", "8.8.8.8", "bogus"} {
+		if ip != "81.2.69.160" {
+			_, err = io.WriteString(bodyWriter, ip+"\n")
+			require.NoError(t, err)
+		}
+		require.True(t, lines.Scan(), "result of %s", ip)
+		var r v1.StreamResult
+		require.NoError(t, json.Unmarshal(lines.Bytes(), &r))
+		assert.Equal(t, ip, r.Input)
+	}
+
+	require.NoError(t, bodyWriter.Close())
+	assert.False(t, lines.Scan(), "the stream ends with the input")
+	require.NoError(t, lines.Err())
+}
+
+func TestLookupStream_Quota(t *testing.T) {
+	e, keys := newAuthServer(t)
+	db, err := wherego.OpenBytes(geoiptest.MustBuild(geoiptest.SampleCity()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	h := &GeoIPHandler{GeoService: &wherego.Service{DB: db}}
+	e.POST("/v1/lookup/stream", h.LookupStream, APIKey(keys))
+
+	// The key has a daily quota of two requests: the request pays for the
+	// first address, the second address uses up the quota.
+	req := httptest.NewRequest(http.MethodPost, "/v1/lookup/stream", strings.NewReader("81.2.69.160\n8.8.8.8\n81.2.69.160\n8.8.8.8\n"))
+	req.Header.Set(HeaderAPIKey, "team-secret")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	results := decodeStream(t, rec.Body.String())
+	require.Len(t, results, 3, "the stream ends at the quota")
+	assert.Equal(t, "GB", results[0].Result.Country.ISOCode)
+	assert.Equal(t, "US", results[1].Result.Country.ISOCode)
+	assert.Equal(t, "81.2.69.160", results[2].Input)
+	assert.Nil(t, results[2].Result)
+	assert.Equal(t, &v1.StreamError{Status: http.StatusTooManyRequests, Code: CodeQuotaExceeded, Detail: "The quota of the API key is used up."}, results[2].Error)
+}
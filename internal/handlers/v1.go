@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	v1 "github.com/gustavosett/WhereGo/internal/api/v1"
+	"github.com/gustavosett/WhereGo/internal/format"
+	"github.com/labstack/echo/v4"
+)
+
+// LookupV1 serves GET /v1/lookup/:ip with the v1 response contract.
+func (h *GeoIPHandler) LookupV1(c echo.Context) error {
+	ip := c.Param("ip")
+	f, p := h.responseFormat(c)
+	if p != nil {
+		return WriteProblem(c, p)
+	}
+	if h.notModified(c, ip, formatVariant(f)) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	return h.lookupV1(c, ip, f)
+}
+
+// LookupMe serves GET /v1/me, the v1 lookup of the caller's address as
+// reported by Echo's IPExtractor. The response differs per caller, so it
+// must not be cached.
+func (h *GeoIPHandler) LookupMe(c echo.Context) error {
+	c.Response().Header()[headerCacheControl] = privateNoStore
+	f, p := h.responseFormat(c)
+	if p != nil {
+		return WriteProblem(c, p)
+	}
+	return h.lookupV1(c, c.RealIP(), f)
+}
+
+// lookupV1 sends the v1 lookup of ip in format f, or as JSON if f is nil.
+func (h *GeoIPHandler) lookupV1(c echo.Context, ip string, f *format.Format) error {
+	if !h.GeoService.DB.KnownDatabaseType() {
+		// Custom databases have no fixed schema, serve the decoded record.
+		result, err := h.GeoService.LookupRecord(ip)
+		if err != nil {
+			return lookupError(c, ip, err)
+		}
+		if f != nil {
+			return h.writeFormatted(c, f, result)
+		}
+		return c.JSON(http.StatusOK, result)
+	}
+
+	city, err := h.GeoService.LookupIP(ip)
+	if err != nil {
+		return lookupError(c, ip, err)
+	}
+	if f != nil {
+		return h.writeFormatted(c, f, v1.NewCityResponse(city))
+	}
+	return c.JSON(http.StatusOK, v1.NewCityResponse(city))
+}
+
+// Deprecated marks every response of a route as deprecated since the given
+// time (RFC 9745) and links to its successor, which is the same path under
+// successorPrefix, such as "/v1".
+func Deprecated(since time.Time, successorPrefix string) echo.MiddlewareFunc {
+	deprecation := "@" + strconv.FormatInt(since.Unix(), 10)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			header := c.Response().Header()
+			header.Set("Deprecation", deprecation)
+			header.Set("Link", "<"+successorPrefix+c.Request().URL.EscapedPath()+`>; rel="successor-version"`)
+			return next(c)
+		}
+	}
+}
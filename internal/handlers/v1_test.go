@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gustavosett/WhereGo/internal/geoiptest"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// assertGolden compares got with testdata/name, or rewrites the file when
+// the tests run with -update.
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, got, 0o600))
+	}
+	want, err := os.ReadFile(path)
+	require.NoError(t, err, "run the tests with -update to create the golden file")
+	assert.Equal(t, string(want), string(got), "response differs from %s", path)
+}
+
+// The v1 response shape is a contract with our clients. If one of these
+// tests fails, the change is breaking: only additions are allowed in v1.
+func TestLookupV1_Golden(t *testing.T) {
+	db, err := wherego.OpenBytes(geoiptest.MustBuild(geoiptest.SampleCity()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	h := &GeoIPHandler{GeoService: &wherego.Service{DB: db}}
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.GET("/v1/lookup/:ip", h.LookupV1)
+
+	tests := []struct {
+		name   string
+		ip     string
+		status int
+	}{
+		{"city", "81.2.69.160", http.StatusOK},
+		{"country_only", "8.8.8.8", http.StatusOK},
+		{"ipv6", "2606:4700::1111", http.StatusOK},
+		{"not_found", "127.0.0.1", http.StatusNotFound},
+		{"invalid_ip", "bogus", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/lookup/"+tt.ip+"?pretty", nil))
+			assert.Equal(t, tt.status, rec.Code)
+			assertGolden(t, filepath.Join("v1", tt.name+".json"), rec.Body.Bytes())
+		})
+	}
+}
+
+func TestLookupMe(t *testing.T) {
+	db, err := wherego.OpenBytes(geoiptest.MustBuild(geoiptest.SampleCity()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	h := &GeoIPHandler{GeoService: &wherego.Service{DB: db}}
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.IPExtractor = echo.ExtractIPDirect()
+	e.GET("/v1/me", h.LookupMe)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		status     int
+		want       string
+	}{
+		{"IPv4 Caller", "81.2.69.160:52000", http.StatusOK, `"ip_address":"81.2.69.160"`},
+		{"IPv6 Caller", "[2606:4700::1111]:52000", http.StatusOK, `"ip_address":"2606:4700::1111"`},
+		{"Loopback Caller", "127.0.0.1:52000", http.StatusNotFound, `"address_class":"loopback"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/me", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set(echo.HeaderXForwardedFor, "8.8.8.8")
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.want, "untrusted X-Forwarded-For is ignored")
+		})
+	}
+}
+
+func TestLookupV1_Overrides(t *testing.T) {
+	db, err := wherego.OpenBytes(geoiptest.MustBuild(geoiptest.SampleCity()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	path := filepath.Join(t.TempDir(), "overrides.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+overrides:
+  - network: 10.20.0.0/16
+    country: {iso_code: DE, names: {en: Germany}}
+    labels: {datacenter: fra1}
+`), 0o600))
+	overrides, err := wherego.LoadOverrides(path)
+	require.NoError(t, err)
+
+	h := &GeoIPHandler{GeoService: &wherego.Service{DB: db, Overrides: overrides}}
+	e := echo.New()
+	e.GET("/v1/lookup/:ip", h.LookupV1)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/lookup/10.20.1.1?pretty", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assertGolden(t, filepath.Join("v1", "override.json"), rec.Body.Bytes())
+}
+
+func TestLookupV1_CustomDatabase(t *testing.T) {
+	db, err := wherego.OpenBytes(geoiptest.MustBuild(geoiptest.Database{
+		DatabaseType: "Acme-Offices",
+		Networks: map[netip.Prefix]any{
+			netip.MustParsePrefix("10.20.0.0/16"): geoiptest.Map{"site": "Lisbon"},
+		},
+	}), wherego.AllowUnknownDatabaseType())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	h := &GeoIPHandler{GeoService: &wherego.Service{DB: db}}
+	e := echo.New()
+	e.GET("/v1/lookup/:ip", h.LookupV1)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/lookup/10.20.0.1", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"site":"Lisbon"}`, rec.Body.String())
+}
+
+func TestDeprecated(t *testing.T) {
+	e := echo.New()
+	since := time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	e.GET("/lookup/:ip", func(c echo.Context) error {
+		return c.String(http.StatusOK, "ok")
+	}, Deprecated(since, "/v1"))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/lookup/8.8.8.8", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "@1792368000", rec.Header().Get("Deprecation"))
+	assert.Equal(t, `</v1/lookup/8.8.8.8>; rel="successor-version"`, rec.Header().Get("Link"))
+	assert.Equal(t, "ok", rec.Body.String())
+}
@@ -0,0 +1,423 @@
+// Package resp serves lookups over the Redis serialization protocol
+// (RESP2), so services that already have a Redis client can geolocate
+// addresses without an HTTP client:
+//
+//	GEOIP.LOOKUP ip [field]
+//	GEOIP.MLOOKUP ip [ip ...] [FIELD field]
+//
+// A lookup replies with the v1 lookup response as a JSON string, or with
+// the value at a dotted field path such as "country.iso_code", as the text
+// response format does. Addresses and fields without a value reply with
+// nil. GEOIP.MLOOKUP replies with an array of the lookups of every address,
+// in which invalid addresses are error elements.
+//
+// Clients may pipeline commands. PING, ECHO, QUIT, SELECT 0 and the
+// CLIENT and COMMAND commands clients send when they connect are
+// supported as well; there are no keys to read or write. With API keys,
+// connections authenticate with AUTH, passing an API key as the password.
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "github.com/gustavosett/WhereGo/internal/api/v1"
+	"github.com/gustavosett/WhereGo/internal/auth"
+	"github.com/gustavosett/WhereGo/internal/format"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+)
+
+// closeWait is how long clients have to receive their last replies when
+// the server shuts down.
+const closeWait = 5 * time.Second
+
+// lookupRoute is the route lookups count as for API keys: they answer as
+// GET /v1/lookup/{ip} does.
+const lookupRoute = "/v1/lookup/:ip"
+
+// Defaults of the Server limits.
+const (
+	// DefaultIdleTimeout is how long a connection may take to send a
+	// command.
+	DefaultIdleTimeout = 5 * time.Minute
+	// DefaultWriteTimeout is how long a connection may take to receive
+	// the replies to a command.
+	DefaultWriteTimeout = 30 * time.Second
+	// DefaultMaxConns is how many connections are served at once.
+	DefaultMaxConns = 1000
+)
+
+// Server answers lookup commands.
+type Server struct {
+	Service *wherego.Service
+	// Keys, when set, requires connections to authenticate with AUTH and
+	// an API key before any other command. Every address looked up counts
+	// as a request to /v1/lookup/:ip against the quotas of the key.
+	Keys *auth.Keys
+	// IdleTimeout is how long a connection may take to send a command
+	// before it is closed. Zero means DefaultIdleTimeout.
+	IdleTimeout time.Duration
+	// WriteTimeout is how long a connection may take to receive the
+	// replies to a command before it is closed, so clients that pipeline
+	// commands without reading the replies do not hold a connection
+	// forever. Zero means DefaultWriteTimeout.
+	WriteTimeout time.Duration
+	// MaxConns is how many connections are served at once; connections
+	// over it are answered with an error and closed. Zero means
+	// DefaultMaxConns.
+	MaxConns int
+
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+	shutdown bool
+}
+
+// session is the state of a connection.
+type session struct {
+	// key is the API key the connection authenticated with.
+	key *auth.Key
+}
+
+// ListenAndServe answers commands on the TCP address addr until ctx is
+// done.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve answers commands on the connections accepted by ln until ctx is
+// done. Then it closes ln, lets every connection finish the commands it has
+// read, and returns nil once they are closed.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	stop := context.AfterFunc(ctx, func() {
+		_ = ln.Close()
+		s.close()
+	})
+	defer stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			_ = ln.Close()
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveConn(conn)
+		}()
+	}
+}
+
+// close ends the connections once they have answered the commands they
+// have read, giving clients closeWait to receive the replies.
+func (s *Server) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shutdown = true
+	now := time.Now()
+	for conn := range s.conns {
+		_ = conn.SetReadDeadline(now)
+		_ = conn.SetWriteDeadline(now.Add(closeWait))
+	}
+}
+
+// track registers an open connection. It returns false when the server
+// is shutting down, or when it serves MaxConns connections already, which
+// full reports.
+func (s *Server) track(conn net.Conn) (ok, full bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shutdown {
+		return false, false
+	}
+	if len(s.conns) >= cmp.Or(s.MaxConns, DefaultMaxConns) {
+		return false, true
+	}
+	if s.conns == nil {
+		s.conns = make(map[net.Conn]struct{})
+	}
+	s.conns[conn] = struct{}{}
+	return true, false
+}
+
+// await sets the deadline for the next command of conn, unless the server
+// is shutting down and has cut the reads short already.
+func (s *Server) await(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.shutdown {
+		_ = conn.SetReadDeadline(time.Now().Add(cmp.Or(s.IdleTimeout, DefaultIdleTimeout)))
+	}
+}
+
+// reply sets the deadline for the replies to the command of conn that is
+// being answered, unless the server is shutting down and has set a
+// deadline for the last replies already.
+func (s *Server) reply(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.shutdown {
+		_ = conn.SetWriteDeadline(time.Now().Add(cmp.Or(s.WriteTimeout, DefaultWriteTimeout)))
+	}
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, conn)
+}
+
+// serveConn answers the commands of a connection. Replies are buffered
+// while more pipelined commands are waiting to be read.
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	w := writer{bufio.NewWriter(conn)}
+	if ok, full := s.track(conn); !ok {
+		if full {
+			_ = conn.SetWriteDeadline(time.Now().Add(cmp.Or(s.WriteTimeout, DefaultWriteTimeout)))
+			w.error("ERR max number of clients reached")
+			_ = w.Flush()
+		}
+		return
+	}
+	defer s.untrack(conn)
+
+	r := bufio.NewReaderSize(conn, maxInlineLength)
+	var sess session
+	for {
+		s.await(conn)
+		args, err := readCommand(r)
+		if err != nil {
+			var protocolErr protocolError
+			if errors.As(err, &protocolErr) {
+				s.reply(conn)
+				w.error("ERR " + protocolErr.Error())
+				_ = w.Flush()
+				lingeringClose(conn)
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		// Replies are written as the buffer fills up, so the deadline
+		// covers the command as well as the flush after it. A write that
+		// misses it fails the flush, which closes the connection.
+		s.reply(conn)
+		quit := s.exec(w, &sess, args)
+		if quit || r.Buffered() == 0 {
+			s.reply(conn)
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+// lingeringClose lets the client read the last reply before the
+// connection is closed: closing it with unread input would reset it, and
+// the reply could be lost.
+func lingeringClose(conn net.Conn) {
+	cw, ok := conn.(interface{ CloseWrite() error })
+	if !ok || cw.CloseWrite() != nil {
+		return
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _ = io.Copy(io.Discard, io.LimitReader(conn, 1<<20))
+}
+
+// exec runs a command and writes its reply. It reports whether the client
+// asked to close the connection.
+func (s *Server) exec(w writer, sess *session, args [][]byte) (quit bool) {
+	name := strings.ToUpper(string(args[0]))
+	args = args[1:]
+	if s.Keys != nil && sess.key == nil && name != "AUTH" && name != "QUIT" {
+		w.error("NOAUTH Authentication required.")
+		return false
+	}
+	switch name {
+	case "GEOIP.LOOKUP":
+		if len(args) != 1 && len(args) != 2 {
+			wrongArgs(w, name)
+			return false
+		}
+		var field string
+		if len(args) == 2 {
+			field = string(args[1])
+		}
+		s.lookup(w, sess, string(args[0]), field)
+	case "GEOIP.MLOOKUP":
+		ips, field := args, ""
+		if n := len(args); n >= 2 && strings.EqualFold(string(args[n-2]), "FIELD") {
+			ips, field = args[:n-2], string(args[n-1])
+		}
+		if len(ips) == 0 {
+			wrongArgs(w, name)
+			return false
+		}
+		w.array(len(ips))
+		for _, ip := range ips {
+			s.lookup(w, sess, string(ip), field)
+		}
+	case "PING":
+		switch len(args) {
+		case 0:
+			w.simple("PONG")
+		case 1:
+			w.bulk(args[0])
+		default:
+			wrongArgs(w, name)
+		}
+	case "ECHO":
+		if len(args) != 1 {
+			wrongArgs(w, name)
+			return false
+		}
+		w.bulk(args[0])
+	case "QUIT":
+		w.simple("OK")
+		return true
+	case "AUTH":
+		// Clients send a user name with the password since Redis 6; it is
+		// ignored.
+		if len(args) != 1 && len(args) != 2 {
+			wrongArgs(w, name)
+			return false
+		}
+		if s.Keys == nil {
+			w.error("ERR Client sent AUTH, but no password is set")
+			return false
+		}
+		key, ok := s.Keys.Authenticate(string(args[len(args)-1]))
+		if !ok {
+			w.error("WRONGPASS invalid username-password pair or user is disabled.")
+			return false
+		}
+		sess.key = key
+		w.simple("OK")
+	case "SELECT":
+		if len(args) != 1 {
+			wrongArgs(w, name)
+		} else if string(args[0]) != "0" {
+			w.error("ERR DB index is out of range")
+		} else {
+			w.simple("OK")
+		}
+	case "CLIENT":
+		// Clients name themselves when they connect.
+		if len(args) > 0 && (strings.EqualFold(string(args[0]), "SETNAME") || strings.EqualFold(string(args[0]), "SETINFO")) {
+			w.simple("OK")
+		} else {
+			w.error("ERR unknown subcommand")
+		}
+	case "COMMAND":
+		// redis-cli asks for the documentation of the commands to complete
+		// them; there is none.
+		w.array(0)
+	default:
+		w.error("ERR unknown command " + quote(name))
+	}
+	return false
+}
+
+// quote quotes a client's argument for an error message, which must fit
+// on one line.
+func quote(arg string) string {
+	if len(arg) > 64 {
+		arg = arg[:64] + "..."
+	}
+	return "'" + strings.Map(func(r rune) rune {
+		if r < ' ' {
+			return ' '
+		}
+		return r
+	}, arg) + "'"
+}
+
+func wrongArgs(w writer, name string) {
+	w.error("ERR wrong number of arguments for '" + strings.ToLower(name) + "' command")
+}
+
+// lookup writes the reply to a lookup of ip.
+func (s *Server) lookup(w writer, sess *session, ip, field string) {
+	if sess.key != nil {
+		_, err := s.Keys.Use(sess.key, lookupRoute, time.Now())
+		switch {
+		case errors.Is(err, auth.ErrRouteNotAllowed):
+			w.error("NOPERM the API key may not look up addresses")
+			return
+		case errors.Is(err, auth.ErrQuotaExceeded):
+			w.error("ERR the quota of the API key is used up")
+			return
+		}
+	}
+
+	v, err := s.result(ip)
+	var invalidMethod wherego.InvalidMethodError
+	switch {
+	case errors.Is(err, wherego.ErrNotFound):
+		w.null()
+		return
+	case errors.Is(err, wherego.ErrInvalidIP):
+		w.error("ERR invalid IP address")
+		return
+	case errors.As(err, &invalidMethod):
+		w.error("ERR " + err.Error())
+		return
+	case err != nil:
+		w.error("ERR lookup failed")
+		return
+	}
+
+	if field == "" {
+		var buf bytes.Buffer
+		if err := format.JSON.Serialize(&buf, v, format.Options{}); err != nil {
+			w.error("ERR lookup failed")
+			return
+		}
+		w.bulk(bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}))
+		return
+	}
+	value, err := format.Lookup(v, field)
+	switch {
+	case err != nil:
+		w.error("ERR unknown field " + quote(field))
+	case value == "":
+		w.null()
+	default:
+		w.bulk([]byte(value))
+	}
+}
+
+// result looks up ip as GET /v1/lookup/{ip} does.
+func (s *Server) result(ip string) (any, error) {
+	if !s.Service.DB.KnownDatabaseType() {
+		// Custom databases have no fixed schema, serve the decoded record.
+		return s.Service.LookupRecord(ip)
+	}
+	city, err := s.Service.LookupIP(ip)
+	if err != nil {
+		return nil, err
+	}
+	return v1.NewCityResponse(city), nil
+}
@@ -0,0 +1,336 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gustavosett/WhereGo/internal/auth"
+	"github.com/gustavosett/WhereGo/internal/geoiptest"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startServer serves the sample database with s on a local port and
+// returns its address and a function that shuts the server down.
+func startServer(t *testing.T, s *Server) (string, func()) {
+	t.Helper()
+	db, err := wherego.OpenBytes(geoiptest.MustBuild(geoiptest.SampleCity()))
+	require.NoError(t, err)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s.Service = &wherego.Service{DB: db}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx, ln) }()
+	shutdown := func() {
+		cancel()
+		assert.NoError(t, <-done)
+	}
+	t.Cleanup(func() {
+		if ctx.Err() == nil {
+			shutdown()
+		}
+		_ = db.Close()
+	})
+	return ln.Addr().String(), shutdown
+}
+
+func newClient(t *testing.T, addr string) *redis.Client {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestServer_Lookup(t *testing.T) {
+	addr, _ := startServer(t, &Server{})
+	client := newClient(t, addr)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		args    []any
+		want    any
+		wantErr string
+	}{
+		{name: "Field", args: []any{"GEOIP.LOOKUP", "81.2.69.160", "country.iso_code"}, want: "GB"},
+		{name: "Nested Field", args: []any{"geoip.lookup", "81.2.69.160", "city.names.en"}, want: "London"},
+		{name: "Empty Field", args: []any{"GEOIP.LOOKUP", "8.8.8.8", "city.names.en"}, want: nil},
+		{name: "Not Found", args: []any{"GEOIP.LOOKUP", "127.0.0.1"}, want: nil},
+		{name: "Invalid IP", args: []any{"GEOIP.LOOKUP", "bogus"}, wantErr: "ERR invalid IP address"},
+		{name: "Unknown Field", args: []any{"GEOIP.LOOKUP", "8.8.8.8", "country.bogus"}, wantErr: "ERR unknown field 'country.bogus'"},
+		{name: "Wrong Arguments", args: []any{"GEOIP.LOOKUP"}, wantErr: "ERR wrong number of arguments for 'geoip.lookup' command"},
+		{name: "Unknown Command", args: []any{"GET", "key"}, wantErr: "ERR unknown command 'GET'"},
+		{name: "Echo", args: []any{"ECHO", "hello"}, want: "hello"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := client.Do(ctx, tt.args...).Result()
+			switch {
+			case tt.wantErr != "":
+				require.Error(t, err)
+				assert.Equal(t, tt.wantErr, err.Error())
+			case tt.want == nil:
+				assert.ErrorIs(t, err, redis.Nil)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+
+	t.Run("Whole Response", func(t *testing.T) {
+		got, err := client.Do(ctx, "GEOIP.LOOKUP", "8.8.8.8").Text()
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(got, `{"traits":{"ip_address":"8.8.8.8","network":"8.8.8.0/24"}`), got)
+		assert.Contains(t, got, `"country":{"iso_code":"US"`)
+	})
+
+	t.Run("Ping", func(t *testing.T) {
+		assert.Equal(t, "PONG", client.Ping(ctx).Val())
+	})
+}
+
+func TestServer_MLookup(t *testing.T) {
+	addr, _ := startServer(t, &Server{})
+	client := newClient(t, addr)
+	ctx := context.Background()
+
+	got, err := client.Do(ctx, "GEOIP.MLOOKUP", "8.8.8.8", "127.0.0.1", "bogus", "81.2.69.160", "FIELD", "country.iso_code").Slice()
+	require.NoError(t, err)
+	require.Len(t, got, 4)
+	assert.Equal(t, "US", got[0])
+	assert.Nil(t, got[1])
+	assert.EqualError(t, got[2].(error), "ERR invalid IP address")
+	assert.Equal(t, "GB", got[3])
+
+	got, err = client.Do(ctx, "GEOIP.MLOOKUP", "8.8.8.8").Slice()
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Contains(t, got[0], `"iso_code":"US"`)
+
+	err = client.Do(ctx, "GEOIP.MLOOKUP", "FIELD", "country.iso_code").Err()
+	assert.EqualError(t, err, "ERR wrong number of arguments for 'geoip.mlookup' command")
+}
+
+func TestServer_Pipeline(t *testing.T) {
+	addr, _ := startServer(t, &Server{})
+	client := newClient(t, addr)
+	ctx := context.Background()
+
+	ips := []string{"8.8.8.8", "81.2.69.160", "2606:4700::1111", "bogus"}
+	cmds, err := client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for range 100 {
+			for _, ip := range ips {
+				p.Do(ctx, "GEOIP.LOOKUP", ip, "country.iso_code")
+			}
+		}
+		return nil
+	})
+	require.Error(t, err, "the pipeline contains an invalid address")
+	require.Len(t, cmds, 400)
+	for i, cmd := range cmds {
+		got, err := cmd.(*redis.Cmd).Text()
+		switch ips[i%len(ips)] {
+		case "bogus":
+			assert.EqualError(t, err, "ERR invalid IP address")
+		case "81.2.69.160":
+			assert.Equal(t, "GB", got)
+		default:
+			assert.Equal(t, "US", got)
+		}
+	}
+}
+
+func TestServer_Inline(t *testing.T) {
+	addr, _ := startServer(t, &Server{})
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+
+	_, err = io.WriteString(conn, "PING\r\n\r\ngeoip.lookup 81.2.69.160 country.iso_code\nQUIT\r\n")
+	require.NoError(t, err)
+	got, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.Equal(t, "+PONG\r\n$2\r\nGB\r\n+OK\r\n", string(got))
+}
+
+func TestServer_ProtocolError(t *testing.T) {
+	addr, _ := startServer(t, &Server{})
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "Bad Array Length", input: "*x\r\n", want: "-ERR Protocol error: invalid multibulk length\r\n"},
+		{name: "Bad Bulk Length", input: "*1\r\n$99999\r\n", want: "-ERR Protocol error: invalid bulk length\r\n"},
+		{name: "Too Many Arguments", input: "*1001\r\n", want: "-ERR Protocol error: invalid multibulk length\r\n"},
+		{name: "Too Long Argument", input: "*1\r\n$513\r\n", want: "-ERR Protocol error: invalid bulk length\r\n"},
+		{name: "Not A Bulk String", input: "*1\r\n:1\r\n", want: "-ERR Protocol error: expected '$', got ':'\r\n"},
+		{name: "Too Long Inline", input: strings.Repeat("a", 5000) + "\r\n", want: "-ERR Protocol error: too big inline request\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", addr)
+			require.NoError(t, err)
+			defer conn.Close()
+			require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+
+			_, err = io.WriteString(conn, tt.input)
+			require.NoError(t, err)
+			got, err := io.ReadAll(conn)
+			require.NoError(t, err, "the connection is closed after the error")
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	addr, shutdown := startServer(t, &Server{})
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+	r := bufio.NewReader(conn)
+
+	_, err = io.WriteString(conn, "PING\r\n")
+	require.NoError(t, err)
+	line, err := r.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "+PONG\r\n", line)
+
+	shutdown()
+	_, err = r.ReadByte()
+	assert.ErrorIs(t, err, io.EOF, "open connections are closed")
+	_, err = net.Dial("tcp", addr)
+	assert.Error(t, err, "the listener is closed")
+}
+
+func TestServer_Auth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+keys:
+  - name: team
+    hash: `+auth.HashKey("team-secret")+`
+    daily_quota: 3
+  - name: admin
+    hash: `+auth.HashKey("admin-secret")+`
+    routes: ["/admin/*"]
+`), 0o600))
+	keys, err := auth.LoadKeys(path)
+	require.NoError(t, err)
+	addr, _ := startServer(t, &Server{Keys: keys})
+	ctx := context.Background()
+
+	t.Run("Missing Key", func(t *testing.T) {
+		conn, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+		defer conn.Close()
+		require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+		_, err = io.WriteString(conn, "PING\r\nGEOIP.LOOKUP 8.8.8.8\r\nAUTH wrong\r\nQUIT\r\n")
+		require.NoError(t, err)
+		got, err := io.ReadAll(conn)
+		require.NoError(t, err)
+		assert.Equal(t, "-NOAUTH Authentication required.\r\n-NOAUTH Authentication required.\r\n"+
+			"-WRONGPASS invalid username-password pair or user is disabled.\r\n+OK\r\n", string(got))
+	})
+
+	t.Run("Invalid Key", func(t *testing.T) {
+		client := redis.NewClient(&redis.Options{Addr: addr, Password: "wrong", MaxRetries: -1})
+		defer client.Close()
+		assert.ErrorContains(t, client.Ping(ctx).Err(), "WRONGPASS")
+	})
+
+	t.Run("Route Not Allowed", func(t *testing.T) {
+		client := redis.NewClient(&redis.Options{Addr: addr, Password: "admin-secret"})
+		defer client.Close()
+		err := client.Do(ctx, "GEOIP.LOOKUP", "8.8.8.8").Err()
+		assert.EqualError(t, err, "NOPERM the API key may not look up addresses")
+	})
+
+	t.Run("Quota", func(t *testing.T) {
+		client := redis.NewClient(&redis.Options{Addr: addr, Username: "default", Password: "team-secret"})
+		defer client.Close()
+		require.NoError(t, client.Ping(ctx).Err())
+		got, err := client.Do(ctx, "GEOIP.LOOKUP", "8.8.8.8", "country.iso_code").Text()
+		require.NoError(t, err)
+		assert.Equal(t, "US", got)
+
+		// Every address counts against the quota of three requests.
+		all, err := client.Do(ctx, "GEOIP.MLOOKUP", "8.8.8.8", "81.2.69.160", "8.8.8.8", "FIELD", "country.iso_code").Slice()
+		require.NoError(t, err)
+		require.Len(t, all, 3)
+		assert.Equal(t, "US", all[0])
+		assert.Equal(t, "GB", all[1])
+		assert.EqualError(t, all[2].(error), "ERR the quota of the API key is used up")
+	})
+}
+
+func TestServer_IdleTimeout(t *testing.T) {
+	addr, _ := startServer(t, &Server{IdleTimeout: 50 * time.Millisecond})
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+
+	// A command sent in parts must arrive within the timeout too.
+	_, err = io.WriteString(conn, "*2\r\n$4\r\nECHO\r\n")
+	require.NoError(t, err)
+	got, err := io.ReadAll(conn)
+	require.NoError(t, err, "the connection is closed")
+	assert.Empty(t, got)
+}
+
+func TestServer_WriteTimeout(t *testing.T) {
+	s := &Server{WriteTimeout: 50 * time.Millisecond}
+	addr, _ := startServer(t, s)
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Pipeline more replies than the socket buffers hold, and read none.
+	go func() {
+		commands := strings.Repeat("GEOIP.LOOKUP 81.2.69.160\r\n", 1000)
+		for range 100 {
+			if _, err := io.WriteString(conn, commands); err != nil {
+				return
+			}
+		}
+	}()
+	conns := func() int {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.conns)
+	}
+	require.Eventually(t, func() bool { return conns() == 1 }, 5*time.Second, time.Millisecond)
+	assert.Eventually(t, func() bool { return conns() == 0 }, 10*time.Second, 10*time.Millisecond, "the connection is closed")
+}
+
+func TestServer_MaxConns(t *testing.T) {
+	addr, _ := startServer(t, &Server{MaxConns: 1})
+	first := newClient(t, addr)
+	ctx := context.Background()
+	require.NoError(t, first.Ping(ctx).Err())
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+	got, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.Equal(t, "-ERR max number of clients reached\r\n", string(got))
+
+	assert.NoError(t, first.Ping(ctx).Err(), "the first connection is still served")
+}
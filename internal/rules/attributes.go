@@ -0,0 +1,157 @@
+// Package rules evaluates geo-fencing policies: ordered rules whose
+// expressions, such as
+//
+//	country in [US, CA] and not anonymous.is_tor_exit_node
+//
+// match the City, ASN and Anonymous IP data of an address, and decide
+// whether the address is allowed.
+package rules
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+)
+
+// Source is a set of databases an expression needs data from.
+type Source uint8
+
+// Sources of attributes.
+const (
+	SourceCity Source = 1 << iota
+	SourceASN
+	SourceAnonymousIP
+)
+
+// Attributes are the data of an address that expressions match. Sources
+// without data for the address are nil.
+type Attributes struct {
+	IP          netip.Addr
+	City        *wherego.City
+	ASN         *wherego.ASN
+	AnonymousIP *wherego.AnonymousIP
+}
+
+// Lookup returns the attributes of addr from the sources of svc, looking
+// up only the given sources. Missing data is not an error; a database
+// without the data of a source is, as a wherego.InvalidMethodError.
+func Lookup(svc *wherego.Service, addr netip.Addr, sources Source) (*Attributes, error) {
+	a := &Attributes{IP: addr}
+	var err error
+	if sources&SourceCity != 0 {
+		if a.City, err = svc.LookupIP(addr.String()); err != nil && !errors.Is(err, wherego.ErrNotFound) {
+			return nil, err
+		}
+	}
+	if sources&SourceASN != 0 {
+		if a.ASN, err = svc.LookupASN(addr); err != nil && !errors.Is(err, wherego.ErrNotFound) {
+			return nil, err
+		}
+	}
+	if sources&SourceAnonymousIP != 0 {
+		if a.AnonymousIP, err = svc.LookupAnonymousIP(addr); err != nil && !errors.Is(err, wherego.ErrNotFound) {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// Check returns the error lookups of sources fail with in svc, such as a
+// wherego.InvalidMethodError when svc has no database with the data of a
+// source. It looks up an address no database has data for.
+func Check(svc *wherego.Service, sources Source) error {
+	_, err := Lookup(svc, netip.IPv4Unspecified(), sources)
+	return err
+}
+
+// kind is the type of an attribute, which decides the operators and
+// values it can be compared with.
+type kind int
+
+const (
+	kindString kind = iota
+	kindNumber
+	kindBool
+	kindIP
+)
+
+func (k kind) String() string {
+	return [...]string{"string", "number", "boolean", "IP address"}[k]
+}
+
+// attribute is a field that expressions can name.
+type attribute struct {
+	kind   kind
+	source Source
+	str    func(*Attributes) string
+	num    func(*Attributes) uint64
+	boolf  func(*Attributes) bool
+}
+
+func cityString(f func(*wherego.City) string) attribute {
+	return attribute{kind: kindString, source: SourceCity, str: func(a *Attributes) string {
+		if a.City == nil {
+			return ""
+		}
+		return f(a.City)
+	}}
+}
+
+func anonymousFlag(f func(*wherego.AnonymousIP) bool) attribute {
+	return attribute{kind: kindBool, source: SourceAnonymousIP, boolf: func(a *Attributes) bool {
+		return a.AnonymousIP != nil && f(a.AnonymousIP)
+	}}
+}
+
+// attributes are the fields expressions can name. Names of City data
+// follow the JSON of lookups, shortened to the ISO code or English name.
+var attributes = map[string]attribute{
+	"ip": {kind: kindIP},
+	"address_class": {kind: kindString, str: func(a *Attributes) string {
+		return string(wherego.ClassifyAddress(a.IP))
+	}},
+
+	"continent":          cityString(func(c *wherego.City) string { return c.Continent.Code }),
+	"country":            cityString(func(c *wherego.City) string { return c.Country.ISOCode }),
+	"registered_country": cityString(func(c *wherego.City) string { return c.RegisteredCountry.ISOCode }),
+	"region": cityString(func(c *wherego.City) string {
+		if len(c.Subdivisions) == 0 {
+			return ""
+		}
+		return c.Subdivisions[0].ISOCode
+	}),
+	"city":        cityString(func(c *wherego.City) string { return c.City.Names.English }),
+	"postal_code": cityString(func(c *wherego.City) string { return c.Postal.Code }),
+	"time_zone":   cityString(func(c *wherego.City) string { return c.Location.TimeZone }),
+	"is_in_european_union": {kind: kindBool, source: SourceCity, boolf: func(a *Attributes) bool {
+		return a.City != nil && a.City.Country.IsInEuropeanUnion
+	}},
+
+	"asn": {kind: kindNumber, source: SourceASN, num: func(a *Attributes) uint64 {
+		if a.ASN == nil {
+			return 0
+		}
+		return uint64(a.ASN.AutonomousSystemNumber)
+	}},
+	"as_organization": {kind: kindString, source: SourceASN, str: func(a *Attributes) string {
+		if a.ASN == nil {
+			return ""
+		}
+		return a.ASN.AutonomousSystemOrganization
+	}},
+
+	"anonymous.is_anonymous":         anonymousFlag(func(a *wherego.AnonymousIP) bool { return a.IsAnonymous }),
+	"anonymous.is_anonymous_vpn":     anonymousFlag(func(a *wherego.AnonymousIP) bool { return a.IsAnonymousVPN }),
+	"anonymous.is_hosting_provider":  anonymousFlag(func(a *wherego.AnonymousIP) bool { return a.IsHostingProvider }),
+	"anonymous.is_public_proxy":      anonymousFlag(func(a *wherego.AnonymousIP) bool { return a.IsPublicProxy }),
+	"anonymous.is_residential_proxy": anonymousFlag(func(a *wherego.AnonymousIP) bool { return a.IsResidentialProxy }),
+	"anonymous.is_tor_exit_node":     anonymousFlag(func(a *wherego.AnonymousIP) bool { return a.IsTorExitNode }),
+}
+
+// lookupAttribute returns the attribute named name, ignoring case.
+func lookupAttribute(name string) (attribute, bool) {
+	a, ok := attributes[strings.ToLower(name)]
+	return a, ok
+}
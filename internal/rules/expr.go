@@ -0,0 +1,489 @@
+package rules
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Expr is a compiled rule expression. Expressions combine comparisons of
+// attributes with and, or, not and parentheses:
+//
+//	country in [US, CA] and not anonymous.is_tor_exit_node
+//	asn == 15169 or as_organization == "Example Hosting"
+//	ip in [10.0.0.0/8, 2001:db8::/32]
+//
+// Boolean attributes are conditions by themselves. Strings compare with
+// == and !=, ignoring case; numbers also with <, <=, > and >=; IP addresses
+// with == and !=. Every attribute can be tested against a list with in and
+// not in, where an IP address matches the networks and addresses listed.
+// Attributes without data for an address are the empty string, 0 or
+// false. Keywords and attribute names ignore case.
+type Expr struct {
+	src     string
+	root    node
+	sources Source
+}
+
+// SyntaxError is an error in an expression, at byte Offset.
+type SyntaxError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("offset %d: %s", e.Offset, e.Msg)
+}
+
+// Compile parses and type-checks the expression src.
+func Compile(src string) (*Expr, error) {
+	p := &parser{lex: lexer{src: src}}
+	p.next()
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+	return &Expr{src: src, root: root, sources: p.sources}, nil
+}
+
+// MustCompile is Compile for expressions known to be valid. It panics on
+// error.
+func MustCompile(src string) *Expr {
+	e, err := Compile(src)
+	if err != nil {
+		panic(fmt.Sprintf("rules: Compile(%q): %v", src, err))
+	}
+	return e
+}
+
+// String returns the source of the expression.
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Sources returns the databases the expression needs data from.
+func (e *Expr) Sources() Source {
+	return e.sources
+}
+
+// Match reports whether the attributes satisfy the expression.
+func (e *Expr) Match(a *Attributes) bool {
+	return e.root.eval(a)
+}
+
+type node interface {
+	eval(a *Attributes) bool
+}
+
+type (
+	andNode   struct{ left, right node }
+	orNode    struct{ left, right node }
+	notNode   struct{ operand node }
+	constNode bool
+	boolNode  struct{ attr attribute }
+)
+
+func (n andNode) eval(a *Attributes) bool  { return n.left.eval(a) && n.right.eval(a) }
+func (n orNode) eval(a *Attributes) bool   { return n.left.eval(a) || n.right.eval(a) }
+func (n notNode) eval(a *Attributes) bool  { return !n.operand.eval(a) }
+func (n constNode) eval(*Attributes) bool  { return bool(n) }
+func (n boolNode) eval(a *Attributes) bool { return n.attr.boolf(a) }
+
+type stringNode struct {
+	attr   attribute
+	values []string
+	negate bool
+}
+
+func (n stringNode) eval(a *Attributes) bool {
+	v := n.attr.str(a)
+	return slices.ContainsFunc(n.values, func(s string) bool { return strings.EqualFold(s, v) }) != n.negate
+}
+
+type numberNode struct {
+	attr   attribute
+	op     string
+	values []uint64
+	negate bool
+}
+
+func (n numberNode) eval(a *Attributes) bool {
+	v := n.attr.num(a)
+	switch n.op {
+	case "<":
+		return v < n.values[0]
+	case "<=":
+		return v <= n.values[0]
+	case ">":
+		return v > n.values[0]
+	case ">=":
+		return v >= n.values[0]
+	}
+	return slices.Contains(n.values, v) != n.negate
+}
+
+type ipNode struct {
+	networks []netip.Prefix
+	negate   bool
+}
+
+func (n ipNode) eval(a *Attributes) bool {
+	addr := a.IP.Unmap()
+	return slices.ContainsFunc(n.networks, func(p netip.Prefix) bool { return p.Contains(addr) }) != n.negate
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokComma
+	tokInvalid
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	offset int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// is reports whether t is the keyword kw.
+func (t token) is(kw string) bool {
+	return t.kind == tokWord && strings.EqualFold(t.text, kw)
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+// isDelimiter reports whether c ends a word.
+func isDelimiter(c byte) bool {
+	return strings.IndexByte(" \t\r\n()[],\"=!<>", c) >= 0
+}
+
+func (l *lexer) next() token {
+	for l.pos < len(l.src) && strings.IndexByte(" \t\r\n", l.src[l.pos]) >= 0 {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos == len(l.src) {
+		return token{kind: tokEOF, offset: start}
+	}
+	c := l.src[l.pos]
+	l.pos++
+	switch c {
+	case '(':
+		return token{kind: tokLParen, text: "(", offset: start}
+	case ')':
+		return token{kind: tokRParen, text: ")", offset: start}
+	case '[':
+		return token{kind: tokLBracket, text: "[", offset: start}
+	case ']':
+		return token{kind: tokRBracket, text: "]", offset: start}
+	case ',':
+		return token{kind: tokComma, text: ",", offset: start}
+	case '=', '!', '<', '>':
+		if l.pos < len(l.src) && l.src[l.pos] == '=' {
+			l.pos++
+		}
+		op := l.src[start:l.pos]
+		if op == "=" || op == "!" {
+			return token{kind: tokInvalid, text: op, offset: start}
+		}
+		return token{kind: tokOp, text: op, offset: start}
+	case '"':
+		end := strings.IndexByte(l.src[l.pos:], '"')
+		if end < 0 {
+			l.pos = len(l.src)
+			return token{kind: tokInvalid, text: l.src[start:], offset: start}
+		}
+		text := l.src[l.pos : l.pos+end]
+		l.pos += end + 1
+		return token{kind: tokString, text: text, offset: start}
+	}
+	for l.pos < len(l.src) && !isDelimiter(l.src[l.pos]) {
+		l.pos++
+	}
+	return token{kind: tokWord, text: l.src[start:l.pos], offset: start}
+}
+
+// parser is a recursive descent parser of the grammar
+//
+//	or         = and { "or" and }
+//	and        = unary { "and" unary }
+//	unary      = "not" unary | primary
+//	primary    = "(" or ")" | "true" | "false" | comparison
+//	comparison = attribute [ op value | [ "not" ] "in" list ]
+//	list       = "[" value { "," value } [ "," ] "]"
+//
+// where not binds tighter than and, and and tighter than or.
+type parser struct {
+	lex     lexer
+	tok     token
+	sources Source
+}
+
+func (p *parser) next() {
+	p.tok = p.lex.next()
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	if p.tok.kind == tokInvalid && strings.HasPrefix(p.tok.text, `"`) {
+		return &SyntaxError{Offset: p.tok.offset, Msg: "unterminated string"}
+	}
+	return &SyntaxError{Offset: p.tok.offset, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.is("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.is("and") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.tok.is("not") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	switch {
+	case p.tok.kind == tokLParen:
+		p.next()
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokRParen {
+			return nil, p.errorf("expected ) but found %s", p.tok)
+		}
+		p.next()
+		return n, nil
+	case p.tok.is("true"):
+		p.next()
+		return constNode(true), nil
+	case p.tok.is("false"):
+		p.next()
+		return constNode(false), nil
+	case p.tok.kind == tokWord && !isKeyword(p.tok.text):
+		return p.parseComparison()
+	}
+	return nil, p.errorf("expected condition but found %s", p.tok)
+}
+
+func isKeyword(s string) bool {
+	switch strings.ToLower(s) {
+	case "and", "or", "not", "in", "true", "false":
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseComparison() (node, error) {
+	name := p.tok
+	attr, ok := lookupAttribute(name.text)
+	if !ok {
+		return nil, p.errorf("unknown attribute %s", name)
+	}
+	p.sources |= attr.source
+	p.next()
+
+	var op token
+	var values []token
+	switch {
+	case p.tok.kind == tokOp:
+		op = p.tok
+		p.next()
+		if p.tok.kind != tokWord && p.tok.kind != tokString {
+			return nil, p.errorf("expected value but found %s", p.tok)
+		}
+		values = []token{p.tok}
+		p.next()
+	case p.tok.is("in"), p.tok.is("not"):
+		op = p.tok
+		if p.tok.is("not") {
+			p.next()
+			if !p.tok.is("in") {
+				return nil, p.errorf("expected in but found %s", p.tok)
+			}
+			op.text = "not in"
+		}
+		op.text = strings.ToLower(op.text)
+		p.next()
+		list, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		values = list
+	case p.tok.kind == tokInvalid:
+		return nil, p.errorf("unexpected %s", p.tok)
+	default:
+		if attr.kind != kindBool {
+			return nil, &SyntaxError{Offset: name.offset, Msg: fmt.Sprintf("%s attribute %s needs a comparison", attr.kind, name)}
+		}
+		return boolNode{attr}, nil
+	}
+	return compare(name, attr, op, values)
+}
+
+func (p *parser) parseList() ([]token, error) {
+	if p.tok.kind != tokLBracket {
+		return nil, p.errorf("expected [ but found %s", p.tok)
+	}
+	p.next()
+	var values []token
+	for p.tok.kind != tokRBracket {
+		if p.tok.kind != tokWord && p.tok.kind != tokString {
+			return nil, p.errorf("expected value but found %s", p.tok)
+		}
+		values = append(values, p.tok)
+		p.next()
+		if p.tok.kind == tokComma {
+			p.next()
+		} else if p.tok.kind != tokRBracket {
+			return nil, p.errorf("expected , or ] but found %s", p.tok)
+		}
+	}
+	p.next()
+	return values, nil
+}
+
+// compare type-checks the comparison of the attribute named name with
+// values, and returns its node.
+func compare(name token, attr attribute, op token, values []token) (node, error) {
+	negate := op.text == "!=" || op.text == "not in"
+	equality := op.text == "==" || op.text == "!=" || op.text == "in" || op.text == "not in"
+	if !equality && attr.kind != kindNumber {
+		return nil, &SyntaxError{Offset: op.offset, Msg: fmt.Sprintf("operator %s does not apply to %s attribute %s", op.text, attr.kind, name)}
+	}
+
+	switch attr.kind {
+	case kindBool:
+		if op.text != "==" && op.text != "!=" {
+			return nil, &SyntaxError{Offset: op.offset, Msg: fmt.Sprintf("operator %s does not apply to %s attribute %s", op.text, attr.kind, name)}
+		}
+		var want bool
+		switch {
+		case values[0].is("true"):
+			want = true
+		case values[0].is("false"):
+		default:
+			return nil, &SyntaxError{Offset: values[0].offset, Msg: fmt.Sprintf("expected true or false but found %s", values[0])}
+		}
+		var n node = boolNode{attr}
+		if want == negate {
+			n = notNode{n}
+		}
+		return n, nil
+
+	case kindNumber:
+		nums := make([]uint64, len(values))
+		for i, v := range values {
+			text := v.text
+			if len(text) > 2 && strings.EqualFold(text[:2], "AS") {
+				text = text[2:]
+			}
+			n, err := strconv.ParseUint(text, 10, 32)
+			if err != nil {
+				return nil, &SyntaxError{Offset: v.offset, Msg: fmt.Sprintf("expected number but found %s", v)}
+			}
+			nums[i] = n
+		}
+		return numberNode{attr: attr, op: op.text, values: nums, negate: negate}, nil
+
+	case kindIP:
+		networks := make([]netip.Prefix, len(values))
+		for i, v := range values {
+			network, err := parseNetwork(v.text)
+			if err != nil || (op.text == "==" || op.text == "!=") && network.Bits() != network.Addr().BitLen() {
+				return nil, &SyntaxError{Offset: v.offset, Msg: fmt.Sprintf("expected IP address or network but found %s", v)}
+			}
+			networks[i] = network
+		}
+		return ipNode{networks: networks, negate: negate}, nil
+	}
+
+	strs := make([]string, len(values))
+	for i, v := range values {
+		strs[i] = v.text
+	}
+	return stringNode{attr: attr, values: strs, negate: negate}, nil
+}
+
+// parseNetwork parses a CIDR or a single address, which is the network of
+// only that address.
+func parseNetwork(s string) (netip.Prefix, error) {
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	if prefix.Addr().Is4In6() {
+		if prefix.Bits() < 96 {
+			return netip.Prefix{}, fmt.Errorf("invalid network %s", s)
+		}
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+	}
+	return prefix.Masked(), nil
+}
@@ -0,0 +1,114 @@
+package rules
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpr_Match(t *testing.T) {
+	london := &Attributes{
+		IP: netip.MustParseAddr("81.2.69.160"),
+		City: &wherego.City{
+			Country:      wherego.CountryRecord{ISOCode: "GB"},
+			Continent:    wherego.Continent{Code: "EU"},
+			Subdivisions: []wherego.CitySubdivision{{ISOCode: "ENG"}},
+		},
+		ASN: &wherego.ASN{AutonomousSystemNumber: 20712, AutonomousSystemOrganization: "Andrews & Arnold Ltd"},
+	}
+	tor := &Attributes{
+		IP:          netip.MustParseAddr("185.220.101.7"),
+		AnonymousIP: &wherego.AnonymousIP{IsAnonymous: true, IsTorExitNode: true},
+	}
+
+	tests := []struct {
+		name  string
+		expr  string
+		attrs *Attributes
+		want  bool
+	}{
+		{"Country In List", "country in [US, GB]", london, true},
+		{"Country Not In List", "country not in [US, GB]", london, false},
+		{"Case Insensitive", "Country == gb AND region == \"eng\"", london, true},
+		{"Missing Data Is Empty", "country == \"\"", tor, true},
+		{"Boolean Attribute", "anonymous.is_tor_exit_node", tor, true},
+		{"Missing Flags Are False", "anonymous.is_anonymous", london, false},
+		{"Boolean Comparison", "anonymous.is_tor_exit_node == false", tor, false},
+		{"Boolean Inequality", "anonymous.is_tor_exit_node != false", tor, true},
+		{"Not Binds Tighter Than And", "not anonymous.is_tor_exit_node and country == GB", london, true},
+		{"And Binds Tighter Than Or", "country == US and asn == 1 or continent == EU", london, true},
+		{"Parentheses", "country == US and (asn == 1 or continent == EU)", london, false},
+		{"Number Comparison", "asn >= 20000 and asn < 30000", london, true},
+		{"AS Prefix", "asn in [AS15169, AS20712]", london, true},
+		{"Organization", `as_organization == "andrews & arnold ltd"`, london, true},
+		{"IP In Networks", "ip in [10.0.0.0/8, 185.220.101.0/24]", tor, true},
+		{"IP Not In Networks", "ip not in [2001:db8::/32, 81.2.69.160]", london, false},
+		{"IP Equality", "ip == 81.2.69.160", london, true},
+		{"Address Class", "address_class == \"\"", london, true},
+		{"Trailing Comma", "country in [GB,]", london, true},
+		{"Constant", "true and not false", tor, true},
+		{"Request Example", "country in [US, CA] and not anonymous.is_tor_exit_node", tor, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := Compile(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, e.Match(tt.attrs))
+			assert.Equal(t, tt.expr, e.String())
+		})
+	}
+}
+
+func TestExpr_Sources(t *testing.T) {
+	tests := []struct {
+		expr string
+		want Source
+	}{
+		{"ip in [10.0.0.0/8] or address_class == private", 0},
+		{"country == US", SourceCity},
+		{"asn == 1 and anonymous.is_anonymous", SourceASN | SourceAnonymousIP},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, MustCompile(tt.expr).Sources(), tt.expr)
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		expr   string
+		offset int
+		msg    string
+	}{
+		{"Empty", "", 0, "expected condition but found end of expression"},
+		{"Unknown Attribute", "contry == US", 0, `unknown attribute "contry"`},
+		{"Missing Comparison", "country", 0, `string attribute "country" needs a comparison`},
+		{"Ordering Strings", "country < US", 8, `operator < does not apply to string attribute "country"`},
+		{"Single Equals", "country = US", 8, `unexpected "="`},
+		{"Missing Value", "country ==", 10, "expected value but found end of expression"},
+		{"Missing List", "country in US", 11, `expected [ but found "US"`},
+		{"Unclosed List", "country in [US", 14, "expected , or ] but found end of expression"},
+		{"Not Without In", "country not US", 12, `expected in but found "US"`},
+		{"Unclosed Parenthesis", "(country == US", 14, "expected ) but found end of expression"},
+		{"Trailing Tokens", "country == US GB", 14, `unexpected "GB"`},
+		{"Unterminated String", `country == "US`, 11, "unterminated string"},
+		{"Invalid Number", "asn == google", 7, `expected number but found "google"`},
+		{"Invalid Boolean", "anonymous.is_anonymous == yes", 26, `expected true or false but found "yes"`},
+		{"Boolean In List", "anonymous.is_anonymous in [true]", 23, `operator in does not apply to boolean attribute "anonymous.is_anonymous"`},
+		{"Invalid Network", "ip in [10.0.0.0/33]", 7, `expected IP address or network but found "10.0.0.0/33"`},
+		{"Network Equality", "ip == 10.0.0.0/8", 6, `expected IP address or network but found "10.0.0.0/8"`},
+		{"Dangling Operator", "country == US and", 17, "expected condition but found end of expression"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.expr)
+			var syntaxErr *SyntaxError
+			require.ErrorAs(t, err, &syntaxErr)
+			assert.Equal(t, tt.offset, syntaxErr.Offset)
+			assert.Equal(t, tt.msg, syntaxErr.Msg)
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package rules
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"gopkg.in/yaml.v3"
+)
+
+// Action is what a policy does with an address.
+type Action string
+
+// Actions of rules and policies.
+const (
+	ActionAllow Action = "allow"
+	ActionDeny  Action = "deny"
+)
+
+// Rule is a named rule of a policy.
+type Rule struct {
+	Name   string
+	Action Action
+	When   *Expr
+}
+
+// Decision is the outcome of evaluating a policy for an address.
+type Decision struct {
+	Action Action
+	// Rule is the name of the rule that matched. It is empty when no rule
+	// matched and the policy's default action applied.
+	Rule string
+}
+
+// Allowed reports whether the decision allows the address.
+func (d Decision) Allowed() bool {
+	return d.Action == ActionAllow
+}
+
+// Default reports whether no rule matched.
+func (d Decision) Default() bool {
+	return d.Rule == ""
+}
+
+// Policy is an ordered list of rules, the first matching rule deciding
+// the action for an address, and a default action for addresses no rule
+// matches.
+//
+// Policies are loaded from a YAML or JSON file:
+//
+//	default: deny
+//	rules:
+//	  - name: no-tor
+//	    action: deny
+//	    when: anonymous.is_tor_exit_node
+//	  - name: north-america
+//	    action: allow
+//	    when: country in [US, CA]
+//
+// The default action is allow when the file does not set one. A file
+// without rules must set it, and an empty file is an error, so a file
+// emptied by a truncated write does not allow every address. Reload and
+// Watch replace the rules atomically, so Policy is safe for concurrent use
+// while the file changes.
+type Policy struct {
+	path  string
+	table atomic.Pointer[policyTable]
+
+	// mu serializes reloads.
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	// svc is the service of Bind, whose databases reloaded rules are
+	// checked against.
+	svc *wherego.Service
+}
+
+type policyTable struct {
+	def     Action
+	rules   []Rule
+	sources Source
+}
+
+type policyFile struct {
+	Default Action     `yaml:"default"`
+	Rules   []ruleSpec `yaml:"rules"`
+}
+
+type ruleSpec struct {
+	Name   string `yaml:"name"`
+	Action Action `yaml:"action"`
+	When   string `yaml:"when"`
+}
+
+// Load reads the policy file at path.
+func Load(path string) (*Policy, error) {
+	p := &Policy{path: path}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Path returns the file the policy is loaded from.
+func (p *Policy) Path() string {
+	return p.path
+}
+
+// Len returns the number of rules.
+func (p *Policy) Len() int {
+	return len(p.table.Load().rules)
+}
+
+// Rules returns the rules in order.
+func (p *Policy) Rules() []Rule {
+	return append([]Rule(nil), p.table.Load().rules...)
+}
+
+// Sources returns the databases the rules need data from.
+func (p *Policy) Sources() Source {
+	return p.table.Load().sources
+}
+
+// Bind checks that svc has the databases the rules need data from, and
+// makes Reload and Watch reject rules that need data svc has no database
+// for, so a policy cannot fail every evaluation with lookup errors.
+func (p *Policy) Bind(svc *wherego.Service) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := Check(svc, p.table.Load().sources); err != nil {
+		return fmt.Errorf("policy %s: %w", p.path, err)
+	}
+	p.svc = svc
+	return nil
+}
+
+// Reload reads the policy file again. On error, including rules that need
+// data the service of Bind has no database for, the current rules stay in
+// place.
+func (p *Policy) Reload() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return err
+	}
+	table, err := parsePolicy(data)
+	if err != nil {
+		return fmt.Errorf("policy %s: %w", p.path, err)
+	}
+	if p.svc != nil {
+		if err := Check(p.svc, table.sources); err != nil {
+			return fmt.Errorf("policy %s: %w", p.path, err)
+		}
+	}
+	p.table.Store(table)
+	p.modTime, p.size = info.ModTime(), info.Size()
+	return nil
+}
+
+// Watch polls the policy file every interval and reloads it when its
+// modification time or size changes, until ctx is done. Reload errors are
+// passed to onError, which may be nil.
+func (p *Policy) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !p.changed() {
+			continue
+		}
+		if err := p.Reload(); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+func (p *Policy) changed() bool {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		// Report the error through Reload.
+		return true
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !info.ModTime().Equal(p.modTime) || info.Size() != p.size
+}
+
+// Evaluate looks up the data the rules need for addr in svc and decides
+// its action. Missing data is not an error; other lookup errors, such as a
+// database without the data of an attribute the rules use, are returned.
+func (p *Policy) Evaluate(svc *wherego.Service, addr netip.Addr) (Decision, error) {
+	decision, _, err := p.EvaluateWith(svc, addr, 0)
+	return decision, err
+}
+
+// EvaluateWith is Evaluate for callers that need data of addr besides the
+// decision: it also looks up sources, and returns the attributes.
+func (p *Policy) EvaluateWith(svc *wherego.Service, addr netip.Addr, sources Source) (Decision, *Attributes, error) {
+	t := p.table.Load()
+	a, err := Lookup(svc, addr.Unmap(), t.sources|sources)
+	if err != nil {
+		return Decision{}, nil, err
+	}
+	return t.decide(a), a, nil
+}
+
+// Decide decides the action for the attributes of an address.
+func (p *Policy) Decide(a *Attributes) Decision {
+	return p.table.Load().decide(a)
+}
+
+func (t *policyTable) decide(a *Attributes) Decision {
+	for _, rule := range t.rules {
+		if rule.When.Match(a) {
+			return Decision{Action: rule.Action, Rule: rule.Name}
+		}
+	}
+	return Decision{Action: t.def}
+}
+
+func parsePolicy(data []byte) (*policyTable, error) {
+	// YAML is a superset of JSON, so this reads both formats.
+	var file policyFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil {
+		if errors.Is(err, io.EOF) {
+			// An empty file is more likely a truncated write than a policy
+			// that allows everything.
+			return nil, errors.New("empty policy")
+		}
+		return nil, err
+	}
+	if file.Default == "" && len(file.Rules) == 0 {
+		return nil, errors.New("no rules and no default action")
+	}
+
+	table := &policyTable{def: ActionAllow, rules: make([]Rule, 0, len(file.Rules))}
+	if file.Default != "" {
+		if !file.Default.valid() {
+			return nil, fmt.Errorf("invalid default action %q", file.Default)
+		}
+		table.def = file.Default
+	}
+	seen := make(map[string]bool, len(file.Rules))
+	for i, spec := range file.Rules {
+		switch {
+		case spec.Name == "":
+			return nil, fmt.Errorf("rule %d: missing name", i)
+		case seen[spec.Name]:
+			return nil, fmt.Errorf("rule %d: duplicate name %q", i, spec.Name)
+		case !spec.Action.valid():
+			return nil, fmt.Errorf("rule %q: invalid action %q", spec.Name, spec.Action)
+		case spec.When == "":
+			return nil, fmt.Errorf("rule %q: missing when", spec.Name)
+		}
+		seen[spec.Name] = true
+		when, err := Compile(spec.When)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", spec.Name, err)
+		}
+		table.rules = append(table.rules, Rule{Name: spec.Name, Action: spec.Action, When: when})
+		table.sources |= when.Sources()
+	}
+	return table, nil
+}
+
+func (a Action) valid() bool {
+	return a == ActionAllow || a == ActionDeny
+}
@@ -0,0 +1,201 @@
+package rules
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gustavosett/WhereGo/internal/geoiptest"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const samplePolicy = `
+default: deny
+rules:
+  - name: no-tor
+    action: deny
+    when: anonymous.is_tor_exit_node
+  - name: offices
+    action: allow
+    when: ip in [10.0.0.0/8]
+  - name: north-america
+    action: allow
+    when: country in [US, CA] and asn != 64496
+`
+
+func writePolicy(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func sampleService(t *testing.T) *wherego.Service {
+	t.Helper()
+	svc := &wherego.Service{}
+	for _, db := range []struct {
+		reader **wherego.Reader
+		data   geoiptest.Database
+	}{
+		{&svc.DB, geoiptest.SampleCity()},
+		{&svc.ASNDB, geoiptest.SampleASN()},
+		{&svc.AnonymousIPDB, geoiptest.SampleAnonymousIP()},
+	} {
+		reader, err := wherego.OpenBytes(geoiptest.MustBuild(db.data))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = reader.Close() })
+		*db.reader = reader
+	}
+	return svc
+}
+
+func TestPolicy_Evaluate(t *testing.T) {
+	p, err := Load(writePolicy(t, samplePolicy))
+	require.NoError(t, err)
+	assert.Equal(t, 3, p.Len())
+	assert.Equal(t, SourceCity|SourceASN|SourceAnonymousIP, p.Sources())
+	svc := sampleService(t)
+
+	tests := []struct {
+		name string
+		ip   string
+		want Decision
+	}{
+		{"Allowed Country", "8.8.8.8", Decision{Action: ActionAllow, Rule: "north-america"}},
+		{"Tor Exit Node", "185.220.101.7", Decision{Action: ActionDeny, Rule: "no-tor"}},
+		{"Network Without Data", "10.1.2.3", Decision{Action: ActionAllow, Rule: "offices"}},
+		{"Default", "81.2.69.160", Decision{Action: ActionDeny}},
+		{"IPv4-Mapped Address", "::ffff:8.8.8.8", Decision{Action: ActionAllow, Rule: "north-america"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := p.Evaluate(svc, netip.MustParseAddr(tt.ip))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.want.Action == ActionAllow, got.Allowed())
+			assert.Equal(t, tt.want.Rule == "", got.Default())
+		})
+	}
+
+	t.Run("Missing Database", func(t *testing.T) {
+		_, err := p.Evaluate(&wherego.Service{DB: svc.DB}, netip.MustParseAddr("8.8.8.8"))
+		var invalidMethod wherego.InvalidMethodError
+		assert.ErrorAs(t, err, &invalidMethod)
+	})
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	p, err := Load(writePolicy(t, "default: allow"))
+	require.NoError(t, err)
+	assert.Zero(t, p.Len())
+	assert.Equal(t, Decision{Action: ActionAllow}, p.Decide(&Attributes{}))
+
+	p, err = Load(writePolicy(t, `{"rules": [{"name": "eu", "action": "deny", "when": "continent == EU"}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, "eu", p.Rules()[0].Name)
+	assert.Equal(t, "continent == EU", p.Rules()[0].When.String())
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"Missing File", ""},
+		{"Empty File", "\n"},
+		{"Only Comments", "# default: deny\n"},
+		{"No Rules And No Default", "rules: []"},
+		{"Invalid YAML", "rules: ["},
+		{"Unknown Field", "rules: [{name: a, action: deny, when: 'true', after: b}]"},
+		{"Invalid Default", "default: block"},
+		{"Missing Name", "rules: [{action: deny, when: 'true'}]"},
+		{"Duplicate Name", "rules: [{name: a, action: deny, when: 'true'}, {name: a, action: allow, when: 'true'}]"},
+		{"Invalid Action", "rules: [{name: a, action: block, when: 'true'}]"},
+		{"Missing When", "rules: [{name: a, action: deny}]"},
+		{"Invalid Expression", "rules: [{name: a, action: deny, when: 'country =='}]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "missing.yaml")
+			if tt.content != "" {
+				path = writePolicy(t, tt.content)
+			}
+			p, err := Load(path)
+			assert.Error(t, err)
+			assert.Nil(t, p)
+		})
+	}
+}
+
+func TestPolicy_Reload(t *testing.T) {
+	path := writePolicy(t, samplePolicy)
+	p, err := Load(path)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte("rules: ["), 0o600))
+	assert.Error(t, p.Reload())
+	assert.Equal(t, 3, p.Len(), "a broken file should keep the current rules")
+
+	for _, content := range []string{"", "rules: []"} {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		assert.Error(t, p.Reload())
+		assert.Equal(t, 3, p.Len(), "an emptied file should keep the current rules")
+		assert.Equal(t, Decision{Action: ActionDeny}, p.Decide(&Attributes{}), "the default action should still deny")
+	}
+
+	require.NoError(t, os.WriteFile(path, []byte("rules: [{name: all, action: deny, when: 'true'}]"), 0o600))
+	require.NoError(t, p.Reload())
+	assert.Equal(t, 1, p.Len())
+}
+
+func TestPolicy_Bind(t *testing.T) {
+	svc := sampleService(t)
+	cityOnly := &wherego.Service{DB: svc.DB}
+
+	p, err := Load(writePolicy(t, samplePolicy))
+	require.NoError(t, err)
+	var invalidMethod wherego.InvalidMethodError
+	assert.ErrorAs(t, p.Bind(cityOnly), &invalidMethod, "the rules need ASN and Anonymous IP data")
+	assert.NoError(t, p.Bind(svc))
+
+	t.Run("Reload", func(t *testing.T) {
+		path := writePolicy(t, "rules: [{name: us, action: allow, when: country == US}]")
+		p, err := Load(path)
+		require.NoError(t, err)
+		require.NoError(t, p.Bind(cityOnly))
+
+		require.NoError(t, os.WriteFile(path, []byte(samplePolicy), 0o600))
+		assert.ErrorAs(t, p.Reload(), &invalidMethod)
+		assert.Equal(t, 1, p.Len(), "rules the service lacks data for should keep the current rules")
+
+		require.NoError(t, os.WriteFile(path, []byte("rules: [{name: eu, action: allow, when: is_in_european_union}]"), 0o600))
+		require.NoError(t, p.Reload())
+		assert.Equal(t, "eu", p.Rules()[0].Name)
+	})
+}
+
+func TestPolicy_Watch(t *testing.T) {
+	path := writePolicy(t, samplePolicy)
+	p, err := Load(path)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		p.Watch(ctx, time.Millisecond, func(err error) { t.Error(err) })
+	}()
+
+	require.NoError(t, os.WriteFile(path, []byte("rules: [{name: all, action: deny, when: 'true'}]"), 0o600))
+	assert.Eventually(t, func() bool { return p.Len() == 1 }, 5*time.Second, time.Millisecond)
+
+	cancel()
+	wg.Wait()
+}
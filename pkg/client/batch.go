@@ -0,0 +1,181 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"strings"
+
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+)
+
+const (
+	streamPath        = "/v1/lookup/stream"
+	streamContentType = "text/plain; charset=utf-8"
+)
+
+// Result is the lookup of one address of a batch or stream.
+type Result struct {
+	// IP is the address as it was passed, without surrounding white space.
+	IP string
+	// City is the lookup of IP, unless it failed.
+	City *wherego.City
+	// Record is the lookup of IP when the server has a database of a
+	// custom type, which has no fixed schema.
+	Record map[string]any
+	// Err is the *Error of a failed lookup.
+	Err error
+}
+
+// streamLine is a line of the response of POST /v1/lookup/stream.
+type streamLine struct {
+	Input  string         `json:"input"`
+	Result *wherego.City  `json:"result"`
+	Record map[string]any `json:"record"`
+	Error  *Error         `json:"error"`
+}
+
+func (l *streamLine) result() Result {
+	r := Result{IP: l.Input, City: l.Result, Record: l.Record}
+	if l.Error != nil {
+		r.Err = l.Error
+	}
+	return r
+}
+
+// LookupBatch looks up ips in one request to POST /v1/lookup/stream, and
+// returns one Result per entry of ips, in the same order. Cached addresses
+// are not sent; blank entries fail with wherego.ErrInvalidIP. The error is
+// only set when the request fails as a whole; the request is retried like
+// a lookup.
+func (c *Client) LookupBatch(ctx context.Context, ips []string) ([]Result, error) {
+	results := make([]Result, len(ips))
+	var pending []int
+	var body strings.Builder
+	for i, ip := range ips {
+		ip = strings.TrimSpace(ip)
+		results[i].IP = ip
+		if city, ok := c.cache.get(cacheKey(ip)); ok {
+			results[i].City = city
+			continue
+		}
+		if ip == "" || strings.ContainsAny(ip, "\r\n") {
+			results[i].Err = &Error{
+				Status: http.StatusBadRequest,
+				Code:   CodeInvalidIP,
+				Title:  http.StatusText(http.StatusBadRequest),
+				Detail: "The entry is not an IP address.",
+			}
+			continue
+		}
+		pending = append(pending, i)
+		body.WriteString(ip)
+		body.WriteByte('\n')
+	}
+	if len(pending) == 0 {
+		return results, nil
+	}
+
+	payload := body.String()
+	err := c.do(ctx, http.MethodPost, streamPath, func() io.Reader { return strings.NewReader(payload) }, streamContentType,
+		func(res *http.Response) error {
+			dec := json.NewDecoder(res.Body)
+			for n := 0; ; n++ {
+				var line streamLine
+				if err := dec.Decode(&line); errors.Is(err, io.EOF) {
+					if n != len(pending) {
+						return fmt.Errorf("wherego: stream ended after %d of %d results", n, len(pending))
+					}
+					return nil
+				} else if err != nil {
+					return fmt.Errorf("wherego: read stream: %w", err)
+				}
+				if n >= len(pending) {
+					return fmt.Errorf("wherego: stream has more than %d results", len(pending))
+				}
+				i := pending[n]
+				results[i] = line.result()
+				results[i].IP = strings.TrimSpace(ips[i])
+			}
+		})
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range pending {
+		if results[i].City != nil {
+			c.cache.add(cacheKey(results[i].IP), results[i].City)
+		}
+	}
+	return results, nil
+}
+
+// Stream looks up the addresses of ips as they come, over one request to
+// POST /v1/lookup/stream, and yields their results in the same order while
+// ips is still being read. Blank entries are skipped. The sequence stops
+// with a non-nil error when the request fails; it is not retried, since
+// ips can only be read once. Breaking out of the loop cancels the request.
+//
+// ips is read on another goroutine. Memory use does not grow with the
+// number of addresses, which makes Stream suited to large files and
+// unbounded sources.
+func (c *Client) Stream(ctx context.Context, ips iter.Seq[string]) iter.Seq2[Result, error] {
+	return func(yield func(Result, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		pr, pw := io.Pipe()
+		defer func() { _ = pr.CloseWithError(errStreamDone) }()
+		go func() {
+			for ip := range ips {
+				if ip = strings.TrimSpace(ip); ip == "" {
+					continue
+				}
+				if _, err := io.WriteString(pw, ip+"\n"); err != nil {
+					return
+				}
+			}
+			_ = pw.Close()
+		}()
+
+		req, err := c.newRequest(ctx, http.MethodPost, streamPath, pr, streamContentType)
+		if err != nil {
+			yield(Result{}, err)
+			return
+		}
+		res, err := c.http.Do(req)
+		if err != nil {
+			yield(Result{}, err)
+			return
+		}
+		defer func() { _ = res.Body.Close() }()
+		if res.StatusCode >= http.StatusBadRequest {
+			yield(Result{}, readError(res))
+			return
+		}
+
+		dec := json.NewDecoder(res.Body)
+		for {
+			var line streamLine
+			if err := dec.Decode(&line); errors.Is(err, io.EOF) {
+				return
+			} else if err != nil {
+				yield(Result{}, fmt.Errorf("wherego: read stream: %w", err))
+				return
+			}
+			r := line.result()
+			if r.City != nil {
+				c.cache.add(cacheKey(r.IP), r.City)
+			}
+			if !yield(r, nil) {
+				return
+			}
+		}
+	}
+}
+
+// errStreamDone stops reading the addresses of a stream that has ended.
+var errStreamDone = errors.New("wherego: stream done")
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"container/list"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+)
+
+// cache is a least recently used cache of lookups by address that expire
+// after a fixed time. A nil *cache caches nothing.
+type cache struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu    sync.Mutex
+	items map[string]*list.Element
+	order *list.List
+}
+
+type cacheItem struct {
+	key     string
+	city    *wherego.City
+	expires time.Time
+}
+
+func newCache(size int, ttl time.Duration) *cache {
+	if size <= 0 || ttl <= 0 {
+		return nil
+	}
+	return &cache{
+		size:  size,
+		ttl:   ttl,
+		now:   time.Now,
+		items: make(map[string]*list.Element, size),
+		order: list.New(),
+	}
+}
+
+// cacheKey returns the canonical form of ip, so "::FFFF:1.2.3.4" and
+// "::ffff:1.2.3.4" share an entry. Strings that are not addresses are
+// their own keys.
+func cacheKey(ip string) string {
+	if addr, err := netip.ParseAddr(ip); err == nil {
+		return addr.String()
+	}
+	return ip
+}
+
+func (c *cache) get(key string) (*wherego.City, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := el.Value.(*cacheItem)
+	if !c.now().Before(item.expires) {
+		c.order.Remove(el)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return item.city, true
+}
+
+func (c *cache) add(key string, city *wherego.City) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := c.now().Add(c.ttl)
+	if el, ok := c.items[key]; ok {
+		item := el.Value.(*cacheItem)
+		item.city, item.expires = city, expires
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&cacheItem{key: key, city: city, expires: expires})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheItem).key)
+	}
+}
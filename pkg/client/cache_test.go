@@ -0,0 +1,68 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache_Expiry(t *testing.T) {
+	now := time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	c := newCache(2, time.Minute)
+	c.now = func() time.Time { return now }
+
+	city := &wherego.City{}
+	c.add("8.8.8.8", city)
+	got, ok := c.get("8.8.8.8")
+	assert.True(t, ok)
+	assert.Same(t, city, got)
+
+	now = now.Add(time.Minute)
+	_, ok = c.get("8.8.8.8")
+	assert.False(t, ok, "expired")
+	assert.Zero(t, c.order.Len())
+}
+
+func TestCache_Eviction(t *testing.T) {
+	c := newCache(2, time.Minute)
+	c.add("1.1.1.1", &wherego.City{})
+	c.add("8.8.8.8", &wherego.City{})
+	_, _ = c.get("1.1.1.1")
+	c.add("9.9.9.9", &wherego.City{})
+
+	_, ok := c.get("8.8.8.8")
+	assert.False(t, ok, "least recently used")
+	_, ok = c.get("1.1.1.1")
+	assert.True(t, ok)
+	_, ok = c.get("9.9.9.9")
+	assert.True(t, ok)
+}
+
+func TestCache_Disabled(t *testing.T) {
+	assert.Nil(t, newCache(0, time.Minute))
+	assert.Nil(t, newCache(10, 0))
+
+	var c *cache
+	c.add("8.8.8.8", &wherego.City{})
+	_, ok := c.get("8.8.8.8")
+	assert.False(t, ok)
+}
+
+func TestCacheKey(t *testing.T) {
+	assert.Equal(t, "2606:4700::1111", cacheKey("2606:4700:0:0::1111"))
+	assert.Equal(t, "::ffff:1.2.3.4", cacheKey("::FFFF:1.2.3.4"))
+	assert.Equal(t, "bogus", cacheKey("bogus"))
+}
+
+func TestBackoff(t *testing.T) {
+	c := &Client{minBackoff: 100 * time.Millisecond, maxBackoff: time.Second}
+	for attempt, ceiling := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 800 * time.Millisecond, 5: time.Second, 40: time.Second} {
+		for range 100 {
+			wait := c.backoff(attempt)
+			assert.Positive(t, wait)
+			assert.LessOrEqual(t, wait, ceiling, "attempt %d", attempt)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+// Package client is the Go client of the WhereGo HTTP API. It looks up
+// addresses through the /v1 routes and returns the models of package
+// wherego, so code can switch between a remote server and an in-process
+// wherego.Service without changing types.
+//
+//	c, err := client.New("https://geo.example.com", client.WithAPIKey(key))
+//	if err != nil {
+//		return err
+//	}
+//	city, err := c.Lookup(ctx, "81.2.69.160")
+//	if errors.Is(err, wherego.ErrNotFound) {
+//		// No data for the address.
+//	}
+//
+// Requests are retried with exponential backoff and jitter when the
+// server is unavailable. NewFake returns a client served by a
+// wherego.Service in the same process, for tests.
+//
+// The package follows the stability policy of package wherego.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+)
+
+// HeaderAPIKey is the request header the API key is sent in.
+const HeaderAPIKey = "X-API-Key"
+
+// Defaults of the client options.
+const (
+	DefaultTimeout     = 10 * time.Second
+	DefaultMaxAttempts = 3
+	DefaultMinBackoff  = 100 * time.Millisecond
+	DefaultMaxBackoff  = 2 * time.Second
+)
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sends requests with hc instead of http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.http = hc
+	}
+}
+
+// WithAPIKey sends key in the X-API-Key header of every request.
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithTimeout bounds each attempt of a lookup or batch. Zero disables the
+// timeout; the context of the call still applies. Streams are only bounded
+// by their context. It defaults to DefaultTimeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithRetry makes up to maxAttempts attempts of a request when the server
+// is unavailable, waiting a random time up to minBackoff, 2*minBackoff,
+// 4*minBackoff and so on, at most maxBackoff, between attempts. A
+// maxAttempts of 1 disables retries.
+func WithRetry(maxAttempts int, minBackoff, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxAttempts = max(maxAttempts, 1)
+		c.minBackoff, c.maxBackoff = minBackoff, max(maxBackoff, minBackoff)
+	}
+}
+
+// WithCache keeps up to size successful lookups for ttl, so repeated
+// lookups of an address do not reach the server. Cached results are shared
+// and must not be modified.
+func WithCache(size int, ttl time.Duration) Option {
+	return func(c *Client) {
+		c.cache = newCache(size, ttl)
+	}
+}
+
+// Client calls the WhereGo HTTP API. It is safe for concurrent use.
+type Client struct {
+	base        *url.URL
+	http        *http.Client
+	apiKey      string
+	timeout     time.Duration
+	maxAttempts int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	cache       *cache
+}
+
+// New returns a client of the WhereGo server at baseURL, such as
+// "https://geo.example.com". A path in baseURL prefixes the API routes.
+func New(baseURL string, options ...Option) (*Client, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse base URL: %w", err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" || base.Host == "" {
+		return nil, fmt.Errorf("base URL %q is not an absolute http or https URL", baseURL)
+	}
+	base.Path = strings.TrimSuffix(base.Path, "/")
+
+	c := &Client{
+		base:        base,
+		http:        http.DefaultClient,
+		timeout:     DefaultTimeout,
+		maxAttempts: DefaultMaxAttempts,
+		minBackoff:  DefaultMinBackoff,
+		maxBackoff:  DefaultMaxBackoff,
+	}
+	for _, option := range options {
+		if option != nil {
+			option(c)
+		}
+	}
+	return c, nil
+}
+
+// Lookup returns the City data of ip from GET /v1/lookup/{ip}. It returns
+// an *Error for problem responses, which matches wherego.ErrNotFound and
+// wherego.ErrInvalidIP with errors.Is.
+func (c *Client) Lookup(ctx context.Context, ip string) (*wherego.City, error) {
+	key := cacheKey(ip)
+	if city, ok := c.cache.get(key); ok {
+		return city, nil
+	}
+	city := new(wherego.City)
+	if err := c.getJSON(ctx, "/v1/lookup/"+url.PathEscape(ip), city); err != nil {
+		return nil, err
+	}
+	c.cache.add(key, city)
+	return city, nil
+}
+
+// Me returns the City data of the address the server sees the client at,
+// from GET /v1/me. It is never cached.
+func (c *Client) Me(ctx context.Context) (*wherego.City, error) {
+	city := new(wherego.City)
+	if err := c.getJSON(ctx, "/v1/me", city); err != nil {
+		return nil, err
+	}
+	return city, nil
+}
+
+func (c *Client) getJSON(ctx context.Context, path string, v any) error {
+	return c.do(ctx, http.MethodGet, path, nil, "", func(res *http.Response) error {
+		return json.NewDecoder(res.Body).Decode(v)
+	})
+}
+
+// do sends a request with retries and hands a successful response to
+// decode. body returns a fresh request body for every attempt; nil sends
+// none.
+func (c *Client) do(ctx context.Context, method, path string, body func() io.Reader, contentType string,
+	decode func(*http.Response) error) error {
+	for attempt := 1; ; attempt++ {
+		wait, err := c.attempt(ctx, method, path, body, contentType, decode)
+		if wait < 0 || attempt >= c.maxAttempts {
+			return err
+		}
+		if wait == 0 {
+			wait = c.backoff(attempt)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt makes one attempt of a request. It returns how long to wait
+// before the next attempt: zero for the backoff, or -1 when the request
+// must not be retried.
+func (c *Client) attempt(ctx context.Context, method, path string, body func() io.Reader, contentType string,
+	decode func(*http.Response) error) (time.Duration, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	var r io.Reader
+	if body != nil {
+		r = body()
+	}
+	req, err := c.newRequest(ctx, method, path, r, contentType)
+	if err != nil {
+		return -1, err
+	}
+	res, err := c.http.Do(req)
+	if err != nil {
+		if ctx.Err() != nil && !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return -1, err
+		}
+		return 0, err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return c.retryWait(res), readError(res)
+	}
+	return -1, decode(res)
+}
+
+// newRequest returns a request for path, which is escaped, under the base
+// URL.
+func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader, contentType string) (*http.Request, error) {
+	unescaped, err := url.PathUnescape(path)
+	if err != nil {
+		return nil, err
+	}
+	u := *c.base
+	u.RawPath = c.base.EscapedPath() + path
+	u.Path += unescaped
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.apiKey != "" {
+		req.Header.Set(HeaderAPIKey, c.apiKey)
+	}
+	return req, nil
+}
+
+// retryWait returns how long to wait before retrying a failed response, or
+// -1 if it must not be retried. Unavailable servers are retried after the
+// backoff; used-up quotas only when they reset within the longest backoff.
+func (c *Client) retryWait(res *http.Response) time.Duration {
+	switch res.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+	case http.StatusTooManyRequests:
+		seconds, err := strconv.Atoi(res.Header.Get("Retry-After"))
+		if err != nil {
+			return 0
+		}
+		if wait := time.Duration(seconds) * time.Second; wait <= c.maxBackoff {
+			return wait
+		}
+		return -1
+	default:
+		return -1
+	}
+	return 0
+}
+
+// backoff returns a random wait before the attempt after the given one,
+// with "full jitter": up to minBackoff doubled for every attempt made,
+// capped at maxBackoff.
+func (c *Client) backoff(attempt int) time.Duration {
+	ceiling := c.maxBackoff
+	if shift := attempt - 1; shift < 32 && c.minBackoff<<shift < ceiling {
+		ceiling = c.minBackoff << shift
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling) + 1
+}
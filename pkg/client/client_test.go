@@ -0,0 +1,310 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gustavosett/WhereGo/internal/geoiptest"
+	"github.com/gustavosett/WhereGo/pkg/client"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/gustavosett/WhereGo/pkg/wherego/geohttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sampleService(t *testing.T) *wherego.Service {
+	t.Helper()
+	db, err := wherego.OpenBytes(geoiptest.MustBuild(geoiptest.SampleCity()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return &wherego.Service{DB: db}
+}
+
+// countingServer serves the v1 API of svc over HTTP and counts the
+// requests, answering the first failures of them with failStatus.
+type countingServer struct {
+	*httptest.Server
+	requests   atomic.Int32
+	failures   int32
+	failStatus int
+	retryAfter string
+}
+
+func newCountingServer(t *testing.T, svc *wherego.Service) *countingServer {
+	t.Helper()
+	s := &countingServer{}
+	h := geohttp.NewHandler(svc)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n := s.requests.Add(1); n <= s.failures {
+			if s.retryAfter != "" {
+				w.Header().Set("Retry-After", s.retryAfter)
+			}
+			http.Error(w, "upstream unavailable", s.failStatus)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestLookup(t *testing.T) {
+	svc := sampleService(t)
+	server := newCountingServer(t, svc)
+	remote, err := client.New(server.URL + "/")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		ip          string
+		wantCountry string
+		wantErr     error
+		wantCode    string
+		wantClass   wherego.AddressClass
+	}{
+		{name: "City", ip: "81.2.69.160", wantCountry: "GB"},
+		{name: "IPv6", ip: "2606:4700::1111", wantCountry: "US"},
+		{name: "Not Found", ip: "127.0.0.1", wantErr: wherego.ErrNotFound, wantCode: client.CodeNotFound, wantClass: wherego.AddressClassLoopback},
+		{name: "Invalid IP", ip: "bogus", wantErr: wherego.ErrInvalidIP, wantCode: client.CodeInvalidIP},
+		{name: "Escaped Path", ip: "a/b?c", wantErr: wherego.ErrInvalidIP, wantCode: client.CodeInvalidIP},
+	}
+	for name, c := range map[string]*client.Client{"Remote": remote, "Fake": client.NewFake(svc)} {
+		for _, tt := range tests {
+			t.Run(name+" "+tt.name, func(t *testing.T) {
+				city, err := c.Lookup(context.Background(), tt.ip)
+				if tt.wantErr != nil {
+					require.ErrorIs(t, err, tt.wantErr)
+					var apiErr *client.Error
+					require.ErrorAs(t, err, &apiErr)
+					assert.Equal(t, tt.wantCode, apiErr.Code)
+					assert.Equal(t, tt.wantClass, apiErr.AddressClass)
+					return
+				}
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantCountry, city.Country.ISOCode)
+				assert.Equal(t, tt.ip, city.Traits.IPAddress.String())
+			})
+		}
+	}
+
+	t.Run("Fake Me", func(t *testing.T) {
+		_, err := client.NewFake(svc).Me(context.Background())
+		var apiErr *client.Error
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, wherego.AddressClassDocumentation, apiErr.AddressClass, "fake requests come from %s", client.FakeRemoteAddr)
+	})
+}
+
+func TestNew(t *testing.T) {
+	for _, baseURL := range []string{"", "geo.example.com", "ftp://geo.example.com", "http://%zz"} {
+		_, err := client.New(baseURL)
+		assert.Error(t, err, baseURL)
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(client.HeaderAPIKey)
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"status":401,"code":"invalid_api_key","detail":"The API key is not valid."}`))
+	}))
+	defer server.Close()
+
+	c, err := client.New(server.URL, client.WithAPIKey("secret"))
+	require.NoError(t, err)
+	_, err = c.Lookup(context.Background(), "8.8.8.8")
+	assert.EqualError(t, err, "wherego: 401 invalid_api_key: The API key is not valid.")
+	assert.Equal(t, "secret", got)
+}
+
+func TestRetry(t *testing.T) {
+	svc := sampleService(t)
+
+	tests := []struct {
+		name         string
+		failures     int32
+		failStatus   int
+		retryAfter   string
+		maxAttempts  int
+		wantRequests int32
+		wantStatus   int
+	}{
+		{name: "Recovers", failures: 2, failStatus: http.StatusServiceUnavailable, maxAttempts: 3, wantRequests: 3},
+		{name: "Gives Up", failures: 5, failStatus: http.StatusBadGateway, maxAttempts: 3, wantRequests: 3, wantStatus: http.StatusBadGateway},
+		{name: "Disabled", failures: 1, failStatus: http.StatusServiceUnavailable, maxAttempts: 1, wantRequests: 1, wantStatus: http.StatusServiceUnavailable},
+		{name: "Not Retryable", failures: 1, failStatus: http.StatusInternalServerError, maxAttempts: 3, wantRequests: 1, wantStatus: http.StatusInternalServerError},
+		{name: "Short Retry After", failures: 1, failStatus: http.StatusTooManyRequests, retryAfter: "0", maxAttempts: 3, wantRequests: 2},
+		{name: "Long Retry After", failures: 1, failStatus: http.StatusTooManyRequests, retryAfter: "3600", maxAttempts: 3, wantRequests: 1, wantStatus: http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newCountingServer(t, svc)
+			server.failures, server.failStatus, server.retryAfter = tt.failures, tt.failStatus, tt.retryAfter
+			c, err := client.New(server.URL, client.WithRetry(tt.maxAttempts, time.Millisecond, 5*time.Millisecond))
+			require.NoError(t, err)
+
+			city, err := c.Lookup(context.Background(), "81.2.69.160")
+			assert.Equal(t, tt.wantRequests, server.requests.Load())
+			if tt.wantStatus != 0 {
+				var apiErr *client.Error
+				require.ErrorAs(t, err, &apiErr)
+				assert.Equal(t, tt.wantStatus, apiErr.Status)
+				assert.Empty(t, apiErr.Code, "not a problem document")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "GB", city.Country.ISOCode)
+		})
+	}
+
+	t.Run("Context Canceled During Backoff", func(t *testing.T) {
+		server := newCountingServer(t, svc)
+		server.failures, server.failStatus = 5, http.StatusServiceUnavailable
+		c, err := client.New(server.URL, client.WithRetry(5, time.Hour, time.Hour))
+		require.NoError(t, err)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		_, err = c.Lookup(ctx, "81.2.69.160")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, int32(1), server.requests.Load())
+	})
+
+	t.Run("Timeout Per Attempt", func(t *testing.T) {
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			<-r.Context().Done()
+		}))
+		defer server.Close()
+		c, err := client.New(server.URL, client.WithTimeout(10*time.Millisecond), client.WithRetry(2, time.Millisecond, time.Millisecond))
+		require.NoError(t, err)
+
+		_, err = c.Lookup(context.Background(), "81.2.69.160")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, int32(2), requests.Load())
+	})
+}
+
+func TestCache(t *testing.T) {
+	server := newCountingServer(t, sampleService(t))
+	c, err := client.New(server.URL, client.WithCache(16, time.Minute))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	first, err := c.Lookup(ctx, "2606:4700::1111")
+	require.NoError(t, err)
+	second, err := c.Lookup(ctx, "2606:4700:0::1111")
+	require.NoError(t, err)
+	assert.Same(t, first, second)
+	assert.Equal(t, int32(1), server.requests.Load())
+
+	_, err = c.Lookup(ctx, "127.0.0.1")
+	require.ErrorIs(t, err, wherego.ErrNotFound)
+	_, err = c.Lookup(ctx, "127.0.0.1")
+	require.ErrorIs(t, err, wherego.ErrNotFound)
+	assert.Equal(t, int32(3), server.requests.Load(), "failed lookups are not cached")
+
+	results, err := c.LookupBatch(ctx, []string{"2606:4700::1111"})
+	require.NoError(t, err)
+	assert.Same(t, first, results[0].City)
+	assert.Equal(t, int32(3), server.requests.Load(), "cached addresses are not sent")
+}
+
+func TestLookupBatch(t *testing.T) {
+	svc := sampleService(t)
+	server := newCountingServer(t, svc)
+	server.failures, server.failStatus = 1, http.StatusServiceUnavailable
+	remote, err := client.New(server.URL, client.WithRetry(2, time.Millisecond, time.Millisecond))
+	require.NoError(t, err)
+
+	for name, c := range map[string]*client.Client{"Remote": remote, "Fake": client.NewFake(svc)} {
+		t.Run(name, func(t *testing.T) {
+			results, err := c.LookupBatch(context.Background(), []string{" 81.2.69.160 ", "", "127.0.0.1", "8.8.8.8", "bogus"})
+			require.NoError(t, err)
+			require.Len(t, results, 5)
+
+			assert.Equal(t, "81.2.69.160", results[0].IP)
+			require.NotNil(t, results[0].City)
+			assert.Equal(t, "London", results[0].City.City.Names.English)
+			assert.ErrorIs(t, results[1].Err, wherego.ErrInvalidIP)
+			assert.ErrorIs(t, results[2].Err, wherego.ErrNotFound)
+			assert.Equal(t, "US", results[3].City.Country.ISOCode)
+			assert.ErrorIs(t, results[4].Err, wherego.ErrInvalidIP)
+		})
+	}
+	assert.Equal(t, int32(2), server.requests.Load(), "the batch is retried")
+
+	t.Run("Nothing To Send", func(t *testing.T) {
+		results, err := remote.LookupBatch(context.Background(), []string{" "})
+		require.NoError(t, err)
+		assert.ErrorIs(t, results[0].Err, wherego.ErrInvalidIP)
+		assert.Equal(t, int32(2), server.requests.Load())
+	})
+}
+
+func TestStream(t *testing.T) {
+	svc := sampleService(t)
+	server := newCountingServer(t, svc)
+	remote, err := client.New(server.URL)
+	require.NoError(t, err)
+	ips := []string{"81.2.69.160", " ", "127.0.0.1", "8.8.8.8"}
+
+	for name, c := range map[string]*client.Client{"Remote": remote, "Fake": client.NewFake(svc)} {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+			for r, err := range c.Stream(context.Background(), slices.Values(ips)) {
+				require.NoError(t, err)
+				if r.Err != nil {
+					assert.ErrorIs(t, r.Err, wherego.ErrNotFound)
+					got = append(got, r.IP+" error")
+					continue
+				}
+				got = append(got, r.IP+" "+r.City.Country.ISOCode)
+			}
+			assert.Equal(t, []string{"81.2.69.160 GB", "127.0.0.1 error", "8.8.8.8 US"}, got)
+		})
+	}
+
+	endless := func(yield func(string) bool) {
+		for yield("8.8.8.8") {
+		}
+	}
+	for name, c := range map[string]*client.Client{"Remote": remote, "Fake": client.NewFake(svc)} {
+		t.Run("Break "+name, func(t *testing.T) {
+			n := 0
+			for r, err := range c.Stream(context.Background(), endless) {
+				require.NoError(t, err)
+				assert.Equal(t, "US", r.City.Country.ISOCode)
+				if n++; n == 100 {
+					break
+				}
+			}
+			assert.Equal(t, 100, n)
+		})
+	}
+
+	t.Run("Request Fails", func(t *testing.T) {
+		server := newCountingServer(t, svc)
+		server.failures, server.failStatus = 1, http.StatusServiceUnavailable
+		c, err := client.New(server.URL)
+		require.NoError(t, err)
+
+		var errs []error
+		for _, err := range c.Stream(context.Background(), slices.Values(ips)) {
+			errs = append(errs, err)
+		}
+		require.Len(t, errs, 1)
+		var apiErr *client.Error
+		require.True(t, errors.As(errs[0], &apiErr))
+		assert.Equal(t, http.StatusServiceUnavailable, apiErr.Status)
+	})
+}
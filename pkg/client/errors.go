@@ -0,0 +1,75 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+)
+
+// Problem codes of the API that Error.Unwrap maps to errors of package
+// wherego. See the README for every code.
+const (
+	CodeInvalidIP = "invalid_ip"
+	CodeNotFound  = "ip_not_found"
+)
+
+// maxErrorBody is how much of an error response that is not a problem
+// document is kept in Error.Detail.
+const maxErrorBody = 512
+
+// Error is a failed request or lookup, from the RFC 7807 problem details
+// of the response.
+type Error struct {
+	// Status is the HTTP status of the response.
+	Status int `json:"status"`
+	// Code is the stable identifier of the problem, such as
+	// "ip_not_found". It is empty when the response was not a problem
+	// document, such as an error of a proxy.
+	Code   string `json:"code"`
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+	// AddressClass tells why a special-purpose address has no data.
+	AddressClass wherego.AddressClass `json:"address_class"`
+}
+
+func (e *Error) Error() string {
+	s := "wherego: " + strconv.Itoa(e.Status)
+	if e.Code != "" {
+		s += " " + e.Code
+	}
+	if e.Detail != "" {
+		s += ": " + e.Detail
+	}
+	return s
+}
+
+// Unwrap returns wherego.ErrNotFound and wherego.ErrInvalidIP for the
+// problems they stand for, so the errors of the client and of a local
+// wherego.Service can be handled alike.
+func (e *Error) Unwrap() error {
+	switch e.Code {
+	case CodeNotFound:
+		return wherego.ErrNotFound
+	case CodeInvalidIP:
+		return wherego.ErrInvalidIP
+	}
+	return nil
+}
+
+// readError returns the *Error of a failed response.
+func readError(res *http.Response) error {
+	body, err := io.ReadAll(io.LimitReader(res.Body, 64<<10))
+	if err != nil {
+		return fmt.Errorf("wherego: %d: read response: %w", res.StatusCode, err)
+	}
+	e := &Error{}
+	if json.Unmarshal(body, e) != nil || e.Code == "" {
+		e = &Error{Title: http.StatusText(res.StatusCode), Detail: string(body[:min(len(body), maxErrorBody)])}
+	}
+	e.Status = res.StatusCode
+	return e
+}
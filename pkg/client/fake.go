@@ -0,0 +1,121 @@
+package client
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/gustavosett/WhereGo/pkg/wherego/geohttp"
+)
+
+// FakeRemoteAddr is the address the handler of a NewFake client sees
+// requests come from, so Me looks up 192.0.2.1.
+const FakeRemoteAddr = "192.0.2.1:1234"
+
+// NewFake returns a client whose requests are served in the same process
+// by geohttp.NewHandler(svc), for tests of code that uses the client. The
+// responses and errors are those of a real server with the database of
+// svc, without a network. Responses reach the client as the handler writes
+// them, so Stream yields results while it is still sending addresses, as
+// it does with a server.
+func NewFake(svc *wherego.Service, options ...Option) *Client {
+	transport := &handlerTransport{handler: geohttp.NewHandler(svc)}
+	c, err := New("http://wherego.test", append([]Option{WithHTTPClient(&http.Client{Transport: transport})}, options...)...)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// handlerTransport is an http.RoundTripper that serves requests with an
+// http.Handler, each on its own goroutine.
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t *handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+	// Give the handler a request as the server would.
+	server := req.Clone(req.Context())
+	server.RemoteAddr = FakeRemoteAddr
+	server.RequestURI = req.URL.RequestURI()
+	if server.Body == nil {
+		server.Body = http.NoBody
+	}
+
+	body, pw := io.Pipe()
+	w := &pipeResponseWriter{
+		header:   make(http.Header),
+		body:     pw,
+		response: make(chan *http.Response, 1),
+	}
+	w.res = &http.Response{
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Body:       body,
+		Request:    req,
+	}
+	go func() {
+		defer func() {
+			_ = server.Body.Close()
+			w.WriteHeader(http.StatusOK)
+			_ = pw.Close()
+		}()
+		t.handler.ServeHTTP(w, server)
+	}()
+
+	select {
+	case res := <-w.response:
+		return res, nil
+	case <-req.Context().Done():
+		_ = body.CloseWithError(req.Context().Err())
+		return nil, req.Context().Err()
+	}
+}
+
+// pipeResponseWriter is an http.ResponseWriter that passes the response to
+// the client as it is written: the header once it is written, and the body
+// through a pipe, so writes wait for the client to read them as they would
+// on a connection.
+type pipeResponseWriter struct {
+	header   http.Header
+	body     *io.PipeWriter
+	res      *http.Response
+	response chan *http.Response
+	once     sync.Once
+}
+
+func (w *pipeResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *pipeResponseWriter) WriteHeader(status int) {
+	w.once.Do(func() {
+		w.res.StatusCode = status
+		w.res.Status = strconv.Itoa(status) + " " + http.StatusText(status)
+		w.res.Header = w.header.Clone()
+		w.res.ContentLength = -1
+		if n, err := strconv.ParseInt(w.res.Header.Get("Content-Length"), 10, 64); err == nil {
+			w.res.ContentLength = n
+		}
+		w.response <- w.res
+	})
+}
+
+func (w *pipeResponseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
+
+// Flush implements http.Flusher. Writes reach the client right away.
+func (w *pipeResponseWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+}
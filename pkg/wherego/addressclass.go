@@ -0,0 +1,114 @@
+package wherego
+
+import "net/netip"
+
+// AddressClass identifies a special-purpose address block from the IANA
+// IPv4 and IPv6 Special-Purpose Address Registries. The zero value is used
+// for ordinary global unicast addresses.
+type AddressClass string
+
+// Address classes returned by ClassifyAddress.
+const (
+	AddressClassUnspecified   AddressClass = "unspecified"
+	AddressClassThisNetwork   AddressClass = "this_network"
+	AddressClassPrivate       AddressClass = "private"
+	AddressClassCGNAT         AddressClass = "cgnat"
+	AddressClassLoopback      AddressClass = "loopback"
+	AddressClassLinkLocal     AddressClass = "link_local"
+	AddressClassIETFProtocol  AddressClass = "ietf_protocol"
+	AddressClassDocumentation AddressClass = "documentation"
+	AddressClassBenchmarking  AddressClass = "benchmarking"
+	AddressClassMulticast     AddressClass = "multicast"
+	AddressClassReserved      AddressClass = "reserved"
+	AddressClassBroadcast     AddressClass = "broadcast"
+	AddressClassIPv4Mapped    AddressClass = "ipv4_mapped"
+	AddressClassNAT64         AddressClass = "nat64"
+	AddressClassDiscard       AddressClass = "discard"
+	AddressClass6to4          AddressClass = "6to4"
+	AddressClassTeredo        AddressClass = "teredo"
+)
+
+type addressBlock struct {
+	prefix netip.Prefix
+	class  AddressClass
+}
+
+// specialBlocks lists the special-purpose blocks, more specific blocks
+// before the blocks containing them. IPv6 unique local addresses
+// (fc00::/7) are classed as private, like netip.Addr.IsPrivate does.
+var specialBlocks = []addressBlock{
+	// IPv4, RFC 6890 and the IANA IPv4 Special-Purpose Address Registry.
+	{netip.MustParsePrefix("255.255.255.255/32"), AddressClassBroadcast},
+	{netip.MustParsePrefix("0.0.0.0/8"), AddressClassThisNetwork},
+	{netip.MustParsePrefix("10.0.0.0/8"), AddressClassPrivate},
+	{netip.MustParsePrefix("100.64.0.0/10"), AddressClassCGNAT},
+	{netip.MustParsePrefix("127.0.0.0/8"), AddressClassLoopback},
+	{netip.MustParsePrefix("169.254.0.0/16"), AddressClassLinkLocal},
+	{netip.MustParsePrefix("172.16.0.0/12"), AddressClassPrivate},
+	{netip.MustParsePrefix("192.0.0.0/24"), AddressClassIETFProtocol},
+	{netip.MustParsePrefix("192.0.2.0/24"), AddressClassDocumentation},
+	{netip.MustParsePrefix("192.88.99.0/24"), AddressClass6to4},
+	{netip.MustParsePrefix("192.168.0.0/16"), AddressClassPrivate},
+	{netip.MustParsePrefix("198.18.0.0/15"), AddressClassBenchmarking},
+	{netip.MustParsePrefix("198.51.100.0/24"), AddressClassDocumentation},
+	{netip.MustParsePrefix("203.0.113.0/24"), AddressClassDocumentation},
+	{netip.MustParsePrefix("224.0.0.0/4"), AddressClassMulticast},
+	{netip.MustParsePrefix("240.0.0.0/4"), AddressClassReserved},
+
+	// IPv6, the IANA IPv6 Special-Purpose Address Registry.
+	{netip.MustParsePrefix("::/128"), AddressClassUnspecified},
+	{netip.MustParsePrefix("::1/128"), AddressClassLoopback},
+	{netip.MustParsePrefix("::ffff:0:0/96"), AddressClassIPv4Mapped},
+	{netip.MustParsePrefix("64:ff9b::/96"), AddressClassNAT64},
+	{netip.MustParsePrefix("64:ff9b:1::/48"), AddressClassNAT64},
+	{netip.MustParsePrefix("100::/64"), AddressClassDiscard},
+	{netip.MustParsePrefix("2001::/32"), AddressClassTeredo},
+	{netip.MustParsePrefix("2001:2::/48"), AddressClassBenchmarking},
+	{netip.MustParsePrefix("2001:db8::/32"), AddressClassDocumentation},
+	{netip.MustParsePrefix("2001::/23"), AddressClassIETFProtocol},
+	{netip.MustParsePrefix("2002::/16"), AddressClass6to4},
+	{netip.MustParsePrefix("3fff::/20"), AddressClassDocumentation},
+	{netip.MustParsePrefix("fc00::/7"), AddressClassPrivate},
+	{netip.MustParsePrefix("fe80::/10"), AddressClassLinkLocal},
+	{netip.MustParsePrefix("ff00::/8"), AddressClassMulticast},
+}
+
+// ClassifyAddress returns the special-purpose class of addr, or the empty
+// class for a global unicast address.
+func ClassifyAddress(addr netip.Addr) AddressClass {
+	addr = addr.WithZone("")
+	// Most lookups are for public addresses; skip the table for the
+	// global unicast ranges that contain no special-purpose block.
+	if addr.Is4() {
+		switch b := addr.As4()[0]; b {
+		case 0, 10, 100, 127, 169, 172, 192, 198, 203:
+		default:
+			if b < 224 {
+				return ""
+			}
+		}
+	}
+	for _, block := range specialBlocks {
+		if block.prefix.Contains(addr) {
+			return block.class
+		}
+	}
+	return ""
+}
+
+// EmbeddedIPv4 returns the IPv4 address embedded in a 6to4 (2002::/16) or
+// Teredo (2001::/32) address. For Teredo this is the client's public
+// address, stored inverted in the last 32 bits.
+func EmbeddedIPv4(addr netip.Addr) (netip.Addr, bool) {
+	if !addr.Is6() || addr.Is4In6() {
+		return netip.Addr{}, false
+	}
+	b := addr.As16()
+	switch {
+	case b[0] == 0x20 && b[1] == 0x02:
+		return netip.AddrFrom4([4]byte{b[2], b[3], b[4], b[5]}), true
+	case b[0] == 0x20 && b[1] == 0x01 && b[2] == 0 && b[3] == 0:
+		return netip.AddrFrom4([4]byte{^b[12], ^b[13], ^b[14], ^b[15]}), true
+	}
+	return netip.Addr{}, false
+}
@@ -0,0 +1,125 @@
+package wherego
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyAddress(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want AddressClass
+	}{
+		{"8.8.8.8", ""},
+		{"1.1.1.1", ""},
+		{"172.32.0.1", ""},
+		{"0.0.0.0", AddressClassThisNetwork},
+		{"10.1.2.3", AddressClassPrivate},
+		{"172.16.5.4", AddressClassPrivate},
+		{"192.168.1.1", AddressClassPrivate},
+		{"100.64.0.1", AddressClassCGNAT},
+		{"100.128.0.1", ""},
+		{"127.0.0.1", AddressClassLoopback},
+		{"169.254.169.254", AddressClassLinkLocal},
+		{"192.0.0.8", AddressClassIETFProtocol},
+		{"192.0.2.1", AddressClassDocumentation},
+		{"198.51.100.7", AddressClassDocumentation},
+		{"203.0.113.9", AddressClassDocumentation},
+		{"192.88.99.1", AddressClass6to4},
+		{"198.19.0.1", AddressClassBenchmarking},
+		{"224.0.0.251", AddressClassMulticast},
+		{"240.0.0.1", AddressClassReserved},
+		{"255.255.255.255", AddressClassBroadcast},
+		{"2606:4700::1111", ""},
+		{"::", AddressClassUnspecified},
+		{"::1", AddressClassLoopback},
+		{"::ffff:8.8.8.8", AddressClassIPv4Mapped},
+		{"64:ff9b::808:808", AddressClassNAT64},
+		{"100::1", AddressClassDiscard},
+		{"2001:0:4136:e378:8000:63bf:f7f7:f7f7", AddressClassTeredo},
+		{"2001:2::1", AddressClassBenchmarking},
+		{"2001:db8::1", AddressClassDocumentation},
+		{"3fff::1", AddressClassDocumentation},
+		{"2001:100::1", AddressClassIETFProtocol},
+		{"2002:808:808::1", AddressClass6to4},
+		{"fd00::1", AddressClassPrivate},
+		{"fe80::1", AddressClassLinkLocal},
+		{"fe80::1%eth0", AddressClassLinkLocal},
+		{"ff02::1", AddressClassMulticast},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			assert.Equal(t, tt.want, ClassifyAddress(netip.MustParseAddr(tt.ip)))
+		})
+	}
+}
+
+func TestEmbeddedIPv4(t *testing.T) {
+	tests := []struct {
+		name string
+		ip   string
+		want string
+	}{
+		{"6to4", "2002:808:808::1", "8.8.8.8"},
+		{"Teredo", "2001:0:4136:e378:8000:63bf:f7f7:f7f7", "8.8.8.8"},
+		{"Plain IPv6", "2606:4700::1111", ""},
+		{"IPv4", "8.8.8.8", ""},
+		{"IPv4-Mapped", "::ffff:8.8.8.8", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := EmbeddedIPv4(netip.MustParseAddr(tt.ip))
+			assert.Equal(t, tt.want != "", ok)
+			if ok {
+				assert.Equal(t, tt.want, got.String())
+			}
+		})
+	}
+}
+
+func TestService_AddressClass(t *testing.T) {
+	db := openSampleCity(t)
+
+	tests := []struct {
+		name     string
+		embedded bool
+		ip       string
+		class    AddressClass
+		embedIP  string
+		country  string
+	}{
+		{"Public", false, "8.8.8.8", "", "", "US"},
+		{"Loopback", false, "127.0.0.1", AddressClassLoopback, "", ""},
+		{"6to4 Not Resolved", false, "2002:808:808::1", AddressClass6to4, "", ""},
+		{"6to4 Resolved", true, "2002:808:808::1", AddressClass6to4, "8.8.8.8", "US"},
+		{"Teredo Resolved", true, "2001:0:4136:e378:8000:63bf:aefd:ba5f", AddressClassTeredo, "81.2.69.160", "GB"},
+	}
+	for _, cache := range []*Cache{nil, NewCache(100)} {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				svc := &Service{DB: db, Cache: cache, LookupEmbeddedIPv4: tt.embedded}
+				city, err := svc.LookupIP(tt.ip)
+				if tt.country == "" {
+					assert.ErrorIs(t, err, ErrNotFound, "address class alone is not data")
+					_, err = svc.LookupIPJSON(tt.ip)
+					assert.ErrorIs(t, err, ErrNotFound)
+					return
+				}
+				require.NoError(t, err)
+				assert.Equal(t, tt.ip, city.Traits.IPAddress.String())
+				assert.Equal(t, tt.class, city.Traits.AddressClass)
+				assert.Equal(t, tt.embedIP, city.Traits.EmbeddedIPv4)
+				assert.Equal(t, tt.country, city.Country.ISOCode)
+
+				want, err := json.Marshal(city)
+				require.NoError(t, err)
+				got, err := svc.LookupIPJSON(tt.ip)
+				require.NoError(t, err)
+				assert.Equal(t, string(want), string(got))
+			})
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package wherego
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Allocation budgets for the lookup hot path. Decoding a record with the
+// MaxMind DB reflection decoder costs one allocation, plus the slice when
+// the record has subdivisions; everything else is pooled.
+var lookupAllocBudgets = []struct {
+	name   string
+	ip     string
+	cached bool
+	allocs float64
+	err    error
+}{
+	{"Uncached", "8.8.8.8", false, 1, nil},
+	{"Uncached Subdivisions", "81.2.69.160", false, 3, nil},
+	{"Uncached Not Found", "127.0.0.1", false, 0, ErrNotFound},
+	{"Cache Hit", "81.2.69.160", true, 0, nil},
+}
+
+func TestAppendIPJSON_AllocationBudget(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocation counts are not stable under the race detector")
+	}
+	db := openSampleCity(t)
+
+	for _, tt := range lookupAllocBudgets {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &Service{DB: db}
+			if tt.cached {
+				svc.Cache = NewCache(10)
+			}
+			buf := make([]byte, 0, 4096)
+			var err error
+			allocs := testing.AllocsPerRun(100, func() {
+				buf, err = svc.AppendIPJSON(buf[:0], tt.ip)
+			})
+			require.ErrorIs(t, err, tt.err)
+			assert.LessOrEqual(t, allocs, tt.allocs, "allocations per lookup regressed")
+		})
+	}
+}
+
+func BenchmarkService_LookupIP(b *testing.B) {
+	for _, tt := range lookupAllocBudgets {
+		b.Run(tt.name, func(b *testing.B) {
+			svc := &Service{DB: openSampleCityB(b)}
+			if tt.cached {
+				svc.Cache = NewCache(10)
+			}
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := svc.LookupIP(tt.ip); err != tt.err {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkService_AppendIPJSON(b *testing.B) {
+	for _, tt := range lookupAllocBudgets {
+		b.Run(tt.name, func(b *testing.B) {
+			svc := &Service{DB: openSampleCityB(b)}
+			if tt.cached {
+				svc.Cache = NewCache(10)
+			}
+			buf := make([]byte, 0, 4096)
+			b.ReportAllocs()
+			for b.Loop() {
+				var err error
+				if buf, err = svc.AppendIPJSON(buf[:0], tt.ip); err != tt.err {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkCity_Encode compares the hand-written encoder with jsoniter.
+func BenchmarkCity_Encode(b *testing.B) {
+	svc := &Service{DB: openSampleCityB(b)}
+	city, err := svc.LookupIP("81.2.69.160")
+	require.NoError(b, err)
+
+	b.Run("AppendJSON", func(b *testing.B) {
+		buf := make([]byte, 0, 4096)
+		b.ReportAllocs()
+		for b.Loop() {
+			buf = city.AppendJSON(buf[:0])
+		}
+	})
+	b.Run("jsoniter", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := json.Marshal(city); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,184 @@
+package wherego
+
+import (
+	"bytes"
+	"container/list"
+	"hash/maphash"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+)
+
+const cacheShards = 64
+
+// ipAddressPlaceholder is how an unset IPAddress serializes. Cached JSON is
+// encoded without the IP so one entry can serve the whole network.
+var ipAddressPlaceholder = []byte(`"ip_address":""`)
+
+// Cache is a size-bounded, sharded LRU cache of lookup results. Entries are
+// keyed by the network prefix of the database record, so a single entry
+// covers every address in that network. Each entry also holds the
+// pre-serialized JSON of the result.
+//
+// Entries remember the Reader they were decoded from and are ignored once
+// the Service switches to a different Reader, so a database update never
+// serves stale data. Cache is safe for concurrent use.
+type Cache struct {
+	seed   maphash.Seed
+	shards [cacheShards]cacheShard
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+// CacheStats is a snapshot of the cache counters.
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+	Capacity  int
+}
+
+// HitRatio returns the fraction of lookups served from the cache.
+func (s CacheStats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+type cacheShard struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[netip.Prefix]*list.Element
+	order    *list.List
+}
+
+type cacheEntry struct {
+	prefix netip.Prefix
+	db     *Reader
+	city   City
+	// hasData caches city.HasData().
+	hasData bool
+	// jsonHead and jsonTail surround the IP address in the serialized
+	// result. jsonTail is nil when the IP could not be spliced in.
+	jsonHead []byte
+	jsonTail []byte
+}
+
+// NewCache returns a cache holding about size entries, spread evenly over
+// its shards. It returns nil if size is not positive; a nil *Cache disables
+// caching.
+func NewCache(size int) *Cache {
+	if size <= 0 {
+		return nil
+	}
+	c := &Cache{seed: maphash.MakeSeed()}
+	perShard := (size + cacheShards - 1) / cacheShards
+	for i := range c.shards {
+		c.shards[i] = cacheShard{
+			capacity: perShard,
+			items:    make(map[netip.Prefix]*list.Element, perShard),
+			order:    list.New(),
+		}
+	}
+	return c
+}
+
+// Stats returns a snapshot of the cache counters.
+func (c *Cache) Stats() CacheStats {
+	stats := CacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+	}
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		stats.Entries += s.order.Len()
+		stats.Capacity += s.capacity
+		s.mu.Unlock()
+	}
+	return stats
+}
+
+// Purge removes every entry from the cache.
+func (c *Cache) Purge() {
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		clear(s.items)
+		s.order.Init()
+		s.mu.Unlock()
+	}
+}
+
+func (c *Cache) shard(prefix netip.Prefix) *cacheShard {
+	return &c.shards[maphash.Comparable(c.seed, prefix)%cacheShards]
+}
+
+func (c *Cache) get(db *Reader, prefix netip.Prefix) (*cacheEntry, bool) {
+	s := c.shard(prefix)
+	s.mu.Lock()
+	elem, ok := s.items[prefix]
+	if ok {
+		entry := elem.Value.(*cacheEntry)
+		if entry.db == db {
+			s.order.MoveToFront(elem)
+			s.mu.Unlock()
+			c.hits.Add(1)
+			return entry, true
+		}
+		// Decoded from a previous database.
+		s.order.Remove(elem)
+		delete(s.items, prefix)
+	}
+	s.mu.Unlock()
+	c.misses.Add(1)
+	return nil, false
+}
+
+func (c *Cache) add(entry *cacheEntry) {
+	s := c.shard(entry.prefix)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, ok := s.items[entry.prefix]; ok {
+		elem.Value = entry
+		s.order.MoveToFront(elem)
+		return
+	}
+	s.items[entry.prefix] = s.order.PushFront(entry)
+	if s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.items, oldest.Value.(*cacheEntry).prefix)
+		c.evictions.Add(1)
+	}
+}
+
+func newCacheEntry(db *Reader, prefix netip.Prefix, city *City) *cacheEntry {
+	entry := &cacheEntry{prefix: prefix, db: db, city: *city, hasData: city.HasData()}
+	entry.city.Traits.IPAddress = netip.Addr{}
+
+	b := entry.city.AppendJSON(nil)
+	if i := bytes.Index(b, ipAddressPlaceholder); i >= 0 {
+		split := i + len(ipAddressPlaceholder) - 1
+		entry.jsonHead, entry.jsonTail = b[:split], b[split:]
+	} else {
+		entry.jsonHead = b
+	}
+	return entry
+}
+
+// appendJSON appends the serialized result for ipAddress to dst.
+func (e *cacheEntry) appendJSON(dst []byte, ipAddress netip.Addr) []byte {
+	dst = append(dst, e.jsonHead...)
+	if e.jsonTail != nil {
+		dst = ipAddress.AppendTo(dst)
+		dst = append(dst, e.jsonTail...)
+	}
+	return dst
+}
@@ -0,0 +1,151 @@
+package wherego
+
+import (
+	"net/netip"
+	"sync"
+	"testing"
+
+	"github.com/gustavosett/WhereGo/internal/geoiptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCache_Disabled(t *testing.T) {
+	assert.Nil(t, NewCache(0))
+	assert.Nil(t, NewCache(-1))
+}
+
+func TestCache_Eviction(t *testing.T) {
+	c := NewCache(1)
+	db := &Reader{}
+	assert.Equal(t, cacheShards, c.Stats().Capacity, "one entry per shard")
+
+	// Fill a single shard past its capacity of one.
+	first := netip.MustParsePrefix("10.0.0.0/8")
+	s := c.shard(first)
+	sameShard := []netip.Prefix{first}
+	for i := 0; len(sameShard) < 3; i++ {
+		p := netip.PrefixFrom(netip.AddrFrom4([4]byte{20, byte(i >> 8), byte(i), 0}), 24)
+		if c.shard(p) == s {
+			sameShard = append(sameShard, p)
+		}
+	}
+	for _, p := range sameShard {
+		c.add(&cacheEntry{prefix: p, db: db})
+	}
+
+	_, ok := c.get(db, sameShard[0])
+	assert.False(t, ok, "least recently used entry should be evicted")
+	_, ok = c.get(db, sameShard[2])
+	assert.True(t, ok)
+
+	stats := c.Stats()
+	assert.Equal(t, uint64(2), stats.Evictions)
+	assert.Equal(t, 1, stats.Entries)
+
+	c.Purge()
+	assert.Equal(t, 0, c.Stats().Entries)
+}
+
+func TestCache_InvalidatedByNewReader(t *testing.T) {
+	c := NewCache(10)
+	prefix := netip.MustParsePrefix("10.0.0.0/8")
+	oldDB, newDB := &Reader{}, &Reader{}
+	c.add(&cacheEntry{prefix: prefix, db: oldDB})
+
+	_, ok := c.get(newDB, prefix)
+	assert.False(t, ok)
+	_, ok = c.get(oldDB, prefix)
+	assert.False(t, ok, "stale entry should have been dropped")
+
+	stats := c.Stats()
+	assert.Equal(t, uint64(0), stats.Hits)
+	assert.Equal(t, uint64(2), stats.Misses)
+	assert.Equal(t, 0.0, stats.HitRatio())
+}
+
+func TestService_Cache(t *testing.T) {
+	db := openSampleCity(t)
+	cached := &Service{DB: db, Cache: NewCache(100)}
+	uncached := &Service{DB: db}
+
+	for _, ip := range []string{"8.8.8.8", "8.8.8.4", "81.2.69.160", "2606:4700::1111", "127.0.0.1"} {
+		t.Run(ip, func(t *testing.T) {
+			want, wantErr := uncached.LookupIP(ip)
+			got, err := cached.LookupIP(ip)
+			assert.Equal(t, wantErr, err)
+			assert.Equal(t, want, got)
+			if wantErr != nil {
+				assert.ErrorIs(t, wantErr, ErrNotFound)
+				_, err = cached.LookupIPJSON(ip)
+				assert.ErrorIs(t, err, ErrNotFound)
+				return
+			}
+
+			wantJSON, err := json.Marshal(want)
+			require.NoError(t, err)
+			gotJSON, err := cached.LookupIPJSON(ip)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(wantJSON), string(gotJSON))
+
+			uncachedJSON, err := uncached.LookupIPJSON(ip)
+			require.NoError(t, err)
+			assert.Equal(t, wantJSON, uncachedJSON)
+		})
+	}
+
+	stats := cached.Cache.Stats()
+	// 8.8.8.8 and 8.8.8.4 share a network. The JSON for the loopback
+	// address carries its address class and bypasses the cache.
+	assert.Equal(t, 4, stats.Entries)
+	assert.Equal(t, uint64(4), stats.Misses)
+	assert.Equal(t, uint64(5), stats.Hits)
+	assert.InDelta(t, 5.0/9, stats.HitRatio(), 0.001)
+
+	_, err := cached.LookupIPJSON("bogus")
+	assert.ErrorIs(t, err, ErrInvalidIP)
+	_, err = cached.LookupIP("bogus")
+	assert.ErrorIs(t, err, ErrInvalidIP)
+}
+
+func TestService_CacheErrors(t *testing.T) {
+	t.Run("Unsupported Database", func(t *testing.T) {
+		r, err := OpenBytes(geoiptest.MustBuild(geoiptest.Database{
+			DatabaseType: "GeoLite2-ASN",
+			Networks: map[netip.Prefix]any{
+				netip.MustParsePrefix("8.8.8.0/24"): geoiptest.Map{"autonomous_system_number": 15169},
+			},
+		}))
+		require.NoError(t, err)
+		svc := &Service{DB: r, Cache: NewCache(10)}
+		_, err = svc.LookupIP("8.8.8.8")
+		assert.IsType(t, InvalidMethodError{}, err)
+	})
+
+	t.Run("Closed Database", func(t *testing.T) {
+		r, err := OpenBytes(geoiptest.MustBuild(geoiptest.SampleCity()))
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		svc := &Service{DB: r, Cache: NewCache(10)}
+		_, err = svc.LookupIPJSON("8.8.8.8")
+		assert.Error(t, err)
+	})
+}
+
+func TestService_CacheConcurrent(t *testing.T) {
+	svc := &Service{DB: openSampleCity(t), Cache: NewCache(100)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				city, err := svc.LookupIP("81.2.69.160")
+				assert.NoError(t, err)
+				assert.Equal(t, "GB", city.Country.ISOCode)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,29 @@
+// Package wherego is the Go library behind the WhereGo server: it reads
+// MaxMind DB files and looks up addresses with caching and overrides. The
+// server and its handlers are built on it. Package geohttp serves the v1
+// lookup API from a Service as an http.Handler, and annotates the requests
+// of other applications with the location of their clients.
+//
+//	svc, err := wherego.NewService("GeoLite2-City.mmdb")
+//	if err != nil {
+//		return err
+//	}
+//	defer svc.DB.Close()
+//
+//	city, err := svc.LookupIP("81.2.69.160")
+//	// city.Country.ISOCode == "GB"
+//
+//	http.Handle("/v1/", geohttp.NewHandler(svc))
+//
+// # Stability
+//
+// The package and geohttp follow semantic versioning with the module's
+// release tags. Within a major version, exported identifiers are not
+// removed or renamed, function signatures do not change, and the JSON
+// names of model fields stay the same. Minor versions may add functions,
+// options, methods and struct fields, so do not compare models with == or
+// build them with unkeyed struct literals. The routes served by
+// geohttp.NewHandler follow the /v1 contract of the HTTP API. Packages
+// under internal/ and the cmd/api server's options are not covered, and
+// may change in any release.
+package wherego
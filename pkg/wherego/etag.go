@@ -0,0 +1,41 @@
+package wherego
+
+import (
+	"net/netip"
+	"strconv"
+)
+
+// AppendETag appends a weak HTTP entity tag for the lookup result of ipStr
+// to dst. The tag is derived from the database build time and the network
+// of the record, plus the overrides version when overrides are configured,
+// so it only changes when the data behind the result does. It does not
+// include the address itself: a tag validates the response of one URL,
+// which names the address already. It returns ErrNotFound when neither
+// the database nor the overrides have a record for ipStr, as there is no
+// resource for a tag to validate.
+//
+// AppendETag walks the search tree but does not decode the record, so
+// checking a client's cached copy is much cheaper than the lookup.
+func (s *Service) AppendETag(dst []byte, ipStr string) ([]byte, error) {
+	addr, err := netip.ParseAddr(ipStr)
+	if err != nil {
+		return dst, ErrInvalidIP
+	}
+	result := s.DB.mmdbReader.Lookup(s.lookupTarget(addr))
+	if err := result.Err(); err != nil {
+		return dst, err
+	}
+	if override, _ := s.lookupOverride(addr); !result.Found() && override == nil {
+		return dst, ErrNotFound
+	}
+
+	dst = append(dst, `W/"`...)
+	dst = strconv.AppendUint(dst, uint64(s.DB.Metadata().BuildEpoch), 36)
+	dst = append(dst, '-')
+	dst = result.Prefix().AppendTo(dst)
+	if s.Overrides != nil {
+		dst = append(dst, '-')
+		dst = strconv.AppendUint(dst, s.Overrides.Version(), 36)
+	}
+	return append(dst, '"'), nil
+}
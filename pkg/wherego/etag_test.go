@@ -0,0 +1,86 @@
+package wherego
+
+import (
+	"os"
+	"testing"
+
+	"github.com/gustavosett/WhereGo/internal/geoiptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_AppendETag(t *testing.T) {
+	svc := &Service{DB: openSampleCity(t)}
+
+	tests := []struct {
+		name string
+		ip   string
+		want string
+	}{
+		{"IPv4 Network", "8.8.8.8", `W/"spduo0-8.8.8.0/24"`},
+		{"Same Network", "8.8.8.200", `W/"spduo0-8.8.8.0/24"`},
+		{"IPv6 Network", "2606:4700::1111", `W/"spduo0-2606:4700::/32"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := svc.AppendETag(nil, tt.ip)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+
+	other, err := svc.AppendETag(nil, "81.2.69.160")
+	require.NoError(t, err)
+	assert.NotContains(t, string(other), "8.8.8.0/24", "other networks get other tags")
+
+	_, err = svc.AppendETag(nil, "bogus")
+	assert.ErrorIs(t, err, ErrInvalidIP)
+
+	_, err = svc.AppendETag(nil, "127.0.0.1")
+	assert.ErrorIs(t, err, ErrNotFound, "addresses without a record have no tag")
+}
+
+func TestService_AppendETag_Changes(t *testing.T) {
+	sample := geoiptest.SampleCity()
+	sample.BuildEpoch++
+	newer, err := OpenBytes(geoiptest.MustBuild(sample))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = newer.Close() })
+
+	svc := &Service{DB: openSampleCity(t)}
+	before, err := svc.AppendETag(nil, "8.8.8.8")
+	require.NoError(t, err)
+
+	svc.DB = newer
+	afterUpdate, err := svc.AppendETag(nil, "8.8.8.8")
+	require.NoError(t, err)
+	assert.NotEqual(t, string(before), string(afterUpdate), "a new database build changes the tag")
+
+	path := writeOverrides(t, "overrides.yaml", sampleOverrides)
+	svc.Overrides, err = LoadOverrides(path)
+	require.NoError(t, err)
+	withOverrides, err := svc.AppendETag(nil, "8.8.8.8")
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte("overrides: [{network: 10.0.0.0/8, labels: {a: b}}]"), 0o600))
+	require.NoError(t, svc.Overrides.Reload())
+	reloaded, err := svc.AppendETag(nil, "8.8.8.8")
+	require.NoError(t, err)
+	assert.NotEqual(t, string(withOverrides), string(reloaded), "new overrides change the tag")
+
+	same, err := LoadOverrides(path)
+	require.NoError(t, err)
+	assert.Equal(t, svc.Overrides.Version(), same.Version(), "versions are stable across loads")
+}
+
+func TestService_AppendETag_Allocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocation counts are not stable under the race detector")
+	}
+	svc := &Service{DB: openSampleCity(t)}
+	buf := make([]byte, 0, 64)
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = svc.AppendETag(buf[:0], "8.8.8.8")
+	})
+	assert.Zero(t, allocs)
+}
@@ -0,0 +1,91 @@
+// Package geohttp serves the v1 lookup API of the WhereGo server from a
+// wherego.Service as an http.Handler, and annotates the requests of other
+// applications with the location of their clients through Middleware:
+//
+//	http.Handle("/v1/", geohttp.NewHandler(svc))
+//
+//	mux := http.NewServeMux()
+//	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+//		fmt.Fprintln(w, geohttp.CountryCodeFromContext(r.Context()))
+//	})
+//	http.ListenAndServe(":8080", geohttp.Middleware(svc)(mux))
+//
+// It is a separate package from wherego, so programs that only look up
+// addresses do not depend on Echo and the server's handlers. It follows
+// the stability policy of package wherego.
+package geohttp
+
+import (
+	"net/http"
+	"net/netip"
+	"time"
+
+	"github.com/gustavosett/WhereGo/internal/format"
+	"github.com/gustavosett/WhereGo/internal/handlers"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/labstack/echo/v4"
+)
+
+// HandlerOption configures the handler built by NewHandler and the
+// middleware.
+type HandlerOption func(*handlerOptions)
+
+type handlerOptions struct {
+	maxAge     time.Duration
+	proxies    []netip.Prefix
+	geoHeaders bool
+}
+
+func applyHandlerOptions(options []HandlerOption) handlerOptions {
+	var opts handlerOptions
+	for _, option := range options {
+		if option != nil {
+			option(&opts)
+		}
+	}
+	return opts
+}
+
+// WithCacheMaxAge lets clients and shared caches reuse lookup responses
+// for maxAge, typically the database update interval. Without it, clients
+// revalidate every response with its ETag.
+func WithCacheMaxAge(maxAge time.Duration) HandlerOption {
+	return func(o *handlerOptions) {
+		o.maxAge = maxAge
+	}
+}
+
+// WithTrustedProxies takes the client address, of /v1/me and of the
+// middleware, from the X-Forwarded-For header when the request comes
+// through proxies in the given networks. Without it, the client address is
+// the remote address of the connection.
+func WithTrustedProxies(proxies ...netip.Prefix) HandlerOption {
+	return func(o *handlerOptions) {
+		o.proxies = append(o.proxies, proxies...)
+	}
+}
+
+// NewHandler returns an http.Handler serving the v1 lookup API of the
+// WhereGo server from s:
+//
+//	GET  /v1/lookup/{ip}
+//	POST /v1/lookup/stream
+//	GET  /v1/me
+//
+// Responses, formats and errors are those of the server; other paths are
+// 404 problem responses. Mount the handler at the root of a mux, or strip
+// the prefix it is mounted under with http.StripPrefix.
+func NewHandler(s *wherego.Service, options ...HandlerOption) http.Handler {
+	opts := applyHandlerOptions(options)
+	h := &handlers.GeoIPHandler{
+		GeoService:  s,
+		CacheMaxAge: opts.maxAge,
+		Formats:     format.Default(),
+	}
+	e := echo.New()
+	e.JSONSerializer = &handlers.JSONSerializer{}
+	e.HTTPErrorHandler = handlers.HTTPErrorHandler
+	e.IPExtractor = handlers.IPExtractor(opts.proxies)
+	h.RegisterV1(e.Group("/v1"))
+	return e
+}
@@ -0,0 +1,115 @@
+package geohttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gustavosett/WhereGo/internal/geoiptest"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/gustavosett/WhereGo/pkg/wherego/geohttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openSample(t *testing.T) *wherego.Service {
+	t.Helper()
+	db, err := wherego.OpenBytes(geoiptest.MustBuild(geoiptest.SampleCity()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return &wherego.Service{DB: db, Cache: wherego.NewCache(16)}
+}
+
+func TestNewHandler(t *testing.T) {
+	h := geohttp.NewHandler(openSample(t),
+		geohttp.WithCacheMaxAge(time.Hour),
+		geohttp.WithTrustedProxies(netip.MustParsePrefix("10.0.0.0/8")))
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		remoteAddr string
+		forwarded  string
+		wantStatus int
+		wantBody   string
+		wantCache  string
+	}{
+		{
+			name:       "Lookup",
+			target:     "/v1/lookup/81.2.69.160",
+			wantStatus: http.StatusOK,
+			wantBody:   `"iso_code":"GB"`,
+			wantCache:  "public, max-age=3600",
+		},
+		{
+			name:       "Format",
+			target:     "/v1/lookup/81.2.69.160?format=text&field=city.names.en",
+			wantStatus: http.StatusOK,
+			wantBody:   "London",
+		},
+		{
+			name:       "Not Found",
+			target:     "/v1/lookup/127.0.0.1",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `"status":404`,
+		},
+		{
+			name:       "Stream",
+			method:     http.MethodPost,
+			target:     "/v1/lookup/stream",
+			body:       "81.2.69.160\n8.8.8.8\n",
+			wantStatus: http.StatusOK,
+			wantBody:   `"iso_code":"US"`,
+		},
+		{
+			name:       "Me Through Trusted Proxy",
+			target:     "/v1/me",
+			remoteAddr: "10.1.2.3:4321",
+			forwarded:  "81.2.69.160",
+			wantStatus: http.StatusOK,
+			wantBody:   `"ip_address":"81.2.69.160"`,
+			wantCache:  "private, no-store",
+		},
+		{
+			name:       "Me Ignores Untrusted Proxy",
+			target:     "/v1/me",
+			remoteAddr: "8.8.8.8:4321",
+			forwarded:  "81.2.69.160",
+			wantStatus: http.StatusOK,
+			wantBody:   `"ip_address":"8.8.8.8"`,
+		},
+		{
+			name:       "Unknown Route",
+			target:     "/lookup/81.2.69.160",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, tt.target, strings.NewReader(tt.body))
+			if tt.remoteAddr != "" {
+				req.RemoteAddr = tt.remoteAddr
+			}
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.wantBody)
+			if tt.wantCache != "" {
+				assert.Equal(t, tt.wantCache, rec.Header().Get("Cache-Control"))
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package geohttp
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+
+	"github.com/gustavosett/WhereGo/internal/handlers"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/labstack/echo/v4"
+)
+
+// geoHeaders are the headers WithGeoHeaders sets, and removes from
+// incoming requests.
+var geoHeaders = []string{
+	wherego.HeaderGeoIP, wherego.HeaderGeoCountry, wherego.HeaderGeoContinent,
+	wherego.HeaderGeoRegion, wherego.HeaderGeoCity, wherego.HeaderGeoPostal,
+	wherego.HeaderGeoLatitude, wherego.HeaderGeoLongitude, wherego.HeaderGeoTimeZone,
+}
+
+// WithGeoHeaders makes the middleware set the X-Geo-* headers on requests
+// from their Geo, for upstreams the request is forwarded to. The headers
+// are removed from incoming requests first, so clients cannot set them.
+func WithGeoHeaders() HandlerOption {
+	return func(o *handlerOptions) {
+		o.geoHeaders = true
+	}
+}
+
+// Geo is the compact location of a request's client address, stored in the
+// request context by the middleware. Fields are empty when the database
+// has no data for them.
+type Geo struct {
+	// IP is the client address.
+	IP netip.Addr
+	// AddressClass is set for special-purpose addresses, such as "private"
+	// or "loopback", which usually have no data.
+	AddressClass wherego.AddressClass
+	// CountryCode is the ISO 3166-1 alpha-2 code of the country, such as
+	// "GB".
+	CountryCode string
+	// Country is the English name of the country.
+	Country string
+	// IsInEuropeanUnion is true for member states of the European Union.
+	IsInEuropeanUnion bool
+	// ContinentCode is the continent, such as "EU".
+	ContinentCode string
+	// RegionCode is the ISO 3166-2 code of the largest subdivision, without
+	// the country, such as "ENG".
+	RegionCode string
+	// City is the English name of the city.
+	City       string
+	PostalCode string
+	// Latitude and Longitude are only meaningful when HasLocation is true.
+	Latitude    float64
+	Longitude   float64
+	HasLocation bool
+	// TimeZone is the IANA time zone, such as "Europe/London".
+	TimeZone string
+}
+
+// newGeo returns the Geo of addr from its City data, which may be nil.
+func newGeo(addr netip.Addr, city *wherego.City) *Geo {
+	g := &Geo{IP: addr, AddressClass: wherego.ClassifyAddress(addr)}
+	if city == nil {
+		return g
+	}
+	g.CountryCode = city.Country.ISOCode
+	g.Country = city.Country.Names.English
+	g.IsInEuropeanUnion = city.Country.IsInEuropeanUnion
+	g.ContinentCode = city.Continent.Code
+	if len(city.Subdivisions) > 0 {
+		g.RegionCode = city.Subdivisions[0].ISOCode
+	}
+	g.City = city.City.Names.English
+	g.PostalCode = city.Postal.Code
+	if city.Location.HasCoordinates() {
+		g.Latitude, g.Longitude, g.HasLocation = *city.Location.Latitude, *city.Location.Longitude, true
+	}
+	g.TimeZone = city.Location.TimeZone
+	return g
+}
+
+type geoContextKey struct{}
+
+// GeoFromContext returns the Geo the middleware stored in the context of a
+// request, if any.
+func GeoFromContext(ctx context.Context) (*Geo, bool) {
+	g, ok := ctx.Value(geoContextKey{}).(*Geo)
+	return g, ok
+}
+
+// CountryCodeFromContext returns the country code of the request's Geo,
+// or "" if the country is not known.
+func CountryCodeFromContext(ctx context.Context) string {
+	if g, ok := GeoFromContext(ctx); ok {
+		return g.CountryCode
+	}
+	return ""
+}
+
+// GeoFromEcho returns the Geo EchoMiddleware stored for the request of c,
+// if any.
+func GeoFromEcho(c echo.Context) (*Geo, bool) {
+	return GeoFromContext(c.Request().Context())
+}
+
+// Middleware returns net/http middleware that looks up the client address
+// of every request in s and stores its Geo in the request context, for
+// GeoFromContext. The client address is the remote address of the
+// connection, or taken from X-Forwarded-For when the request comes
+// through proxies trusted with WithTrustedProxies. Requests are never
+// rejected; addresses without data get a Geo with only IP and
+// AddressClass set. WithCacheMaxAge does not apply.
+func Middleware(s *wherego.Service, options ...HandlerOption) func(http.Handler) http.Handler {
+	opts := applyHandlerOptions(options)
+	extract := handlers.IPExtractor(opts.proxies)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, annotate(s, &opts, extract, r))
+		})
+	}
+}
+
+// EchoMiddleware is Middleware for Echo. The Geo is stored in the context
+// of the request, for GeoFromEcho and GeoFromContext. The client address
+// follows WithTrustedProxies, not the IPExtractor of the Echo instance.
+func EchoMiddleware(s *wherego.Service, options ...HandlerOption) echo.MiddlewareFunc {
+	opts := applyHandlerOptions(options)
+	extract := handlers.IPExtractor(opts.proxies)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.SetRequest(annotate(s, &opts, extract, c.Request()))
+			return next(c)
+		}
+	}
+}
+
+// annotate returns a copy of r with the Geo of its client address, taken
+// by extract as the server does, in its context, and in its headers with
+// WithGeoHeaders. The headers are copied before they are changed, so the
+// caller's request stays as it was. Without a known client address, there
+// is no Geo.
+func annotate(s *wherego.Service, opts *handlerOptions, extract echo.IPExtractor, r *http.Request) *http.Request {
+	ctx := r.Context()
+	addr, err := netip.ParseAddr(extract(r))
+	known := err == nil
+	var city *wherego.City
+	if known {
+		addr = addr.Unmap().WithZone("")
+		// Lookup errors other than missing data, such as a database of
+		// another type, leave the Geo empty too: the request goes on.
+		city, _ = s.LookupIP(addr.String())
+		ctx = context.WithValue(ctx, geoContextKey{}, newGeo(addr, city))
+	}
+	r = r.WithContext(ctx)
+	if !opts.geoHeaders {
+		return r
+	}
+
+	header := r.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	for _, name := range geoHeaders {
+		header.Del(name)
+	}
+	if known {
+		handlers.SetGeoHeaders(header, addr, city)
+	}
+	r.Header = header
+	return r
+}
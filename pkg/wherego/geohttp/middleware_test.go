@@ -0,0 +1,168 @@
+package geohttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/gustavosett/WhereGo/pkg/wherego/geohttp"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	svc := openSample(t)
+	proxies := geohttp.WithTrustedProxies(netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("fd00::/8"))
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		wantIP     string
+		wantGeo    geohttp.Geo
+	}{
+		{
+			name:       "Direct",
+			remoteAddr: "81.2.69.160:4321",
+			wantIP:     "81.2.69.160",
+			wantGeo: geohttp.Geo{
+				CountryCode: "GB", Country: "United Kingdom", ContinentCode: "EU", RegionCode: "ENG",
+				City: "London", PostalCode: "EC2V", Latitude: 51.5142, Longitude: -0.0931, HasLocation: true,
+				TimeZone: "Europe/London",
+			},
+		},
+		{
+			name:       "Forwarded Header Ignored From Untrusted Peer",
+			remoteAddr: "8.8.8.8:4321",
+			forwarded:  []string{"81.2.69.160"},
+			wantIP:     "8.8.8.8",
+			wantGeo: geohttp.Geo{
+				CountryCode: "US", Country: "United States", ContinentCode: "NA",
+				Latitude: 37.751, Longitude: -97.822, HasLocation: true, TimeZone: "America/Chicago",
+			},
+		},
+		{
+			name:       "Through Trusted Proxies",
+			remoteAddr: "[fd00::1]:4321",
+			forwarded:  []string{"192.0.2.1, 81.2.69.160", "10.0.0.2"},
+			wantIP:     "81.2.69.160",
+		},
+		{
+			name:       "Spoofed Entry Before Untrusted Hop",
+			remoteAddr: "10.0.0.1:4321",
+			forwarded:  []string{"81.2.69.160, 8.8.8.8"},
+			wantIP:     "8.8.8.8",
+		},
+		{
+			name:       "Malformed Entry",
+			remoteAddr: "10.0.0.1:4321",
+			forwarded:  []string{"81.2.69.160, bogus"},
+			wantIP:     "10.0.0.1",
+			wantGeo:    geohttp.Geo{AddressClass: wherego.AddressClassPrivate},
+		},
+		{
+			name:       "No Data",
+			remoteAddr: "127.0.0.1:4321",
+			wantIP:     "127.0.0.1",
+			wantGeo:    geohttp.Geo{AddressClass: wherego.AddressClassLoopback},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *geohttp.Geo
+			h := geohttp.Middleware(svc, proxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var ok bool
+				got, ok = geohttp.GeoFromContext(r.Context())
+				assert.True(t, ok)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			require.NotNil(t, got)
+			assert.Equal(t, tt.wantIP, got.IP.String())
+			if tt.wantGeo != (geohttp.Geo{}) {
+				tt.wantGeo.IP = got.IP
+				assert.Equal(t, tt.wantGeo, *got)
+			}
+		})
+	}
+
+	t.Run("Unknown Client Address", func(t *testing.T) {
+		called := false
+		h := geohttp.Middleware(svc)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			_, ok := geohttp.GeoFromContext(r.Context())
+			assert.False(t, ok)
+			assert.Empty(t, geohttp.CountryCodeFromContext(r.Context()))
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "@"
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		assert.True(t, called)
+	})
+}
+
+func TestMiddleware_GeoHeaders(t *testing.T) {
+	svc := openSample(t)
+	var got http.Header
+	h := geohttp.Middleware(svc, geohttp.WithGeoHeaders())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "81.2.69.160:4321"
+	req.Header.Set(wherego.HeaderGeoCountry, "FR")
+	req.Header.Set(wherego.HeaderGeoCity, "Paris")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	want := http.Header{}
+	for name, value := range map[string]string{
+		wherego.HeaderGeoIP:        "81.2.69.160",
+		wherego.HeaderGeoCountry:   "GB",
+		wherego.HeaderGeoContinent: "EU",
+		wherego.HeaderGeoRegion:    "ENG",
+		wherego.HeaderGeoCity:      "London",
+		wherego.HeaderGeoPostal:    "EC2V",
+		wherego.HeaderGeoLatitude:  "51.5142",
+		wherego.HeaderGeoLongitude: "-0.0931",
+		wherego.HeaderGeoTimeZone:  "Europe/London",
+	} {
+		want.Set(name, value)
+	}
+	assert.Equal(t, want, got)
+	assert.Equal(t, http.Header{wherego.HeaderGeoCountry: {"FR"}, wherego.HeaderGeoCity: {"Paris"}}, req.Header,
+		"the caller's request is not changed")
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "127.0.0.1:4321"
+	req.Header.Set(wherego.HeaderGeoCountry, "FR")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	want = http.Header{}
+	want.Set(wherego.HeaderGeoIP, "127.0.0.1")
+	assert.Equal(t, want, got, "spoofed headers are removed")
+}
+
+func TestEchoMiddleware(t *testing.T) {
+	e := echo.New()
+	e.Use(geohttp.EchoMiddleware(openSample(t)))
+	e.GET("/", func(c echo.Context) error {
+		g, ok := geohttp.GeoFromEcho(c)
+		if !ok {
+			return c.NoContent(http.StatusNoContent)
+		}
+		return c.String(http.StatusOK, g.CountryCode+" "+geohttp.CountryCodeFromContext(c.Request().Context()))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "81.2.69.160:4321"
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "GB GB", rec.Body.String())
+}
@@ -0,0 +1,16 @@
+package wherego
+
+// Headers carrying the location of a client address. The geohttp
+// middleware sets them on requests with WithGeoHeaders, and the server's
+// /auth endpoint on its responses.
+const (
+	HeaderGeoIP        = "X-Geo-IP"
+	HeaderGeoCountry   = "X-Geo-Country"
+	HeaderGeoContinent = "X-Geo-Continent"
+	HeaderGeoRegion    = "X-Geo-Region"
+	HeaderGeoCity      = "X-Geo-City"
+	HeaderGeoPostal    = "X-Geo-Postal-Code"
+	HeaderGeoLatitude  = "X-Geo-Latitude"
+	HeaderGeoLongitude = "X-Geo-Longitude"
+	HeaderGeoTimeZone  = "X-Geo-Time-Zone"
+)
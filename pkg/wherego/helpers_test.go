@@ -0,0 +1,34 @@
+package wherego
+
+import (
+	"testing"
+
+	"github.com/gustavosett/WhereGo/internal/geoiptest"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+// json is the serializer the API used before the hand-written encoders,
+// kept in tests as the reference output.
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// openSampleCity opens the geoiptest sample City database and closes it
+// when the test ends.
+func openSampleCity(t *testing.T) *Reader {
+	t.Helper()
+	r, err := OpenBytes(geoiptest.MustBuild(geoiptest.SampleCity()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		closeErr := r.Close()
+		require.NoError(t, closeErr)
+	})
+	return r
+}
+
+func openSampleCityB(b *testing.B) *Reader {
+	b.Helper()
+	r, err := OpenBytes(geoiptest.MustBuild(geoiptest.SampleCity()))
+	require.NoError(b, err)
+	b.Cleanup(func() { _ = r.Close() })
+	return r
+}
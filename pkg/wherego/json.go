@@ -0,0 +1,273 @@
+package wherego
+
+import (
+	"maps"
+	"math"
+	"net/netip"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+)
+
+// The AppendJSON methods below serialize the City and Country models
+// without reflection. Their output is byte-for-byte what
+// jsoniter.ConfigCompatibleWithStandardLibrary produces for the same value,
+// which is what the API served before, so they can be swapped in on the hot
+// path without changing responses. Like jsoniter, they ignore the omitzero
+// option and write every field, except the ones tagged omitempty.
+
+// AppendJSON appends the JSON encoding of c to dst.
+func (c *City) AppendJSON(dst []byte) []byte {
+	dst = append(dst, `{"traits":`...)
+	dst = c.Traits.appendJSON(dst)
+	dst = append(dst, `,"postal":{"code":`...)
+	dst = appendJSONString(dst, c.Postal.Code)
+	dst = append(dst, `},"continent":`...)
+	dst = c.Continent.appendJSON(dst)
+	dst = append(dst, `,"city":{"names":`...)
+	dst = c.City.Names.appendJSON(dst)
+	dst = append(dst, `,"geoname_id":`...)
+	dst = strconv.AppendUint(dst, uint64(c.City.GeoNameID), 10)
+	dst = append(dst, `},"subdivisions":`...)
+	if c.Subdivisions == nil {
+		dst = append(dst, "null"...)
+	} else {
+		dst = append(dst, '[')
+		for i := range c.Subdivisions {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = c.Subdivisions[i].appendJSON(dst)
+		}
+		dst = append(dst, ']')
+	}
+	dst = append(dst, `,"represented_country":`...)
+	dst = c.RepresentedCountry.appendJSON(dst)
+	dst = append(dst, `,"country":`...)
+	dst = c.Country.appendJSON(dst)
+	dst = append(dst, `,"registered_country":`...)
+	dst = c.RegisteredCountry.appendJSON(dst)
+	dst = append(dst, `,"location":`...)
+	dst = c.Location.appendJSON(dst)
+	if len(c.Labels) > 0 {
+		dst = append(dst, `,"labels":{`...)
+		for i, k := range slices.Sorted(maps.Keys(c.Labels)) {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendJSONString(dst, k)
+			dst = append(dst, ':')
+			dst = appendJSONString(dst, c.Labels[k])
+		}
+		dst = append(dst, '}')
+	}
+	if c.Source != "" {
+		dst = append(dst, `,"source":`...)
+		dst = appendJSONString(dst, c.Source)
+	}
+	return append(dst, '}')
+}
+
+// appendJSONSource sets the Source field of the City encoded at the end of
+// dst. Source is the last field, so this is the same as setting it before
+// encoding.
+func appendJSONSource(dst []byte, source string) []byte {
+	dst = append(dst[:len(dst)-1], `,"source":`...)
+	dst = appendJSONString(dst, source)
+	return append(dst, '}')
+}
+
+// AppendJSON appends the JSON encoding of c to dst.
+func (c *Country) AppendJSON(dst []byte) []byte {
+	dst = append(dst, `{"traits":{"ip_address":`...)
+	dst = appendJSONAddr(dst, c.Traits.IPAddress)
+	dst = append(dst, `,"network":`...)
+	dst = appendJSONPrefix(dst, c.Traits.Network)
+	dst = append(dst, `,"is_anycast":`...)
+	dst = strconv.AppendBool(dst, c.Traits.IsAnycast)
+	dst = append(dst, `},"continent":`...)
+	dst = c.Continent.appendJSON(dst)
+	dst = append(dst, `,"represented_country":`...)
+	dst = c.RepresentedCountry.appendJSON(dst)
+	dst = append(dst, `,"country":`...)
+	dst = c.Country.appendJSON(dst)
+	dst = append(dst, `,"registered_country":`...)
+	dst = c.RegisteredCountry.appendJSON(dst)
+	return append(dst, '}')
+}
+
+func (n *Names) appendJSON(dst []byte) []byte {
+	dst = append(dst, `{"de":`...)
+	dst = appendJSONString(dst, n.German)
+	dst = append(dst, `,"en":`...)
+	dst = appendJSONString(dst, n.English)
+	dst = append(dst, `,"es":`...)
+	dst = appendJSONString(dst, n.Spanish)
+	dst = append(dst, `,"fr":`...)
+	dst = appendJSONString(dst, n.French)
+	dst = append(dst, `,"ja":`...)
+	dst = appendJSONString(dst, n.Japanese)
+	dst = append(dst, `,"pt-BR":`...)
+	dst = appendJSONString(dst, n.BrazilianPortuguese)
+	dst = append(dst, `,"ru":`...)
+	dst = appendJSONString(dst, n.Russian)
+	dst = append(dst, `,"zh-CN":`...)
+	dst = appendJSONString(dst, n.SimplifiedChinese)
+	return append(dst, '}')
+}
+
+func (c *Continent) appendJSON(dst []byte) []byte {
+	dst = append(dst, `{"names":`...)
+	dst = c.Names.appendJSON(dst)
+	dst = append(dst, `,"code":`...)
+	dst = appendJSONString(dst, c.Code)
+	dst = append(dst, `,"geoname_id":`...)
+	dst = strconv.AppendUint(dst, uint64(c.GeoNameID), 10)
+	return append(dst, '}')
+}
+
+func (l *Location) appendJSON(dst []byte) []byte {
+	dst = append(dst, `{"latitude":`...)
+	dst = appendJSONFloatPtr(dst, l.Latitude)
+	dst = append(dst, `,"longitude":`...)
+	dst = appendJSONFloatPtr(dst, l.Longitude)
+	dst = append(dst, `,"time_zone":`...)
+	dst = appendJSONString(dst, l.TimeZone)
+	dst = append(dst, `,"metro_code":`...)
+	dst = strconv.AppendUint(dst, uint64(l.MetroCode), 10) //nolint:staticcheck // still part of the response
+	dst = append(dst, `,"accuracy_radius":`...)
+	dst = strconv.AppendUint(dst, uint64(l.AccuracyRadius), 10)
+	return append(dst, '}')
+}
+
+func (r *RepresentedCountry) appendJSON(dst []byte) []byte {
+	dst = append(dst, `{"names":`...)
+	dst = r.Names.appendJSON(dst)
+	dst = append(dst, `,"iso_code":`...)
+	dst = appendJSONString(dst, r.ISOCode)
+	dst = append(dst, `,"type":`...)
+	dst = appendJSONString(dst, r.Type)
+	dst = append(dst, `,"geoname_id":`...)
+	dst = strconv.AppendUint(dst, uint64(r.GeoNameID), 10)
+	dst = append(dst, `,"is_in_european_union":`...)
+	dst = strconv.AppendBool(dst, r.IsInEuropeanUnion)
+	return append(dst, '}')
+}
+
+func (s *CitySubdivision) appendJSON(dst []byte) []byte {
+	dst = append(dst, `{"names":`...)
+	dst = s.Names.appendJSON(dst)
+	dst = append(dst, `,"iso_code":`...)
+	dst = appendJSONString(dst, s.ISOCode)
+	dst = append(dst, `,"geoname_id":`...)
+	dst = strconv.AppendUint(dst, uint64(s.GeoNameID), 10)
+	return append(dst, '}')
+}
+
+func (c *CountryRecord) appendJSON(dst []byte) []byte {
+	dst = append(dst, `{"names":`...)
+	dst = c.Names.appendJSON(dst)
+	dst = append(dst, `,"iso_code":`...)
+	dst = appendJSONString(dst, c.ISOCode)
+	dst = append(dst, `,"geoname_id":`...)
+	dst = strconv.AppendUint(dst, uint64(c.GeoNameID), 10)
+	dst = append(dst, `,"is_in_european_union":`...)
+	dst = strconv.AppendBool(dst, c.IsInEuropeanUnion)
+	return append(dst, '}')
+}
+
+func (t *CityTraits) appendJSON(dst []byte) []byte {
+	dst = append(dst, `{"ip_address":`...)
+	dst = appendJSONAddr(dst, t.IPAddress)
+	dst = append(dst, `,"network":`...)
+	dst = appendJSONPrefix(dst, t.Network)
+	dst = append(dst, `,"is_anycast":`...)
+	dst = strconv.AppendBool(dst, t.IsAnycast)
+	if t.AddressClass != "" {
+		dst = append(dst, `,"address_class":`...)
+		dst = appendJSONString(dst, string(t.AddressClass))
+	}
+	if t.EmbeddedIPv4 != "" {
+		dst = append(dst, `,"embedded_ipv4":`...)
+		dst = appendJSONString(dst, t.EmbeddedIPv4)
+	}
+	return append(dst, '}')
+}
+
+func appendJSONAddr(dst []byte, addr netip.Addr) []byte {
+	dst = append(dst, '"')
+	if addr.IsValid() {
+		dst = addr.AppendTo(dst)
+	}
+	return append(dst, '"')
+}
+
+func appendJSONPrefix(dst []byte, prefix netip.Prefix) []byte {
+	dst = append(dst, '"')
+	if prefix.IsValid() {
+		dst = prefix.AppendTo(dst)
+	}
+	return append(dst, '"')
+}
+
+func appendJSONFloatPtr(dst []byte, f *float64) []byte {
+	if f == nil {
+		return append(dst, "null"...)
+	}
+	format := byte('f')
+	if abs := math.Abs(*f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	return strconv.AppendFloat(dst, *f, format, -1, 64)
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString appends s as a JSON string, escaping HTML characters,
+// U+2028, U+2029 and invalid UTF-8 the same way encoding/json does.
+func appendJSONString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '"', '\\':
+				dst = append(dst, '\\', b)
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, `\ufffd`...)
+			i++
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
@@ -0,0 +1,78 @@
+package wherego
+
+import (
+	"math"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendJSON_MatchesJsoniter(t *testing.T) {
+	lat, lon := 51.5142, -0.0931
+	tiny, huge := 1e-7, 2.5e21
+	names := Names{
+		German: "Köln", English: `Quote " and \ slash`, Spanish: "<b>&amp;</b>",
+		French: "tab\tnew\nline\rcr", Japanese: "北アメリカ", BrazilianPortuguese: "ctl\x01\x1f",
+		Russian: "sep\u2028\u2029", SimplifiedChinese: "bad\xffutf8",
+	}
+
+	cities := map[string]City{
+		"Empty": {},
+		"Full": {
+			Traits: CityTraits{
+				IPAddress: netip.MustParseAddr("81.2.69.160"),
+				Network:   netip.MustParsePrefix("81.2.69.0/24"),
+				IsAnycast: true,
+			},
+			Postal:             CityPostal{Code: "EC2V"},
+			Continent:          Continent{Names: names, Code: "EU", GeoNameID: 6255148},
+			City:               CityRecord{Names: names, GeoNameID: 2643743},
+			Subdivisions:       []CitySubdivision{{Names: names, ISOCode: "ENG", GeoNameID: 1}, {ISOCode: "X"}},
+			RepresentedCountry: RepresentedCountry{Names: names, ISOCode: "US", Type: "military", GeoNameID: 2, IsInEuropeanUnion: true},
+			Country:            CountryRecord{Names: names, ISOCode: "GB", GeoNameID: 2635167},
+			RegisteredCountry:  CountryRecord{ISOCode: "FR", IsInEuropeanUnion: true},
+			Location:           Location{Latitude: &lat, Longitude: &lon, TimeZone: "Europe/London", MetroCode: 7, AccuracyRadius: 10},
+		},
+		"IPv6 And Exponents": {
+			Traits:       CityTraits{IPAddress: netip.MustParseAddr("2606:4700::1111"), Network: netip.MustParsePrefix("2606:4700::/32")},
+			Subdivisions: []CitySubdivision{},
+			Location:     Location{Latitude: &tiny, Longitude: &huge},
+		},
+	}
+	for name, city := range cities {
+		t.Run("City "+name, func(t *testing.T) {
+			want, err := json.Marshal(&city)
+			require.NoError(t, err)
+			assert.Equal(t, string(want), string(city.AppendJSON(nil)))
+		})
+	}
+
+	countries := map[string]Country{
+		"Empty": {},
+		"Full": {
+			Traits:             CountryTraits{IPAddress: netip.MustParseAddr("8.8.8.8"), Network: netip.MustParsePrefix("8.8.8.0/24")},
+			Continent:          Continent{Names: names, Code: "NA"},
+			RepresentedCountry: RepresentedCountry{Type: "military"},
+			Country:            CountryRecord{ISOCode: "US"},
+			RegisteredCountry:  CountryRecord{ISOCode: "US", IsInEuropeanUnion: true},
+		},
+	}
+	for name, country := range countries {
+		t.Run("Country "+name, func(t *testing.T) {
+			want, err := json.Marshal(&country)
+			require.NoError(t, err)
+			assert.Equal(t, string(want), string(country.AppendJSON(nil)))
+		})
+	}
+}
+
+func TestAppendJSONFloatPtr(t *testing.T) {
+	for _, f := range []float64{0, 1, -1, 0.5, 1e-6, 9.99e-7, 1e20, 1e21, -1e21, math.SmallestNonzeroFloat64, math.MaxFloat64} {
+		want, err := json.Marshal(f)
+		require.NoError(t, err)
+		assert.Equal(t, string(want), string(appendJSONFloatPtr(nil, &f)), "%v", f)
+	}
+	assert.Equal(t, "null", string(appendJSONFloatPtr(nil, nil)))
+}
@@ -1,4 +1,4 @@
-package geoip
+package wherego
 
 import "net/netip"
 
@@ -45,6 +45,32 @@ func (n Names) HasData() bool {
 	return n != zeroNames
 }
 
+// Localized returns the name for locale, such as "de" or "pt-BR", or the
+// English name when there is none for locale.
+func (n Names) Localized(locale string) string {
+	var name string
+	switch locale {
+	case "de":
+		name = n.German
+	case "es":
+		name = n.Spanish
+	case "fr":
+		name = n.French
+	case "ja":
+		name = n.Japanese
+	case "pt-BR":
+		name = n.BrazilianPortuguese
+	case "ru":
+		name = n.Russian
+	case "zh-CN":
+		name = n.SimplifiedChinese
+	}
+	if name == "" {
+		return n.English
+	}
+	return name
+}
+
 // Common types used across multiple database records
 
 // Continent contains data for the continent record associated with an IP address.
@@ -324,13 +350,22 @@ type CityTraits struct {
 	// IsAnycast is true if the IP address belongs to an anycast network.
 	// See https://en.wikipedia.org/wiki/Anycast
 	IsAnycast bool `json:"is_anycast,omitzero" maxminddb:"is_anycast"`
+	// AddressClass is the special-purpose class of the IP address, such as
+	// "private" or "loopback". It is empty for global unicast addresses.
+	AddressClass AddressClass `json:"address_class,omitempty" maxminddb:"-"`
+	// EmbeddedIPv4 is the IPv4 address embedded in a 6to4 or Teredo address
+	// when the Service looked up that address instead.
+	EmbeddedIPv4 string `json:"embedded_ipv4,omitempty" maxminddb:"-"`
 }
 
-// HasData returns true if the CityTraits has any data (excluding Network and IPAddress).
+// HasData returns true if the CityTraits has any data (excluding Network,
+// IPAddress and the fields derived from the address itself).
 func (t CityTraits) HasData() bool {
 	cmp := t
 	cmp.Network = zeroCityTraits.Network
 	cmp.IPAddress = zeroCityTraits.IPAddress
+	cmp.AddressClass = zeroCityTraits.AddressClass
+	cmp.EmbeddedIPv4 = zeroCityTraits.EmbeddedIPv4
 	return cmp != zeroCityTraits
 }
 
@@ -437,6 +472,12 @@ type City struct {
 	// Location contains data for the location record associated with the IP
 	// address
 	Location Location `json:"location,omitzero"            maxminddb:"location"`
+	// Labels contains custom labels set by an overrides source, such as the
+	// datacenter an internal network belongs to
+	Labels map[string]string `json:"labels,omitempty"             maxminddb:"labels"`
+	// Source names the source that answered the lookup. It is only set
+	// when the Service has overrides configured.
+	Source string `json:"source,omitempty"             maxminddb:"-"`
 }
 
 // HasData returns true if any GeoIP data was found for the IP in the City database.
@@ -444,7 +485,8 @@ type City struct {
 func (c City) HasData() bool {
 	return c.Traits.HasData() || c.Postal.HasData() || c.Continent.HasData() ||
 		c.City.HasData() || c.hasSubdivisionsData() || c.RepresentedCountry.HasData() ||
-		c.Country.HasData() || c.RegisteredCountry.HasData() || c.Location.HasData()
+		c.Country.HasData() || c.RegisteredCountry.HasData() || c.Location.HasData() ||
+		len(c.Labels) > 0
 }
 
 func (c City) hasSubdivisionsData() bool {
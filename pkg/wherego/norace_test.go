@@ -0,0 +1,5 @@
+//go:build !race
+
+package wherego
+
+const raceEnabled = false
@@ -0,0 +1,497 @@
+package wherego
+
+import (
+	"bytes"
+	"context"
+	stdjson "encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Values of City.Source.
+const (
+	// SourceDatabase means the result came from the MaxMind database only.
+	SourceDatabase = "database"
+	// SourceOverride means an override matched and the database had no
+	// data for the address.
+	SourceOverride = "override"
+	// SourceOverrideDatabase means an override matched and was merged with
+	// the database result.
+	SourceOverrideDatabase = "override+database"
+)
+
+// Overrides replaces lookup results for selected networks, such as private
+// ranges or office egress IPs the upstream database gets wrong. Each
+// override holds a partial City whose non-zero fields take precedence over
+// the database result. The most specific matching network wins.
+//
+// Since only non-zero fields are merged, an override cannot set a field to
+// its zero value: is_in_european_union: false or an empty string leaves the
+// database value in place, and fields cannot be cleared.
+//
+// Overrides are loaded from a YAML or JSON file, or from a MaxMind DB file
+// when the path ends in ".mmdb". A YAML or JSON file lists the networks
+// under an "overrides" key; every other key of an entry uses the City JSON
+// field names:
+//
+//	overrides:
+//	  - network: 10.20.0.0/16
+//	    country: {iso_code: DE, names: {en: Germany}}
+//	    city: {names: {en: Frankfurt}}
+//	    labels: {datacenter: fra1}
+//
+// Reload and Watch replace the overrides atomically, so Overrides is safe
+// for concurrent use while the file changes.
+type Overrides struct {
+	path  string
+	table atomic.Pointer[overrideTable]
+
+	// mu serializes reloads.
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+}
+
+type overrideTable struct {
+	entries []override
+	// networks indexes entries by network.
+	networks prefixTrie
+	// db is set instead of entries for MaxMind DB overrides.
+	db *Reader
+	// records caches the decoded records of db by network.
+	records sync.Map
+	// version is a hash of the file contents.
+	version uint64
+
+	// mu guards db against being closed while lookups use it.
+	mu     sync.RWMutex
+	closed bool
+}
+
+type override struct {
+	network netip.Prefix
+	city    City
+}
+
+type overridesFile struct {
+	Overrides []overrideSpec `yaml:"overrides"`
+}
+
+type overrideSpec struct {
+	Network string         `yaml:"network"`
+	Fields  map[string]any `yaml:",inline"`
+}
+
+// LoadOverrides reads the overrides file at path.
+func LoadOverrides(path string) (*Overrides, error) {
+	o := &Overrides{path: path}
+	if err := o.Reload(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Path returns the file the overrides are loaded from.
+func (o *Overrides) Path() string {
+	return o.path
+}
+
+// Len returns the number of override networks. It returns -1 for MaxMind DB
+// overrides, whose networks are not enumerated.
+func (o *Overrides) Len() int {
+	t := o.table.Load()
+	if t.db != nil {
+		return -1
+	}
+	return len(t.entries)
+}
+
+// Version identifies the contents of the overrides file. It changes when
+// the file is reloaded with different contents, and is the same for the
+// same contents across processes.
+func (o *Overrides) Version() uint64 {
+	return o.table.Load().version
+}
+
+// Reload reads the overrides file again. On error the current overrides
+// stay in place.
+func (o *Overrides) Reload() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	info, err := os.Stat(o.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(o.path)
+	if err != nil {
+		return err
+	}
+	table, err := parseOverrides(o.path, data)
+	if err != nil {
+		return err
+	}
+	h := fnv.New64a()
+	h.Write(data)
+	table.version = h.Sum64()
+	if old := o.table.Swap(table); old != nil {
+		_ = old.close()
+	}
+	o.modTime, o.size = info.ModTime(), info.Size()
+	return nil
+}
+
+// Close releases the database of MaxMind DB overrides. Lookups after Close
+// find no overrides; stop Watch before calling it.
+func (o *Overrides) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if old := o.table.Swap(&overrideTable{}); old != nil {
+		return old.close()
+	}
+	return nil
+}
+
+// Watch polls the overrides file every interval and reloads it when its
+// modification time or size changes, until ctx is done. Reload errors are
+// passed to onError, which may be nil.
+func (o *Overrides) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !o.changed() {
+			continue
+		}
+		if err := o.Reload(); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+func (o *Overrides) changed() bool {
+	info, err := os.Stat(o.path)
+	if err != nil {
+		// Report the error through Reload.
+		return true
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return !info.ModTime().Equal(o.modTime) || info.Size() != o.size
+}
+
+// Lookup returns the override for addr and the network it was defined for.
+// The returned City must not be modified.
+func (o *Overrides) Lookup(addr netip.Addr) (*City, netip.Prefix, bool) {
+	addr = addr.Unmap()
+	for {
+		t := o.table.Load()
+		if t.db == nil {
+			if entry := t.networks.lookup(addr); entry != nil {
+				return &entry.city, entry.network, true
+			}
+			return nil, netip.Prefix{}, false
+		}
+		if city, network, ok, closed := t.lookupDB(addr); !closed {
+			return city, network, ok
+		}
+		// A reload closed the database after it was loaded; use the
+		// new one.
+	}
+}
+
+// lookupDB looks addr up in t.db. It reports closed, without a result, if
+// t was replaced and its database closed.
+func (t *overrideTable) lookupDB(addr netip.Addr) (city *City, network netip.Prefix, ok, closed bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.closed {
+		return nil, netip.Prefix{}, false, true
+	}
+	result := t.db.mmdbReader.Lookup(addr)
+	if !result.Found() {
+		return nil, netip.Prefix{}, false, false
+	}
+	network = result.Prefix()
+	if cached, ok := t.records.Load(network); ok {
+		return cached.(*City), network, true, false
+	}
+	city = new(City)
+	if err := result.Decode(city); err != nil {
+		return nil, netip.Prefix{}, false, false
+	}
+	// The networks of the file are finite, so the cache is bounded by it.
+	cached, _ := t.records.LoadOrStore(network, city)
+	return cached.(*City), network, true, false
+}
+
+// close closes the database of a replaced table once the lookups using it
+// are done.
+func (t *overrideTable) close() error {
+	if t.db == nil {
+		return nil
+	}
+	t.mu.Lock()
+	t.closed = true
+	t.mu.Unlock()
+	return t.db.Close()
+}
+
+// applyOverride merges override, the override for the address of city
+// defined for network, into city and sets city.Source. A nil override
+// only sets city.Source.
+func applyOverride(city, override *City, network netip.Prefix) {
+	if override == nil {
+		city.Source = SourceDatabase
+		return
+	}
+	city.Source = SourceOverride
+	if city.HasData() {
+		city.Source = SourceOverrideDatabase
+	}
+	mergeCity(city, override)
+	if !city.Traits.Network.IsValid() || network.Bits() > city.Traits.Network.Bits() {
+		city.Traits.Network = network
+	}
+}
+
+// applyEnterpriseOverride is applyOverride for Enterprise records, which
+// have no Source. Fields of override the Enterprise model does not have,
+// such as its labels, are left out.
+func applyEnterpriseOverride(enterprise *Enterprise, override *City, network netip.Prefix) {
+	if override == nil {
+		return
+	}
+	mergeValue(reflect.ValueOf(enterprise).Elem(), reflect.ValueOf(override).Elem())
+	if !enterprise.Traits.Network.IsValid() || network.Bits() > enterprise.Traits.Network.Bits() {
+		enterprise.Traits.Network = network
+	}
+}
+
+func parseOverrides(path string, data []byte) (*overrideTable, error) {
+	if strings.EqualFold(filepath.Ext(path), ".mmdb") {
+		db, err := OpenBytes(data, AllowUnknownDatabaseType())
+		if err != nil {
+			return nil, fmt.Errorf("overrides %s: %w", path, err)
+		}
+		return &overrideTable{db: db}, nil
+	}
+
+	// YAML is a superset of JSON, so this reads both formats.
+	var file overridesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("overrides %s: %w", path, err)
+	}
+
+	table := &overrideTable{entries: make([]override, 0, len(file.Overrides))}
+	seen := make(map[netip.Prefix]bool, len(file.Overrides))
+	for i, spec := range file.Overrides {
+		entry, err := spec.parse()
+		if err != nil {
+			return nil, fmt.Errorf("overrides %s: entry %d: %w", path, i, err)
+		}
+		if seen[entry.network] {
+			return nil, fmt.Errorf("overrides %s: entry %d: duplicate network %s", path, i, entry.network)
+		}
+		seen[entry.network] = true
+		table.entries = append(table.entries, entry)
+	}
+	for i := range table.entries {
+		table.networks.insert(&table.entries[i])
+	}
+	return table, nil
+}
+
+func (s overrideSpec) parse() (override, error) {
+	network, err := parseNetwork(s.Network)
+	if err != nil {
+		return override{}, err
+	}
+
+	// Map the fields onto City through its JSON names, so the file uses
+	// the same schema as the API responses.
+	raw, err := stdjson.Marshal(s.Fields)
+	if err != nil {
+		return override{}, err
+	}
+	dec := stdjson.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	entry := override{network: network}
+	if err := dec.Decode(&entry.city); err != nil {
+		return override{}, err
+	}
+	// The address and network always come from the lookup itself.
+	entry.city.Traits.IPAddress = netip.Addr{}
+	entry.city.Traits.Network = netip.Prefix{}
+	entry.city.Source = ""
+	return entry, nil
+}
+
+// parseNetwork parses a CIDR or a single address.
+func parseNetwork(s string) (netip.Prefix, error) {
+	if s == "" {
+		return netip.Prefix{}, errors.New("missing network")
+	}
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	if prefix.Addr().Is4In6() {
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+	}
+	return prefix.Masked(), nil
+}
+
+// prefixTrie is a binary trie of override networks, which finds the most
+// specific network containing an address in one walk over its bits.
+type prefixTrie struct {
+	v4, v6 *trieNode
+}
+
+type trieNode struct {
+	children [2]*trieNode
+	// entry is the override whose network ends at the node, if any.
+	entry *override
+}
+
+func (t *prefixTrie) insert(entry *override) {
+	root := &t.v6
+	if entry.network.Addr().Is4() {
+		root = &t.v4
+	}
+	if *root == nil {
+		*root = new(trieNode)
+	}
+	node := *root
+	bytes := entry.network.Addr().AsSlice()
+	for i := range entry.network.Bits() {
+		bit := bytes[i/8] >> (7 - i%8) & 1
+		if node.children[bit] == nil {
+			node.children[bit] = new(trieNode)
+		}
+		node = node.children[bit]
+	}
+	node.entry = entry
+}
+
+// lookup returns the override of the most specific network containing
+// addr, or nil.
+func (t *prefixTrie) lookup(addr netip.Addr) *override {
+	node := t.v6
+	if addr.Is4() {
+		node = t.v4
+	}
+	var match *override
+	bytes := addr.AsSlice()
+	for i := 0; node != nil; i++ {
+		if node.entry != nil {
+			match = node.entry
+		}
+		if i == len(bytes)*8 {
+			break
+		}
+		node = node.children[bytes[i/8]>>(7-i%8)&1]
+	}
+	return match
+}
+
+var modelsPkgPath = reflect.TypeFor[City]().PkgPath()
+
+// mergeCity copies the non-zero fields of src into dst. Nested records are
+// merged field by field, labels key by key, and slices are replaced as a
+// whole. dst does not share any memory with src afterwards, and maps in
+// dst are copied rather than written to, since they may belong to the
+// cache.
+func mergeCity(dst, src *City) {
+	mergeValue(reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem())
+}
+
+func mergeValue(dst, src reflect.Value) {
+	if dst.Type() != src.Type() {
+		mergeConverted(dst, src)
+		return
+	}
+	switch src.Kind() {
+	case reflect.Struct:
+		if src.Type().PkgPath() == modelsPkgPath {
+			for i := range src.NumField() {
+				mergeValue(dst.Field(i), src.Field(i))
+			}
+			return
+		}
+	case reflect.Map:
+		if src.Len() == 0 {
+			return
+		}
+		merged := reflect.MakeMapWithSize(src.Type(), dst.Len()+src.Len())
+		for _, m := range []reflect.Value{dst, src} {
+			iter := m.MapRange()
+			for iter.Next() {
+				merged.SetMapIndex(iter.Key(), iter.Value())
+			}
+		}
+		dst.Set(merged)
+		return
+	case reflect.Pointer:
+		if src.IsNil() {
+			return
+		}
+		v := reflect.New(src.Type().Elem())
+		v.Elem().Set(src.Elem())
+		dst.Set(v)
+		return
+	case reflect.Slice:
+		if src.Len() == 0 {
+			return
+		}
+		dst.Set(reflect.AppendSlice(reflect.MakeSlice(src.Type(), 0, src.Len()), src))
+		return
+	}
+	if !src.IsZero() {
+		dst.Set(src)
+	}
+}
+
+// mergeConverted merges src into dst of another model, such as a
+// CityRecord into an EnterpriseCityRecord: records field by field by name,
+// skipping the fields dst does not have, and slices element by element.
+// Values of other kinds are skipped.
+func mergeConverted(dst, src reflect.Value) {
+	switch {
+	case src.Kind() == reflect.Struct && dst.Kind() == reflect.Struct:
+		for i := range src.NumField() {
+			if field := dst.FieldByName(src.Type().Field(i).Name); field.IsValid() {
+				mergeValue(field, src.Field(i))
+			}
+		}
+	case src.Kind() == reflect.Slice && dst.Kind() == reflect.Slice && src.Len() > 0:
+		elems := reflect.MakeSlice(dst.Type(), src.Len(), src.Len())
+		for i := range src.Len() {
+			mergeValue(elems.Index(i), src.Index(i))
+		}
+		dst.Set(elems)
+	}
+}
@@ -0,0 +1,396 @@
+package wherego
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gustavosett/WhereGo/internal/geoiptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleOverrides = `
+overrides:
+  - network: 10.20.0.0/16
+    country: {iso_code: DE, names: {en: Germany}}
+    city: {names: {en: Frankfurt}}
+    location: {latitude: 50.11, longitude: 8.68, time_zone: Europe/Berlin}
+    labels: {datacenter: fra1}
+  - network: 10.20.30.0/24
+    city: {names: {en: Offenbach}}
+    labels: {rack: r30}
+  - network: 81.2.69.160
+    labels: {office: london}
+    postal: {code: EC1A}
+`
+
+func writeOverrides(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadOverrides(t *testing.T) {
+	o, err := LoadOverrides(writeOverrides(t, "overrides.yaml", sampleOverrides))
+	require.NoError(t, err)
+	assert.Equal(t, 3, o.Len())
+
+	tests := []struct {
+		name    string
+		ip      string
+		network string
+		city    string
+	}{
+		{"Most Specific Wins", "10.20.30.1", "10.20.30.0/24", "Offenbach"},
+		{"Covering Network", "10.20.1.1", "10.20.0.0/16", "Frankfurt"},
+		{"Single Address", "81.2.69.160", "81.2.69.160/32", ""},
+		{"IPv4-Mapped Address", "::ffff:10.20.1.1", "10.20.0.0/16", "Frankfurt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			city, network, ok := o.Lookup(netip.MustParseAddr(tt.ip))
+			require.True(t, ok)
+			assert.Equal(t, tt.network, network.String())
+			assert.Equal(t, tt.city, city.City.Names.English)
+		})
+	}
+
+	_, _, ok := o.Lookup(netip.MustParseAddr("10.21.0.1"))
+	assert.False(t, ok)
+}
+
+func TestLoadOverrides_JSON(t *testing.T) {
+	o, err := LoadOverrides(writeOverrides(t, "overrides.json",
+		`{"overrides": [{"network": "192.168.0.0/16", "country": {"iso_code": "BR"}}]}`))
+	require.NoError(t, err)
+
+	city, _, ok := o.Lookup(netip.MustParseAddr("192.168.1.1"))
+	require.True(t, ok)
+	assert.Equal(t, "BR", city.Country.ISOCode)
+}
+
+func TestLoadOverrides_MMDB(t *testing.T) {
+	data := geoiptest.MustBuild(geoiptest.Database{
+		DatabaseType: "WhereGo-Overrides",
+		Networks: map[netip.Prefix]any{
+			netip.MustParsePrefix("10.0.0.0/8"): geoiptest.Map{
+				"country": geoiptest.Map{"iso_code": "US"},
+				"labels":  geoiptest.Map{"datacenter": "iad1"},
+			},
+		},
+	})
+	path := filepath.Join(t.TempDir(), "overrides.mmdb")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	o, err := LoadOverrides(path)
+	require.NoError(t, err)
+	assert.Equal(t, -1, o.Len())
+
+	city, network, ok := o.Lookup(netip.MustParseAddr("10.1.2.3"))
+	require.True(t, ok)
+	assert.Equal(t, "10.0.0.0/8", network.String())
+	assert.Equal(t, "US", city.Country.ISOCode)
+	assert.Equal(t, map[string]string{"datacenter": "iad1"}, city.Labels)
+
+	again, _, ok := o.Lookup(netip.MustParseAddr("10.200.0.1"))
+	require.True(t, ok)
+	assert.Same(t, city, again, "records are decoded once per network")
+
+	_, _, ok = o.Lookup(netip.MustParseAddr("11.0.0.1"))
+	assert.False(t, ok)
+
+	old := o.table.Load()
+	require.NoError(t, o.Reload())
+	assert.True(t, old.closed, "reloading closes the replaced database")
+	_, _, ok = o.Lookup(netip.MustParseAddr("10.1.2.3"))
+	assert.True(t, ok)
+
+	current := o.table.Load()
+	require.NoError(t, o.Close())
+	assert.True(t, current.closed, "closing releases the database")
+	_, _, ok = o.Lookup(netip.MustParseAddr("10.1.2.3"))
+	assert.False(t, ok, "lookups after Close find no overrides")
+}
+
+func TestOverrides_ReloadMMDBConcurrently(t *testing.T) {
+	data := geoiptest.MustBuild(geoiptest.Database{
+		DatabaseType: "WhereGo-Overrides",
+		Networks: map[netip.Prefix]any{
+			netip.MustParsePrefix("10.0.0.0/8"): geoiptest.Map{"labels": geoiptest.Map{"a": "b"}},
+		},
+	})
+	path := filepath.Join(t.TempDir(), "overrides.mmdb")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	o, err := LoadOverrides(path)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1000 {
+				_, _, ok := o.Lookup(netip.MustParseAddr("10.1.2.3"))
+				assert.True(t, ok)
+			}
+		}()
+	}
+	for range 20 {
+		require.NoError(t, o.Reload())
+	}
+	wg.Wait()
+}
+
+func TestPrefixTrie(t *testing.T) {
+	var trie prefixTrie
+	entries := []override{
+		{network: netip.MustParsePrefix("0.0.0.0/0")},
+		{network: netip.MustParsePrefix("10.0.0.0/8")},
+		{network: netip.MustParsePrefix("10.1.0.0/16")},
+		{network: netip.MustParsePrefix("10.1.2.3/32")},
+		{network: netip.MustParsePrefix("2001:db8::/32")},
+		{network: netip.MustParsePrefix("2001:db8::1/128")},
+	}
+	for i := range entries {
+		trie.insert(&entries[i])
+	}
+
+	tests := []struct {
+		name string
+		ip   string
+		want string
+	}{
+		{"Host Route", "10.1.2.3", "10.1.2.3/32"},
+		{"Nested Network", "10.1.2.4", "10.1.0.0/16"},
+		{"Covering Network", "10.2.0.1", "10.0.0.0/8"},
+		{"Default Route", "192.0.2.1", "0.0.0.0/0"},
+		{"IPv6 Host Route", "2001:db8::1", "2001:db8::1/128"},
+		{"IPv6 Network", "2001:db8::2", "2001:db8::/32"},
+		{"No Match", "2001:db9::1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := trie.lookup(netip.MustParseAddr(tt.ip))
+			if tt.want == "" {
+				assert.Nil(t, entry)
+				return
+			}
+			require.NotNil(t, entry)
+			assert.Equal(t, tt.want, entry.network.String())
+		})
+	}
+}
+
+func TestLoadOverrides_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"Missing File", "", ""},
+		{"Invalid YAML", "o.yaml", "overrides: ["},
+		{"Missing Network", "o.yaml", "overrides: [{labels: {a: b}}]"},
+		{"Invalid Network", "o.yaml", "overrides: [{network: 10.0.0.0/33}]"},
+		{"Unknown Field", "o.yaml", "overrides: [{network: 10.0.0.0/8, contry: {iso_code: US}}]"},
+		{"Wrong Type", "o.yaml", "overrides: [{network: 10.0.0.0/8, country: US}]"},
+		{"Duplicate Network", "o.yaml", "overrides: [{network: 10.0.0.0/8}, {network: 10.1.0.0/8}]"},
+		{"Invalid MMDB", "o.mmdb", "not a database"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "missing.yaml")
+			if tt.file != "" {
+				path = writeOverrides(t, tt.file, tt.content)
+			}
+			o, err := LoadOverrides(path)
+			assert.Error(t, err)
+			assert.Nil(t, o)
+		})
+	}
+}
+
+func TestOverrides_Reload(t *testing.T) {
+	path := writeOverrides(t, "overrides.yaml", sampleOverrides)
+	o, err := LoadOverrides(path)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte("overrides: ["), 0o600))
+	assert.Error(t, o.Reload())
+	assert.Equal(t, 3, o.Len(), "a broken file should keep the current overrides")
+
+	require.NoError(t, os.WriteFile(path, []byte("overrides: [{network: 172.16.0.0/12}]"), 0o600))
+	require.NoError(t, o.Reload())
+	assert.Equal(t, 1, o.Len())
+}
+
+func TestOverrides_Watch(t *testing.T) {
+	path := writeOverrides(t, "overrides.yaml", sampleOverrides)
+	o, err := LoadOverrides(path)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		o.Watch(ctx, time.Millisecond, func(err error) { t.Error(err) })
+	}()
+
+	require.NoError(t, os.WriteFile(path, []byte("overrides: [{network: 172.16.0.0/12}]"), 0o600))
+	assert.Eventually(t, func() bool { return o.Len() == 1 }, 5*time.Second, time.Millisecond)
+
+	cancel()
+	wg.Wait()
+}
+
+func TestService_Overrides(t *testing.T) {
+	db := openSampleCity(t)
+	o, err := LoadOverrides(writeOverrides(t, "overrides.yaml", sampleOverrides))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		ip      string
+		source  string
+		network string
+	}{
+		{"Override Only", "10.20.30.1", SourceOverride, "10.20.30.0/24"},
+		{"Merged With Database", "81.2.69.160", SourceOverrideDatabase, "81.2.69.160/32"},
+		{"Database Only", "8.8.8.8", SourceDatabase, "8.8.8.0/24"},
+		{"Not Found", "127.0.0.1", "", ""},
+	}
+	for _, cache := range []*Cache{nil, NewCache(100)} {
+		svc := &Service{DB: db, Cache: cache, Overrides: o}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				city, err := svc.LookupIP(tt.ip)
+				if tt.source == "" {
+					assert.ErrorIs(t, err, ErrNotFound)
+					_, err = svc.LookupIPJSON(tt.ip)
+					assert.ErrorIs(t, err, ErrNotFound)
+					return
+				}
+				require.NoError(t, err)
+				assert.Equal(t, tt.source, city.Source)
+				if tt.network != "" {
+					assert.Equal(t, tt.network, city.Traits.Network.String())
+				}
+
+				want, err := json.Marshal(city)
+				require.NoError(t, err)
+				got, err := svc.LookupIPJSON(tt.ip)
+				require.NoError(t, err)
+				assert.Equal(t, string(want), string(got))
+			})
+		}
+	}
+
+	svc := &Service{DB: db, Overrides: o}
+	city, err := svc.LookupIP("81.2.69.160")
+	require.NoError(t, err)
+	assert.Equal(t, "GB", city.Country.ISOCode, "database fields are kept")
+	assert.Equal(t, "London", city.City.Names.English)
+	assert.Equal(t, "EC1A", city.Postal.Code, "override fields win")
+	assert.Equal(t, map[string]string{"office": "london"}, city.Labels)
+
+	city, err = svc.LookupIP("10.20.30.1")
+	require.NoError(t, err)
+	assert.Empty(t, city.Country.ISOCode, "covering networks are not merged")
+	assert.Equal(t, "Offenbach", city.City.Names.English)
+	assert.Nil(t, city.Location.Latitude)
+}
+
+func TestService_OverridesZeroValues(t *testing.T) {
+	o, err := LoadOverrides(writeOverrides(t, "overrides.yaml", `
+overrides:
+  - network: 81.2.69.160
+    registered_country: {is_in_european_union: false}
+    postal: {code: ""}
+`))
+	require.NoError(t, err)
+	svc := &Service{DB: openSampleCity(t), Overrides: o}
+
+	city, err := svc.LookupIP("81.2.69.160")
+	require.NoError(t, err)
+	assert.Equal(t, SourceOverrideDatabase, city.Source)
+	// Zero values are not merged, so overrides cannot clear fields.
+	assert.True(t, city.RegisteredCountry.IsInEuropeanUnion)
+	assert.Equal(t, "EC2V", city.Postal.Code)
+}
+
+func TestService_LookupEnterprise(t *testing.T) {
+	db, err := OpenBytes(geoiptest.MustBuild(geoiptest.Database{
+		DatabaseType: "GeoIP2-Enterprise",
+		Networks: map[netip.Prefix]any{
+			netip.MustParsePrefix("81.2.69.0/24"): geoiptest.Map{
+				"country":      geoiptest.Map{"iso_code": "GB", "confidence": uint16(99)},
+				"subdivisions": []any{geoiptest.Map{"iso_code": "ENG", "confidence": uint16(80)}},
+				"traits":       geoiptest.Map{"isp": "Andrews & Arnold"},
+			},
+		},
+	}))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	o, err := LoadOverrides(writeOverrides(t, "overrides.yaml", sampleOverrides+`
+  - network: 81.2.69.192/28
+    country: {iso_code: IE}
+    subdivisions: [{iso_code: D}]
+`))
+	require.NoError(t, err)
+	svc := &Service{DB: db, Overrides: o}
+
+	enterprise, err := svc.LookupEnterprise(netip.MustParseAddr("81.2.69.160"))
+	require.NoError(t, err)
+	assert.Equal(t, "GB", enterprise.Country.ISOCode, "database fields are kept")
+	assert.Equal(t, uint8(99), enterprise.Country.Confidence)
+	assert.Equal(t, "EC1A", enterprise.Postal.Code, "override fields win")
+	assert.Equal(t, "81.2.69.160/32", enterprise.Traits.Network.String())
+	assert.Equal(t, "Andrews & Arnold", enterprise.Traits.ISP)
+
+	enterprise, err = svc.LookupEnterprise(netip.MustParseAddr("81.2.69.200"))
+	require.NoError(t, err)
+	assert.Equal(t, "IE", enterprise.Country.ISOCode)
+	assert.Equal(t, []EnterpriseSubdivision{{ISOCode: "D"}}, enterprise.Subdivisions, "slices are replaced")
+	assert.Equal(t, netip.MustParseAddr("81.2.69.200"), enterprise.Traits.IPAddress)
+
+	enterprise, err = svc.LookupEnterprise(netip.MustParseAddr("10.20.30.1"))
+	require.NoError(t, err, "overrides answer for addresses the database has no data for")
+	assert.Equal(t, "Offenbach", enterprise.City.Names.English)
+
+	_, err = svc.LookupEnterprise(netip.MustParseAddr("127.0.0.1"))
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = (&Service{DB: openSampleCity(t)}).LookupEnterprise(netip.MustParseAddr("81.2.69.160"))
+	var invalidMethod InvalidMethodError
+	assert.ErrorAs(t, err, &invalidMethod)
+}
+
+func TestMergeCity(t *testing.T) {
+	lat := 1.5
+	labels := map[string]string{"a": "1"}
+	dst := &City{
+		Country:      CountryRecord{ISOCode: "US", GeoNameID: 1},
+		Subdivisions: []CitySubdivision{{ISOCode: "CA"}},
+		Labels:       labels,
+	}
+	src := &City{
+		Country:      CountryRecord{ISOCode: "CA"},
+		Subdivisions: []CitySubdivision{{ISOCode: "ON"}, {ISOCode: "TOR"}},
+		Location:     Location{Latitude: &lat},
+		Labels:       map[string]string{"b": "2"},
+	}
+
+	mergeCity(dst, src)
+	assert.Equal(t, CountryRecord{ISOCode: "CA", GeoNameID: 1}, dst.Country)
+	assert.Equal(t, src.Subdivisions, dst.Subdivisions)
+	assert.Equal(t, map[string]string{"a": "1", "b": "2"}, dst.Labels)
+	assert.Equal(t, map[string]string{"a": "1"}, labels, "maps are copied, not modified")
+	require.NotNil(t, dst.Location.Latitude)
+	assert.NotSame(t, src.Location.Latitude, dst.Location.Latitude)
+	assert.Equal(t, 1.5, *dst.Location.Latitude)
+}
@@ -1,6 +1,7 @@
-package geoip
+package wherego
 
 import (
+	"errors"
 	"fmt"
 	"net/netip"
 
@@ -31,17 +32,28 @@ type Reader struct {
 type Option func(*readerOptions)
 
 type readerOptions struct {
-	maxminddb []maxminddb.ReaderOption
+	maxminddb    []maxminddb.ReaderOption
+	allowUnknown bool
 }
 
-func applyOptions(options []Option) []maxminddb.ReaderOption {
+// AllowUnknownDatabaseType makes Open and OpenBytes accept databases whose
+// type is not one of the GeoIP2/GeoLite2 types known to this package. The
+// typed lookup methods return InvalidMethodError for such databases; use
+// the generic Lookup function to decode their records instead.
+func AllowUnknownDatabaseType() Option {
+	return func(o *readerOptions) {
+		o.allowUnknown = true
+	}
+}
+
+func applyOptions(options []Option) readerOptions {
 	var opts readerOptions
 	for _, option := range options {
 		if option != nil {
 			option(&opts)
 		}
 	}
-	return opts.maxminddb
+	return opts
 }
 
 // InvalidMethodError is returned when a lookup method is called on a
@@ -72,12 +84,12 @@ func (e UnknownDatabaseTypeError) Error() string {
 // The database file is opened using a memory map. Use the Close method on the
 // Reader object to return the resources to the system.
 func Open(file string, options ...Option) (*Reader, error) {
-	reader, err := maxminddb.Open(file, applyOptions(options)...)
+	opts := applyOptions(options)
+	reader, err := maxminddb.Open(file, opts.maxminddb...)
 	if err != nil {
 		return nil, err
 	}
-	dbType, err := getDBType(reader)
-	return &Reader{reader, dbType}, err
+	return newReader(reader, opts)
 }
 
 // OpenBytes takes a byte slice corresponding to a GeoIP2/GeoLite2 database
@@ -85,11 +97,20 @@ func Open(file string, options ...Option) (*Reader, error) {
 // used directly; any modification of it after opening the database will result
 // in errors while reading from the database.
 func OpenBytes(bytes []byte, options ...Option) (*Reader, error) {
-	reader, err := maxminddb.OpenBytes(bytes, applyOptions(options)...)
+	opts := applyOptions(options)
+	reader, err := maxminddb.OpenBytes(bytes, opts.maxminddb...)
 	if err != nil {
 		return nil, err
 	}
+	return newReader(reader, opts)
+}
+
+func newReader(reader *maxminddb.Reader, opts readerOptions) (*Reader, error) {
 	dbType, err := getDBType(reader)
+	var unknown UnknownDatabaseTypeError
+	if opts.allowUnknown && errors.As(err, &unknown) {
+		err = nil
+	}
 	return &Reader{reader, dbType}, err
 }
 
@@ -273,6 +294,27 @@ func (r *Reader) ISP(ipAddress netip.Addr) (*ISP, error) {
 	return &val, nil
 }
 
+// Lookup takes an IP address as a netip.Addr and decodes its record into a
+// value of type T, returning it together with the network the record
+// belongs to. Unlike the Reader methods it works with any database type,
+// including custom databases opened with AllowUnknownDatabaseType. If the
+// IP address is not in the database, the zero value of T is returned
+// without an error.
+func Lookup[T any](r *Reader, ipAddress netip.Addr) (T, netip.Prefix, error) {
+	var val T
+	result := r.mmdbReader.Lookup(ipAddress)
+	if err := result.Decode(&val); err != nil {
+		return val, netip.Prefix{}, err
+	}
+	return val, result.Prefix(), nil
+}
+
+// KnownDatabaseType returns true if the database is one of the
+// GeoIP2/GeoLite2 types supported by the typed lookup methods.
+func (r *Reader) KnownDatabaseType() bool {
+	return r.databaseType != 0
+}
+
 // Metadata takes no arguments and returns a struct containing metadata about
 // the MaxMind database in use by the Reader.
 func (r *Reader) Metadata() maxminddb.Metadata {
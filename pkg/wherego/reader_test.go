@@ -1,10 +1,11 @@
-package geoip
+package wherego
 
 import (
 	"net/netip"
 	"os"
 	"testing"
 
+	"github.com/gustavosett/WhereGo/internal/geoiptest"
 	"github.com/oschwald/maxminddb-golang/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -86,6 +87,15 @@ func TestModels_HasData(t *testing.T) {
 	})
 }
 
+func TestNames_Localized(t *testing.T) {
+	names := Names{English: "Germany", German: "Deutschland", BrazilianPortuguese: "Alemanha"}
+	assert.Equal(t, "Deutschland", names.Localized("de"))
+	assert.Equal(t, "Alemanha", names.Localized("pt-BR"))
+	assert.Equal(t, "Germany", names.Localized("en"))
+	assert.Equal(t, "Germany", names.Localized("ja"), "missing names fall back to English")
+	assert.Equal(t, "Germany", names.Localized("xx"))
+}
+
 func TestGetDBType(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -161,8 +171,12 @@ func TestApplyOptions(t *testing.T) {
 	var called bool
 	opt := func(o *readerOptions) { called = true }
 	opts := applyOptions([]Option{opt, nil})
-	assert.IsType(t, []maxminddb.ReaderOption{}, opts)
+	assert.IsType(t, readerOptions{}, opts)
 	assert.True(t, called)
+	assert.False(t, opts.allowUnknown)
+
+	opts = applyOptions([]Option{AllowUnknownDatabaseType()})
+	assert.True(t, opts.allowUnknown)
 }
 
 func setupIntegration(t *testing.T) string {
@@ -315,3 +329,54 @@ func TestReader_DecodeErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestLookup_CustomDatabase(t *testing.T) {
+	type office struct {
+		Site  string `maxminddb:"site"`
+		Floor uint   `maxminddb:"floor"`
+	}
+
+	dbBytes := geoiptest.MustBuild(geoiptest.Database{
+		DatabaseType: "Acme-Offices",
+		Networks: map[netip.Prefix]any{
+			netip.MustParsePrefix("10.20.0.0/16"): geoiptest.Map{"site": "Lisbon", "floor": 3},
+		},
+	})
+
+	t.Run("Rejected By Default", func(t *testing.T) {
+		_, err := OpenBytes(dbBytes)
+		assert.IsType(t, UnknownDatabaseTypeError{}, err)
+	})
+
+	r, err := OpenBytes(dbBytes, AllowUnknownDatabaseType())
+	require.NoError(t, err)
+	defer func() {
+		closeErr := r.Close()
+		require.NoError(t, closeErr)
+	}()
+	assert.False(t, r.KnownDatabaseType())
+
+	t.Run("Typed Methods Unsupported", func(t *testing.T) {
+		_, err := r.City(netip.MustParseAddr("10.20.1.1"))
+		assert.IsType(t, InvalidMethodError{}, err)
+	})
+
+	t.Run("Struct", func(t *testing.T) {
+		got, prefix, err := Lookup[office](r, netip.MustParseAddr("10.20.1.1"))
+		require.NoError(t, err)
+		assert.Equal(t, office{Site: "Lisbon", Floor: 3}, got)
+		assert.Equal(t, netip.MustParsePrefix("10.20.0.0/16"), prefix)
+	})
+
+	t.Run("Map", func(t *testing.T) {
+		got, _, err := Lookup[map[string]any](r, netip.MustParseAddr("10.20.1.1"))
+		require.NoError(t, err)
+		assert.Equal(t, "Lisbon", got["site"])
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		got, _, err := Lookup[*office](r, netip.MustParseAddr("192.0.2.1"))
+		require.NoError(t, err)
+		assert.Nil(t, got)
+	})
+}
@@ -0,0 +1,303 @@
+package wherego
+
+import (
+	"errors"
+	"math"
+	"net/netip"
+	"sync"
+)
+
+var (
+	ErrInvalidIP = errors.New("invalid IP address")
+	// ErrNotFound is returned when the database has no data for a valid
+	// IP address.
+	ErrNotFound = errors.New("no data found for the IP address")
+)
+
+type Service struct {
+	DB *Reader
+	// Cache optionally caches City results by network. A nil Cache
+	// disables caching.
+	Cache *Cache
+	// Overrides optionally replaces City results for selected networks and
+	// marks every result with its Source. A nil Overrides disables them.
+	Overrides *Overrides
+	// LookupEmbeddedIPv4 makes lookups for 6to4 and Teredo addresses use
+	// the record of the IPv4 address embedded in them.
+	LookupEmbeddedIPv4 bool
+	// ASNDB optionally answers LookupASN from a GeoLite2-ASN or GeoIP2-ISP
+	// database opened next to DB. When it is nil, LookupASN uses DB.
+	ASNDB *Reader
+	// AnonymousIPDB optionally answers LookupAnonymousIP from a GeoIP2
+	// Anonymous IP database opened next to DB. When it is nil,
+	// LookupAnonymousIP uses DB.
+	AnonymousIPDB *Reader
+}
+
+func NewService(dbPath string, options ...Option) (*Service, error) {
+	db, err := Open(dbPath, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &Service{DB: db}, nil
+}
+
+// LookupIP returns the City record for ipStr, or ErrNotFound if there is
+// no data for it. Results served from the
+// cache share their Subdivisions slice and Labels with the cache and must
+// not be modified.
+func (s *Service) LookupIP(ipStr string) (*City, error) {
+	addr, err := netip.ParseAddr(ipStr)
+	if err != nil {
+		return nil, ErrInvalidIP
+	}
+
+	target := s.lookupTarget(addr)
+	city, err := s.lookupCity(target)
+	if err != nil {
+		return nil, err
+	}
+	override, network := s.lookupOverride(addr)
+	s.annotate(city, addr, target, override, network)
+	if !city.HasData() {
+		return nil, ErrNotFound
+	}
+	return city, nil
+}
+
+// lookupTarget returns the address whose record answers a lookup for addr.
+func (s *Service) lookupTarget(addr netip.Addr) netip.Addr {
+	if s.LookupEmbeddedIPv4 {
+		if v4, ok := EmbeddedIPv4(addr); ok {
+			return v4
+		}
+	}
+	return addr
+}
+
+// lookupOverride returns the override for addr and its network, or nil if
+// there is none.
+func (s *Service) lookupOverride(addr netip.Addr) (*City, netip.Prefix) {
+	if s.Overrides == nil {
+		return nil, netip.Prefix{}
+	}
+	override, network, _ := s.Overrides.Lookup(addr)
+	return override, network
+}
+
+// annotate sets the fields that depend on the queried address rather than
+// on the database record, which was looked up for target, and applies the
+// override for addr returned by lookupOverride.
+func (s *Service) annotate(city *City, addr, target netip.Addr, override *City, network netip.Prefix) {
+	city.Traits.IPAddress = addr
+	city.Traits.AddressClass = ClassifyAddress(addr)
+	if target != addr {
+		city.Traits.EmbeddedIPv4 = target.String()
+	}
+	if s.Overrides != nil {
+		applyOverride(city, override, network)
+	}
+}
+
+func (s *Service) lookupCity(addr netip.Addr) (*City, error) {
+	if s.Cache == nil {
+		return s.DB.City(addr)
+	}
+	entry, err := s.cachedCity(addr)
+	if err != nil {
+		return nil, err
+	}
+	city := entry.city
+	city.Traits.IPAddress = addr
+	return &city, nil
+}
+
+// LookupIPJSON returns the City record for ipStr serialized as JSON.
+func (s *Service) LookupIPJSON(ipStr string) ([]byte, error) {
+	return s.AppendIPJSON(nil, ipStr)
+}
+
+// AppendIPJSON appends the City record for ipStr serialized as JSON to dst.
+// Like LookupIP, it returns ErrNotFound if there is no data for ipStr.
+// This is the allocation-free lookup path: cache hits copy pre-serialized
+// bytes, and misses decode into a pooled City and encode it without
+// reflection. With a dst of sufficient capacity a cache hit does not
+// allocate; a miss allocates only inside the MaxMind DB decoder.
+func (s *Service) AppendIPJSON(dst []byte, ipStr string) ([]byte, error) {
+	addr, err := netip.ParseAddr(ipStr)
+	if err != nil {
+		return dst, ErrInvalidIP
+	}
+
+	override, network := s.lookupOverride(addr)
+	if s.Cache != nil && cacheable(addr, override) {
+		entry, err := s.cachedCity(addr)
+		if err != nil {
+			return dst, err
+		}
+		if !entry.hasData {
+			return dst, ErrNotFound
+		}
+		dst = entry.appendJSON(dst, addr)
+		if s.Overrides != nil {
+			dst = appendJSONSource(dst, SourceDatabase)
+		}
+		return dst, nil
+	}
+
+	target := s.lookupTarget(addr)
+	buf := cityPool.Get().(*cityBuffer)
+	defer cityPool.Put(buf)
+	if err := buf.decode(s.DB, target); err != nil {
+		return dst, err
+	}
+	s.annotate(&buf.city, addr, target, override, network)
+	if !buf.city.HasData() {
+		return dst, ErrNotFound
+	}
+	return buf.city.AppendJSON(dst), nil
+}
+
+// cacheable reports whether the cached JSON for the network of addr can be
+// served as is. Cached JSON is shared by the whole network, so it cannot
+// carry an address class or an override for part of it.
+func cacheable(addr netip.Addr, override *City) bool {
+	return ClassifyAddress(addr) == "" && override == nil
+}
+
+func (s *Service) cachedCity(addr netip.Addr) (*cacheEntry, error) {
+	if isCity&s.DB.databaseType == 0 {
+		return nil, InvalidMethodError{"City", s.DB.Metadata().DatabaseType}
+	}
+	// Walking the search tree is cheap; decoding the record is not. Find
+	// the record's network first and only decode on a miss.
+	result := s.DB.mmdbReader.Lookup(addr)
+	if err := result.Err(); err != nil {
+		return nil, err
+	}
+	prefix := result.Prefix()
+	if entry, ok := s.Cache.get(s.DB, prefix); ok {
+		return entry, nil
+	}
+
+	city, err := s.DB.City(addr)
+	if err != nil {
+		return nil, err
+	}
+	entry := newCacheEntry(s.DB, prefix, city)
+	s.Cache.add(entry)
+	return entry, nil
+}
+
+// LookupEnterprise returns the Enterprise record of addr from DB, with the
+// override for addr merged over it as LookupIP does. It returns
+// ErrNotFound if there is no data for addr, and an InvalidMethodError if
+// DB is not an Enterprise database. Enterprise records are not cached.
+func (s *Service) LookupEnterprise(addr netip.Addr) (*Enterprise, error) {
+	enterprise, err := s.DB.Enterprise(s.lookupTarget(addr))
+	if err != nil {
+		return nil, err
+	}
+	enterprise.Traits.IPAddress = addr
+	if override, network := s.lookupOverride(addr); override != nil {
+		applyEnterpriseOverride(enterprise, override, network)
+	}
+	if !enterprise.HasData() {
+		return nil, ErrNotFound
+	}
+	return enterprise, nil
+}
+
+// LookupASN returns the autonomous system of addr from ASNDB, or from DB
+// when there is no ASNDB. It returns ErrNotFound if there is no data for
+// addr, and an InvalidMethodError if the database has no ASN data.
+func (s *Service) LookupASN(addr netip.Addr) (*ASN, error) {
+	db := s.ASNDB
+	if db == nil {
+		db = s.DB
+	}
+	asn, err := db.ASN(s.lookupTarget(addr))
+	if err != nil {
+		return nil, err
+	}
+	if !asn.HasData() {
+		return nil, ErrNotFound
+	}
+	asn.IPAddress = addr
+	return asn, nil
+}
+
+// LookupAnonymousIP returns the anonymizer flags of addr from
+// AnonymousIPDB, or from DB when there is no AnonymousIPDB. It returns
+// ErrNotFound if no flag is set for addr, and an InvalidMethodError if the
+// database has no anonymizer data.
+func (s *Service) LookupAnonymousIP(addr netip.Addr) (*AnonymousIP, error) {
+	db := s.AnonymousIPDB
+	if db == nil {
+		db = s.DB
+	}
+	anonymous, err := db.AnonymousIP(s.lookupTarget(addr))
+	if err != nil {
+		return nil, err
+	}
+	if !anonymous.HasData() {
+		return nil, ErrNotFound
+	}
+	anonymous.IPAddress = addr
+	return anonymous, nil
+}
+
+// LookupRecord decodes the full record for ipStr into a generic map. It
+// works with any database type, which makes it the lookup to use for
+// custom databases opened with AllowUnknownDatabaseType. It returns
+// ErrNotFound if there is no record for ipStr.
+func (s *Service) LookupRecord(ipStr string) (map[string]any, error) {
+	addr, err := netip.ParseAddr(ipStr)
+	if err != nil {
+		return nil, ErrInvalidIP
+	}
+
+	record, _, err := Lookup[map[string]any](s.DB, addr)
+	if err != nil {
+		return nil, err
+	}
+	if len(record) == 0 {
+		return nil, ErrNotFound
+	}
+	return record, nil
+}
+
+var cityPool = sync.Pool{New: func() any { return new(cityBuffer) }}
+
+// cityBuffer is a reusable City together with storage for its coordinates.
+// The decoder fills non-nil pointers in place, so pointing Latitude and
+// Longitude at the buffer avoids allocating new float64 values per lookup.
+type cityBuffer struct {
+	city     City
+	lat, lon float64
+}
+
+// decode looks up addr and decodes its record into b.city, like
+// Reader.City does.
+func (b *cityBuffer) decode(r *Reader, addr netip.Addr) error {
+	if isCity&r.databaseType == 0 {
+		return InvalidMethodError{"City", r.Metadata().DatabaseType}
+	}
+	// NaN marks coordinates the record does not set.
+	b.lat, b.lon = math.NaN(), math.NaN()
+	b.city = City{Location: Location{Latitude: &b.lat, Longitude: &b.lon}}
+
+	result := r.mmdbReader.Lookup(addr)
+	if err := result.Decode(&b.city); err != nil {
+		return err
+	}
+	if math.IsNaN(b.lat) {
+		b.city.Location.Latitude = nil
+	}
+	if math.IsNaN(b.lon) {
+		b.city.Location.Longitude = nil
+	}
+	b.city.Traits.IPAddress = addr
+	b.city.Traits.Network = result.Prefix()
+	return nil
+}
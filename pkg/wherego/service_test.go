@@ -0,0 +1,194 @@
+package wherego
+
+import (
+	"net/netip"
+	"os"
+	"testing"
+
+	"github.com/gustavosett/WhereGo/internal/geoiptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewService(t *testing.T) {
+	t.Run("Invalid Path", func(t *testing.T) {
+		svc, err := NewService("nonexistent/path/to/database.mmdb")
+		assert.Error(t, err)
+		assert.Nil(t, svc)
+	})
+
+	t.Run("Success Integration", func(t *testing.T) {
+		dbPath := "../../data/city.db"
+		if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+			t.Skipf("Skipping integration test: database not found at %s", dbPath)
+		}
+
+		svc, err := NewService(dbPath)
+		require.NoError(t, err)
+		assert.NotNil(t, svc)
+		assert.NotNil(t, svc.DB)
+
+		// Cleanup
+		svcErr := svc.DB.Close()
+		require.NoError(t, svcErr)
+	})
+}
+
+func TestLookupIP_Validation(t *testing.T) {
+	svc := &Service{DB: nil}
+
+	tests := []struct {
+		name  string
+		ipStr string
+	}{
+		{"Alphabetical", "invalid-ip"},
+		{"Empty", ""},
+		{"Partial IPv4", "192.168"},
+		{"IPv4 with Port", "8.8.8.8:80"},
+		{"IPv6 with Port", "[2001:db8::1]:80"},
+		{"Out of Range", "300.300.300.300"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			city, err := svc.LookupIP(tt.ipStr)
+
+			assert.ErrorIs(t, err, ErrInvalidIP, "Should return sentinel error for invalid IP")
+			assert.Nil(t, city)
+		})
+	}
+}
+
+func TestLookupIP_Integration(t *testing.T) {
+	dbPath := "../../data/city.db"
+	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+		t.Skipf("Skipping integration test: database not found at %s", dbPath)
+	}
+
+	svc, err := NewService(dbPath)
+	require.NoError(t, err)
+	defer func() {
+		closeErr := svc.DB.Close()
+		require.NoError(t, closeErr)
+	}()
+
+	tests := []struct {
+		name        string
+		ipStr       string
+		expectFound bool
+		expectedISO string
+	}{
+		{
+			name:        "Valid Google DNS (US)",
+			ipStr:       "8.8.8.8",
+			expectFound: true,
+			expectedISO: "US", // Usually resolves to US
+		},
+		{
+			name:        "Valid Cloudflare DNS (IPv6)",
+			ipStr:       "2606:4700:4700::1111",
+			expectFound: true,
+		},
+		{
+			name:        "Valid Localhost (No Data)",
+			ipStr:       "127.0.0.1",
+			expectFound: false, // Localhost isn't in GeoIP DBs
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			city, err := svc.LookupIP(tt.ipStr)
+			if !tt.expectFound {
+				assert.ErrorIs(t, err, ErrNotFound, "Expected no data for IP %s", tt.ipStr)
+				assert.Nil(t, city)
+				return
+			}
+
+			require.NoError(t, err, "Lookup should not return error for valid IP format")
+			require.NotNil(t, city)
+			assert.True(t, city.HasData(), "Expected data for IP %s", tt.ipStr)
+			if tt.expectedISO != "" {
+				assert.Equal(t, tt.expectedISO, city.Country.ISOCode)
+			}
+		})
+	}
+}
+
+func TestLookupRecord(t *testing.T) {
+	dbBytes := geoiptest.MustBuild(geoiptest.Database{
+		DatabaseType: "Acme-Offices",
+		Networks: map[netip.Prefix]any{
+			netip.MustParsePrefix("10.20.0.0/16"): geoiptest.Map{"site": "Lisbon"},
+		},
+	})
+	db, err := OpenBytes(dbBytes, AllowUnknownDatabaseType())
+	require.NoError(t, err)
+	svc := &Service{DB: db}
+
+	record, err := svc.LookupRecord("10.20.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"site": "Lisbon"}, record)
+
+	_, err = svc.LookupRecord("10.21.0.1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = svc.LookupRecord("not-an-ip")
+	assert.ErrorIs(t, err, ErrInvalidIP)
+}
+
+func TestLookupASN(t *testing.T) {
+	city, err := OpenBytes(geoiptest.MustBuild(geoiptest.SampleCity()))
+	require.NoError(t, err)
+	asnDB, err := OpenBytes(geoiptest.MustBuild(geoiptest.SampleASN()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = city.Close()
+		_ = asnDB.Close()
+	})
+
+	svc := &Service{DB: city, ASNDB: asnDB}
+	asn, err := svc.LookupASN(netip.MustParseAddr("8.8.8.8"))
+	require.NoError(t, err)
+	assert.Equal(t, uint(15169), asn.AutonomousSystemNumber)
+	assert.Equal(t, "GOOGLE", asn.AutonomousSystemOrganization)
+	assert.Equal(t, netip.MustParsePrefix("8.8.8.0/24"), asn.Network)
+
+	_, err = svc.LookupASN(netip.MustParseAddr("127.0.0.1"))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	svc = &Service{DB: city, ASNDB: asnDB, LookupEmbeddedIPv4: true}
+	asn, err = svc.LookupASN(netip.MustParseAddr("2002:808:808::1"))
+	require.NoError(t, err)
+	assert.Equal(t, uint(15169), asn.AutonomousSystemNumber, "the embedded IPv4 address is looked up")
+	assert.Equal(t, netip.MustParseAddr("2002:808:808::1"), asn.IPAddress)
+
+	_, err = (&Service{DB: city}).LookupASN(netip.MustParseAddr("8.8.8.8"))
+	var invalidMethod InvalidMethodError
+	assert.ErrorAs(t, err, &invalidMethod, "without ASNDB, DB answers")
+}
+
+func TestLookupAnonymousIP(t *testing.T) {
+	city, err := OpenBytes(geoiptest.MustBuild(geoiptest.SampleCity()))
+	require.NoError(t, err)
+	anonymousDB, err := OpenBytes(geoiptest.MustBuild(geoiptest.SampleAnonymousIP()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = city.Close()
+		_ = anonymousDB.Close()
+	})
+
+	svc := &Service{DB: city, AnonymousIPDB: anonymousDB}
+	anonymous, err := svc.LookupAnonymousIP(netip.MustParseAddr("185.220.101.7"))
+	require.NoError(t, err)
+	assert.True(t, anonymous.IsTorExitNode)
+	assert.False(t, anonymous.IsHostingProvider)
+	assert.Equal(t, netip.MustParseAddr("185.220.101.7"), anonymous.IPAddress)
+
+	_, err = svc.LookupAnonymousIP(netip.MustParseAddr("81.2.69.160"))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = (&Service{DB: city}).LookupAnonymousIP(netip.MustParseAddr("8.8.8.8"))
+	var invalidMethod InvalidMethodError
+	assert.ErrorAs(t, err, &invalidMethod, "without AnonymousIPDB, DB answers")
+}
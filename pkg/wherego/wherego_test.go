@@ -0,0 +1,83 @@
+package wherego_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/gustavosett/WhereGo/internal/geoiptest"
+	"github.com/gustavosett/WhereGo/pkg/wherego"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openSample(t *testing.T) *wherego.Service {
+	t.Helper()
+	db, err := wherego.OpenBytes(geoiptest.MustBuild(geoiptest.SampleCity()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return &wherego.Service{DB: db, Cache: wherego.NewCache(16)}
+}
+
+func TestService(t *testing.T) {
+	svc := openSample(t)
+
+	tests := []struct {
+		name        string
+		ip          string
+		wantCountry string
+		wantErr     error
+	}{
+		{name: "City", ip: "81.2.69.160", wantCountry: "GB"},
+		{name: "IPv6", ip: "2606:4700::1111", wantCountry: "US"},
+		{name: "Not Found", ip: "127.0.0.1", wantErr: wherego.ErrNotFound},
+		{name: "Invalid IP", ip: "bogus", wantErr: wherego.ErrInvalidIP},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			city, err := svc.LookupIP(tt.ip)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCountry, city.Country.ISOCode)
+		})
+	}
+
+	t.Run("Reader Methods", func(t *testing.T) {
+		_, err := svc.DB.ASN(netip.MustParseAddr("81.2.69.160"))
+		var invalidMethod wherego.InvalidMethodError
+		assert.ErrorAs(t, err, &invalidMethod)
+
+		record, network, err := wherego.Lookup[map[string]any](svc.DB, netip.MustParseAddr("8.8.8.8"))
+		require.NoError(t, err)
+		assert.Equal(t, geoiptest.USNetwork, network)
+		assert.Contains(t, record, "country")
+	})
+
+	t.Run("Address Classes", func(t *testing.T) {
+		assert.Equal(t, wherego.AddressClassLoopback, wherego.ClassifyAddress(netip.MustParseAddr("127.0.0.1")))
+		assert.Empty(t, wherego.ClassifyAddress(netip.MustParseAddr("8.8.8.8")))
+	})
+}
+
+func TestOpen_UnknownDatabaseType(t *testing.T) {
+	custom := geoiptest.Database{
+		DatabaseType: "Custom-Routing",
+		Networks:     map[netip.Prefix]any{geoiptest.USNetwork: geoiptest.Map{"pop": "ord"}},
+	}
+
+	_, err := wherego.OpenBytes(geoiptest.MustBuild(custom))
+	var unknown wherego.UnknownDatabaseTypeError
+	require.ErrorAs(t, err, &unknown)
+	assert.Equal(t, "Custom-Routing", unknown.DatabaseType)
+
+	db, err := wherego.OpenBytes(geoiptest.MustBuild(custom), wherego.AllowUnknownDatabaseType())
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	record, _, err := wherego.Lookup[struct {
+		Pop string `maxminddb:"pop"`
+	}](db, netip.MustParseAddr("8.8.8.8"))
+	require.NoError(t, err)
+	assert.Equal(t, "ord", record.Pop)
+}